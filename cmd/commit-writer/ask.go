@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// askTTY asks the model's clarifying questions on the terminal, which
+// works even when stdin and stdout are taken, as in a git hook.
+func askTTY(questions []string) ([]string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("no terminal to ask on: %w", err)
+	}
+	defer tty.Close()
+	return ask(bufio.NewReader(tty), tty, questions)
+}
+
+// ask writes each question to w and reads its answer, one line, from r. An
+// empty line skips a question; end of input skips the rest.
+func ask(r *bufio.Reader, w io.Writer, questions []string) ([]string, error) {
+	fmt.Fprintln(w, "The model has questions about this change (press Enter to skip one):")
+	answers := make([]string, len(questions))
+	for i, q := range questions {
+		fmt.Fprintf(w, "%d. %s\n> ", i+1, q)
+		line, err := r.ReadString('\n')
+		answers[i] = strings.TrimSpace(line)
+		if err == io.EOF {
+			fmt.Fprintln(w)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return answers, nil
+}
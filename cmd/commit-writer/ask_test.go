@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAsk(t *testing.T) {
+	var out strings.Builder
+	questions := []string{"Is this a bug fix?", "Which bug?", "Anything else?"}
+	answers, err := ask(bufio.NewReader(strings.NewReader("yes\n\nthe login loop")), &out, questions)
+	if err != nil || !reflect.DeepEqual(answers, []string{"yes", "", "the login loop"}) {
+		t.Errorf("answers = %q, %v", answers, err)
+	}
+	if !strings.Contains(out.String(), "1. Is this a bug fix?\n> ") || !strings.Contains(out.String(), "3. Anything else?\n> ") {
+		t.Errorf("output:\n%s", out.String())
+	}
+
+	// End of input skips the remaining questions.
+	answers, err = ask(bufio.NewReader(strings.NewReader("")), &out, questions)
+	if err != nil || !reflect.DeepEqual(answers, []string{"", "", ""}) {
+		t.Errorf("answers at EOF = %q, %v", answers, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+)
+
+// alternatives styles res's summary n more times for -n and returns the
+// finished messages that differ from msg and from each other. The summary
+// is reused, so only the stages after it run again, and without the diff
+// the factuality check is skipped. Failures only warn.
+func alternatives(cfg generator.Config, res *generator.Result, msg string, n int, finish func(context.Context, string) (string, error), statusf, warnf func(string, ...interface{})) []string {
+	if res.Offline || res.Summary == "" || res.Summary == res.Message {
+		statusf("Only one candidate: the message was written without the style model")
+		return nil
+	}
+	ctx := context.Background()
+	cfg.Summary, cfg.SaveSummary, cfg.Verify = res.Summary, "", false
+	seen := map[string]bool{strings.TrimSpace(msg): true}
+	var alts []string
+	for i := 0; i < n; i++ {
+		statusf("Generating candidate %d of %d", i+2, n+1)
+		alt, err := generator.New(cfg).Generate(ctx)
+		if err != nil {
+			warnf("candidate %d: %v", i+2, err)
+			continue
+		}
+		text, err := finish(ctx, alt.Message)
+		if err != nil {
+			warnf("candidate %d rejected: %v", i+2, err)
+			continue
+		}
+		if text = strings.TrimSpace(text); !seen[text] {
+			seen[text] = true
+			alts = append(alts, text)
+		}
+	}
+	return alts
+}
+
+// commentAlternatives formats alts for the hook file as blocks of lines
+// starting with comment, which the author can uncomment in the editor to
+// use one instead.
+func commentAlternatives(alts []string, comment string) string {
+	var b strings.Builder
+	for i, alt := range alts {
+		fmt.Fprintf(&b, "%s\n%s Alternative %d (uncomment it and delete the message above to use it):\n", comment, comment, i+1)
+		for _, line := range strings.Split(alt, "\n") {
+			if line == "" {
+				b.WriteString(comment + "\n")
+			} else {
+				b.WriteString(comment + " " + line + "\n")
+			}
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/kylegalloway/commit-writer/pkg/changelog"
+	"github.com/kylegalloway/commit-writer/pkg/forge"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// runChangelog writes release notes for the commits in revRange, by
+// default everything since the latest tag, and returns the exit code. The
+// release-notes format has the summarizer write prose per group; with
+// releaseTag it also becomes the body of that GitHub release, and the
+// range defaults to the commits since the tag before it, which local-only
+// mode refuses.
+func runChangelog(cfg generator.Config, revRange, format, releaseTag string, localOnly bool, statusf, warnf func(string, ...interface{})) int {
+	if releaseTag != "" && localOnly {
+		fmt.Fprintln(os.Stderr, "--release-tag: local-only: the release notes would be posted to GitHub")
+		return 9
+	}
+	if revRange == "" && releaseTag != "" {
+		revRange = releaseTag
+		if prev := gitdiff.TagBefore(releaseTag); prev != "" {
+			revRange = prev + ".." + releaseTag
+		}
+	}
+	if revRange == "" {
+		revRange = "HEAD"
+		if tag := gitdiff.LatestTag(); tag != "" {
+			revRange = tag + "..HEAD"
+		}
+	}
+	statusf("Collecting commits in %s", revRange)
+	commits, err := gitdiff.Log(revRange)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	entries := make([]changelog.Entry, len(commits))
+	for i, c := range commits {
+		entries[i] = changelog.Parse(c)
+	}
+
+	var notes bytes.Buffer
+	switch format {
+	case "", "markdown":
+		err = changelog.WriteMarkdown(os.Stdout, "unreleased", entries)
+	case "cliff":
+		err = changelog.WriteTOML(os.Stdout, entries)
+	case "json":
+		err = changelog.WriteJSON(os.Stdout, entries)
+	case "release-notes":
+		title := releaseTag
+		if title == "" {
+			title = "unreleased"
+		}
+		err = changelog.WriteReleaseNotes(&notes, title, entries, releaseProse(cfg, warnf))
+		if err == nil {
+			_, err = os.Stdout.Write(notes.Bytes())
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown changelog format %q (want markdown, cliff, json or release-notes)\n", format)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	statusf("Wrote %d commit(s)", len(entries))
+
+	if releaseTag != "" {
+		rel := &forge.Release{URL: os.Getenv("GITHUB_API_URL"), Token: os.Getenv("GITHUB_TOKEN"), Repo: os.Getenv("GITHUB_REPOSITORY")}
+		if rel.Token == "" {
+			rel.Token = os.Getenv("GH_TOKEN")
+		}
+		if rel.Repo == "" {
+			rel.Repo = forge.GitHubRepo()
+		}
+		if rel.Repo == "" {
+			fmt.Fprintln(os.Stderr, "the origin remote is not on GitHub; set GITHUB_REPOSITORY to owner/name")
+			return 13
+		}
+		statusf("Setting the notes of the %s release in %s", releaseTag, rel.Repo)
+		url, err := rel.SetBody(context.Background(), releaseTag, notes.String())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 13
+		}
+		statusf("Release notes set: %s", url)
+	}
+	return 0
+}
+
+// releaseProse returns the per-group prose writer for release notes. When
+// the model is unreachable or fails, groups keep their commit lists.
+func releaseProse(cfg generator.Config, warnf func(string, ...interface{})) func(changelog.Section) string {
+	ctx := context.Background()
+	g := generator.New(cfg)
+	checked, reachable := false, false
+	return func(s changelog.Section) string {
+		if !checked {
+			checked = true
+			if err := g.Check(ctx); err != nil {
+				warnf("%v; listing the commits instead", err)
+			} else {
+				reachable = true
+			}
+		}
+		if !reachable {
+			return ""
+		}
+		messages := make([]string, len(s.Entries))
+		for i, e := range s.Entries {
+			messages[i] = e.Header
+			if e.Body != "" {
+				messages[i] += "\n\n" + e.Body
+			}
+		}
+		out, err := g.ReleaseNotes(ctx, s.Name, messages)
+		if err != nil {
+			warnf("%s release notes: %v; listing the commits instead", s.Name, err)
+			return ""
+		}
+		return out
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+)
+
+func TestRunChangelogLocalOnly(t *testing.T) {
+	// The release is refused before any commits are read or notes written.
+	t.Setenv("GITHUB_TOKEN", "token")
+	nop := func(string, ...interface{}) {}
+	if code := runChangelog(generator.Config{}, "v1.0.0..v1.1.0", "release-notes", "v1.1.0", true, nop, nop); code != 9 {
+		t.Errorf("runChangelog under local-only = %d, want 9", code)
+	}
+}
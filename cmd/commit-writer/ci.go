@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/ci"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/lint"
+	"github.com/kylegalloway/commit-writer/pkg/plugin"
+)
+
+// ciOptions are the flags of the ci subcommand.
+type ciOptions struct {
+	Squash bool   // suggest a squash message instead of checking the commits
+	JUnit  string // path to write a JUnit XML report to
+}
+
+// ciRange picks the commits to check: --range, the CI job's push or pull
+// request, or everything on HEAD that is not on base.
+func ciRange(revRange, base string) string {
+	if revRange != "" {
+		return revRange
+	}
+	if r, ok := ci.Range(); ok {
+		return r
+	}
+	if base == "" {
+		base = gitdiff.DefaultBase()
+	}
+	return base + "..HEAD"
+}
+
+// runCI checks every commit message in the range against rules and the
+// validator plugins, or with opts.Squash suggests a message for squashing
+// the range, and returns the exit code.
+func runCI(cfg generator.Config, opts ciOptions, revRange, base string, rules lint.Rules, validators []string, finish func(context.Context, string) (string, error), statusf func(string, ...interface{})) int {
+	ctx := context.Background()
+	revRange = ciRange(revRange, base)
+	annotate := os.Getenv("GITHUB_ACTIONS") == "true"
+	if opts.Squash {
+		return ciSquash(ctx, cfg, revRange, annotate, finish, statusf)
+	}
+
+	statusf("Checking commit messages in %s", revRange)
+	commits, err := gitdiff.Log(revRange)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	paths := make([]string, len(validators))
+	for i, name := range validators {
+		if paths[i], err = plugin.Find(name); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	var results []ci.Result
+	for _, c := range commits {
+		r := ci.Result{Hash: c.Hash, Subject: c.Subject(), Problems: rules.Check(c.Message)}
+		for i, path := range paths {
+			if err := plugin.Validate(ctx, path, c.Message); err != nil {
+				r.Problems = append(r.Problems, lint.Problem{Rule: "validator " + validators[i], Message: err.Error()})
+			}
+		}
+		results = append(results, r)
+	}
+
+	if annotate {
+		ci.WriteAnnotations(os.Stdout, results)
+	} else {
+		ci.WriteText(os.Stdout, results)
+	}
+	if opts.JUnit != "" {
+		f, err := os.Create(opts.JUnit)
+		if err == nil {
+			err = ci.WriteJUnit(f, results)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write JUnit report: %v\n", err)
+			return 2
+		}
+		statusf("JUnit report written to %s", opts.JUnit)
+	}
+	failed := ci.Failed(results)
+	statusf("Checked %d commit(s), %d failed", len(results), failed)
+	if failed > 0 {
+		return 14
+	}
+	return 0
+}
+
+// ciSquash generates one commit message for the whole range.
+func ciSquash(ctx context.Context, cfg generator.Config, revRange string, annotate bool, finish func(context.Context, string) (string, error), statusf func(string, ...interface{})) int {
+	base := revRange
+	if i := strings.Index(revRange, ".."); i >= 0 {
+		base = revRange[:i]
+	}
+	statusf("Collecting changes between %s and HEAD", base)
+	diff, err := gitdiff.Branch(base)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Fprintf(os.Stderr, "no changes between %s and HEAD\n", base)
+		return 2
+	}
+	cfg.Diff = diff
+	if cfg.DiffFrom, err = gitdiff.MergeBase(base); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	res, err := generator.New(cfg).Generate(ctx)
+	if err != nil {
+		exitOnError(err)
+	}
+	msg, err := finish(ctx, res.Message)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 12
+	}
+	fmt.Println(msg)
+	if annotate {
+		ci.Notice(os.Stdout, "Suggested squash message", msg)
+	}
+	return 0
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestCIRange(t *testing.T) {
+	for _, env := range []string{"GITHUB_BASE_REF", "GITHUB_ACTIONS", "CI_MERGE_REQUEST_DIFF_BASE_SHA", "GITLAB_CI"} {
+		t.Setenv(env, "")
+	}
+	if got := ciRange("a..b", "main"); got != "a..b" {
+		t.Errorf("explicit range = %q", got)
+	}
+	if got := ciRange("", "release"); got != "release..HEAD" {
+		t.Errorf("base range = %q", got)
+	}
+	t.Setenv("GITHUB_BASE_REF", "main")
+	if got := ciRange("", "release"); got != "origin/main..HEAD" {
+		t.Errorf("pull request range = %q", got)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// signFailureRe matches git's errors when GPG or SSH signing fails.
+var signFailureRe = regexp.MustCompile(`(?i)gpg failed to sign|failed to write commit object|error: (?:load key|signing failed)|ssh-keygen`)
+
+// gitCommit commits the staged changes with msg. Signing follows git's own
+// commit.gpgsign and gpg.format settings; sign or signKey force it on. The
+// message is passed through a temp file so the terminal stays free for
+// pinentry, and GPG_TTY is filled in when missing so curses/tty pinentry
+// can find the terminal.
+func gitCommit(msg string, sign bool, signKey string) error {
+	return commitWith(vcs.Git{}, msg, sign, signKey)
+}
+
+// commitWith commits the changes with msg in v, as gitCommit does in git.
+// sign and signKey only apply to git.
+func commitWith(v vcs.VCS, msg string, sign bool, signKey string) error {
+	f, err := os.CreateTemp("", "commit-writer-msg-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create message file: %w", err)
+	}
+	msgPath := f.Name()
+	if _, err := f.WriteString(msg + "\n"); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write message file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write message file: %w", err)
+	}
+
+	args := v.CommitArgs(msg, msgPath)
+	signArg := "-S"
+	if signKey != "" {
+		signArg += signKey
+	}
+	if sign || signKey != "" {
+		args = append(args, signArg)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	if os.Getenv("GPG_TTY") == "" && runtime.GOOS != "windows" {
+		tty := exec.Command("tty")
+		tty.Stdin = os.Stdin
+		if out, err := tty.Output(); err == nil {
+			cmd.Env = append(cmd.Env, "GPG_TTY="+strings.TrimSpace(string(out)))
+		}
+	}
+	// Keep stdout reserved for the message; git's output goes to stderr.
+	var stderr bytes.Buffer
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	if err := cmd.Run(); err != nil {
+		if signFailureRe.MatchString(stderr.String()) {
+			return fmt.Errorf("signing failed: %w\nIf pinentry could not prompt, run 'export GPG_TTY=$(tty)' (or unlock your SSH agent) and retry with:\n  git commit %s -F %s", err, signArg, msgPath)
+		}
+		return fmt.Errorf("%s commit failed: %w; the message was kept in %s", v.Name(), err, msgPath)
+	}
+	if err := os.Remove(msgPath); err != nil {
+		log.Printf("warning: failed to remove message file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+)
+
+const (
+	// compareWidth is the output width without a COLUMNS variable.
+	compareWidth = 120
+	// compareGap separates the columns.
+	compareGap = 3
+	// minColumn keeps many models readable on a narrow terminal.
+	minColumn = 20
+)
+
+// compareResult is one model's message for --compare.
+type compareResult struct {
+	Model   string `json:"model"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Confidence is only set with a message.
+	Confidence *generator.Confidence `json:"confidence,omitempty"`
+	// RequestID identifies the model's run in its server's logs.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// runCompare generates the message with each model in turn, used for both
+// stages, and prints the results side by side, one after another with
+// linear, or as a JSON array with asJSON. It returns the exit code: 0
+// unless every model failed.
+func runCompare(cfg generator.Config, models []string, asJSON, linear bool, finish func(context.Context, string) (string, error), statusf func(string, ...interface{})) int {
+	ctx := context.Background()
+	results := make([]compareResult, len(models))
+	failed := 0
+	for i, model := range models {
+		statusf("Generating with %s (%d of %d)", model, i+1, len(models))
+		mcfg := cfg
+		mcfg.SummarizerModel, mcfg.StyleModel = model, model
+		// A diffstat fallback would look the same for every model.
+		mcfg.NoFallback = true
+		results[i].Model = model
+		res, err := generator.New(mcfg).Generate(ctx)
+		if err == nil {
+			res.Message, err = finish(ctx, res.Message)
+		}
+		if err != nil {
+			var gerr *generator.Error
+			if errors.As(err, &gerr) {
+				err = gerr.Err
+				results[i].RequestID = gerr.RequestID
+			}
+			results[i].Error = err.Error()
+			failed++
+			continue
+		}
+		results[i].Message, results[i].Confidence = res.Message, &res.Confidence
+		results[i].RequestID = res.RequestID
+	}
+	switch {
+	case asJSON:
+		out, _ := json.MarshalIndent(results, "", "  ")
+		fmt.Println(string(out))
+	case linear:
+		fmt.Print(sequence(results))
+	default:
+		width := compareWidth
+		if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+			width = n
+		}
+		fmt.Print(columns(results, width))
+	}
+	if failed == len(models) {
+		return 1
+	}
+	return 0
+}
+
+// parseModels splits a --compare list, e.g. "gemma3:4B, qwen2.5:7b".
+func parseModels(list string) ([]string, error) {
+	var models []string
+	for _, m := range strings.Split(list, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	if len(models) < 2 {
+		return nil, fmt.Errorf("--compare needs at least two models, e.g. --compare gemma3:4B,qwen2.5:7b")
+	}
+	return models, nil
+}
+
+// columns lays out the results side by side within width, each headed by
+// its model and wrapped to its column.
+func columns(results []compareResult, width int) string {
+	n := len(results)
+	col := (width - compareGap*(n-1)) / n
+	if col < minColumn {
+		col = minColumn
+	}
+	cells := make([][]string, n)
+	rows := 0
+	for i, r := range results {
+		text := r.Message
+		if r.Error != "" {
+			text = "error: " + r.Error
+		}
+		head := wrap(r.Model, col)
+		cells[i] = append(append(head, strings.Repeat("-", col)), wrap(text, col)...)
+		if len(cells[i]) > rows {
+			rows = len(cells[i])
+		}
+	}
+	var b strings.Builder
+	for row := 0; row < rows; row++ {
+		var line strings.Builder
+		for i := range cells {
+			cell := ""
+			if row < len(cells[i]) {
+				cell = cells[i][row]
+			}
+			if i > 0 {
+				line.WriteString(strings.Repeat(" ", compareGap))
+			}
+			line.WriteString(cell + strings.Repeat(" ", col-utf8.RuneCountInString(cell)))
+		}
+		b.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	return b.String()
+}
+
+// sequence lists the results one after another, each headed by its model,
+// for screen readers and logs that can't follow columns.
+func sequence(results []compareResult) string {
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		text := r.Message
+		if r.Error != "" {
+			text = "error: " + r.Error
+		}
+		fmt.Fprintf(&b, "Model %d of %d: %s\n%s\n", i+1, len(results), r.Model, text)
+	}
+	return b.String()
+}
+
+// wrap breaks each line of text at spaces to at most width characters,
+// splitting longer words.
+func wrap(text string, width int) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		cur := ""
+		for _, word := range strings.Fields(line) {
+			for utf8.RuneCountInString(word) > width {
+				if cur != "" {
+					out, cur = append(out, cur), ""
+				}
+				r := []rune(word)
+				out, word = append(out, string(r[:width])), string(r[width:])
+			}
+			switch {
+			case cur == "":
+				cur = word
+			case utf8.RuneCountInString(cur)+1+utf8.RuneCountInString(word) <= width:
+				cur += " " + word
+			default:
+				out, cur = append(out, cur), word
+			}
+		}
+		// Blank lines stay, e.g. between title and body.
+		out = append(out, cur)
+	}
+	return out
+}
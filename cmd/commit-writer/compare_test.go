@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModels(t *testing.T) {
+	got, err := parseModels(" gemma3:4B, qwen2.5:7b ,")
+	if err != nil || !reflect.DeepEqual(got, []string{"gemma3:4B", "qwen2.5:7b"}) {
+		t.Errorf("parseModels = %q, %v", got, err)
+	}
+	if _, err := parseModels("gemma3:4B"); err == nil {
+		t.Error("parseModels accepted a single model")
+	}
+}
+
+func TestColumns(t *testing.T) {
+	results := []compareResult{
+		{Model: "gemma3:4B", Message: "Round cart totals\n\nTotals are now rounded to whole cents."},
+		{Model: "qwen2.5:7b", Error: "model not found"},
+	}
+	want := "" +
+		"gemma3:4B                qwen2.5:7b\n" +
+		"----------------------   ----------------------\n" +
+		"Round cart totals        error: model not found\n" +
+		"\n" +
+		"Totals are now rounded\n" +
+		"to whole cents.\n"
+	if got := columns(results, 47); got != want {
+		t.Errorf("columns =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSequence(t *testing.T) {
+	results := []compareResult{
+		{Model: "gemma3:4B", Message: "Round cart totals\n\nTotals are now rounded to whole cents."},
+		{Model: "qwen2.5:7b", Error: "model not found"},
+	}
+	want := "Model 1 of 2: gemma3:4B\nRound cart totals\n\nTotals are now rounded to whole cents.\n\nModel 2 of 2: qwen2.5:7b\nerror: model not found\n"
+	if got := sequence(results); got != want {
+		t.Errorf("sequence =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	got := wrap("a verylongidentifier b", 8)
+	want := []string{"a", "verylong", "identifi", "er b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrap = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/clipboard"
+	"github.com/kylegalloway/commit-writer/pkg/forge"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/history"
+	"github.com/kylegalloway/commit-writer/pkg/notify"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// delivery is a generated message and what main hands over with it.
+type delivery struct {
+	Subcommand string
+	Message    string
+	Result     *generator.Result
+	// Previous is the refined message's history entry for refine.
+	Previous history.Entry
+	Config   generator.Config
+	Finish   func(context.Context, string) (string, error)
+	Record   func(history.Entry)
+	Notify   func(title, body string)
+	Webhook  *notify.Webhook
+	Repo     vcs.VCS
+	Status   func(string, ...interface{})
+	Warn     func(string, ...interface{})
+}
+
+// deliver prints the message and any alternatives, copies it, writes the
+// hook file, commits or describes it, records it in the history and posts
+// it to the webhook. It returns the exit code.
+func (o *options) deliver(d delivery) int {
+	var alts []string
+	if o.candidates > 1 {
+		alts = alternatives(d.Config, d.Result, d.Message, o.candidates-1, d.Finish, d.Status, d.Warn)
+	}
+	d.Notify("Commit message ready", strings.SplitN(d.Message, "\n", 2)[0])
+	if o.porcelain {
+		title, body := forge.SplitMessage(d.Message)
+		fmt.Print(format.Porcelain(title, body, d.Result.Offline))
+	} else {
+		fmt.Println(d.Message)
+	}
+	if o.hookFile == "" {
+		for i, alt := range alts {
+			fmt.Fprintf(os.Stderr, "\nAlternative %d:\n%s\n", i+1, alt)
+		}
+	}
+
+	if o.copyMsg {
+		if err := clipboard.Copy(d.Message); err != nil {
+			d.Warn("could not copy the message: %v", err)
+		} else {
+			d.Status("Message copied to the clipboard")
+		}
+	}
+
+	// The history keeps the message even when writing the hook file or
+	// committing fails, which is when it is needed most.
+	entry := history.Entry{Repo: d.Repo.Root(), Branch: d.Repo.Branch(), DiffHash: d.Result.DiffHash, SummarizerModel: o.summarizerModel, StyleModel: o.styleModel, Tone: o.tone, Offline: d.Result.Offline, Message: d.Message, Summary: d.Result.Summary}
+	if d.Result.SummarizerModel != "" {
+		entry.SummarizerModel, entry.StyleModel = d.Result.SummarizerModel, d.Result.StyleModel
+	}
+	if d.Subcommand == "refine" {
+		// The summary, and so the change, is the refined message's.
+		entry.DiffHash, entry.SummarizerModel = d.Previous.DiffHash, d.Previous.SummarizerModel
+	}
+	record := func() { d.Record(entry) }
+	if o.hookFile != "" {
+		// A comment line the VCS strips tells the author when to look twice.
+		hookMsg := d.Message + "\n\n" + d.Repo.Comment() + " commit-writer confidence: " + d.Result.Confidence.String()
+		if len(alts) > 0 {
+			hookMsg += "\n" + commentAlternatives(alts, d.Repo.Comment())
+		}
+		if code, err := writeHook(o.hookFile, hookMsg, d.Repo.Comment(), o.forceWrite, d.Status); err != nil {
+			record()
+			fmt.Fprintln(os.Stderr, err)
+			if o.debug {
+				log.Printf("hook write error: %v", err)
+			}
+			return code
+		}
+		d.Status("Hook file updated: %s", o.hookFile)
+	}
+
+	if o.doCommit {
+		d.Status("Committing staged changes")
+		if err := commitWith(d.Repo, d.Message, o.sign, o.signKey); err != nil {
+			record()
+			fmt.Fprintln(os.Stderr, err)
+			if o.debug {
+				log.Printf("commit error: %v", err)
+			}
+			return 10
+		}
+		entry.Accepted, entry.Commit = true, d.Repo.Head()
+		d.Status("Committed")
+	}
+	if o.describe {
+		d.Status("Describing the working-copy change")
+		if err := d.Repo.(vcs.Jujutsu).Describe(d.Message); err != nil {
+			record()
+			fmt.Fprintln(os.Stderr, err)
+			return 10
+		}
+		entry.Accepted = true
+		d.Status("Described")
+	}
+	record()
+	if d.Webhook != nil {
+		d.Status("Posting message to webhook")
+		event := notify.Event{Repo: repoName(d.Repo), Branch: d.Repo.Branch(), Message: d.Message}
+		if err := d.Webhook.Post(context.Background(), event); err != nil {
+			d.Warn("%v", err)
+		}
+	}
+	d.Status("Done")
+	return 0
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/plugin"
+	"github.com/kylegalloway/commit-writer/pkg/tunnel"
+)
+
+// doctorOptions are the settings "commit-writer doctor" checks.
+type doctorOptions struct {
+	ConfigPath      string
+	ExplicitConfig  bool
+	OllamaURL       string
+	APIKey          string
+	Provider        string // empty for the config's, else ollama
+	SummarizerModel string
+	StyleModel      string
+	LocalOnly       bool
+}
+
+// finding is the outcome of one doctor check.
+type finding struct {
+	Level  string // "ok", "warn" or "FAIL"
+	Name   string
+	Detail string
+	Fix    string
+}
+
+// runDoctor checks what commit-writer depends on and prints each result
+// with a fix for what's wrong. It returns the exit code: 1 if any check
+// failed.
+func runDoctor(opts doctorOptions) int {
+	var findings []finding
+	add := func(level, name, detail, fix string) {
+		findings = append(findings, finding{level, name, detail, fix})
+	}
+
+	if out, err := exec.Command("git", "--version").Output(); err != nil {
+		add("FAIL", "git", err.Error(), "install git and make sure it is on PATH")
+	} else {
+		add("ok", "git", strings.TrimSpace(string(out)), "")
+	}
+
+	root := gitdiff.RepoRoot()
+	switch {
+	case root == "":
+		detail := "not in a git repository"
+		if err := gitdiff.CheckWorkTree(); err != nil {
+			detail = err.Error()
+		}
+		add("warn", "repository", detail, "run commit-writer inside the repository whose changes it should describe")
+	case gitdiff.HasStaged():
+		add("ok", "repository", root+": staged changes to describe", "")
+	case gitdiff.HasChanges():
+		add("ok", "repository", root+": unstaged changes only; they are described when nothing is staged", "")
+	default:
+		add("warn", "repository", root+": no changes to describe", "edit and stage files with git add")
+	}
+
+	cfg, err := config.Load(opts.ConfigPath, opts.ExplicitConfig)
+	switch {
+	case err != nil:
+		add("FAIL", "config", err.Error(), "fix the file, or pass --config with another one")
+	case opts.ConfigPath == "":
+		add("ok", "config", "no config location; using defaults", "")
+	default:
+		if _, statErr := os.Stat(opts.ConfigPath); statErr != nil {
+			add("ok", "config", opts.ConfigPath+" does not exist; using defaults", "")
+		} else {
+			add("ok", "config", opts.ConfigPath, "")
+		}
+	}
+	if _, _, err := config.LoadPolicy(config.SystemPolicyPath()); err != nil {
+		add("FAIL", "policy", err.Error(), "ask whoever manages this machine to fix the policy file")
+	}
+
+	provider := opts.Provider
+	if provider == "" {
+		provider = cfg.Provider
+	}
+	if provider != "" && provider != "ollama" {
+		if path, err := plugin.Find(provider); err != nil {
+			add("FAIL", "provider", err.Error(), fmt.Sprintf("install %s%s on PATH, or drop the provider setting", plugin.Prefix, provider))
+		} else {
+			add("ok", "provider", "plugin "+path+"; skipping the Ollama checks", "")
+		}
+	} else {
+		findings = append(findings, checkOllama(opts, cfg)...)
+	}
+
+	if root != "" {
+		findings = append(findings, checkHook(gitdiff.GitPath("hooks")))
+	}
+
+	failed := 0
+	for _, f := range findings {
+		fmt.Printf("%-5s %s: %s\n", f.Level, f.Name, f.Detail)
+		if f.Fix != "" {
+			fmt.Printf("      fix: %s\n", f.Fix)
+		}
+		if f.Level == "FAIL" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		fmt.Printf("\n%d problem(s) found\n", failed)
+		return 1
+	}
+	return 0
+}
+
+// checkOllama checks that the server answers and has every model a run
+// would use installed.
+func checkOllama(opts doctorOptions, cfg config.Config) []finding {
+	localOnly := opts.LocalOnly || cfg.LocalOnly
+	if tunnel.IsSSH(opts.OllamaURL) {
+		if localOnly {
+			return []finding{{"FAIL", "ollama", opts.OllamaURL + ": local-only: an SSH tunnel sends data to another machine", "drop --local-only, or run Ollama on this machine"}}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		t, err := tunnel.Open(ctx, opts.OllamaURL)
+		cancel()
+		if err != nil {
+			return []finding{{"FAIL", "ollama", err.Error(), "check that plain ssh to the host works, and that Ollama runs there"}}
+		}
+		defer t.Close()
+		opts.OllamaURL = t.URL
+	} else if localOnly {
+		if err := llm.CheckLoopback(opts.OllamaURL); err != nil {
+			return []finding{{"FAIL", "ollama", err.Error(), "point --ollama or OLLAMA_URL at a local server, or drop --local-only"}}
+		}
+	}
+	client := &llm.Ollama{URL: opts.OllamaURL, APIKey: opts.APIKey, LoopbackOnly: localOnly}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := client.Check(ctx); err != nil {
+		var unreachable *llm.UnreachableError
+		if errors.As(err, &unreachable) && unreachable.Hint != "" {
+			return []finding{{"FAIL", "ollama", opts.OllamaURL + ": not reachable", unreachable.Hint}}
+		}
+		return []finding{{"FAIL", "ollama", fmt.Sprintf("%s: %v", opts.OllamaURL, err), "start it with 'ollama serve', or point --ollama or OLLAMA_URL at your server"}}
+	}
+	installed, err := client.ListModels(ctx)
+	if err != nil {
+		return []finding{{"FAIL", "ollama", fmt.Sprintf("%s: listing models: %v", opts.OllamaURL, err), "check the server's logs; a proxy in front of it may need OLLAMA_API_KEY"}}
+	}
+	findings := []finding{{"ok", "ollama", fmt.Sprintf("%s (%d models installed)", opts.OllamaURL, len(installed)), ""}}
+	models := []string{opts.SummarizerModel, opts.StyleModel}
+	for _, st := range cfg.Stages() {
+		models = append(models, st.Model)
+	}
+	seen := map[string]bool{"": true}
+	for _, m := range models {
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		if modelInstalled(m, installed) {
+			findings = append(findings, finding{"ok", "model", m, ""})
+		} else {
+			findings = append(findings, finding{"FAIL", "model", m + " is not installed", "ollama pull " + m})
+		}
+	}
+	return findings
+}
+
+// modelInstalled reports whether name is among the installed models.
+// Ollama names are case-insensitive and default to the "latest" tag.
+func modelInstalled(name string, installed []string) bool {
+	norm := func(s string) string {
+		s = strings.ToLower(s)
+		if !strings.Contains(s, ":") {
+			s += ":latest"
+		}
+		return s
+	}
+	for _, m := range installed {
+		if norm(m) == norm(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHook reports whether the prepare-commit-msg hook in dir runs
+// commit-writer.
+func checkHook(dir string) finding {
+	path := filepath.Join(dir, "prepare-commit-msg")
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return finding{"warn", "hook", "no prepare-commit-msg hook in " + dir, "only needed for automatic messages on git commit; run commit-writer install-hook"}
+	case err != nil:
+		return finding{"FAIL", "hook", err.Error(), "check the permissions of " + dir}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return finding{"FAIL", "hook", err.Error(), "check the permissions of " + path}
+	}
+	if !strings.Contains(string(data), "commit-writer") {
+		return finding{"warn", "hook", path + " does not run commit-writer", "add a commit-writer --hook \"$1\" line to it, or replace it with commit-writer install-hook --force"}
+	}
+	if first, _, _ := strings.Cut(string(data), "\n"); strings.HasPrefix(first, "#!") && strings.HasSuffix(first, "\r") {
+		return finding{"FAIL", "hook", path + " has Windows (CRLF) line endings, so sh cannot run it", "run commit-writer install-hook --force, or save it with LF line endings"}
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return finding{"FAIL", "hook", path + " is not executable, so git skips it", "chmod +x " + path}
+	}
+	return finding{"ok", "hook", path, ""}
+}
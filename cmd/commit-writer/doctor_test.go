@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestModelInstalled(t *testing.T) {
+	installed := []string{"gemma3:4b", "mistral:latest"}
+	for name, want := range map[string]bool{
+		"gemma3:4B":      true,
+		"mistral":        true,
+		"mistral:latest": true,
+		"gemma3":         false,
+		"qwen2.5:7b":     false,
+	} {
+		if got := modelInstalled(name, installed); got != want {
+			t.Errorf("modelInstalled(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCheckHook(t *testing.T) {
+	dir := t.TempDir()
+	if f := checkHook(dir); f.Level != "warn" {
+		t.Errorf("missing hook: %+v", f)
+	}
+	path := filepath.Join(dir, "prepare-commit-msg")
+	write := func(content string, mode os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("#!/bin/sh\nexit 0\n", 0755)
+	if f := checkHook(dir); f.Level != "warn" {
+		t.Errorf("hook without commit-writer: %+v", f)
+	}
+	write("#!/bin/sh\ncommit-writer --hook \"$1\"\n", 0644)
+	if f := checkHook(dir); runtime.GOOS != "windows" && f.Level != "FAIL" {
+		t.Errorf("non-executable hook: %+v", f)
+	}
+	write("#!/bin/sh\r\ncommit-writer --hook \"$1\"\r\n", 0755)
+	if f := checkHook(dir); f.Level != "FAIL" {
+		t.Errorf("CRLF hook: %+v", f)
+	}
+	write(hookScript(`C:\Users\me\bin\commit-writer.exe`, nil), 0755)
+	if f := checkHook(dir); f.Level != "ok" {
+		t.Errorf("installed hook: %+v", f)
+	}
+}
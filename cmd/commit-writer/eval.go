@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/eval"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// evalCase is a change to generate a message for.
+type evalCase struct {
+	source, original, diff string
+}
+
+// runEval generates a message for each diff file, or else for each commit
+// in revRange or the latest limit commits, has judge score it and prints
+// the report in format ("markdown" or "json"). It returns the exit code.
+func runEval(cfg generator.Config, files []string, revRange string, limit int, judge, format string, finish func(context.Context, string) (string, error), statusf func(string, ...interface{})) int {
+	ctx := context.Background()
+	cases, err := evalCases(files, revRange, limit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(cases) == 0 {
+		fmt.Fprintln(os.Stderr, "nothing to evaluate")
+		return 2
+	}
+	if judge == "" {
+		judge = cfg.SummarizerModel
+	}
+	report := eval.Report{SummarizerModel: cfg.SummarizerModel, StyleModel: cfg.StyleModel, Tone: cfg.Tone, JudgeModel: judge}
+	for i, c := range cases {
+		statusf("Evaluating %s (%d of %d)", c.source, i+1, len(cases))
+		ccfg := cfg
+		ccfg.Diff = c.diff
+		ccfg.NoFallback = true
+		result := eval.Case{Source: c.source, Original: c.original}
+		g := generator.New(ccfg)
+		res, err := g.Generate(ctx)
+		if err == nil {
+			result.Message, err = finish(ctx, res.Message)
+		}
+		if err == nil {
+			var score eval.Score
+			if score, err = g.Judge(ctx, judge, result.Message); err == nil {
+				result.Score = &score
+			}
+		}
+		if err != nil {
+			var gerr *generator.Error
+			if errors.As(err, &gerr) {
+				err = gerr.Err
+			}
+			result.Error = err.Error()
+		}
+		report.Cases = append(report.Cases, result)
+	}
+	report.Summarize()
+	if format == "json" {
+		if err := eval.WriteJSON(os.Stdout, report); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	} else {
+		eval.WriteMarkdown(os.Stdout, report)
+	}
+	if report.Scored == 0 {
+		return 1
+	}
+	return 0
+}
+
+// evalCases reads the diff files, or else the commits in revRange or the
+// latest limit commits on HEAD, skipping those without changes.
+func evalCases(files []string, revRange string, limit int) ([]evalCase, error) {
+	var cases []evalCase
+	if len(files) > 0 {
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read diff: %w", err)
+			}
+			if strings.TrimSpace(string(data)) != "" {
+				cases = append(cases, evalCase{source: f, diff: string(data)})
+			}
+		}
+		return cases, nil
+	}
+	var commits []gitdiff.Commit
+	var err error
+	if revRange != "" {
+		commits, err = gitdiff.Log(revRange)
+	} else {
+		commits, err = gitdiff.Recent("HEAD", limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range commits {
+		diff, err := gitdiff.CommitDiff(c.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(diff) != "" {
+			cases = append(cases, evalCase{source: c.Hash[:7], original: c.Subject(), diff: diff})
+		}
+	}
+	return cases, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// runExplain prints a plain-English explanation of the commit named in
+// args, from its message and its changes to its first parent. It returns
+// the exit code: 2 for a missing or unknown revision or an empty commit.
+func runExplain(cfg generator.Config, args []string, statusf func(string, ...interface{})) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: commit-writer explain [flags] <rev>")
+		return 2
+	}
+	rev := args[0]
+	msg, err := gitdiff.Message(rev)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	statusf("Reading the changes of %s", rev)
+	diff, err := gitdiff.CommitDiff(rev)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Fprintf(os.Stderr, "%s changes no files\n", rev)
+		return 2
+	}
+	cfg.Diff = diff
+	out, err := generator.New(cfg).Explain(context.Background(), msg)
+	if err != nil {
+		exitOnError(err)
+	}
+	fmt.Println(out)
+	return 0
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// failSoftLines bounds how much of a failed run's stderr goes into the
+// hook file.
+const failSoftLines = 10
+
+// hookWrap holds the --hook modes that run commit-writer again as a child
+// process and act on how it went.
+type hookWrap struct {
+	FailSoft bool   // note a failure in the hook file and exit 0
+	Editor   bool   // open the hook file in an editor afterwards
+	Comment  string // prefix of the lines the VCS drops from messages
+}
+
+// runWrapped runs commit-writer again with args minus --fail-soft and
+// --editor. With FailSoft, a failure for any reason, including a crash, is
+// explained in a comment in the hook file instead, so a broken generator
+// never blocks a commit. With Editor, the hook file is then opened for
+// editing, as Mercurial's ui.editor would. It returns the exit code.
+func runWrapped(args []string, hookFile string, w hookWrap) int {
+	code, why := runChild(withoutFlag(withoutFlag(args, "fail-soft"), "editor"))
+	if code != 0 {
+		if !w.FailSoft {
+			if code < 0 {
+				return 1
+			}
+			return code
+		}
+		failSoftNote(hookFile, w.Comment, code, why)
+	}
+	if w.Editor {
+		return openEditor(hookFile)
+	}
+	return 0
+}
+
+// runChild runs commit-writer with args, passing its output through, and
+// returns its exit code (-1 when it has none, e.g. after a crash) and,
+// when that isn't 0, the last lines it wrote to stderr.
+func runChild(args []string) (int, string) {
+	exe, err := os.Executable()
+	if err != nil {
+		return -1, err.Error()
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin, cmd.Stdout = os.Stdin, os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	err = cmd.Run()
+	if err == nil {
+		return 0, ""
+	}
+	code := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	}
+	why := failureLines(stderr.String())
+	if why == "" {
+		why = err.Error()
+	}
+	return code, why
+}
+
+// failSoftNote appends the failure to the hook file as lines starting
+// with comment, which the VCS leaves out of the commit message.
+func failSoftNote(hookFile, comment string, code int, why string) {
+	old, _ := os.ReadFile(hookFile)
+	nl := newline(old)
+	var b strings.Builder
+	if code >= 0 {
+		fmt.Fprintf(&b, "%s%s commit-writer failed (exit code %d), so write the message yourself:%s", nl, comment, code, nl)
+	} else {
+		fmt.Fprintf(&b, "%s%s commit-writer failed, so write the message yourself:%s", nl, comment, nl)
+	}
+	for _, line := range strings.Split(why, "\n") {
+		b.WriteString(strings.TrimRight(comment+" "+line, " ") + nl)
+	}
+	f, err := os.OpenFile(hookFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(b.String())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not note the failure in %s: %v\n", hookFile, err)
+	}
+	fmt.Fprintln(os.Stderr, "commit-writer failed; continuing the commit because of --fail-soft")
+}
+
+// failureLines returns the last lines of a run's stderr that aren't
+// progress messages.
+func failureLines(stderr string) string {
+	var lines []string
+	for _, line := range strings.Split(stderr, "\n") {
+		if line = strings.TrimRight(line, "\r "); line != "" && !strings.HasPrefix(line, "[status] ") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > failSoftLines {
+		lines = lines[len(lines)-failSoftLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// withoutFlag drops a boolean flag from args in any of its spellings, e.g.
+// "-name", "--name" or "--name=true".
+func withoutFlag(args []string, name string) []string {
+	var out []string
+	for i, a := range args {
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+		flagName := strings.SplitN(strings.TrimLeft(a, "-"), "=", 2)[0]
+		if strings.HasPrefix(a, "-") && flagName == name {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithoutFlag(t *testing.T) {
+	args := []string{"--hook", "msg", "--fail-soft", "-fail-soft=true", "--tone", "dry", "--", "--fail-soft"}
+	got := withoutFlag(args, "fail-soft")
+	want := []string{"--hook", "msg", "--tone", "dry", "--", "--fail-soft"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("withoutFlag = %q, want %q", got, want)
+	}
+}
+
+func TestFailSoftNote(t *testing.T) {
+	hookFile := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	if err := os.WriteFile(hookFile, []byte("\n# Please enter the commit message\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	why := failureLines("[status] Checking Ollama\nWarning: slow\n\nollama is not running\n")
+	failSoftNote(hookFile, "#", 1, why)
+	data, err := os.ReadFile(hookFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\n# Please enter the commit message\n\n# commit-writer failed (exit code 1), so write the message yourself:\n# Warning: slow\n# ollama is not running\n"
+	if string(data) != want {
+		t.Errorf("hook file = %q, want %q", data, want)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			t.Errorf("uncommented line %q would end up in the commit", line)
+		}
+	}
+}
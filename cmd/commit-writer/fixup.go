@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// fixupMaxLines is the most lines a staged change may add and remove to be
+// offered as a fix to the last commit.
+const fixupMaxLines = 10
+
+// chooseFixup decides whether to write a fixup! or squash! message: a
+// small change to the files of an unpushed last commit is likely a fix to
+// it, which --fixup, or the answer to the offer, describes as such for git
+// rebase --autosquash. It returns "fixup", "squash" or "", the last
+// commit's subject, and an exit code when --fixup cannot be followed.
+func chooseFixup(subcommand string, o *options, repo vcs.VCS, statusf func(string, ...interface{})) (fixup, subject string, code int) {
+	switch {
+	case o.fixupLast:
+		if repo.Name() != "git" {
+			fmt.Fprintln(os.Stderr, "--fixup only works in git repositories")
+			return "", "", 2
+		}
+		subject, err := lastSubject()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return "", "", 2
+		}
+		return "fixup", subject, 0
+	case !o.noFixup && subcommand == "" && repo.Name() == "git" && !o.porcelain && !o.pickHunks && o.candidates <= 1 && o.loadSummary == "":
+		diff, err := repo.Diff()
+		if err != nil {
+			return "", "", 0
+		}
+		subject, ok := fixupTarget(gitdiff.ParseStat(diff))
+		if !ok {
+			return "", "", 0
+		}
+		// A hook or a script has nobody at the terminal to answer.
+		asked := false
+		if o.hookFile == "" && stdinIsTerminal() {
+			fixup, err = offerFixupTTY(subject)
+			asked = err == nil
+		}
+		if !asked {
+			statusf("The staged change looks like a fix to the last commit; --fixup writes a fixup! message for it")
+		}
+		return fixup, subject, 0
+	}
+	return "", "", 0
+}
+
+// lastSubject returns the subject of the last commit.
+func lastSubject() (string, error) {
+	msg, err := gitdiff.Message("HEAD")
+	if err != nil {
+		return "", fmt.Errorf("no commit to fix up: %w", err)
+	}
+	return strings.SplitN(msg, "\n", 2)[0], nil
+}
+
+// fixupTarget returns the subject of the last commit when the staged
+// change looks like a fix to it: small, only in files that commit changed,
+// and the commit not pushed yet.
+func fixupTarget(stats []gitdiff.FileStat) (string, bool) {
+	files, err := gitdiff.Files("HEAD")
+	if err != nil || !looksLikeFixup(stats, files) || !gitdiff.Unpushed("HEAD") {
+		return "", false
+	}
+	subject, err := lastSubject()
+	return subject, err == nil
+}
+
+// looksLikeFixup reports whether a change has at most fixupMaxLines lines
+// and touches nothing but files.
+func looksLikeFixup(stats []gitdiff.FileStat, files []string) bool {
+	if len(stats) == 0 {
+		return false
+	}
+	changed := map[string]bool{}
+	for _, f := range files {
+		changed[f] = true
+	}
+	lines := 0
+	for _, s := range stats {
+		if !changed[s.Path] {
+			return false
+		}
+		lines += s.Added + s.Removed
+	}
+	return lines <= fixupMaxLines
+}
+
+// stdinIsTerminal reports whether standard input is a terminal.
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// offerFixupTTY asks on the terminal, like askTTY, whether to write a
+// fixup! or squash! message for the commit with subject instead.
+func offerFixupTTY(subject string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+	return offerFixup(bufio.NewReader(tty), tty, subject), nil
+}
+
+// offerFixup asks on w and returns "fixup", "squash" or "" for a
+// standalone message, which is also the answer at end of input.
+func offerFixup(r *bufio.Reader, w io.Writer, subject string) string {
+	fmt.Fprintf(w, "The staged change is small and only touches files the last commit changed:\n  %s\n", subject)
+	fmt.Fprint(w, "Write a fixup! message for it, a squash! message with a description, or a new message? [f/s/N] ")
+	line, err := r.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(w)
+		return ""
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "f", "fixup":
+		return "fixup"
+	case "s", "squash":
+		return "squash"
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestLooksLikeFixup(t *testing.T) {
+	files := []string{"a.go", "b.go"}
+	for _, tc := range []struct {
+		stats []gitdiff.FileStat
+		want  bool
+	}{
+		{[]gitdiff.FileStat{{Path: "a.go", Added: 2, Removed: 1}}, true},
+		{[]gitdiff.FileStat{{Path: "a.go", Added: 5}, {Path: "b.go", Removed: 5}}, true},
+		{[]gitdiff.FileStat{{Path: "a.go", Added: 6}, {Path: "b.go", Removed: 5}}, false},
+		{[]gitdiff.FileStat{{Path: "a.go", Added: 1}, {Path: "c.go", Added: 1}}, false},
+		{nil, false},
+	} {
+		if got := looksLikeFixup(tc.stats, files); got != tc.want {
+			t.Errorf("looksLikeFixup(%+v) = %v, want %v", tc.stats, got, tc.want)
+		}
+	}
+}
+
+func TestOfferFixup(t *testing.T) {
+	for in, want := range map[string]string{"f\n": "fixup", "Squash\n": "squash", "\n": "", "n\n": "", "": "", "s": "squash"} {
+		var out strings.Builder
+		if got := offerFixup(bufio.NewReader(strings.NewReader(in)), &out, "Add retries"); got != want {
+			t.Errorf("offerFixup(%q) = %q, want %q", in, got, want)
+		}
+		if !strings.Contains(out.String(), "  Add retries\n") {
+			t.Errorf("output:\n%s", out.String())
+		}
+	}
+}
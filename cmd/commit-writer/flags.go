@@ -0,0 +1,403 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/profile"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+	"github.com/kylegalloway/commit-writer/pkg/trace"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// subcommands, given as the first argument, do something other than
+// generate a single commit message:
+//
+//	serve [flags]                      run the HTTP server
+//	pr [flags]                         describe the current branch
+//	ci [flags]                         check a CI job's commit messages
+//	changelog [flags]                  write release notes
+//	split [flags]                      suggest several commits
+//	learn [flags]                      write a style profile
+//	last                               show the latest message
+//	history [flags]                    list past messages
+//	stats                              show how past messages fared
+//	eval [flags] [diff files]          score generated messages
+//	refine [flags] feedback            rewrite the latest message
+//	undo [hook file]                   restore a hook file from its backup
+//	watch [flags]                      keep a draft message up to date
+//	doctor                             check the setup
+//	explain [flags] <rev>              explain an existing commit
+//	review [flags]                     write notes for a reviewer
+//	standup [flags]                    summarize your recent commits
+//	install-hook [--force] [-- flags]  set up the prepare-commit-msg hook
+//	update [--check]                   install the latest release
+var subcommands = []string{
+	"serve", "pr", "ci", "changelog", "split", "learn", "last", "history", "stats", "eval",
+	"refine", "undo", "watch", "doctor", "explain", "review", "standup", "install-hook", "update",
+}
+
+// options holds the command-line flags.
+type options struct {
+	ollamaURL       string
+	summarizerModel string
+	styleModel      string
+	tone            string
+	intensity       float64
+	personaName     string
+	candidates      int
+	hookFile        string
+	forceWrite      bool
+	debug           bool
+	noLabels        bool
+	titleOnly       bool
+	saveSummary     string
+	loadSummary     string
+	timeoutSecs     int
+	noFallback      bool
+	noRedact        bool
+	configPath      string
+	localOnly       bool
+	auditPath       string
+	anonymize       bool
+	useKeychain     bool
+	doCommit        bool
+	sign            bool
+	signKey         string
+	recordPath      string
+	replayPath      string
+	provider        string
+	postPlugins     stringList
+	validators      stringList
+	paramFlags      stringList
+	seed            int
+	compareList     string
+	reposList       string
+	deterministic   bool
+	maxPromptTokens int
+	maxOutputTokens int
+	minBodyLines    int
+	maxBodyLines    int
+	listenAddr      string
+	watchOpts       watchOptions
+	debounceSecs    int
+	jsonrpcMode     bool
+	pr              prOptions
+	ticketLookup    bool
+	why             string
+	contextFile     string
+	styleExamples   string
+	askMode         bool
+	maxQuestions    int
+	pickHunks       bool
+	fixupLast       bool
+	noFixup         bool
+	ciOpts          ciOptions
+	splitOpts       splitOptions
+	porcelain       bool
+	copyMsg         bool
+	notifyDone      bool
+	failSoft        bool
+	openEdit        bool
+	vcsName         string
+	describe        bool
+	releaseTag      string
+	since           string
+	webhookURL      string
+	uiLang          string
+	traceSpans      bool
+	otlpEndpoint    string
+	noANSI          bool
+	linearOutput    bool
+	revRange        string
+	changelogFormat string
+	judgeModel      string
+	goSemantic      bool
+	noClassify      bool
+	noRelated       bool
+	allowDuplicates bool
+	noRefCheck      bool
+	styleDocs       bool
+	depNotes        bool
+	riskNote        bool
+	securityNote    bool
+	semverTrailer   bool
+	todos           bool
+	verify          bool
+	speculate       bool
+	apiChanges      bool
+	profileFile     string
+	noProfile       bool
+	strict          bool
+	noHistory       bool
+	historyLimit    int
+	checkUpdate     bool
+	// explicit holds the names of the flags given on the command line, so
+	// defaults can tell them apart from the config's and a persona's.
+	explicit map[string]bool
+}
+
+// parseArgs splits off the subcommand, if args start with one, and parses
+// the flags that follow.
+func parseArgs(args []string) (string, *options) {
+	subcommand := ""
+	if len(args) > 0 {
+		for _, name := range subcommands {
+			if args[0] == name {
+				subcommand, args = args[0], args[1:]
+				break
+			}
+		}
+	}
+	o := &options{}
+	flag.StringVar(&o.ollamaURL, "ollama", os.Getenv("OLLAMA_URL"), "Ollama URL")
+	flag.StringVar(&o.summarizerModel, "summ-model", "gemma3:4B", "Summarizer model")
+	flag.StringVar(&o.styleModel, "style-model", "mistral:7b", "Styling model")
+	flag.StringVar(&o.tone, "tone", defaultTone, "Tone for stylistic rewrite, or a weighted blend such as 'dry:0.7,sarcastic:0.3'")
+	flag.Float64Var(&o.intensity, "intensity", 1, "How far the style rewrite departs from the plain summary, from 0 (not at all) to 1")
+	flag.StringVar(&o.hookFile, "hook", "", "Path for git hook commit message file")
+	flag.BoolVar(&o.forceWrite, "force", false, "Overwrite existing commit message in hook file; with 'commit-writer install-hook', replace another prepare-commit-msg hook; with 'commit-writer update', reinstall the latest release over a development build or the same version")
+	flag.BoolVar(&o.debug, "debug", false, "Enable debug logging")
+	flag.BoolVar(&o.noLabels, "no-labels", false, "Remove Title:/Body: labels from output")
+	flag.BoolVar(&o.titleOnly, "title-only", false, "Generate descriptive title only (no body)")
+	flag.IntVar(&o.minBodyLines, "min-body-lines", 0, "Ask for a body of at least this many lines, and once more when it is shorter (default: 2, asked but not enforced)")
+	flag.IntVar(&o.maxBodyLines, "max-body-lines", 0, "Ask for a body of at most this many lines, and cut a longer one (default: 40, asked but not enforced)")
+	flag.StringVar(&o.saveSummary, "save-summary", "", "Save factual summary to file (for review or reuse)")
+	flag.StringVar(&o.loadSummary, "load-summary", "", "Load summary from file and skip first LLM")
+	flag.IntVar(&o.timeoutSecs, "timeout", 300, "HTTP timeout in seconds for Ollama requests")
+	flag.BoolVar(&o.noFallback, "no-fallback", false, "Exit with an error instead of writing a diffstat-based message when Ollama is unreachable")
+	flag.StringVar(&o.configPath, "config", os.Getenv("COMMIT_WRITER_CONFIG"), "Path to JSON config file (default: user config dir)")
+	flag.BoolVar(&o.localOnly, "local-only", false, "Refuse to send data to non-loopback Ollama URLs")
+	flag.StringVar(&o.auditPath, "audit-log", "", "Append every prompt and response to this JSONL audit log")
+	flag.BoolVar(&o.anonymize, "anonymize", false, "Pseudonymize emails, internal hostnames and configured codenames before sending")
+	flag.BoolVar(&o.useKeychain, "keychain", false, "Read the Ollama API key from the OS credential store when OLLAMA_API_KEY is unset")
+	flag.BoolVar(&o.doCommit, "commit", false, "Commit the staged changes with the generated message")
+	flag.BoolVar(&o.sign, "sign", false, "Sign the --commit or split --apply commits (GPG or SSH, per gpg.format) even if commit.gpgsign is off")
+	flag.StringVar(&o.signKey, "sign-key", "", "Key ID to sign the --commit or split --apply commits with (implies --sign)")
+	flag.BoolVar(&o.noRedact, "no-redact", false, "Send the diff without redacting secrets (not recommended)")
+	flag.StringVar(&o.recordPath, "record", "", "Record model responses to this cassette file, keyed by prompt hash")
+	flag.StringVar(&o.replayPath, "replay", "", "Replay model responses from this cassette file instead of calling Ollama")
+	flag.StringVar(&o.provider, "provider", "", "Model provider: ollama (default) or the name of a commit-writer-<name> plugin on PATH")
+	flag.Var(&o.postPlugins, "post", "Post-processor plugin to run on the message (repeatable)")
+	flag.Var(&o.validators, "validate", "Validator plugin the message must pass (repeatable)")
+	flag.Var(&o.paramFlags, "param", "Set a pipeline stage's generation parameter as stage.key=value, e.g. summary.num_ctx=8192 (repeatable; keys: "+strings.Join(prompt.ParamNames, ", ")+")")
+	flag.StringVar(&o.compareList, "compare", "", "Generate with each of these comma-separated models (each used for both stages) and print the messages side by side, or as JSON with --porcelain")
+	flag.IntVar(&o.seed, "seed", -1, "Random seed sent with every model call (default: random, or 42 with --deterministic)")
+	flag.BoolVar(&o.deterministic, "deterministic", false, "Use temperature 0 and a fixed seed for every model call, so the same diff reproduces the same message")
+	flag.IntVar(&o.maxPromptTokens, "max-prompt-tokens", 0, "Most tokens a prompt may take: sets num_ctx and truncates large diffs to fit, with a warning, instead of leaving the model to cut them off (default: the model's context length)")
+	flag.IntVar(&o.maxOutputTokens, "max-output-tokens", 0, "Most tokens each model call may generate (num_predict)")
+	flag.StringVar(&o.listenAddr, "listen", "", "Address for 'commit-writer serve' to listen on (default "+defaultListen+"), or for 'commit-writer watch' to serve the draft on")
+	flag.StringVar(&o.watchOpts.Draft, "draft-file", "", "File 'commit-writer watch' keeps the draft message in (default: commit-writer-draft in the git directory)")
+	flag.IntVar(&o.debounceSecs, "debounce", 3, "Seconds the changes must stay the same before 'commit-writer watch' drafts a message")
+	flag.BoolVar(&o.jsonrpcMode, "jsonrpc", false, "Serve JSON-RPC requests on stdin/stdout for editor integrations")
+	flag.StringVar(&o.pr.Base, "base", "", "Base branch for 'commit-writer pr' and 'ci' (default: origin/HEAD, else main)")
+	flag.BoolVar(&o.pr.Create, "create", false, "Open the pull/merge request after generating it ('commit-writer pr')")
+	flag.BoolVar(&o.pr.Draft, "draft", false, "Open the pull/merge request as a draft ('commit-writer pr --create')")
+	flag.StringVar(&o.pr.Forge, "forge", "", "Code host for 'commit-writer pr': github or gitlab (default: detect from origin)")
+	flag.StringVar(&o.pr.Template, "template", "", "Description template name for 'commit-writer pr' (default: the repository's default template)")
+	flag.StringVar(&o.why, "why", "", "Why the change was made, e.g. \"working around upstream bug #42\"; given to the summarizer so the body doesn't have to guess")
+	flag.IntVar(&o.candidates, "n", 1, "Generate this many candidate messages; with --hook the others are added as comments to uncomment instead, otherwise they are printed to stderr")
+	flag.StringVar(&o.personaName, "persona", "", "Use this persona from the config file: its tone, example messages and formatting quirks")
+	flag.StringVar(&o.styleExamples, "style-examples", "", "File of example commit messages, separated by '---' lines, whose voice the style model imitates")
+	flag.StringVar(&o.contextFile, "context-file", "", "Read the reason for the change, like --why, from this file")
+	flag.BoolVar(&o.askMode, "ask", false, "Let the summarizer ask a few questions about the change on the terminal first and use the answers in the body")
+	flag.IntVar(&o.maxQuestions, "max-questions", 3, "Most questions --ask may put to you")
+	flag.BoolVar(&o.fixupLast, "fixup", false, "Write a 'fixup! <subject>' message for the last commit instead of generating one")
+	flag.BoolVar(&o.noFixup, "no-fixup", false, "Never offer a fixup! message when the staged change looks like a fix to the last commit")
+	flag.BoolVar(&o.pickHunks, "interactive-scope", false, "Pick on the terminal which staged hunks the message describes; what is staged does not change")
+	flag.BoolVar(&o.ticketLookup, "ticket", false, "Fetch the Jira, Linear or Azure Boards ticket named in the branch and give it to the summarizer as context")
+	flag.StringVar(&o.revRange, "range", "", "Revision range for 'commit-writer ci' and 'changelog' (default: detected from CI, or since the latest tag)")
+	flag.BoolVar(&o.ciOpts.Squash, "squash", false, "Suggest a squash message for the range instead of checking it ('commit-writer ci')")
+	flag.StringVar(&o.ciOpts.JUnit, "junit", "", "Write a JUnit XML report of the checks to this file ('commit-writer ci')")
+	flag.BoolVar(&o.splitOpts.Apply, "apply", false, "Commit each suggested group in turn after asking ('commit-writer split')")
+	flag.StringVar(&o.reposList, "repos", "", "Generate a message in each of these comma-separated repositories that has uncommitted changes ('-' reads one per line from stdin)")
+	flag.BoolVar(&o.copyMsg, "copy", false, "Also put the final message on the system clipboard")
+	flag.BoolVar(&o.notifyDone, "notify", false, "Show a desktop notification when the message is ready or generation fails")
+	flag.BoolVar(&o.failSoft, "fail-soft", false, "With --hook, exit 0 on any error and explain it in a comment in the hook file instead of blocking the commit")
+	flag.BoolVar(&o.openEdit, "editor", false, "With --hook, open the hook file in $VISUAL or $EDITOR afterwards, for use as Mercurial's ui.editor")
+	flag.StringVar(&o.vcsName, "vcs", "", "Version control system: git, hg, jj or sl (default: detected from the working directory)")
+	flag.BoolVar(&o.describe, "describe", false, "In a jj repository, set the working-copy change's description to the message with 'jj describe'")
+	flag.BoolVar(&o.porcelain, "porcelain", false, "Print NUL-separated version, state, title and body fields without status output, for scripts and git UIs")
+	flag.StringVar(&o.changelogFormat, "format", "markdown", "Output format for 'commit-writer changelog': markdown, cliff (git-cliff TOML groups), json (conventional-changelog) or release-notes (prose per group, written by the summarizer); for 'commit-writer eval': markdown or json")
+	flag.StringVar(&o.since, "since", "yesterday", "With 'commit-writer standup', how far back to look for your commits (anything git log --since accepts)")
+	flag.StringVar(&o.releaseTag, "release-tag", "", "With 'commit-writer changelog --format release-notes', set the notes as the body of this tag's GitHub release (needs GITHUB_TOKEN)")
+	flag.StringVar(&o.judgeModel, "judge-model", "", "Model that scores the messages in 'commit-writer eval' (default: the summarizer model)")
+	flag.BoolVar(&o.goSemantic, "go-semantic", false, "Describe changed Go files to the summarizer by declaration (functions, types, signatures) instead of raw hunks")
+	flag.BoolVar(&o.noRelated, "no-related", false, "Don't show the summarizer the latest commits touching the changed files")
+	flag.BoolVar(&o.allowDuplicates, "allow-duplicates", false, "Keep a title that nearly repeats a recent commit instead of asking the model for another")
+	flag.BoolVar(&o.noRefCheck, "no-ref-check", false, "Keep file and function names the diff doesn't contain in the message instead of asking the model again and stripping them")
+	flag.BoolVar(&o.noClassify, "no-classify", false, "Don't detect test-only, docs-only, formatting-only and dependency changes (which get a hint to the summarizer and a conventional type in the title) or tell the summarizer the changed files' languages, Go API changes and how a merge's conflicts were resolved")
+	flag.BoolVar(&o.styleDocs, "style-docs", false, "Run the style pass for docs-only changes too (skipped by default)")
+	flag.BoolVar(&o.depNotes, "dep-notes", false, "Add notable upstream changes from GitHub release notes to dependency bump messages")
+	flag.BoolVar(&o.riskNote, "risk", false, "Append a short risk note to the body when the diff touches auth code, migrations, public API or CI configuration (or a configured risk rule)")
+	flag.BoolVar(&o.securityNote, "security", false, "Append a marked security note to the body when the diff touches cryptography, auth or permission checks, CORS, SQL built from strings or TLS verification (or a configured security rule)")
+	flag.BoolVar(&o.apiChanges, "api-changes", false, "Add an \"API changes\" paragraph listing the exported Go declarations added, removed or redefined")
+	flag.BoolVar(&o.verify, "verify", false, "Have the summarizer check the final message against the diff and correct claims the diff doesn't support")
+	flag.BoolVar(&o.speculate, "speculate", false, "Start the style pass on the summary's first lines while the summarizer writes the rest, for lower latency when Ollama runs both models at once")
+	flag.BoolVar(&o.todos, "todos", false, "List the TODO, FIXME and XXX comments the diff adds in the message body")
+	flag.BoolVar(&o.semverTrailer, "semver-trailer", false, "End the message with a Semver-Impact: none|patch|minor|major trailer inferred from the type, breaking changes and exported Go API changes")
+	flag.StringVar(&o.profileFile, "profile", "", "Style profile written by 'commit-writer learn' and followed when generating (default: "+profile.File+" in the repository)")
+	flag.BoolVar(&o.noProfile, "no-profile", false, "Ignore the repository's style profile")
+	flag.BoolVar(&o.noHistory, "no-history", false, "Don't record the generated message in the history read by 'commit-writer last' and 'commit-writer history'")
+	flag.BoolVar(&o.checkUpdate, "check", false, "With 'commit-writer update', only report whether a newer release is available")
+	flag.IntVar(&o.historyLimit, "limit", 20, "With 'commit-writer history', how many messages to list (0 for all); with 'eval', how many recent commits to score")
+	flag.BoolVar(&o.strict, "strict", false, "Reject generated messages that break the style profile's conventions")
+	flag.StringVar(&o.webhookURL, "webhook", os.Getenv("COMMIT_WRITER_WEBHOOK"), "Post the repository, branch and generated message to this Slack-compatible webhook")
+	flag.BoolVar(&o.noANSI, "no-ansi", false, "Strip escape sequences and control characters from everything printed, and ask plugins and hooks for no color via NO_COLOR (default with NO_COLOR or TERM=dumb)")
+	flag.BoolVar(&o.linearOutput, "linear-output", false, "Print line by line, never side by side, for screen readers and logs (default with TERM=dumb)")
+	flag.BoolVar(&o.traceSpans, "trace", false, "Print where the run spent its time, as a tree of spans, to stderr")
+	flag.StringVar(&o.otlpEndpoint, "otlp-endpoint", trace.EnvEndpoint(), "Export spans to this OTLP/HTTP traces URL, e.g. http://localhost:4318/v1/traces (default from OTEL_EXPORTER_OTLP_TRACES_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT)")
+	flag.StringVar(&o.uiLang, "ui-lang", "", "Language of commit-writer's own messages, e.g. 'de' (default: from LC_ALL, LC_MESSAGES or LANG; languages without a catalog fall back to English)")
+	_ = flag.CommandLine.Parse(args)
+	o.explicit = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { o.explicit[f.Name] = true })
+	return subcommand, o
+}
+
+// check reports the first flag that does not apply to subcommand, or to
+// repo's version control system, or that conflicts with another flag.
+func (o *options) check(subcommand string, repo vcs.VCS) error {
+	if o.doCommit && o.hookFile != "" {
+		return errors.New("--commit cannot be combined with --hook; the hook already runs inside git commit")
+	}
+	if o.describe && (repo.Name() != "jj" || o.hookFile != "" || o.doCommit || subcommand != "" && subcommand != "refine" || o.jsonrpcMode || o.reposList != "" || o.compareList != "") {
+		return errors.New("--describe only applies to generating a single message in a jj repository, without --hook or --commit")
+	}
+	if repo.Name() != "git" {
+		switch {
+		case subcommand == "pr" || subcommand == "ci" || subcommand == "changelog" || subcommand == "split" || subcommand == "learn" || subcommand == "eval" || subcommand == "watch" || subcommand == "stats" || subcommand == "explain" || subcommand == "standup":
+			return fmt.Errorf("%s only works in git repositories", subcommand)
+		case o.sign || o.signKey != "" || o.reposList != "" || o.ticketLookup:
+			return errors.New("--sign, --sign-key, --repos and --ticket only work in git repositories")
+		}
+	}
+	if (o.sign || o.signKey != "") && !o.doCommit && !o.splitOpts.Apply {
+		return errors.New("--sign and --sign-key require --commit or 'split --apply'")
+	}
+	if (subcommand == "serve" || o.jsonrpcMode) && (o.hookFile != "" || o.doCommit || o.loadSummary != "" || o.saveSummary != "" || o.ticketLookup) {
+		return errors.New("serve and --jsonrpc cannot be combined with --hook, --commit, --load-summary, --save-summary or --ticket")
+	}
+	if subcommand != "" && subcommand != "serve" && subcommand != "refine" && (o.hookFile != "" || o.doCommit || o.jsonrpcMode) {
+		return fmt.Errorf("%s cannot be combined with --hook, --commit or --jsonrpc", subcommand)
+	}
+	if subcommand == "split" && (o.loadSummary != "" || o.saveSummary != "") {
+		return errors.New("split cannot be combined with --load-summary or --save-summary; each suggested commit gets its own summary")
+	}
+	if (o.pr.Create || o.pr.Draft || o.pr.Forge != "" || o.pr.Template != "") && subcommand != "pr" {
+		return errors.New("--create, --draft, --forge and --template only apply to 'commit-writer pr'")
+	}
+	if o.pr.Base != "" && subcommand != "pr" && subcommand != "ci" {
+		return errors.New("--base only applies to 'commit-writer pr' and 'commit-writer ci'")
+	}
+	if o.ciOpts != (ciOptions{}) && subcommand != "ci" {
+		return errors.New("--squash and --junit only apply to 'commit-writer ci'")
+	}
+	if o.listenAddr != "" && subcommand != "serve" && subcommand != "watch" {
+		return errors.New("--listen only applies to 'commit-writer serve' and 'commit-writer watch'")
+	}
+	if (o.watchOpts.Draft != "" || o.debounceSecs != 3) && subcommand != "watch" {
+		return errors.New("--draft-file and --debounce only apply to 'commit-writer watch'")
+	}
+	if o.debounceSecs < 0 {
+		return errors.New("--debounce must be 0 or more")
+	}
+	if subcommand == "watch" && (o.loadSummary != "" || o.saveSummary != "") {
+		return errors.New("watch summarizes every change; it cannot be combined with --load-summary or --save-summary")
+	}
+	if o.splitOpts.Apply && subcommand != "split" {
+		return errors.New("--apply only applies to 'commit-writer split'")
+	}
+	if o.revRange != "" && subcommand != "ci" && subcommand != "changelog" && subcommand != "learn" && subcommand != "eval" {
+		return errors.New("--range only applies to 'commit-writer ci', 'changelog', 'learn' and 'eval'")
+	}
+	if o.historyLimit != 20 && subcommand != "history" && subcommand != "eval" {
+		return errors.New("--limit only applies to 'commit-writer history' and 'commit-writer eval'")
+	}
+	if o.changelogFormat != "markdown" && subcommand != "changelog" && subcommand != "eval" {
+		return errors.New("--format only applies to 'commit-writer changelog' and 'commit-writer eval'")
+	}
+	if subcommand == "eval" && o.changelogFormat != "markdown" && o.changelogFormat != "json" {
+		return fmt.Errorf("unknown eval format %q (want markdown or json)", o.changelogFormat)
+	}
+	if o.since != "yesterday" && subcommand != "standup" {
+		return errors.New("--since only applies to 'commit-writer standup'")
+	}
+	if o.releaseTag != "" && (subcommand != "changelog" || o.changelogFormat != "release-notes") {
+		return errors.New("--release-tag only applies to 'commit-writer changelog --format release-notes'")
+	}
+	if o.judgeModel != "" && subcommand != "eval" {
+		return errors.New("--judge-model only applies to 'commit-writer eval'")
+	}
+	if subcommand == "eval" && (o.loadSummary != "" || o.saveSummary != "") {
+		return errors.New("eval generates a summary for every change; it cannot be combined with --load-summary or --save-summary")
+	}
+	if (subcommand == "explain" || subcommand == "review" || subcommand == "standup") && (o.loadSummary != "" || o.saveSummary != "") {
+		return fmt.Errorf("%s reads the diff itself; it cannot be combined with --load-summary or --save-summary", subcommand)
+	}
+	if subcommand == "refine" && (o.loadSummary != "" || o.why != "" || o.contextFile != "") {
+		return errors.New("refine reuses the latest message's summary; it cannot be combined with --load-summary, --why or --context-file")
+	}
+	if o.webhookURL != "" && (subcommand != "" && subcommand != "refine" || o.jsonrpcMode) {
+		return errors.New("--webhook only applies to generating a commit message, not to subcommands or --jsonrpc")
+	}
+	if o.candidates < 1 {
+		return errors.New("-n must be at least 1")
+	}
+	if o.candidates > 1 && (subcommand != "" && subcommand != "refine" || o.jsonrpcMode || o.reposList != "" || o.compareList != "" || o.porcelain) {
+		return errors.New("-n only applies to generating a single commit message, not to subcommands, --jsonrpc, --repos, --compare or --porcelain")
+	}
+	if o.porcelain && (subcommand != "" && subcommand != "refine" || o.jsonrpcMode) {
+		return errors.New("--porcelain only applies to generating a commit message, not to subcommands or --jsonrpc")
+	}
+	if (o.copyMsg || o.notifyDone) && (subcommand != "" && subcommand != "refine" || o.jsonrpcMode || o.reposList != "" || o.compareList != "") {
+		return errors.New("--copy and --notify only apply to generating a single commit message, not to subcommands, --jsonrpc, --repos or --compare")
+	}
+	if o.askMode && (subcommand != "" || o.jsonrpcMode) {
+		return errors.New("--ask only applies to generating a commit message on a terminal, not to subcommands or --jsonrpc")
+	}
+	if o.askMode && o.loadSummary != "" {
+		return errors.New("--ask cannot be combined with --load-summary; the answers go to the summarizer")
+	}
+	if o.fixupLast && (o.noFixup || subcommand != "" || o.jsonrpcMode || o.reposList != "" || o.compareList != "" || o.candidates > 1 || o.pickHunks) {
+		return errors.New("--fixup only applies to a single commit message; it cannot be combined with --no-fixup, subcommands, --jsonrpc, --repos, --compare, -n or --interactive-scope")
+	}
+	if o.pickHunks && (subcommand != "" || o.jsonrpcMode || o.reposList != "" || o.loadSummary != "") {
+		return errors.New("--interactive-scope only applies to generating a commit message from the diff, not to subcommands, --jsonrpc, --repos or --load-summary")
+	}
+	if o.compareList != "" && (subcommand != "" || o.jsonrpcMode || o.hookFile != "" || o.doCommit || o.saveSummary != "" || o.askMode) {
+		return errors.New("--compare only prints messages; it cannot be combined with subcommands, --jsonrpc, --hook, --commit, --save-summary or --ask")
+	}
+	if o.reposList != "" && (subcommand != "" || o.jsonrpcMode || o.hookFile != "" || o.compareList != "" || o.loadSummary != "" || o.saveSummary != "" || o.porcelain || o.ticketLookup) {
+		return errors.New("--repos cannot be combined with subcommands, --jsonrpc, --hook, --compare, --load-summary, --save-summary, --porcelain or --ticket")
+	}
+	if o.seed < -1 {
+		return errors.New("--seed must be 0 or more")
+	}
+	if o.maxQuestions < 1 {
+		return errors.New("--max-questions must be at least 1")
+	}
+	if subcommand == "serve" && o.jsonrpcMode {
+		return errors.New("serve and --jsonrpc cannot be combined")
+	}
+	if o.recordPath != "" && o.replayPath != "" {
+		return errors.New("--record and --replay cannot be combined")
+	}
+	if (o.why != "" || o.contextFile != "") && o.loadSummary != "" {
+		return errors.New("--why and --context-file cannot be combined with --load-summary; the reason goes to the summarizer")
+	}
+	switch {
+	case o.minBodyLines < 0 || o.maxBodyLines < 0:
+		return errors.New("--min-body-lines and --max-body-lines must not be negative")
+	case o.maxBodyLines > 0 && o.minBodyLines > o.maxBodyLines:
+		return errors.New("--min-body-lines must not be more than --max-body-lines")
+	case o.titleOnly && o.minBodyLines > 0:
+		return errors.New("--min-body-lines cannot be used with --title-only")
+	}
+	if o.maxPromptTokens < 0 || o.maxOutputTokens < 0 {
+		return errors.New("--max-prompt-tokens and --max-output-tokens must not be negative")
+	}
+	return nil
+}
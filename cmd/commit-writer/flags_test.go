@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/tier"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+func TestParseArgsAndCheck(t *testing.T) {
+	// parseArgs registers the flags on flag.CommandLine, so it runs once.
+	subcommand, defaults := parseArgs([]string{"standup", "--since", "monday", "--summ-model", "gemma3:4B"})
+	if subcommand != "standup" || defaults.since != "monday" || defaults.candidates != 1 {
+		t.Fatalf("parseArgs = %q, %+v", subcommand, defaults)
+	}
+	// A flag given its default value still counts as given.
+	if !defaults.explicit["summ-model"] || !defaults.explicit["since"] || defaults.explicit["style-model"] || defaults.explicit["tone"] {
+		t.Errorf("explicit = %v, want since and summ-model", defaults.explicit)
+	}
+	if err := defaults.check(subcommand, vcs.Git{}); err != nil {
+		t.Errorf("check(standup --since) = %v", err)
+	}
+	for _, tc := range []struct {
+		subcommand string
+		set        func(o *options)
+		want       string
+	}{
+		{"", func(o *options) {}, "--since only applies"},
+		{"standup", func(o *options) { o.loadSummary = "s.txt" }, "standup reads the diff itself"},
+		{"", func(o *options) { o.since = "yesterday"; o.doCommit, o.hookFile = true, "MSG" }, "--commit cannot be combined with --hook"},
+		{"", func(o *options) { o.since = "yesterday"; o.minBodyLines, o.maxBodyLines = 3, 1 }, "--min-body-lines must not be more"},
+		{"serve", func(o *options) { o.since = "yesterday"; o.jsonrpcMode = true }, "serve and --jsonrpc"},
+	} {
+		o := *defaults
+		tc.set(&o)
+		if err := o.check(tc.subcommand, vcs.Git{}); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("check(%q) = %v, want %q", tc.subcommand, err, tc.want)
+		}
+	}
+}
+
+func TestGeneratorConfigModelTiers(t *testing.T) {
+	cfg := config.Config{ModelTiers: tier.Tiers{{MaxLines: 50, SummarizerModel: "qwen2.5:0.5b"}, {SummarizerModel: "qwen2.5:14b"}}}
+	for _, tc := range []struct {
+		explicit map[string]bool
+		want     bool
+	}{
+		{nil, true},
+		{map[string]bool{"tone": true}, true},
+		// Naming the configured default model still picks it over the tiers.
+		{map[string]bool{"summ-model": true}, false},
+		{map[string]bool{"style-model": true}, false},
+	} {
+		o := &options{summarizerModel: "gemma3:4B", styleModel: "mistral:7b", explicit: tc.explicit}
+		if got := o.generatorConfig(cfg, generation{}).ModelTiers != nil; got != tc.want {
+			t.Errorf("explicit %v: tiers kept = %v, want %v", tc.explicit, got, tc.want)
+		}
+	}
+}
+
+func TestUsePersona(t *testing.T) {
+	half := 0.5
+	p := config.Persona{Tone: "dry", Intensity: &half, Quirks: []string{"no emoji"}}
+
+	o := &options{tone: defaultTone, intensity: 1}
+	if _, quirks := o.usePersona(p); o.tone != "dry" || o.intensity != 0.5 || len(quirks) != 1 {
+		t.Errorf("without flags: tone %q, intensity %v, quirks %q", o.tone, o.intensity, quirks)
+	}
+	// The default values given explicitly still win over the persona's.
+	o = &options{tone: defaultTone, intensity: 1, explicit: map[string]bool{"tone": true, "intensity": true}}
+	if o.usePersona(p); o.tone != defaultTone || o.intensity != 1 {
+		t.Errorf("with --tone and --intensity: tone %q, intensity %v", o.tone, o.intensity)
+	}
+}
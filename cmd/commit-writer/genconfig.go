@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/deps"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/notify"
+	"github.com/kylegalloway/commit-writer/pkg/plugin"
+	"github.com/kylegalloway/commit-writer/pkg/profile"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+	"github.com/kylegalloway/commit-writer/pkg/redact"
+	"github.com/kylegalloway/commit-writer/pkg/trace"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// generation is what main works out before generating that the options
+// and config file don't hold directly: keys, clients, loaded files and the
+// repository.
+type generation struct {
+	APIKey       string
+	Client       llm.Client
+	Timeout      time.Duration
+	DenyPaths    []string
+	Anonymizer   *redact.Anonymizer
+	ReleaseNotes *deps.ReleaseNotes
+	Ticket       string
+	Footer       []string
+	Profile      *profile.Profile
+	Examples     []string
+	Quirks       []string
+	Summary      string
+	Feedback     string
+	Previous     string
+	Params       prompt.StageParams
+	VCS          vcs.VCS
+	Status       func(string, ...interface{})
+	Warn         func(string, ...interface{})
+}
+
+// generatorConfig assembles the generator config from the options, the
+// config file and g.
+func (o *options) generatorConfig(cfg config.Config, g generation) generator.Config {
+	var seed *int
+	if o.seed >= 0 {
+		seed = &o.seed
+	} else if o.deterministic {
+		fixed := 42
+		seed = &fixed
+	}
+	// Models named on the command line win over the configured tiers.
+	modelTiers := cfg.ModelTiers
+	if o.explicit["summ-model"] || o.explicit["style-model"] {
+		modelTiers = nil
+	}
+	return generator.Config{
+		URL:             o.ollamaURL,
+		APIKey:          g.APIKey,
+		Client:          g.Client,
+		SummarizerModel: o.summarizerModel,
+		StyleModel:      o.styleModel,
+		Tone:            o.tone,
+		Intensity:       &o.intensity,
+		TitleOnly:       o.titleOnly,
+		Timeout:         g.Timeout,
+		LocalOnly:       o.localOnly,
+		NoFallback:      o.noFallback,
+		NoRedact:        o.noRedact,
+		DenyPaths:       g.DenyPaths,
+		Anonymizer:      g.Anonymizer,
+		AuditLog:        o.auditPath,
+		NoClassify:      o.noClassify,
+		NoRelated:       o.noRelated,
+		AllowDuplicates: o.allowDuplicates,
+		NoRefCheck:      o.noRefCheck,
+		StyleDocs:       o.styleDocs,
+		ReleaseNotes:    g.ReleaseNotes,
+		GoSemantic:      o.goSemantic || cfg.GoSemantic,
+		Risk:            o.riskNote || cfg.Risk.Enabled,
+		RiskRules:       cfg.Risk.Rules,
+		Security:        o.securityNote || cfg.Security.Enabled,
+		SecurityRules:   cfg.Security.Rules,
+		APIChanges:      o.apiChanges || cfg.APIChanges,
+		Todos:           o.todos || cfg.Todos,
+		Verify:          o.verify || cfg.Verify,
+		Speculate:       o.speculate,
+		Tracer:          tracer,
+		SemverTrailer:   o.semverTrailer || cfg.SemverTrailer,
+		Ticket:          g.Ticket,
+		Why:             o.why,
+		MaxQuestions:    o.maxQuestions,
+		Footer:          g.Footer,
+		Profile:         g.Profile,
+		StyleExamples:   g.Examples,
+		Quirks:          g.Quirks,
+		Summary:         g.Summary,
+		SaveSummary:     o.saveSummary,
+		Feedback:        g.Feedback,
+		Previous:        g.Previous,
+		Pipeline:        cfg.Pipeline,
+		Middleware:      cfg.Middleware,
+		Clean:           cfg.Clean,
+		Rules:           cfg.Rules,
+		ModelTiers:      modelTiers,
+		Models:          cfg.Models,
+		Params:          g.Params,
+		Seed:            seed,
+		Deterministic:   o.deterministic,
+		MaxPromptTokens: o.maxPromptTokens,
+		MaxOutputTokens: o.maxOutputTokens,
+		MinBodyLines:    o.minBodyLines,
+		MaxBodyLines:    o.maxBodyLines,
+		VCS:             g.VCS,
+		Status:          g.Status,
+		Warn:            g.Warn,
+		Debug:           o.debug,
+	}
+}
+
+// settle fills in what the command line left to the config file and lets
+// the policy override both. It returns the deny paths and, for --repos, the
+// settings as they were before the policy.
+func (o *options) settle(subcommand string, cfg config.Config, policy config.Policy) (denyPaths []string, perRepo *repoPolicy) {
+	if o.auditPath == "" {
+		o.auditPath = cfg.AuditLog
+	}
+	perRepo = &repoPolicy{Policy: policy, DenyPaths: cfg.DenyPaths, LocalOnly: o.localOnly || cfg.LocalOnly, AuditLog: o.auditPath, NoRedact: o.noRedact}
+	denyPaths = append(append([]string{}, cfg.DenyPaths...), policy.DenyPaths...)
+	o.localOnly = o.localOnly || cfg.LocalOnly || policy.LocalOnly
+	if policy.AuditLog != "" {
+		o.auditPath = policy.AuditLog
+	}
+	if o.noRedact && policy.Redact {
+		fmt.Fprintln(os.Stderr, "Warning: --no-redact ignored; redaction is required by policy")
+		o.noRedact = false
+	}
+	if o.provider == "" {
+		o.provider = cfg.Provider
+	}
+	if o.pr.Forge == "" {
+		o.pr.Forge = cfg.Forge
+	}
+	if o.provider == "" {
+		o.provider = "ollama"
+	}
+	if o.webhookURL == "" && (subcommand == "" || subcommand == "refine") && !o.jsonrpcMode {
+		o.webhookURL = cfg.Webhook
+	}
+	o.postPlugins = append(append(stringList{}, cfg.PostProcessors...), o.postPlugins...)
+	o.validators = append(append(stringList{}, cfg.Validators...), o.validators...)
+	return denyPaths, perRepo
+}
+
+// usePersona takes the persona's tone and intensity, unless --tone or
+// --intensity was given, even with its default value, and returns its
+// examples and quirks.
+func (o *options) usePersona(p config.Persona) (examples, quirks []string) {
+	if p.Tone != "" && !o.explicit["tone"] {
+		o.tone = p.Tone
+	}
+	if p.Intensity != nil && !o.explicit["intensity"] {
+		o.intensity = *p.Intensity
+	}
+	return p.Examples, p.Quirks
+}
+
+// stageParams merges the config's per-stage parameters with --param, which
+// overrides them one field at a time.
+func (o *options) stageParams(cfg config.Config) (prompt.StageParams, error) {
+	flagParams := prompt.StageParams{}
+	for _, p := range o.paramFlags {
+		if err := flagParams.Set(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := flagParams.Validate(cfg.Stages()); err != nil {
+		return nil, err
+	}
+	params := prompt.StageParams{}
+	for name, p := range cfg.Params {
+		params[name] = p
+	}
+	for name, p := range flagParams {
+		params[name] = params[name].Merge(p)
+	}
+	return params, nil
+}
+
+// readInputs reads the files the options name: --context is added to the
+// author's --why, --style-examples to examples and --load-summary is
+// returned in place of summarizing the diff.
+func (o *options) readInputs(examples []string, statusf func(string, ...interface{})) ([]string, string, error) {
+	if o.contextFile != "" {
+		data, err := os.ReadFile(o.contextFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("Error reading context file: %v", err)
+		}
+		o.why = strings.TrimSpace(o.why + "\n\n" + string(data))
+	}
+	if o.styleExamples != "" {
+		data, err := os.ReadFile(o.styleExamples)
+		if err != nil {
+			return nil, "", fmt.Errorf("Error reading style examples: %v", err)
+		}
+		more := prompt.ParseExamples(string(data))
+		if len(more) == 0 {
+			return nil, "", fmt.Errorf("%s has no example messages", o.styleExamples)
+		}
+		statusf("Loaded %d style example(s) from %s", len(more), o.styleExamples)
+		examples = append(append([]string{}, examples...), more...)
+	}
+	var summary string
+	if o.loadSummary != "" {
+		statusf("Loading summary from %s", o.loadSummary)
+		data, err := os.ReadFile(o.loadSummary)
+		if err != nil {
+			if o.debug {
+				log.Printf("readfile error: %v", err)
+			}
+			return nil, "", fmt.Errorf("Error reading summary file: %v", err)
+		}
+		summary = string(data)
+		statusf("Summary loaded (%d bytes)", len(summary))
+	}
+	return examples, summary, nil
+}
+
+// modelClient returns the client for a --provider plugin, wrapped in a
+// cassette for --record or --replay, or nil for the built-in Ollama client.
+func (o *options) modelClient(apiKey string, timeout time.Duration, statusf func(string, ...interface{})) (llm.Client, error) {
+	var client llm.Client
+	if o.provider != "ollama" {
+		path, err := plugin.Find(o.provider)
+		if err != nil {
+			return nil, err
+		}
+		statusf("Using provider plugin %s", path)
+		client = &plugin.Provider{Path: path, Timeout: timeout}
+	}
+	if o.recordPath == "" && o.replayPath == "" {
+		return client, nil
+	}
+	var live llm.Client
+	path := o.replayPath
+	if o.recordPath != "" {
+		live = client
+		if live == nil {
+			live = &llm.Ollama{URL: o.ollamaURL, APIKey: apiKey, Timeout: timeout, LoopbackOnly: o.localOnly}
+		}
+		path = o.recordPath
+	}
+	cassette, err := llm.OpenCassette(path, live)
+	if err != nil {
+		return nil, err
+	}
+	if live == nil {
+		statusf("Replaying model responses from %s", path)
+	} else {
+		statusf("Recording model responses to %s", path)
+	}
+	return cassette, nil
+}
+
+// services sets up the tracer, the webhook and the dependency release
+// notes the options ask for. It returns an exit code when local-only mode
+// or the policy forbids what they would send.
+func (o *options) services(policy config.Policy) (webhook *notify.Webhook, releaseNotes *deps.ReleaseNotes, code int) {
+	if o.localOnly && o.provider != "ollama" {
+		fmt.Fprintf(os.Stderr, "local-only: provider plugin %q may send data off this machine; only the built-in ollama provider is allowed\n", o.provider)
+		return nil, nil, 9
+	}
+	if o.traceSpans || o.otlpEndpoint != "" {
+		tracer = &trace.Tracer{Endpoint: o.otlpEndpoint}
+		if o.traceSpans {
+			tracer.Report = os.Stderr
+		}
+		if o.localOnly && o.otlpEndpoint != "" {
+			if err := llm.CheckLoopback(o.otlpEndpoint); err != nil {
+				fmt.Fprintf(os.Stderr, "--otlp-endpoint: %v\n", err)
+				return nil, nil, 9
+			}
+			tracer.Client = llm.LoopbackClient(10 * time.Second)
+		}
+		// Long-running modes export as they go; every exit sends the rest.
+		go tracer.FlushEvery(context.Background(), 5*time.Second, func(err error) {
+			fmt.Fprintf(os.Stderr, "%s%v\n", ui.T("Warning: "), err)
+		})
+	}
+	if o.webhookURL != "" {
+		webhook = &notify.Webhook{URL: o.webhookURL}
+		if o.localOnly {
+			if err := llm.CheckLoopback(o.webhookURL); err != nil {
+				fmt.Fprintf(os.Stderr, "--webhook: %v\n", err)
+				return nil, nil, 9
+			}
+			webhook.Client = llm.LoopbackClient(10 * time.Second)
+		}
+	}
+	if o.depNotes {
+		if o.localOnly {
+			fmt.Fprintf(os.Stderr, "--dep-notes: local-only: release notes are fetched from %s\n", deps.GitHubAPI)
+			return nil, nil, 9
+		}
+		releaseNotes = &deps.ReleaseNotes{Token: os.Getenv("GITHUB_TOKEN")}
+	}
+	for _, check := range []error{policy.CheckProvider(o.provider), policy.CheckTone(o.tone)} {
+		if check != nil {
+			fmt.Fprintln(os.Stderr, check)
+			return nil, nil, 11
+		}
+	}
+	return webhook, releaseNotes, 0
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/history"
+)
+
+// runLast prints the latest message generated in the current repository,
+// e.g. to reuse it after an aborted commit. It returns the exit code.
+func runLast(log *history.Log, root string) int {
+	entries, code := repoHistory(log, root)
+	if code != 0 {
+		return code
+	}
+	fmt.Println(entries[len(entries)-1].Message)
+	return 0
+}
+
+// runHistory lists the messages generated in the current repository,
+// newest first and at most limit of them unless limit is 0. It returns the
+// exit code.
+func runHistory(log *history.Log, root string, limit int) int {
+	entries, code := repoHistory(log, root)
+	if code != 0 {
+		return code
+	}
+	for i, n := len(entries)-1, 0; i >= 0 && (limit == 0 || n < limit); i, n = i-1, n+1 {
+		fmt.Println(historyLine(entries[i]))
+	}
+	return 0
+}
+
+// refineBase returns the latest message generated in the current
+// repository for "commit-writer refine", which needs its summary. Problems
+// are reported as exit code 2.
+func refineBase(log *history.Log, root string) (history.Entry, int) {
+	entries, code := repoHistory(log, root)
+	if code != 0 {
+		return history.Entry{}, code
+	}
+	last := entries[len(entries)-1]
+	if last.Summary == "" {
+		fmt.Fprintln(os.Stderr, "the latest message has no saved summary to refine (it was written offline or by an older version); generate a new one")
+		return history.Entry{}, 2
+	}
+	return last, 0
+}
+
+// repoHistory reads the entries of the repository at root, the current
+// one, reporting a missing repository or an empty history as exit code 2.
+func repoHistory(log *history.Log, root string) ([]history.Entry, int) {
+	if root == "" {
+		fmt.Fprintln(os.Stderr, "not in a repository")
+		return nil, 2
+	}
+	entries, err := log.Entries(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read history: %v\n", err)
+		return nil, 2
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "no messages generated in %s yet\n", root)
+		return nil, 2
+	}
+	return entries, 0
+}
+
+// historyLine formats an entry for "commit-writer history", e.g.
+// "2024-05-01 14:03  main  committed 3f2a9c1  Add coupons to the cart".
+func historyLine(e history.Entry) string {
+	when := e.Time
+	if t, err := time.Parse(time.RFC3339, e.Time); err == nil {
+		when = t.Local().Format("2006-01-02 15:04")
+	}
+	state := "suggested"
+	if e.Accepted {
+		state = "committed"
+		if len(e.Commit) >= 7 {
+			state += " " + e.Commit[:7]
+		}
+	}
+	branch := e.Branch
+	if branch == "" {
+		branch = "-"
+	}
+	subject := strings.SplitN(strings.TrimSpace(e.Message), "\n", 2)[0]
+	return fmt.Sprintf("%s  %s  %s  %s", when, branch, state, subject)
+}
+
+// statsCommits bounds how many commits "commit-writer stats" reads.
+const statsCommits = 5000
+
+// runStats reports, per model and tone, how many of the current
+// repository's suggestions were committed unchanged, edited or discarded.
+// It returns the exit code.
+func runStats(log *history.Log, root string) int {
+	entries, code := repoHistory(log, root)
+	if code != 0 {
+		return code
+	}
+	commits, err := gitdiff.Recent("HEAD", statsCommits)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	// Match wants the oldest first.
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tTONE\tSUGGESTED\tUNCHANGED\tEDITED\tDISCARDED\tWORDS KEPT")
+	for _, s := range history.Summarize(history.Match(entries, commits)) {
+		tone := s.Tone
+		if tone == "" {
+			tone = "-"
+		}
+		kept := "-"
+		if s.Edited > 0 {
+			kept = percent(s.Kept)
+		}
+		n := float64(s.Suggested)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", s.Model, tone, s.Suggested,
+			percent(float64(s.Unchanged)/n), percent(float64(s.Edited)/n), percent(float64(s.Discarded)/n), kept)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+func percent(f float64) string {
+	return fmt.Sprintf("%.0f%%", f*100)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/history"
+)
+
+func TestHistoryLine(t *testing.T) {
+	e := history.Entry{Time: "not a time", Branch: "main", Message: "Add coupons\n\nThey apply before tax.", Accepted: true, Commit: "3f2a9c1d0e"}
+	if got, want := historyLine(e), "not a time  main  committed 3f2a9c1  Add coupons"; got != want {
+		t.Errorf("historyLine = %q, want %q", got, want)
+	}
+	e = history.Entry{Time: "x", Message: "Fix totals"}
+	if got, want := historyLine(e), "x  -  suggested  Fix totals"; got != want {
+		t.Errorf("historyLine = %q, want %q", got, want)
+	}
+}
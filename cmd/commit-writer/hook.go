@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// backupSuffix names the copy writeHook keeps of the file it changes, e.g.
+// COMMIT_EDITMSG.cw.bak.
+const backupSuffix = ".cw.bak"
+
+// writeHook writes msg to the commit message file at path. An existing file
+// gets the message appended as a suggestion, under a line starting with
+// comment, unless force is set, in which case it is overwritten; either way
+// its previous content is first saved next to it for "commit-writer undo".
+// On failure it returns the process exit code to use.
+func writeHook(path, msg, comment string, force bool, statusf func(string, ...interface{})) (int, error) {
+	old, readErr := os.ReadFile(path)
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return 5, fmt.Errorf("failed to read hook file: %w", readErr)
+	}
+	exists := readErr == nil
+	nl := newline(old)
+	msg = strings.ReplaceAll(strings.ReplaceAll(msg, "\r\n", "\n"), "\n", nl)
+	switch {
+	case force:
+		statusf("Writing suggested message to %s (overwrite)", path)
+	case exists:
+		statusf("Appending suggested message to %s", path)
+	default:
+		statusf("Writing suggested message to %s", path)
+	}
+
+	// A backup left from an earlier write would otherwise be restored over
+	// this file.
+	backup := path + backupSuffix
+	if exists {
+		if err := os.WriteFile(backup, old, 0644); err != nil {
+			return 5, fmt.Errorf("failed to back up hook file: %w", err)
+		}
+	} else if err := os.Remove(backup); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 5, fmt.Errorf("failed to remove old hook file backup: %w", err)
+	}
+
+	if exists && !force {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return 5, fmt.Errorf("failed to open hook file for append: %w", err)
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil {
+				log.Printf("warning: failed to close hook file: %v", cerr)
+			}
+		}()
+		if _, err := f.WriteString(nl + comment + " Suggested commit message (auto-generated):" + nl + msg + nl); err != nil {
+			return 6, fmt.Errorf("failed to write to hook file: %w", err)
+		}
+		return 0, nil
+	}
+	if err := os.WriteFile(path, []byte(msg+nl), 0644); err != nil {
+		return 7, fmt.Errorf("failed to write hook file: %w", err)
+	}
+	return 0, nil
+}
+
+// newline returns the line ending of a hook file's content: CRLF when it
+// has any, as a Windows editor or core.autocrlf may leave it, so what is
+// written does not mix line endings, else LF.
+func newline(data []byte) string {
+	if bytes.Contains(data, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// hookMarker identifies a hook runInstallHook wrote, which it may replace.
+const hookMarker = "# Installed by commit-writer install-hook."
+
+// runInstallHook writes a prepare-commit-msg hook that runs this binary
+// with --hook, --fail-soft and args into the repository's hooks directory
+// (core.hooksPath, if set). The hook is a POSIX sh script with LF line
+// endings, which Git for Windows runs with its own sh, and it calls the
+// binary by absolute path, so it works without commit-writer on PATH. A
+// hook it did not write is only replaced with force, after a backup. It
+// returns the exit code.
+func runInstallHook(args []string, force bool, statusf func(string, ...interface{})) int {
+	dir := gitdiff.GitPath("hooks")
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "install-hook: not in a git repository")
+		return 2
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "install-hook: cannot find the running binary: %v\n", err)
+		return 1
+	}
+	path := filepath.Join(dir, "prepare-commit-msg")
+	if old, err := os.ReadFile(path); err == nil && !strings.Contains(string(old), hookMarker) {
+		if !force {
+			fmt.Fprintf(os.Stderr, "%s already exists; pass --force to replace it (it is kept as %s)\n", path, path+backupSuffix)
+			return 2
+		}
+		if err := os.WriteFile(path+backupSuffix, old, 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "install-hook: failed to back up %s: %v\n", path, err)
+			return 7
+		}
+		statusf("Saved the previous hook as %s", path+backupSuffix)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "install-hook: %v\n", err)
+		return 7
+	}
+	if err := os.WriteFile(path, []byte(hookScript(exe, args)), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "install-hook: %v\n", err)
+		return 7
+	}
+	// WriteFile leaves the mode of an existing file alone.
+	if err := os.Chmod(path, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "install-hook: %v\n", err)
+		return 7
+	}
+	statusf("Installed %s", path)
+	return 0
+}
+
+// hookScript is the prepare-commit-msg hook running exe with args. Windows
+// paths get forward slashes, which Git for Windows' sh takes as they are.
+func hookScript(exe string, args []string) string {
+	cmd := []string{shellQuote(filepath.ToSlash(exe)), `--hook "$1"`, "--fail-soft"}
+	for _, a := range args {
+		cmd = append(cmd, shellQuote(a))
+	}
+	return "#!/bin/sh\n" + hookMarker + "\n# $1 is the commit message file.\nexec " + strings.Join(cmd, " ") + "\n"
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// openEditor opens path in $VISUAL or $EDITOR and returns the editor's
+// exit code, like the editor a VCS runs for the commit message.
+func openEditor(path string) int {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		if editor == "" {
+			editor = "notepad"
+		}
+		cmd = exec.Command("cmd", "/c", editor+` "`+path+`"`)
+	} else {
+		if editor == "" {
+			editor = "vi"
+		}
+		// Through the shell, so an editor with arguments ("code --wait")
+		// works as it does for git and hg.
+		cmd = exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			return exitErr.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "failed to run editor %q: %v\n", editor, err)
+		return 1
+	}
+	return 0
+}
+
+// runUndo restores the hook file named in args, by default the
+// repository's COMMIT_EDITMSG, from the backup writeHook saved, consuming
+// the backup. It returns the exit code: 2 when there is nothing to restore
+// and 7 when restoring fails.
+func runUndo(args []string, statusf func(string, ...interface{})) int {
+	if len(args) > 1 {
+		fmt.Fprintln(os.Stderr, "usage: commit-writer undo [hook file]")
+		return 2
+	}
+	path := gitdiff.GitPath("COMMIT_EDITMSG")
+	if len(args) == 1 {
+		path = args[0]
+	}
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "not in a git repository; name the hook file to restore")
+		return 2
+	}
+	backup := path + backupSuffix
+	if _, err := os.Stat(backup); err != nil {
+		fmt.Fprintf(os.Stderr, "no backup of %s to restore\n", path)
+		return 2
+	}
+	statusf("Restoring %s from %s", path, backup)
+	if err := os.Rename(backup, path); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to restore hook file: %v\n", err)
+		return 7
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteHook(t *testing.T) {
+	nop := func(string, ...interface{}) {}
+	tests := []struct {
+		name     string
+		existing string
+		force    bool
+		want     string
+	}{
+		{"new file", "", false, "Add thing\n"},
+		{"append to template", "# comment\n", false, "# comment\n\n# Suggested commit message (auto-generated):\nAdd thing\n"},
+		{"force overwrite", "# comment\n", true, "Add thing\n"},
+		{"CRLF template", "# comment\r\n", false, "# comment\r\n\r\n# Suggested commit message (auto-generated):\r\nAdd thing\r\n\r\nIt was missing.\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			msg := "Add thing"
+			if strings.Contains(tt.existing, "\r") {
+				msg += "\n\nIt was missing."
+			}
+			if code, err := writeHook(path, msg, "#", tt.force, nop); err != nil || code != 0 {
+				t.Fatalf("writeHook = %d, %v", code, err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("hook file = %q, want %q", got, tt.want)
+			}
+			backup, err := os.ReadFile(path + backupSuffix)
+			if tt.existing == "" && err == nil || tt.existing != "" && string(backup) != tt.existing {
+				t.Errorf("backup = %q, %v; want %q", backup, err, tt.existing)
+			}
+		})
+	}
+}
+
+func TestHookScript(t *testing.T) {
+	got := hookScript(`C:\Users\me\bin\commit-writer.exe`, []string{"--tone", "it's dry"})
+	want := "#!/bin/sh\n" + hookMarker + "\n# $1 is the commit message file.\n" +
+		`exec 'C:/Users/me/bin/commit-writer.exe' --hook "$1" --fail-soft '--tone' 'it'\''s dry'` + "\n"
+	if runtime.GOOS != "windows" {
+		// Backslashes are file name characters elsewhere.
+		want = strings.Replace(want, "C:/Users/me/bin/", `C:\Users\me\bin\`, 1)
+	}
+	if got != want {
+		t.Errorf("hookScript =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCommentAlternatives(t *testing.T) {
+	got := commentAlternatives([]string{"Add thing\n\nIt was missing.", "Add the thing"}, "HG:")
+	want := "HG:\nHG: Alternative 1 (uncomment it and delete the message above to use it):\nHG: Add thing\nHG:\nHG: It was missing.\n" +
+		"HG:\nHG: Alternative 2 (uncomment it and delete the message above to use it):\nHG: Add the thing"
+	if got != want {
+		t.Errorf("commentAlternatives = %q, want %q", got, want)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	nop := func(string, ...interface{}) {}
+	path := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	if err := os.WriteFile(path, []byte("Fix typo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := writeHook(path, "Add thing", "#", true, nop); err != nil || code != 0 {
+		t.Fatalf("writeHook = %d, %v", code, err)
+	}
+	if code := runUndo([]string{path}, nop); code != 0 {
+		t.Fatalf("runUndo = %d", code)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "Fix typo\n" {
+		t.Errorf("restored hook file = %q, %v", got, err)
+	}
+	if code := runUndo([]string{path}, nop); code != 2 {
+		t.Errorf("second runUndo = %d, want 2", code)
+	}
+
+	// A backup from an earlier write is dropped when the file is new.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+backupSuffix, []byte("stale\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, err := writeHook(path, "Add thing", "#", false, nop); err != nil || code != 0 {
+		t.Fatalf("writeHook = %d, %v", code, err)
+	}
+	if code := runUndo([]string{path}, nop); code != 2 {
+		t.Errorf("runUndo after writing a new file = %d, want 2", code)
+	}
+}
+
+func TestWriteHookError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "COMMIT_EDITMSG")
+	code, err := writeHook(path, "Add thing", "#", false, func(string, ...interface{}) {})
+	if err == nil || code != 7 {
+		t.Errorf("writeHook into missing dir = %d, %v; want exit code 7", code, err)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/profile"
+)
+
+// learnCommits is how many recent commits "commit-writer learn" analyzes
+// without --range.
+const learnCommits = 500
+
+// runLearn builds a style profile from the commits in revRange, by default
+// the latest learnCommits on HEAD, writes it to path and prints the
+// conventions it found. It returns the exit code.
+func runLearn(revRange, path string, statusf func(string, ...interface{})) int {
+	var commits []gitdiff.Commit
+	var err error
+	if revRange != "" {
+		statusf("Collecting commits in %s", revRange)
+		commits, err = gitdiff.Log(revRange)
+	} else {
+		statusf("Collecting the last %d commits", learnCommits)
+		commits, err = gitdiff.Recent("HEAD", learnCommits)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(commits) < profile.MinCommits {
+		fmt.Fprintf(os.Stderr, "found %d commits; a style profile needs at least %d\n", len(commits), profile.MinCommits)
+		return 2
+	}
+	p := profile.Build(commits)
+	if err := p.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write style profile: %v\n", err)
+		return 7
+	}
+	statusf("Analyzed %d commits; wrote %s", p.Commits, path)
+	fmt.Println(p.Instructions())
+	return 0
+}
+
+// profilePath returns the style profile location: path when given, else
+// profile.File in the repository root.
+func profilePath(path string) string {
+	if path != "" {
+		return path
+	}
+	root := gitdiff.RepoRoot()
+	if root == "" {
+		return ""
+	}
+	return filepath.Join(root, filepath.FromSlash(profile.File))
+}
+
+// loadProfile reads the style profile at profilePath(path). A missing
+// default profile is fine and returns nil; a named one must exist.
+func loadProfile(path string) (*profile.Profile, string, error) {
+	full := profilePath(path)
+	if full == "" {
+		return nil, "", nil
+	}
+	p, err := profile.Load(full)
+	if err != nil {
+		if path == "" && errors.Is(err, fs.ErrNotExist) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	return p, full, nil
+}
+
+// checkProfile rejects msg when it breaks the conventions of p, listing
+// each problem.
+func checkProfile(p *profile.Profile, msg string) error {
+	problems := p.Check(format.StripLabels(msg))
+	if len(problems) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString("message does not follow the style profile:")
+	for _, pr := range problems {
+		b.WriteString("\n- " + pr.String())
+	}
+	return errors.New(b.String())
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/profile"
+)
+
+func TestCheckProfile(t *testing.T) {
+	p := &profile.Profile{Commits: 20, SubjectLength: profile.Lengths{Median: 30, P90: 40, Max: 50}, Conventional: 0.1, Capitalized: 1}
+	// Labels are not part of the message that gets committed.
+	if err := checkProfile(p, "Title: Apply coupons\nBody: Coupons apply before tax."); err != nil {
+		t.Errorf("labelled message rejected: %v", err)
+	}
+	err := checkProfile(p, "fix: apply coupons")
+	if err == nil {
+		t.Fatal("message breaking the profile accepted")
+	}
+	for _, want := range []string{"does not follow the style profile", "\n- line 1: subject has a type prefix \"fix:\" (type-unexpected)", "(subject-case)"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,677 @@
+// Command commit-writer generates commit messages from the staged git diff
+// using a local Ollama summarizer and style model.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/container"
+	"github.com/kylegalloway/commit-writer/pkg/forge"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/history"
+	"github.com/kylegalloway/commit-writer/pkg/i18n"
+	"github.com/kylegalloway/commit-writer/pkg/keychain"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/middleware"
+	"github.com/kylegalloway/commit-writer/pkg/notify"
+	"github.com/kylegalloway/commit-writer/pkg/profile"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+	"github.com/kylegalloway/commit-writer/pkg/redact"
+	"github.com/kylegalloway/commit-writer/pkg/rpc"
+	"github.com/kylegalloway/commit-writer/pkg/server"
+	"github.com/kylegalloway/commit-writer/pkg/trace"
+	"github.com/kylegalloway/commit-writer/pkg/tunnel"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// defaultTone is the --tone default.
+const defaultTone = "chaotic, wild, funny"
+
+// ui translates status, warning and error messages into the --ui-lang or
+// LANG language; nil is English.
+var ui i18n.Catalog
+
+// tracer records spans with --trace or an OTLP endpoint; nil otherwise.
+var tracer *trace.Tracer
+
+// sshTunnel forwards to Ollama for an ssh:// --ollama URL; nil otherwise.
+var sshTunnel *tunnel.Tunnel
+
+// exit sends the recorded spans, if any, closes the SSH tunnel and exits
+// with code.
+func exit(code int) {
+	sshTunnel.Close()
+	if err := tracer.Flush(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "%s%v\n", ui.T("Warning: "), err)
+	}
+	os.Exit(code)
+}
+
+// exitOnError reports a generator error and exits with the code for the
+// stage that failed.
+func exitOnError(err error) {
+	var gerr *generator.Error
+	if !errors.As(err, &gerr) {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	switch gerr.Stage {
+	case generator.StageLocalOnly:
+		fmt.Fprintln(os.Stderr, gerr.Err)
+		exit(9)
+	case generator.StageCheck:
+		fmt.Fprintln(os.Stderr, gerr.Err)
+		printRequestID(gerr)
+		exit(1)
+	case generator.StageDiff:
+		fmt.Fprintln(os.Stderr, ui.Sprintf("Error reading git diff: %v", gerr.Err))
+		exit(2)
+	case generator.StageSummary:
+		fmt.Fprintln(os.Stderr, ui.Sprintf("Summarizer error: %v", gerr.Err))
+		fmt.Fprintf(os.Stderr, "\n%s\n%s\n", ui.T("You can test this request manually with:"), gerr.Curl)
+		printRequestID(gerr)
+		exit(3)
+	default:
+		fmt.Fprintln(os.Stderr, ui.Sprintf("Styling model error: %v", gerr.Err))
+		fmt.Fprintf(os.Stderr, "\n%s\n%s\n", ui.T("You can test this request manually with:"), gerr.Curl)
+		printRequestID(gerr)
+		exit(4)
+	}
+}
+
+// printRequestID names the failed run's request ID, which the model
+// server's logs and the audit log carry too.
+func printRequestID(gerr *generator.Error) {
+	if gerr.RequestID != "" {
+		fmt.Fprintln(os.Stderr, ui.Sprintf("Request ID: %s", gerr.RequestID))
+	}
+}
+
+func main() {
+	subcommand, o := parseArgs(os.Args[1:])
+
+	if os.Getenv("TERM") == "dumb" {
+		o.noANSI, o.linearOutput = true, true
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		o.noANSI = true
+	} else if o.noANSI {
+		// Plugins, middleware and hooks run by git inherit it.
+		_ = os.Setenv("NO_COLOR", "1")
+	}
+	if o.uiLang != "" {
+		c, err := i18n.Load(o.uiLang)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "--ui-lang: %v\n", err)
+			os.Exit(2)
+		}
+		ui = c
+	} else {
+		// An environment locale commit-writer has no messages for is no
+		// reason to fail.
+		ui, _ = i18n.Load(i18n.EnvLang())
+	}
+	if err := gitdiff.AbsEnv(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if o.vcsName == "" {
+		o.vcsName = vcs.Detect()
+	}
+	repo, err := vcs.New(o.vcsName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if o.failSoft || o.openEdit {
+		if subcommand != "" || o.hookFile == "" {
+			fmt.Fprintln(os.Stderr, "--fail-soft and --editor require --hook and no subcommand")
+			os.Exit(2)
+		}
+		os.Exit(runWrapped(os.Args[1:], o.hookFile, hookWrap{FailSoft: o.failSoft, Editor: o.openEdit, Comment: repo.Comment()}))
+	}
+
+	ollamaDefaulted := o.ollamaURL == ""
+	if ollamaDefaulted {
+		o.ollamaURL = llm.DefaultURL
+	}
+
+	timeout := time.Duration(o.timeoutSecs) * time.Second
+
+	if err := o.check(subcommand, repo); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var compareModels []string
+	if o.compareList != "" {
+		var err error
+		if compareModels, err = parseModels(o.compareList); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	var repos []string
+	if o.reposList != "" {
+		var err error
+		if repos, err = parseRepos(o.reposList, os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "--repos: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	explicitConfig := o.configPath != ""
+	if !explicitConfig {
+		o.configPath = config.DefaultPath()
+	}
+	// doctor reports a broken config instead of failing on it.
+	if subcommand == "doctor" {
+		os.Exit(runDoctor(doctorOptions{ConfigPath: o.configPath, ExplicitConfig: explicitConfig, OllamaURL: o.ollamaURL, APIKey: os.Getenv("OLLAMA_API_KEY"),
+			Provider: o.provider, SummarizerModel: o.summarizerModel, StyleModel: o.styleModel, LocalOnly: o.localOnly}))
+	}
+	cfg, err := config.Load(o.configPath, explicitConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(8)
+	}
+	// A globally installed hook passes silently in repositories the config
+	// turns commit-writer off in; anything else asked for says why. --repos
+	// skips each repository that is.
+	if generates(subcommand) && len(repos) == 0 {
+		if pattern, off := cfg.Repos.Disabled(currentRepo(repo)); off {
+			if o.hookFile != "" {
+				os.Exit(0)
+			}
+			fmt.Fprintf(os.Stderr, "commit-writer is disabled in this repository by %q under \"repos\" in %s\n", pattern, o.configPath)
+			os.Exit(11)
+		}
+	}
+	var quirks, examples []string
+	if o.personaName != "" {
+		p, ok := cfg.Personas[o.personaName]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown persona %q; define it under \"personas\" in the config file\n", o.personaName)
+			os.Exit(2)
+		}
+		examples, quirks = o.usePersona(p)
+	}
+	if _, err := prompt.DescribeTone(o.tone, o.intensity); err != nil {
+		fmt.Fprintf(os.Stderr, "--tone/--intensity: %v\n", err)
+		os.Exit(2)
+	}
+	policy, havePolicy, err := config.LoadPolicy(config.SystemPolicyPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(11)
+	}
+	// --repos applies each repository's policy rule as it gets there, to
+	// the settings chosen before the policy.
+	var policyRule string
+	if len(policy.Repos) > 0 && len(repos) == 0 {
+		policy, policyRule = policy.ForRepo(currentRepo(repo))
+	}
+	denyPaths, perRepo := o.settle(subcommand, cfg, policy)
+	stageParams, err := o.stageParams(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "--param: %v\n", err)
+		os.Exit(2)
+	}
+	webhook, releaseNotes, code := o.services(policy)
+	if code != 0 {
+		os.Exit(code)
+	}
+	apiKey := os.Getenv("OLLAMA_API_KEY")
+	if apiKey == "" && (o.useKeychain || cfg.Keychain) {
+		key, err := keychain.Lookup("ollama")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s%v\n", ui.T("Warning: "), err)
+		}
+		apiKey = key
+	}
+	var anon *redact.Anonymizer
+	if o.anonymize || cfg.Anonymize.Enabled {
+		anon = redact.NewAnonymizer(cfg.Anonymize.Terms, cfg.Anonymize.Domains)
+	}
+
+	if o.debug {
+		log.SetFlags(log.LstdFlags | log.Lshortfile)
+		log.Printf("debug: ollamaURL=%s summarizerModel=%s styleModel=%s tone=%s hookFile=%s force=%v noLabels=%v titleOnly=%v saveSummary=%s loadSummary=%s timeout=%v noFallback=%v noRedact=%v config=%s localOnly=%v auditLog=%s anonymize=%v apiKey=%v commit=%v sign=%v signKey=%s policy=%v record=%s replay=%s provider=%s ticket=%v goSemantic=%v risk=%v",
+			o.ollamaURL, o.summarizerModel, o.styleModel, o.tone, o.hookFile, o.forceWrite, o.noLabels, o.titleOnly, o.saveSummary, o.loadSummary, timeout, o.noFallback, o.noRedact, o.configPath, o.localOnly, o.auditPath, anon != nil, apiKey != "", o.doCommit, o.sign, o.signKey, havePolicy, o.recordPath, o.replayPath, o.provider, o.ticketLookup || cfg.Tracker.Enabled, o.goSemantic || cfg.GoSemantic, o.riskNote || cfg.Risk.Enabled)
+		if policyRule != "" {
+			log.Printf("debug: policy rule %q applies to this repository", policyRule)
+		}
+	}
+
+	// plain strips what --no-ansi keeps off the screen from text that may
+	// come from models, plugins or the repository.
+	plain := func(s string) string {
+		if o.noANSI {
+			return format.PlainText(s)
+		}
+		return s
+	}
+
+	// helper to print progress status to stderr (keeps stdout reserved for the final message)
+	// The [status] prefix stays untranslated: --fail-soft and scripts look for it.
+	statusf := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "[status] %s\n", plain(ui.Sprintf(format, args...)))
+	}
+
+	warnf := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "%s%s\n", ui.T("Warning: "), plain(ui.Sprintf(format, args...)))
+	}
+
+	historyLog := &history.Log{Path: history.DefaultPath()}
+	if cfg.History.Path != "" {
+		historyLog.Path = cfg.History.Path
+	}
+	if code, ok := runTool(subcommand, o, historyLog, repo, statusf); ok {
+		os.Exit(code)
+	}
+	// The profile describes commit messages, not pull requests or release
+	// notes. With --repos, each repository's own profile is loaded in turn.
+	var styleProfile *profile.Profile
+	if !o.noProfile && subcommand != "pr" && subcommand != "changelog" && (len(repos) == 0 || o.profileFile != "") {
+		p, path, err := loadProfile(o.profileFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(8)
+		}
+		if p != nil {
+			statusf("Following the style profile in %s", path)
+			styleProfile = p
+		}
+	}
+	if o.strict && styleProfile == nil && len(repos) == 0 {
+		fmt.Fprintln(os.Stderr, "--strict needs a style profile; run 'commit-writer learn' first")
+		os.Exit(2)
+	}
+	// Porcelain mode keeps stderr for warnings and errors.
+	if o.porcelain {
+		statusf = func(string, ...interface{}) {}
+	}
+	if ollamaDefaulted && o.provider == "ollama" && o.replayPath == "" {
+		o.ollamaURL = locateOllama(o.localOnly, statusf)
+	}
+	if tunnel.IsSSH(o.ollamaURL) && o.provider == "ollama" && o.replayPath == "" {
+		if o.localOnly {
+			fmt.Fprintf(os.Stderr, "local-only: --ollama %s sends data to another machine\n", o.ollamaURL)
+			os.Exit(9)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		sshTunnel, err = tunnel.Open(ctx, o.ollamaURL)
+		cancel()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		statusf("Forwarding %s to Ollama on %s over SSH", sshTunnel.URL, sshTunnel.Host)
+		o.ollamaURL = sshTunnel.URL
+	}
+
+	// Ticket context comes from the branch of the repository we run in, which
+	// the server modes and --repos do not have.
+	var ticket, ticketFooter string
+	if (o.ticketLookup || cfg.Tracker.Enabled) && subcommand != "serve" && !o.jsonrpcMode && len(repos) == 0 {
+		var code int
+		if ticket, ticketFooter, code = branchTicket(o, cfg, timeout, statusf, warnf); code != 0 {
+			exit(code)
+		}
+	}
+
+	examples, summary, err := o.readInputs(examples, statusf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(2)
+	}
+	// refine styles the latest message's summary again with the author's
+	// feedback.
+	var feedback string
+	var previous history.Entry
+	if subcommand == "refine" {
+		if feedback = strings.TrimSpace(strings.Join(flag.Args(), " ")); feedback == "" {
+			fmt.Fprintln(os.Stderr, "usage: commit-writer refine [flags] \"feedback, e.g. shorter\"")
+			exit(2)
+		}
+		var code int
+		if previous, code = refineBase(historyLog, repo.Root()); code != 0 {
+			exit(code)
+		}
+		statusf("Refining the message generated %s", previous.Time)
+		summary = previous.Summary
+	}
+
+	client, err := o.modelClient(apiKey, timeout, statusf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(2)
+	}
+
+	var footer []string
+	if ticketFooter != "" {
+		footer = append(footer, ticketFooter)
+	}
+	genCfg := o.generatorConfig(cfg, generation{
+		APIKey:       apiKey,
+		Client:       client,
+		Timeout:      timeout,
+		DenyPaths:    denyPaths,
+		Anonymizer:   anon,
+		ReleaseNotes: releaseNotes,
+		Ticket:       ticket,
+		Footer:       footer,
+		Profile:      styleProfile,
+		Examples:     examples,
+		Quirks:       quirks,
+		Summary:      summary,
+		Feedback:     feedback,
+		Previous:     previous.Message,
+		Params:       stageParams,
+		VCS:          repo,
+		Status:       statusf,
+		Warn:         warnf,
+	})
+	if o.askMode {
+		genCfg.Ask = askTTY
+	}
+	if o.pickHunks {
+		diff, err := repo.Diff()
+		if err != nil {
+			exitOnError(&generator.Error{Stage: generator.StageDiff, Err: err})
+		}
+		if genCfg.Diff, err = pickScopeTTY(diff); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(2)
+		}
+		// The picked hunks still come from HEAD and the index (or working
+		// tree), so the checks that read those revisions keep working.
+		genCfg.Scoped = repo.Name() == "git"
+		if genCfg.Scoped && genCfg.Diff != diff && (genCfg.GoSemantic || !genCfg.NoClassify) && touchesGo(genCfg.Diff) {
+			warnf("Go declaration and API changes are read from whole files, including hunks you did not pick")
+		}
+	}
+	// finishMsg applies --no-labels, before_write middleware, plugins,
+	// --no-ansi and --strict.
+	finishMsg := func(ctx context.Context, msg string) (string, error) {
+		msg = strings.TrimSpace(msg)
+		if o.noLabels {
+			msg = format.StripLabels(msg)
+		}
+		if len(cfg.Middleware[middleware.BeforeWrite]) > 0 {
+			statusf("Running %s middleware", middleware.BeforeWrite)
+			out, err := cfg.Middleware.Run(ctx, middleware.BeforeWrite, msg)
+			if err != nil {
+				return "", err
+			}
+			msg = strings.TrimSpace(out)
+		}
+		if len(o.postPlugins) > 0 || len(o.validators) > 0 {
+			out, err := runPlugins(ctx, msg, o.postPlugins, o.validators, statusf)
+			if err != nil {
+				return "", err
+			}
+			msg = out
+		}
+		msg = plain(msg)
+		if o.strict {
+			if err := checkProfile(styleProfile, msg); err != nil {
+				return "", err
+			}
+		}
+		return msg, nil
+	}
+	finish := func(ctx context.Context, msg string) (string, error) {
+		ctx, span := tracer.Start(ctx, "validate")
+		out, err := finishMsg(ctx, msg)
+		span.Finish(err)
+		return out, err
+	}
+
+	if code, ok := runSubcommand(subcommand, o, genCfg, cfg, policy, finish, statusf, warnf); ok {
+		exit(code)
+	}
+
+	if len(compareModels) > 0 {
+		exit(runCompare(genCfg, compareModels, o.porcelain, o.linearOutput, finish, statusf))
+	}
+
+	// recordHistory keeps a generated message unless history is off.
+	recordHistory := func(e history.Entry) {
+		if o.noHistory || cfg.History.Disabled || historyLog.Path == "" {
+			return
+		}
+		if err := historyLog.Record(e); err != nil {
+			warnf("failed to record the message in %s: %v", historyLog.Path, err)
+		}
+	}
+	if len(repos) > 0 {
+		// --repos changes directory; keep relative log paths where we are.
+		for _, path := range []*string{&genCfg.AuditLog, &perRepo.AuditLog, &historyLog.Path} {
+			if *path != "" {
+				if abs, err := filepath.Abs(*path); err == nil {
+					*path = abs
+				}
+			}
+		}
+		perRepo.Provider, perRepo.Tone, perRepo.Webhook, perRepo.Tunnel = o.provider, o.tone, o.webhookURL, sshTunnel != nil
+		opts := reposOptions{Commit: o.doCommit, Sign: o.sign, SignKey: o.signKey, Record: recordHistory, Webhook: webhook, Disabled: cfg.Repos, Policy: perRepo}
+		// Each repository has its own style profile, unless one is named.
+		opts.Prepare = func(c *generator.Config) error {
+			if o.profileFile != "" || o.noProfile {
+				return nil
+			}
+			p, path, err := loadProfile("")
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				statusf("Following the style profile in %s", path)
+			} else if o.strict {
+				return errors.New("--strict needs a style profile; run 'commit-writer learn' there first")
+			}
+			styleProfile, c.Profile = p, p
+			return nil
+		}
+		exit(runRepos(genCfg, repos, opts, finish, statusf, warnf))
+	}
+
+	// notifyf shows a --notify desktop notification; failures only warn.
+	notifyf := func(title, body string) {
+		if !o.notifyDone {
+			return
+		}
+		if name := repoName(repo); name != "" {
+			title += " (" + name + ")"
+		}
+		if err := notify.Desktop(title, body); err != nil {
+			warnf("%v", err)
+		}
+	}
+	fixup, fixupSubject, code := chooseFixup(subcommand, o, repo, statusf)
+	if code != 0 {
+		exit(code)
+	}
+
+	ctx, span := tracer.Start(context.Background(), "commit-writer")
+	var res *generator.Result
+	var finalMsg string
+	if fixup == "fixup" {
+		statusf("Writing a fixup! message for %q", fixupSubject)
+		finalMsg = "fixup! " + fixupSubject
+		res = &generator.Result{Message: finalMsg, Confidence: generator.Confidence{Score: 1, Reasons: []string{"fixup! message for the last commit"}}}
+		span.Finish(nil)
+	} else {
+		var err error
+		if res, err = generator.New(genCfg).Generate(ctx); err != nil {
+			span.Finish(err)
+			notifyf("Commit message failed", err.Error())
+			exitOnError(err)
+		}
+		finalMsg, err = finish(ctx, res.Message)
+		span.Finish(err)
+		if err != nil {
+			notifyf("Commit message rejected", err.Error())
+			fmt.Fprintln(os.Stderr, err)
+			exit(12)
+		}
+		if fixup == "squash" {
+			finalMsg = "squash! " + fixupSubject + "\n\n" + finalMsg
+		}
+	}
+	exit(o.deliver(delivery{
+		Subcommand: subcommand,
+		Message:    finalMsg,
+		Result:     res,
+		Previous:   previous,
+		Config:     genCfg,
+		Finish:     finish,
+		Record:     recordHistory,
+		Notify:     notifyf,
+		Webhook:    webhook,
+		Repo:       repo,
+		Status:     statusf,
+		Warn:       warnf,
+	}))
+}
+
+// runTool runs the subcommands that need neither a model nor the diff and
+// returns their exit code; ok is false for any other subcommand.
+func runTool(subcommand string, o *options, historyLog *history.Log, repo vcs.VCS, statusf func(string, ...interface{})) (code int, ok bool) {
+	switch subcommand {
+	case "update":
+		if o.localOnly {
+			fmt.Fprintf(os.Stderr, "update: local-only: releases are downloaded from %s\n", forge.GitHubAPI)
+			return 9, true
+		}
+		return runUpdate(o.checkUpdate, o.forceWrite, statusf), true
+	case "install-hook":
+		return runInstallHook(flag.Args(), o.forceWrite, statusf), true
+	case "undo":
+		return runUndo(flag.Args(), statusf), true
+	case "last":
+		return runLast(historyLog, repo.Root()), true
+	case "history":
+		return runHistory(historyLog, repo.Root(), o.historyLimit), true
+	case "stats":
+		return runStats(historyLog, repo.Root()), true
+	case "learn":
+		return runLearn(o.revRange, profilePath(o.profileFile), statusf), true
+	}
+	return 0, false
+}
+
+// runSubcommand runs the subcommands that generate text with genCfg, and
+// --jsonrpc, and returns their exit code; ok is false otherwise.
+func runSubcommand(subcommand string, o *options, genCfg generator.Config, cfg config.Config, policy config.Policy, finish func(context.Context, string) (string, error), statusf, warnf func(string, ...interface{})) (code int, ok bool) {
+	switch subcommand {
+	case "pr":
+		return runPR(genCfg, o.pr, finish, statusf), true
+	case "ci":
+		return runCI(genCfg, o.ciOpts, o.revRange, o.pr.Base, cfg.Rules, o.validators, finish, statusf), true
+	case "changelog":
+		return runChangelog(genCfg, o.revRange, o.changelogFormat, o.releaseTag, o.localOnly, statusf, warnf), true
+	case "eval":
+		return runEval(genCfg, flag.Args(), o.revRange, o.historyLimit, o.judgeModel, o.changelogFormat, finish, statusf), true
+	case "explain":
+		return runExplain(genCfg, flag.Args(), statusf), true
+	case "review":
+		return runReview(genCfg), true
+	case "standup":
+		return runStandup(genCfg, o.since, statusf), true
+	case "watch":
+		if o.watchOpts.Draft == "" {
+			o.watchOpts.Draft = gitdiff.GitPath("commit-writer-draft")
+		}
+		o.watchOpts.Listen, o.watchOpts.Debounce = o.listenAddr, time.Duration(o.debounceSecs)*time.Second
+		return runWatch(genCfg, o.watchOpts, finish, statusf, warnf), true
+	case "split":
+		o.splitOpts.Sign, o.splitOpts.SignKey = o.sign, o.signKey
+		return runSplit(genCfg, o.splitOpts, finish, statusf), true
+	case "serve":
+		if o.listenAddr == "" {
+			o.listenAddr = defaultListen
+		}
+		return serve(o.listenAddr, server.Options{Base: genCfg, CheckTone: policy.CheckTone, Finish: finish}, statusf), true
+	}
+	if o.jsonrpcMode {
+		statusf("Serving JSON-RPC on stdin/stdout")
+		if err := rpc.Serve(context.Background(), os.Stdin, os.Stdout, server.Options{Base: genCfg, CheckTone: policy.CheckTone, Finish: finish}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// locateOllama returns the generate URL to use when none is configured:
+// the default when it answers, else the address it was last found on when
+// that still answers, else the first other place Ollama is found, such as
+// the host of the container commit-writer runs in.
+func locateOllama(localOnly bool, statusf func(string, ...interface{})) string {
+	var client *http.Client
+	if localOnly {
+		client = llm.LoopbackClient(2 * time.Second)
+	}
+	ctx := context.Background()
+	candidates := container.Candidates(container.Inside())
+	if container.Find(ctx, client, candidates[:1]) != "" {
+		return llm.DefaultURL
+	}
+	// Try where Ollama answered last time before probing everything.
+	base := ""
+	last := container.Last()
+	for _, c := range candidates[1:] {
+		if c == last {
+			base = container.Find(ctx, client, []string{last})
+		}
+	}
+	if base == "" {
+		base = container.Find(ctx, client, candidates[1:])
+		// Not remembering only costs the next run a full probe.
+		_ = container.Remember(base)
+	}
+	if base == "" {
+		return llm.DefaultURL
+	}
+	statusf("Ollama does not answer on localhost:11434; using %s", base)
+	return base + "/api/generate"
+}
+
+// generates reports whether subcommand, or a run without one, sends the
+// current repository's changes to a model.
+func generates(subcommand string) bool {
+	switch subcommand {
+	case "serve", "ci", "learn", "last", "history", "stats", "undo", "doctor", "install-hook", "update":
+		return false
+	}
+	return true
+}
+
+// currentRepo identifies the current repository for config.Repo patterns.
+func currentRepo(v vcs.VCS) config.Repo {
+	r := config.Repo{Root: v.Root()}
+	if v.Name() == "git" {
+		r.Remotes = gitdiff.RemoteURLs()
+	}
+	return r
+}
+
+// repoName returns the name of the current repository's directory.
+func repoName(v vcs.VCS) string {
+	if root := v.Root(); root != "" {
+		return filepath.Base(root)
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/plugin"
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// runPlugins passes msg through each post-processor plugin in turn and then
+// through each validator, returning the final message.
+func runPlugins(ctx context.Context, msg string, post, validators []string, statusf func(string, ...interface{})) (string, error) {
+	for _, name := range post {
+		path, err := plugin.Find(name)
+		if err != nil {
+			return "", err
+		}
+		statusf("Running post-processor plugin %s", name)
+		if msg, err = plugin.PostProcess(ctx, path, msg); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range validators {
+		path, err := plugin.Find(name)
+		if err != nil {
+			return "", err
+		}
+		statusf("Running validator plugin %s", name)
+		if err := plugin.Validate(ctx, path, msg); err != nil {
+			return "", fmt.Errorf("message rejected by validator %s: %w", name, err)
+		}
+	}
+	return msg, nil
+}
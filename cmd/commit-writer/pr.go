@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/forge"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+)
+
+// prOptions are the flags of the pr subcommand.
+type prOptions struct {
+	Base     string
+	Create   bool
+	Draft    bool
+	Forge    string // "github" or "gitlab"; empty detects from origin
+	Template string // description template name; empty uses the default
+}
+
+// runPR generates a pull request title and description for the current
+// branch against opts.Base, optionally opens it, and returns the exit code.
+func runPR(cfg generator.Config, opts prOptions, finish func(context.Context, string) (string, error), statusf func(string, ...interface{})) int {
+	ctx := context.Background()
+	base := opts.Base
+	if base == "" {
+		base = gitdiff.DefaultBase()
+	}
+	if opts.Forge == "" {
+		opts.Forge = forge.Detect()
+	}
+	host, err := forge.New(opts.Forge)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	tmpl, err := host.Template(gitdiff.RepoRoot(), opts.Template)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s description template: %v\n", opts.Forge, err)
+		return 2
+	}
+	statusf("Collecting changes between %s and HEAD", base)
+	diff, err := gitdiff.Branch(base)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if strings.TrimSpace(diff) == "" {
+		fmt.Fprintf(os.Stderr, "no changes between %s and HEAD\n", base)
+		return 2
+	}
+	commits, err := gitdiff.Commits(base)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	statusf("Branch has %d commit(s), %d byte diff", len(commits), len(diff))
+
+	cfg.Diff = diff
+	if cfg.DiffFrom, err = gitdiff.MergeBase(base); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg.TitleOnly = false
+	cfg.Pipeline = prompt.PullRequestPipeline
+	commitList := "(none)"
+	if len(commits) > 0 {
+		commitList = "- " + strings.Join(commits, "\n- ")
+	}
+	cfg.Vars = map[string]string{"commits": commitList}
+	res, err := generator.New(cfg).Generate(ctx)
+	if err != nil {
+		exitOnError(err)
+	}
+	msg, err := finish(ctx, format.StripLabels(res.Message))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 12
+	}
+	title, body := forge.SplitMessage(msg)
+	if tmpl != "" {
+		statusf("Merging generated description into the %s template", opts.Forge)
+		body = forge.MergeTemplate(tmpl, body)
+	}
+	fmt.Printf("%s\n\n%s\n", title, body)
+
+	if opts.Create {
+		statusf("Opening %s request into %s", opts.Forge, base)
+		url, err := host.Create(ctx, forge.PullRequest{Title: title, Body: body, Base: base, Draft: opts.Draft})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 13
+		}
+		statusf("Opened %s", url)
+	}
+	return 0
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/deps"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/history"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/notify"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// reposOptions control what --repos does with each repository's message.
+type reposOptions struct {
+	Commit  bool // commit the staged changes with the message
+	Sign    bool
+	SignKey string
+	// Prepare adjusts the generator config to the repository we are in,
+	// e.g. to its style profile.
+	Prepare func(*generator.Config) error
+	// Record adds a message to the history.
+	Record  func(history.Entry)
+	Webhook *notify.Webhook
+	// Disabled skips the repositories the config turns commit-writer off in.
+	Disabled config.RepoFilter
+	// Policy, when set, applies the system policy to each repository in
+	// turn rather than the one we started in.
+	Policy *repoPolicy
+}
+
+// repoPolicy holds the settings the system policy may override, as chosen
+// before it applied, so --repos can apply each repository's policy rule.
+type repoPolicy struct {
+	Policy    config.Policy // the system policy, before ForRepo
+	DenyPaths []string      // the config's deny paths
+	LocalOnly bool          // --local-only or the config's local_only
+	AuditLog  string
+	NoRedact  bool
+	Provider  string
+	Tone      string
+	Webhook   string
+	Tunnel    bool // Ollama is reached over an SSH tunnel
+}
+
+// apply sets c's deny paths, local-only mode, audit log and redaction for
+// repository r as main does for the repository it runs in, and returns an
+// error if the policy there forbids the provider, tone or anything that
+// sends data off the machine.
+func (p *repoPolicy) apply(c *generator.Config, r config.Repo) error {
+	policy := p.Policy
+	if len(policy.Repos) > 0 {
+		policy, _ = policy.ForRepo(r)
+	}
+	c.DenyPaths = append(append([]string{}, p.DenyPaths...), policy.DenyPaths...)
+	c.LocalOnly = p.LocalOnly || policy.LocalOnly
+	c.AuditLog = p.AuditLog
+	if policy.AuditLog != "" {
+		c.AuditLog = policy.AuditLog
+	}
+	c.NoRedact = p.NoRedact && !policy.Redact
+	for _, err := range []error{policy.CheckProvider(p.Provider), policy.CheckTone(p.Tone)} {
+		if err != nil {
+			return err
+		}
+	}
+	if !c.LocalOnly {
+		return nil
+	}
+	switch {
+	case p.Provider != "ollama":
+		return fmt.Errorf("local-only: provider plugin %q may send data off this machine; only the built-in ollama provider is allowed", p.Provider)
+	case p.Tunnel:
+		return errors.New("local-only: Ollama is reached over SSH on another machine")
+	case c.ReleaseNotes != nil:
+		return fmt.Errorf("--dep-notes: local-only: release notes are fetched from %s", deps.GitHubAPI)
+	}
+	if p.Webhook != "" {
+		if err := llm.CheckLoopback(p.Webhook); err != nil {
+			return fmt.Errorf("--webhook: %v", err)
+		}
+	}
+	return nil
+}
+
+// runRepos generates a message in each repository that has uncommitted
+// changes, printing each under a "=== dir" heading, and commits it with
+// opts.Commit. A failing repository doesn't stop the others. It returns the
+// exit code: 1 if any repository failed.
+func runRepos(cfg generator.Config, repos []string, opts reposOptions, finish func(context.Context, string) (string, error), statusf, warnf func(string, ...interface{})) int {
+	ctx := context.Background()
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	changed, failed, printed := 0, 0, false
+	for i, dir := range repos {
+		// Relative directories are relative to where we started.
+		if err := os.Chdir(wd); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		statusf("Repository %d of %d: %s", i+1, len(repos), dir)
+		ok, err := repoMessage(ctx, cfg, dir, opts, finish, statusf, warnf, &printed)
+		if ok {
+			changed++
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", dir, err)
+			failed++
+		}
+	}
+	statusf("%d of %d repositories had changes; %d failed", changed, len(repos), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// repoMessage generates, prints and records the message for dir and commits
+// it with opts.Commit. It reports whether the repository has changes;
+// printed tells whether an earlier message needs a blank line after it.
+func repoMessage(ctx context.Context, cfg generator.Config, dir string, opts reposOptions, finish func(context.Context, string) (string, error), statusf, warnf func(string, ...interface{}), printed *bool) (bool, error) {
+	if err := os.Chdir(dir); err != nil {
+		return false, err
+	}
+	root := gitdiff.RepoRoot()
+	if root == "" {
+		return false, errors.New("not a git repository")
+	}
+	r := currentRepo(vcs.Git{})
+	if pattern, off := opts.Disabled.Disabled(r); off {
+		statusf("commit-writer is disabled in %s by %q; skipping", dir, pattern)
+		return false, nil
+	}
+	if !gitdiff.HasChanges() {
+		statusf("No uncommitted changes in %s; skipping", dir)
+		return false, nil
+	}
+	if opts.Policy != nil {
+		if err := opts.Policy.apply(&cfg, r); err != nil {
+			return true, err
+		}
+	}
+	if opts.Prepare != nil {
+		if err := opts.Prepare(&cfg); err != nil {
+			return true, err
+		}
+	}
+	res, err := generator.New(cfg).Generate(ctx)
+	if err != nil {
+		var gerr *generator.Error
+		if errors.As(err, &gerr) {
+			err = gerr.Err
+		}
+		return true, err
+	}
+	msg, err := finish(ctx, res.Message)
+	if err != nil {
+		return true, err
+	}
+	if *printed {
+		fmt.Println()
+	}
+	*printed = true
+	fmt.Printf("=== %s\n\n%s\n", dir, msg)
+
+	entry := history.Entry{Repo: root, Branch: gitdiff.CurrentBranch(), DiffHash: res.DiffHash, SummarizerModel: cfg.SummarizerModel, StyleModel: cfg.StyleModel, Tone: cfg.Tone, Offline: res.Offline, Message: msg, Summary: res.Summary}
+	if opts.Commit {
+		if !gitdiff.HasStaged() {
+			warnf("nothing staged in %s; not committing", dir)
+		} else {
+			statusf("Committing staged changes in %s", dir)
+			if err := gitCommit(msg, opts.Sign, opts.SignKey); err != nil {
+				opts.Record(entry)
+				return true, err
+			}
+			entry.Accepted, entry.Commit = true, gitdiff.Head()
+		}
+	}
+	opts.Record(entry)
+	if opts.Webhook != nil {
+		event := notify.Event{Repo: filepath.Base(root), Branch: entry.Branch, Message: msg}
+		if err := opts.Webhook.Post(ctx, event); err != nil {
+			warnf("%v", err)
+		}
+	}
+	return true, nil
+}
+
+// parseRepos splits a --repos list, e.g. "api, web", or with "-" reads one
+// directory per line from stdin, skipping blank lines and # comments.
+func parseRepos(list string, stdin io.Reader) ([]string, error) {
+	var repos []string
+	if list == "-" {
+		sc := bufio.NewScanner(stdin)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				repos = append(repos, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, dir := range strings.Split(list, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				repos = append(repos, dir)
+			}
+		}
+	}
+	if len(repos) == 0 {
+		return nil, errors.New("no repositories given")
+	}
+	return repos, nil
+}
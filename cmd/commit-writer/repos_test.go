@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/history"
+)
+
+func TestParseRepos(t *testing.T) {
+	got, err := parseRepos(" api, ,web ", nil)
+	if want := []string{"api", "web"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRepos(list) = %q, %v; want %q", got, err, want)
+	}
+	got, err = parseRepos("-", strings.NewReader("# workspace\n~/src/api\n\n  ~/src/web  \n"))
+	if want := []string{"~/src/api", "~/src/web"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRepos(stdin) = %q, %v; want %q", got, err, want)
+	}
+	if _, err := parseRepos(" , ", nil); err == nil {
+		t.Error("parseRepos of an empty list succeeded")
+	}
+}
+
+func TestRepoPolicyApply(t *testing.T) {
+	yes := true
+	p := &repoPolicy{
+		Policy: config.Policy{
+			DenyPaths: []string{"*.key"},
+			Repos: []config.RepoPolicy{{
+				Match:     []string{"/work/secret/*"},
+				LocalOnly: &yes,
+				Redact:    &yes,
+				DenyPaths: []string{"vault/**"},
+			}},
+			AuditLog: "/var/log/commit-writer.jsonl",
+		},
+		DenyPaths: []string{".env"},
+		AuditLog:  "/home/me/audit.jsonl",
+		NoRedact:  true,
+		Provider:  "ollama",
+	}
+
+	// A repository no rule matches keeps the user's choices.
+	cfg := generator.Config{}
+	if err := p.apply(&cfg, config.Repo{Root: "/work/open/api"}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LocalOnly || !cfg.NoRedact || !reflect.DeepEqual(cfg.DenyPaths, []string{".env", "*.key"}) || cfg.AuditLog != "/var/log/commit-writer.jsonl" {
+		t.Errorf("unmatched repository: %+v", cfg)
+	}
+
+	// The next one's rule applies, without the earlier one's settings.
+	cfg = generator.Config{}
+	if err := p.apply(&cfg, config.Repo{Root: "/work/secret/api"}); err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.LocalOnly || cfg.NoRedact || !reflect.DeepEqual(cfg.DenyPaths, []string{".env", "*.key", "vault/**"}) {
+		t.Errorf("matched repository: %+v", cfg)
+	}
+
+	// Local-only there refuses what would leave the machine.
+	for _, q := range []repoPolicy{
+		{Policy: p.Policy, Provider: "openai"},
+		{Policy: p.Policy, Provider: "ollama", Tunnel: true},
+		{Policy: p.Policy, Provider: "ollama", Webhook: "https://hooks.example.com/x"},
+	} {
+		if err := q.apply(&generator.Config{}, config.Repo{Root: "/work/secret/api"}); err == nil || !strings.Contains(err.Error(), "local-only") && !strings.Contains(err.Error(), "loopback") {
+			t.Errorf("apply(%+v) = %v, want a local-only error", q, err)
+		}
+		if err := q.apply(&generator.Config{}, config.Repo{Root: "/work/open/api"}); err != nil {
+			t.Errorf("apply(%+v) outside the rule = %v", q, err)
+		}
+	}
+}
+
+func TestRunReposSkipsDisabled(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := git("init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("a.go", []byte("package a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := git("add", "."); err != nil {
+		t.Fatal(err)
+	}
+
+	var status []string
+	statusf := func(format string, args ...interface{}) { status = append(status, fmt.Sprintf(format, args...)) }
+	finish := func(context.Context, string) (string, error) {
+		t.Fatal("generated a message in a disabled repository")
+		return "", nil
+	}
+	opts := reposOptions{Disabled: config.RepoFilter{Disable: []string{"*"}}, Record: func(history.Entry) {}}
+	if code := runRepos(generator.Config{}, []string{dir}, opts, finish, statusf, statusf); code != 0 {
+		t.Errorf("runRepos = %d, want 0", code)
+	}
+	if !strings.Contains(strings.Join(status, "\n"), `disabled in `+dir+` by "*"`) {
+		t.Errorf("status = %q, want the disabled repository reported", status)
+	}
+}
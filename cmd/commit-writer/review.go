@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+)
+
+// runReview prints notes for a reviewer of the staged (or unstaged)
+// changes. It returns the exit code.
+func runReview(cfg generator.Config) int {
+	out, err := generator.New(cfg).Review(context.Background())
+	if err != nil {
+		exitOnError(err)
+	}
+	fmt.Println(out)
+	return 0
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// scopePreview is how many changed lines of each hunk are shown.
+const scopePreview = 3
+
+// pickScopeTTY asks on the terminal which hunks of diff the message should
+// describe, like askTTY, and returns the diff of those.
+func pickScopeTTY(diff string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal to pick hunks on: %w", err)
+	}
+	defer tty.Close()
+	return pickScope(bufio.NewReader(tty), tty, diff)
+}
+
+// pickScope lists the hunks of diff on w and reads the chosen ones from r.
+// An empty answer or end of input keeps the whole diff; an invalid answer
+// is asked again.
+func pickScope(r *bufio.Reader, w io.Writer, diff string) (string, error) {
+	hunks := gitdiff.Hunks(diff)
+	if len(hunks) < 2 {
+		return diff, nil
+	}
+	fmt.Fprintln(w, "Staged hunks:")
+	for i, h := range hunks {
+		fmt.Fprintf(w, "%3d. %s\n", i+1, hunkLabel(h))
+		for _, line := range hunkPreview(h.Text, scopePreview) {
+			fmt.Fprintf(w, "       %s\n", line)
+		}
+	}
+	for {
+		fmt.Fprint(w, "Hunks the message should describe (e.g. 1,3-5; Enter for all): ")
+		line, err := r.ReadString('\n')
+		if err == io.EOF && strings.TrimSpace(line) == "" {
+			fmt.Fprintln(w)
+			return diff, nil
+		}
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		picked, perr := parseSelection(line, len(hunks))
+		if perr != nil {
+			if err == io.EOF {
+				return "", perr
+			}
+			fmt.Fprintln(w, perr)
+			continue
+		}
+		if picked == nil {
+			return diff, nil
+		}
+		scope := make([]gitdiff.Hunk, len(picked))
+		for i, n := range picked {
+			scope[i] = hunks[n]
+		}
+		return gitdiff.JoinHunks(scope), nil
+	}
+}
+
+// touchesGo reports whether diff changes a .go file.
+func touchesGo(diff string) bool {
+	for _, st := range gitdiff.ParseStat(diff) {
+		if strings.HasSuffix(st.Path, ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// hunkLabel names a hunk by its file, line and, when the hunk header has
+// it, the enclosing function.
+func hunkLabel(h gitdiff.Hunk) string {
+	if h.Text == "" {
+		return h.Path + " (no line changes)"
+	}
+	header := strings.SplitN(h.Text, "\n", 2)[0]
+	f := strings.Fields(header)
+	if len(f) < 4 {
+		return h.Path
+	}
+	line, _, _ := strings.Cut(strings.TrimPrefix(f[2], "+"), ",")
+	label := h.Path + ":" + line
+	if context := strings.Join(f[4:], " "); context != "" {
+		label += " " + context
+	}
+	return label
+}
+
+// hunkPreview returns up to max of a hunk's added and removed lines, and a
+// count of the rest.
+func hunkPreview(text string, max int) []string {
+	var out []string
+	more := 0
+	for _, line := range strings.Split(text, "\n")[1:] {
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+		if len(out) == max {
+			more++
+			continue
+		}
+		out = append(out, line)
+	}
+	if more > 0 {
+		out = append(out, fmt.Sprintf("... %d more changed line(s)", more))
+	}
+	return out
+}
+
+// parseSelection reads a list of 1-based numbers and ranges up to n, such
+// as "1,3-5" or "2 4", and returns the 0-based indices in order. An empty
+// list returns nil.
+func parseSelection(s string, n int) ([]int, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n' })
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	seen := map[int]bool{}
+	for _, f := range fields {
+		lo, hi, isRange := strings.Cut(f, "-")
+		from, err := strconv.Atoi(lo)
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(hi)
+		}
+		if err != nil || from < 1 || to > n || from > to {
+			return nil, fmt.Errorf("%q is not a hunk number or range from 1 to %d", f, n)
+		}
+		for i := from; i <= to; i++ {
+			seen[i-1] = true
+		}
+	}
+	picked := make([]int, 0, len(seen))
+	for i := range seen {
+		picked = append(picked, i)
+	}
+	sort.Ints(picked)
+	return picked, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	for in, want := range map[string][]int{
+		"":          nil,
+		" \n":       nil,
+		"2":         {1},
+		"3-4, 1\n":  {0, 2, 3},
+		"1 2,2-3 ":  {0, 1, 2},
+		"4-4,4":     {3},
+		"1,\t3\r\n": {0, 2},
+	} {
+		if got, err := parseSelection(in, 4); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("parseSelection(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"0", "5", "2-1", "1-9", "x", "-2", "1-"} {
+		if _, err := parseSelection(bad, 4); err == nil {
+			t.Errorf("parseSelection(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestPickScope(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n@@ -9 +9 @@ func f() {\n-x\n+y\n" +
+		"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-c\n+d\n"
+	var out strings.Builder
+	got, err := pickScope(bufio.NewReader(strings.NewReader("7\n2-3\n")), &out, diff)
+	want := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -9 +9 @@ func f() {\n-x\n+y\n" +
+		"diff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-c\n+d\n"
+	if err != nil || got != want {
+		t.Errorf("pickScope = %q, %v; want %q", got, err, want)
+	}
+	if !strings.Contains(out.String(), "  2. a.go:9 func f() {\n       -x\n       +y\n") || !strings.Contains(out.String(), `"7" is not a hunk number`) {
+		t.Errorf("output:\n%s", out.String())
+	}
+
+	// Enter or end of input keeps the whole diff.
+	for _, in := range []string{"\n", ""} {
+		if got, err := pickScope(bufio.NewReader(strings.NewReader(in)), &out, diff); err != nil || got != diff {
+			t.Errorf("pickScope(%q) = %q, %v", in, got, err)
+		}
+	}
+}
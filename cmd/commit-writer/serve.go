@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/server"
+)
+
+// defaultListen is the address "commit-writer serve" listens on without
+// --listen.
+const defaultListen = "127.0.0.1:8787"
+
+// serve runs the HTTP API on addr until interrupted and returns the exit code.
+func serve(addr string, opts server.Options, statusf func(string, ...interface{})) int {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --listen address %q: %v\n", addr, err)
+		return 2
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		fmt.Fprintf(os.Stderr, "Warning: listening on non-loopback address %s; anyone who can reach it can send diffs to your models\n", addr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           server.New(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	statusf("Listening on http://%s (POST /generate, GET /health)", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	statusf("Server stopped")
+	return 0
+}
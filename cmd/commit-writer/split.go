@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// splitOptions are the flags of the split subcommand.
+type splitOptions struct {
+	Apply   bool // commit each group after asking
+	Sign    bool
+	SignKey string
+}
+
+// runSplit suggests how to break the staged changes into several commits
+// and prints each group's files with a generated message. With opts.Apply
+// each group is committed in turn after confirmation. It returns the exit
+// code.
+func runSplit(cfg generator.Config, opts splitOptions, finish func(context.Context, string) (string, error), statusf func(string, ...interface{})) int {
+	ctx := context.Background()
+	if opts.Apply && !gitdiff.HasStaged() {
+		fmt.Fprintln(os.Stderr, "split --apply commits staged changes; stage them with git add first")
+		return 2
+	}
+	statusf("Grouping the changed files into commits")
+	groups, err := generator.New(cfg).Split(ctx)
+	if err != nil {
+		exitOnError(err)
+	}
+	if len(groups) == 1 {
+		statusf("The changes look cohesive; no split suggested")
+	} else {
+		statusf("Suggesting %d commits", len(groups))
+	}
+	// pending holds every staged file not yet committed, including any
+	// the groups leave out, such as sensitive files omitted from the diff.
+	var pending []string
+	if opts.Apply {
+		if pending, err = changedFiles("--staged"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 10
+		}
+		mixed, err := changedFiles("", pending...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 10
+		}
+		if len(mixed) > 0 {
+			fmt.Fprintf(os.Stderr, "split --apply stages whole files, but these also have unstaged changes: %s\nStage or stash them first.\n", strings.Join(mixed, ", "))
+			return 2
+		}
+	}
+	answers := bufio.NewReader(os.Stdin)
+	for i, g := range groups {
+		statusf("Generating the message for commit %d of %d (%s)", i+1, len(groups), g.Title)
+		gcfg := cfg
+		gcfg.Paths = g.Files
+		res, err := generator.New(gcfg).Generate(ctx)
+		if err != nil {
+			exitOnError(err)
+		}
+		msg, err := finish(ctx, res.Message)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 12
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("=== Commit %d of %d: %s\nFiles:\n  %s\n\n%s\n", i+1, len(groups), g.Title, strings.Join(g.Files, "\n  "), msg)
+		if !opts.Apply {
+			continue
+		}
+		switch confirm(answers, fmt.Sprintf("Commit %d of %d?", i+1, len(groups))) {
+		case 'q':
+			statusf("Stopped; the remaining changes are still staged")
+			return 0
+		case 'n':
+			statusf("Skipped; its files stay staged")
+			continue
+		}
+		pending = without(pending, g.Files)
+		if err := commitGroup(msg, pending, opts.Sign, opts.SignKey); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 10
+		}
+		statusf("Committed %d of %d", i+1, len(groups))
+	}
+	if opts.Apply && len(pending) > 0 {
+		statusf("%d files are still staged", len(pending))
+	}
+	return 0
+}
+
+// confirm asks question on stderr and reads y, n or q from r. Anything
+// other than y or n, including end of input, is q.
+func confirm(r *bufio.Reader, question string) byte {
+	fmt.Fprintf(os.Stderr, "%s [y/n/q] ", question)
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		fmt.Fprintln(os.Stderr)
+		return 'q'
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return 'y'
+	case "n", "no":
+		return 'n'
+	}
+	return 'q'
+}
+
+// commitGroup commits the staged changes except others, which are
+// unstaged for the commit and staged again afterwards, even when the
+// commit fails.
+func commitGroup(msg string, others []string, sign bool, signKey string) error {
+	if len(others) == 0 {
+		return gitCommit(msg, sign, signKey)
+	}
+	if err := git(append([]string{"reset", "-q"}, gitdiff.Pathspecs(others)...)...); err != nil {
+		return err
+	}
+	commitErr := gitCommit(msg, sign, signKey)
+	if err := git(append([]string{"add", "-A"}, gitdiff.Pathspecs(others)...)...); err != nil {
+		return fmt.Errorf("%w; restage the remaining files with git add", err)
+	}
+	return commitErr
+}
+
+// changedFiles lists the repo-relative paths git diff reports as changed,
+// with an extra flag such as "--staged" and limited to paths when given.
+// Both sides of a rename are listed.
+func changedFiles(flag string, paths ...string) ([]string, error) {
+	args := []string{"diff", "--name-only", "--no-renames", "-z"}
+	if flag != "" {
+		args = append(args, flag)
+	}
+	if len(paths) > 0 {
+		args = append(args, gitdiff.Pathspecs(paths)...)
+	}
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --name-only failed: %w", err)
+	}
+	return strings.FieldsFunc(string(out), func(r rune) bool { return r == 0 }), nil
+}
+
+// git runs a git command whose output is only of interest on failure.
+func git(args ...string) error {
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %w; output=%s", args[0], err, out)
+	}
+	return nil
+}
+
+// without returns paths minus the ones in drop.
+func without(paths, drop []string) []string {
+	skip := map[string]bool{}
+	for _, p := range drop {
+		skip[p] = true
+	}
+	var kept []string
+	for _, p := range paths {
+		if !skip[p] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
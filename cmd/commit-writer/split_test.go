@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommitGroup(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for _, env := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(env, "Test")
+	}
+	for _, env := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(env, "test@example.com")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	for _, name := range []string{"a.go", "b.go", "my notes.md"} {
+		if err := os.WriteFile(name, []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := git("init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	if err := git("add", "."); err != nil {
+		t.Fatal(err)
+	}
+
+	staged, err := changedFiles("--staged")
+	if err != nil || !reflect.DeepEqual(staged, []string{"a.go", "b.go", "my notes.md"}) {
+		t.Fatalf("staged = %q, %v", staged, err)
+	}
+	if err := os.WriteFile("b.go", []byte("edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if mixed, err := changedFiles("", staged...); err != nil || !reflect.DeepEqual(mixed, []string{"b.go"}) {
+		t.Errorf("unstaged = %q, %v", mixed, err)
+	}
+
+	if err := commitGroup("Add a", without(staged, []string{"a.go"}), false, ""); err != nil {
+		t.Fatalf("commitGroup: %v", err)
+	}
+	out, err := exec.Command("git", "show", "--name-only", "--format=%s", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(out)); !reflect.DeepEqual(got, []string{"Add", "a", "a.go"}) {
+		t.Errorf("commit = %q", out)
+	}
+	// The other files are staged again.
+	if staged, err := changedFiles("--staged"); err != nil || !reflect.DeepEqual(staged, []string{"b.go", "my notes.md"}) {
+		t.Errorf("still staged = %q, %v", staged, err)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("y\nNo\n\nn"))
+	var got []byte
+	for i := 0; i < 5; i++ {
+		got = append(got, confirm(r, "Commit?"))
+	}
+	if string(got) != "ynqnq" {
+		t.Errorf("answers = %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// runStandup prints a few standup bullets summarizing the commits the git
+// user made on any local branch since since, plus their uncommitted work.
+// It returns the exit code.
+func runStandup(cfg generator.Config, since string, statusf func(string, ...interface{})) int {
+	email := gitdiff.UserEmail()
+	if email == "" {
+		fmt.Fprintln(os.Stderr, "standup needs git's user.email to find your commits")
+		return 2
+	}
+	commits, err := gitdiff.Authored(email, since)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	statusf("Found %d commit(s) by %s since %s", len(commits), email, since)
+	messages := make([]string, len(commits))
+	for i, c := range commits {
+		messages[i] = c.Message
+	}
+	out, err := generator.New(cfg).Standup(context.Background(), messages)
+	if err != nil {
+		exitOnError(err)
+	}
+	fmt.Println(out)
+	return 0
+}
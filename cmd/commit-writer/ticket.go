@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/keychain"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/tracker"
+)
+
+// ticketSource is the issue tracker selected in the config file.
+type ticketSource struct {
+	// tracker is nil when keys are only linked, not fetched.
+	tracker tracker.Tracker
+	// url is the endpoint contacted, checked under --local-only.
+	url string
+	// find extracts the ticket key from a branch name.
+	find func(branch string) (string, bool)
+	// footer returns the message footer for a key; nil adds none.
+	footer func(key string) string
+}
+
+// withWord returns a footer function writing "<word> <key>".
+func withWord(word string) func(string) string {
+	return func(key string) string { return strings.TrimSpace(word + " " + key) }
+}
+
+// newTicketSource builds the configured tracker. A nil client uses the
+// tracker's default HTTP client.
+func newTicketSource(tc config.TrackerConfig, useKeychain bool, client *http.Client) (*ticketSource, error) {
+	projectKeys := func(projects []string) func(string) (string, bool) {
+		return func(branch string) (string, bool) { return tracker.FindKey(branch, projects) }
+	}
+	switch tc.Provider {
+	case "", "jira":
+		j, err := jiraTracker(tc.Jira, useKeychain)
+		if err != nil {
+			return nil, err
+		}
+		j.Client = client
+		return &ticketSource{tracker: j, url: j.URL, find: projectKeys(tc.Jira.Projects)}, nil
+	case "linear":
+		key, err := secret("LINEAR_API_KEY", "linear", useKeychain)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			return nil, errors.New("--ticket needs a Linear API key: set LINEAR_API_KEY or use --keychain with account linear")
+		}
+		src := &ticketSource{
+			tracker: &tracker.Linear{APIKey: key, Client: client},
+			url:     tracker.LinearURL,
+			find:    projectKeys(tc.Linear.Teams),
+		}
+		switch tc.Linear.Footer {
+		case "":
+			src.footer = withWord("Fixes")
+		case "none":
+		default:
+			src.footer = withWord(tc.Linear.Footer)
+		}
+		return src, nil
+	case "azure":
+		ac := tc.Azure
+		src := &ticketSource{find: tracker.FindWorkItem, footer: withWord(ac.Footer)}
+		if ac.Organization == "" || ac.Project == "" {
+			return src, nil
+		}
+		token, err := secret("AZURE_DEVOPS_EXT_PAT", "azure-devops", useKeychain)
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, errors.New("--ticket needs an Azure DevOps token to fetch work items: set AZURE_DEVOPS_EXT_PAT or use --keychain with account azure-devops")
+		}
+		a := &tracker.Azure{URL: ac.URL, Organization: ac.Organization, Project: ac.Project, Token: token, Client: client}
+		src.tracker, src.url = a, a.URL
+		if src.url == "" {
+			src.url = tracker.AzureURL
+		}
+		return src, nil
+	default:
+		return nil, fmt.Errorf("unknown tracker provider %q (want jira, linear or azure)", tc.Provider)
+	}
+}
+
+// jiraTracker builds the Jira client from the config file, falling back to
+// JIRA_URL, JIRA_EMAIL and JIRA_API_TOKEN (or the "jira" keychain entry).
+func jiraTracker(jc config.JiraConfig, useKeychain bool) (*tracker.Jira, error) {
+	token, err := secret("JIRA_API_TOKEN", "jira", useKeychain)
+	if err != nil {
+		return nil, err
+	}
+	j := &tracker.Jira{URL: jc.URL, Email: jc.Email, Token: token}
+	if j.URL == "" {
+		j.URL = os.Getenv("JIRA_URL")
+	}
+	if j.Email == "" {
+		j.Email = os.Getenv("JIRA_EMAIL")
+	}
+	if j.URL == "" {
+		return nil, errors.New("--ticket needs a Jira site: set tracker.jira.url in the config file or JIRA_URL")
+	}
+	return j, nil
+}
+
+// secret reads a credential from the environment variable env, falling
+// back to the keychain account when useKeychain is set.
+func secret(env, account string, useKeychain bool) (string, error) {
+	if v := os.Getenv(env); v != "" || !useKeychain {
+		return v, nil
+	}
+	return keychain.Lookup(account)
+}
+
+// branchTicket looks up the ticket for the current branch and returns its
+// context for the prompt and the footer to add, or an exit code when the
+// tracker is misconfigured or off limits in local-only mode.
+func branchTicket(o *options, cfg config.Config, timeout time.Duration, statusf, warnf func(string, ...interface{})) (ticket, footer string, code int) {
+	var client *http.Client
+	if o.localOnly {
+		client = llm.LoopbackClient(timeout)
+	}
+	src, err := newTicketSource(cfg.Tracker, o.useKeychain || cfg.Keychain, client)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return "", "", 8
+	}
+	if o.localOnly && src.url != "" {
+		if err := llm.CheckLoopback(src.url); err != nil {
+			fmt.Fprintf(os.Stderr, "--ticket: %v\n", err)
+			return "", "", 9
+		}
+	}
+	if t := lookupTicket(context.Background(), src, statusf, warnf); t != nil {
+		if t.Title != "" {
+			ticket = t.Context()
+		}
+		if src.footer != nil {
+			footer = src.footer(t.Key)
+		}
+	}
+	return ticket, footer, 0
+}
+
+// lookupTicket fetches the ticket named in the current branch. Ticket
+// context is optional: a branch without a key only warns and returns nil,
+// and without a tracker or when the lookup fails only the key is returned,
+// which is still enough for the footer.
+func lookupTicket(ctx context.Context, src *ticketSource, statusf, warnf func(string, ...interface{})) *tracker.Ticket {
+	branch := gitdiff.CurrentBranch()
+	key, ok := src.find(branch)
+	if !ok {
+		warnf("no ticket key found in branch %q; continuing without ticket context", branch)
+		return nil
+	}
+	if src.tracker == nil {
+		statusf("Linking %s", key)
+		return &tracker.Ticket{Key: key}
+	}
+	statusf("Fetching ticket %s", key)
+	ticket, err := src.tracker.Fetch(ctx, key)
+	if err != nil {
+		warnf("%v; continuing without ticket context", err)
+		return &tracker.Ticket{Key: key}
+	}
+	statusf("Using ticket %s: %s", ticket.Key, ticket.Title)
+	return ticket
+}
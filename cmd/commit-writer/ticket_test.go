@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/config"
+	"github.com/kylegalloway/commit-writer/pkg/tracker"
+)
+
+func TestNewTicketSource(t *testing.T) {
+	t.Setenv("JIRA_URL", "")
+	t.Setenv("JIRA_API_TOKEN", "")
+	t.Setenv("LINEAR_API_KEY", "lin_key")
+	t.Setenv("AZURE_DEVOPS_EXT_PAT", "pat")
+
+	tests := []struct {
+		name       string
+		tc         config.TrackerConfig
+		wantURL    string
+		wantFooter string
+		wantErr    bool
+	}{
+		{"jira", config.TrackerConfig{Jira: config.JiraConfig{URL: "https://example.atlassian.net"}}, "https://example.atlassian.net", "", false},
+		{"jira without site", config.TrackerConfig{}, "", "", true},
+		{"linear", config.TrackerConfig{Provider: "linear"}, tracker.LinearURL, "Fixes AB#1", false},
+		{"linear refs", config.TrackerConfig{Provider: "linear", Linear: config.LinearConfig{Footer: "Refs"}}, tracker.LinearURL, "Refs AB#1", false},
+		{"linear no footer", config.TrackerConfig{Provider: "linear", Linear: config.LinearConfig{Footer: "none"}}, tracker.LinearURL, "", false},
+		{"azure link only", config.TrackerConfig{Provider: "azure"}, "", "AB#1", false},
+		{"azure fetch", config.TrackerConfig{Provider: "azure", Azure: config.AzureConfig{Organization: "acme", Project: "Web", Footer: "Fixes"}}, tracker.AzureURL, "Fixes AB#1", false},
+		{"unknown", config.TrackerConfig{Provider: "trello"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := newTicketSource(tt.tc, false, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("newTicketSource succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newTicketSource: %v", err)
+			}
+			footer := ""
+			if src.footer != nil {
+				footer = src.footer("AB#1")
+			}
+			if src.url != tt.wantURL || footer != tt.wantFooter {
+				t.Errorf("source url=%q footer=%q, want %q %q", src.url, footer, tt.wantURL, tt.wantFooter)
+			}
+		})
+	}
+
+	t.Setenv("LINEAR_API_KEY", "")
+	if _, err := newTicketSource(config.TrackerConfig{Provider: "linear"}, false, nil); err == nil {
+		t.Error("linear without an API key succeeded")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/kylegalloway/commit-writer/pkg/update"
+)
+
+// version is the release this binary was built from, set by the release
+// build with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// currentVersion is version, or the module version "go install" recorded.
+func currentVersion() string {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && update.IsRelease(info.Main.Version) {
+			return info.Main.Version
+		}
+	}
+	return version
+}
+
+// runUpdate replaces the running binary with the latest release, or with
+// checkOnly just reports whether there is a newer one. force reinstalls
+// the latest release over a development build or the same version. It
+// returns the exit code.
+func runUpdate(checkOnly, force bool, statusf func(string, ...interface{})) int {
+	ctx := context.Background()
+	current := currentVersion()
+	u := &update.Updater{Token: os.Getenv("GITHUB_TOKEN")}
+	rel, err := u.Latest(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	newer := update.Newer(rel.Tag, current)
+	if checkOnly {
+		if newer {
+			fmt.Printf("commit-writer %s; %s is available: %s\n", current, rel.Tag, rel.URL)
+		} else {
+			fmt.Printf("commit-writer %s; the latest release is %s\n", current, rel.Tag)
+		}
+		return 0
+	}
+	switch {
+	case newer || force:
+	case !update.IsRelease(current):
+		fmt.Fprintf(os.Stderr, "this is a development build (%s); pass --force to replace it with %s\n", current, rel.Tag)
+		return 1
+	default:
+		statusf("commit-writer %s is up to date", current)
+		return 0
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot find the running binary: %v\n", err)
+		return 1
+	}
+	if manager := update.Managed(exe); manager != "" {
+		fmt.Fprintf(os.Stderr, "%s is managed by %s; update it there instead\n", exe, manager)
+		return 1
+	}
+	name := update.AssetName(runtime.GOOS, runtime.GOARCH)
+	statusf("Downloading %s %s", rel.Tag, name)
+	data, err := u.Download(ctx, rel, name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	statusf("Checksum verified")
+	if err := update.Replace(exe, data); err != nil {
+		fmt.Fprintf(os.Stderr, "replacing %s: %v (rerun with permission to write there, or download %s by hand)\n", exe, err, rel.URL)
+		return 1
+	}
+	statusf("Updated %s from %s to %s", exe, current, rel.Tag)
+	return 0
+}
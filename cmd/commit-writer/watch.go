@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// watchPoll is how often "commit-writer watch" reads the diff.
+const watchPoll = time.Second
+
+// watchOptions are the flags of the watch subcommand.
+type watchOptions struct {
+	Draft    string        // file kept up to date with the draft
+	Listen   string        // address serving the draft, if any
+	Debounce time.Duration // how long the diff must stay unchanged
+}
+
+// runWatch keeps a draft message for the current changes in opts.Draft,
+// and on opts.Listen, regenerating it once the diff has stopped changing
+// for opts.Debounce. It runs until interrupted and returns the exit code.
+func runWatch(cfg generator.Config, opts watchOptions, finish func(context.Context, string) (string, error), statusf, warnf func(string, ...interface{})) int {
+	if gitdiff.RepoRoot() == "" {
+		fmt.Fprintln(os.Stderr, "not in a git repository")
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	d := &draft{State: "waiting"}
+	if opts.Listen != "" {
+		srv := &http.Server{Addr: opts.Listen, Handler: d, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			<-ctx.Done()
+			_ = srv.Close()
+		}()
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				warnf("draft server: %v", err)
+			}
+		}()
+		statusf("Serving the draft on http://%s (GET /draft, GET /title)", opts.Listen)
+	}
+	statusf("Watching for changes; the draft goes to %s (Ctrl-C to stop)", opts.Draft)
+
+	deb := debouncer{wait: opts.Debounce}
+	tick := time.NewTicker(watchPoll)
+	defer tick.Stop()
+	for {
+		diff, err := gitdiff.Staged()
+		if err != nil {
+			warnf("%v", err)
+		} else if hash := fmt.Sprintf("%x", sha256.Sum256([]byte(gitdiff.Normalize(diff)))); deb.due(hash, time.Now()) {
+			deb.done = hash
+			if strings.TrimSpace(diff) == "" {
+				statusf("No changes; clearing the draft")
+				d.set("", "clean", nil)
+			} else {
+				statusf("Changes settled; generating a draft")
+				d.set(d.message(), "generating", nil)
+				msg, err := watchMessage(ctx, cfg, finish)
+				if ctx.Err() != nil {
+					statusf("Stopped watching")
+					return 0
+				}
+				if err != nil {
+					// The old draft stays; the next change tries again.
+					warnf("%v", err)
+					d.set(d.message(), "error", err)
+				} else {
+					statusf("Draft updated: %s", strings.SplitN(msg, "\n", 2)[0])
+					d.set(msg, "ready", nil)
+				}
+			}
+			if err := writeDraft(opts.Draft, d.message()); err != nil {
+				warnf("failed to write the draft: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			statusf("Stopped watching")
+			return 0
+		case <-tick.C:
+		}
+	}
+}
+
+// watchMessage generates and finishes a message for the current changes.
+func watchMessage(ctx context.Context, cfg generator.Config, finish func(context.Context, string) (string, error)) (string, error) {
+	res, err := generator.New(cfg).Generate(ctx)
+	if err != nil {
+		var gerr *generator.Error
+		if errors.As(err, &gerr) {
+			err = gerr.Err
+		}
+		return "", err
+	}
+	return finish(ctx, res.Message)
+}
+
+// writeDraft replaces the draft file, so readers never see half a message.
+func writeDraft(path, msg string) error {
+	if msg != "" {
+		msg += "\n"
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".commit-writer-draft-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(msg); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// debouncer decides when a changing diff has settled.
+type debouncer struct {
+	wait    time.Duration
+	done    string // hash of the diff last generated for
+	pending string // hash of the diff last seen
+	since   time.Time
+}
+
+// due reports whether the diff with hash h, seen at now, has stayed the
+// same for the wait and has no draft yet. The caller sets done once it
+// generates.
+func (d *debouncer) due(h string, now time.Time) bool {
+	if h != d.pending {
+		d.pending, d.since = h, now
+	}
+	return h != d.done && now.Sub(d.since) >= d.wait
+}
+
+// draft is the latest draft message, served as JSON on /draft and as its
+// title in plain text on /title, e.g. for a status bar.
+type draft struct {
+	mu      sync.Mutex
+	Message string `json:"message"`
+	// State is "waiting" before the first draft, "generating", "ready",
+	// "error" (Message is then the previous draft) or "clean" when there
+	// are no changes.
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+	Updated string `json:"updated,omitempty"`
+}
+
+func (d *draft) set(msg, state string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.Message, d.State, d.Error = msg, state, ""
+	if err != nil {
+		d.Error = err.Error()
+	}
+	d.Updated = time.Now().UTC().Format(time.RFC3339)
+}
+
+func (d *draft) message() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Message
+}
+
+func (d *draft) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch r.URL.Path {
+	case "/draft":
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(d)
+	case "/title":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, strings.SplitN(d.Message, "\n", 2)[0])
+	default:
+		http.NotFound(w, r)
+	}
+}
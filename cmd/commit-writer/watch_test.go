@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDebouncer(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := debouncer{wait: 3 * time.Second}
+	steps := []struct {
+		hash string
+		at   time.Duration
+		want bool
+	}{
+		{"a", 0, false},
+		{"a", 2 * time.Second, false},
+		{"b", 3 * time.Second, false}, // still changing
+		{"b", 6 * time.Second, true},
+		{"b", 7 * time.Second, false}, // already drafted
+		{"a", 8 * time.Second, false},
+		{"a", 11 * time.Second, true},
+	}
+	for _, s := range steps {
+		got := d.due(s.hash, start.Add(s.at))
+		if got != s.want {
+			t.Errorf("due(%q) at %v = %v, want %v", s.hash, s.at, got, s.want)
+		}
+		if got {
+			d.done = s.hash
+		}
+	}
+}
+
+func TestDraftServe(t *testing.T) {
+	d := &draft{}
+	d.set("Add coupons\n\nThey apply before tax.", "ready", nil)
+
+	rec := httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/title", nil))
+	if got := rec.Body.String(); got != "Add coupons\n" {
+		t.Errorf("/title = %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("GET", "/draft", nil))
+	var got struct{ Message, State string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.State != "ready" || got.Message != "Add coupons\n\nThey apply before tax." {
+		t.Errorf("/draft = %s, %v", rec.Body, err)
+	}
+
+	rec = httptest.NewRecorder()
+	d.ServeHTTP(rec, httptest.NewRequest("POST", "/draft", nil))
+	if rec.Code != 405 {
+		t.Errorf("POST /draft = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,60 @@
+// Package audit records every prompt and response exchanged with a model in
+// an append-only local log.
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Entry is one line of the prompt audit log. Each model call produces a
+// "request" entry, written before anything is sent, and a "response" entry
+// with the same ID.
+type Entry struct {
+	ID        string                 `json:"id"`
+	Time      string                 `json:"time"`
+	Kind      string                 `json:"kind"`
+	Stage     string                 `json:"stage"`
+	Model     string                 `json:"model"`
+	URL       string                 `json:"url"`
+	Repo      string                 `json:"repo,omitempty"`
+	DiffHash  string                 `json:"diff_sha256,omitempty"`
+	Prompt    string                 `json:"prompt,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	Stop      []string               `json:"stop,omitempty"`
+	Template  string                 `json:"template,omitempty"`
+	Response  string                 `json:"response,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// Log appends entries to a local JSONL file so security teams can see
+// exactly what content was sent to which model.
+type Log struct {
+	// Path is the JSONL file entries are appended to.
+	Path string
+	// Repo and DiffHash are stamped onto every entry.
+	Repo     string
+	DiffHash string
+}
+
+// Record appends e, filling in the time, repo and diff hash.
+func (a *Log) Record(e Entry) error {
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.Repo = a.Repo
+	e.DiffHash = a.DiffHash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(a.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
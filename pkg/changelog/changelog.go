@@ -0,0 +1,240 @@
+// Package changelog groups commits into release notes and writes them as
+// Markdown, git-cliff style TOML sections, conventional-changelog JSON or
+// user-facing release notes.
+package changelog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Entry is one parsed commit. The JSON form follows the commit objects of
+// conventional-commits-parser, which conventional-changelog-writer reads.
+type Entry struct {
+	Hash    string  `json:"hash"`
+	Type    string  `json:"type"`
+	Scope   string  `json:"scope"`
+	Subject string  `json:"subject"`
+	Header  string  `json:"header"`
+	Body    string  `json:"body"`
+	Footer  string  `json:"footer"`
+	Notes   []Note  `json:"notes"`
+	Revert  *string `json:"revert"`
+	// Breaking is set by a "!" after the type or a BREAKING CHANGE note.
+	Breaking bool `json:"-"`
+}
+
+// Note is a footer note such as "BREAKING CHANGE: ...".
+type Note struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+var headerPattern = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?: (.+)$`)
+
+// Parse parses a commit message in Conventional Commits form. Messages that
+// do not follow it get an empty Type and their first line as Subject.
+func Parse(c gitdiff.Commit) Entry {
+	lines := strings.Split(strings.TrimSpace(c.Message), "\n")
+	e := Entry{Hash: c.Hash, Header: lines[0], Subject: lines[0], Notes: []Note{}}
+	if m := headerPattern.FindStringSubmatch(lines[0]); m != nil {
+		e.Type, e.Scope, e.Subject = strings.ToLower(m[1]), m[2], m[4]
+		e.Breaking = m[3] == "!"
+	}
+
+	// Footers start at the first paragraph containing a "BREAKING CHANGE"
+	// note; everything before it is the body.
+	rest := strings.TrimSpace(strings.Join(lines[1:], "\n"))
+	for _, title := range []string{"BREAKING CHANGE: ", "BREAKING-CHANGE: "} {
+		if i := strings.Index(rest, title); i >= 0 && (i == 0 || rest[i-1] == '\n') {
+			e.Footer = strings.TrimSpace(rest[i:])
+			rest = strings.TrimSpace(rest[:i])
+			e.Notes = append(e.Notes, Note{Title: "BREAKING CHANGE", Text: strings.TrimSpace(e.Footer[len(title):])})
+			e.Breaking = true
+			break
+		}
+	}
+	e.Body = rest
+	if e.Breaking && len(e.Notes) == 0 {
+		e.Notes = append(e.Notes, Note{Title: "BREAKING CHANGE", Text: e.Subject})
+	}
+	if e.Type == "revert" {
+		e.Revert = &e.Subject
+	}
+	return e
+}
+
+// groups maps commit types to section names, in output order. They match
+// git-cliff's default commit_parsers.
+var groups = []struct {
+	name  string
+	types []string
+}{
+	{"Features", []string{"feat"}},
+	{"Bug Fixes", []string{"fix"}},
+	{"Documentation", []string{"doc", "docs"}},
+	{"Performance", []string{"perf"}},
+	{"Refactor", []string{"refactor"}},
+	{"Styling", []string{"style"}},
+	{"Testing", []string{"test"}},
+	{"Miscellaneous Tasks", []string{"chore", "ci", "build"}},
+	{"Revert", []string{"revert"}},
+	{"Other", nil},
+}
+
+// Group returns the section name for e.
+func (e Entry) Group() string {
+	for _, g := range groups {
+		for _, t := range g.types {
+			if e.Type == t {
+				return g.name
+			}
+		}
+	}
+	return "Other"
+}
+
+// Section is a named group of entries.
+type Section struct {
+	Name    string
+	Entries []Entry
+}
+
+// Sections groups entries in git-cliff's order, dropping empty groups.
+func Sections(entries []Entry) []Section {
+	var out []Section
+	for _, g := range groups {
+		s := Section{Name: g.name}
+		for _, e := range entries {
+			if e.Group() == g.name {
+				s.Entries = append(s.Entries, e)
+			}
+		}
+		if len(s.Entries) > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (e Entry) line() string {
+	s := e.Subject
+	if e.Breaking {
+		s = "[**breaking**] " + s
+	}
+	if e.Scope != "" {
+		s = "*(" + e.Scope + ")* " + s
+	}
+	return s
+}
+
+// WriteMarkdown writes the sections under a "## <title>" heading, in the
+// layout of git-cliff's default template.
+func WriteMarkdown(w io.Writer, title string, entries []Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n", title)
+	for _, s := range Sections(entries) {
+		fmt.Fprintf(&b, "\n### %s\n\n", s.Name)
+		for _, e := range s.Entries {
+			fmt.Fprintf(&b, "- %s\n", e.line())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteReleaseNotes writes release notes for users under a "## <title>"
+// heading: the breaking changes first, then each section with the prose
+// describe returns for it, or its commit list when that is "".
+func WriteReleaseNotes(w io.Writer, title string, entries []Entry, describe func(Section) string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n", title)
+	var breaking []string
+	for _, e := range entries {
+		for _, n := range e.Notes {
+			breaking = append(breaking, n.Text)
+		}
+	}
+	if len(breaking) > 0 {
+		b.WriteString("\n### Breaking Changes\n\n")
+		for _, text := range breaking {
+			fmt.Fprintf(&b, "- %s\n", text)
+		}
+	}
+	for _, s := range Sections(entries) {
+		fmt.Fprintf(&b, "\n### %s\n\n", s.Name)
+		if prose := strings.TrimSpace(describe(s)); prose != "" {
+			b.WriteString(prose + "\n")
+			continue
+		}
+		for _, e := range s.Entries {
+			fmt.Fprintf(&b, "- %s\n", e.line())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteTOML writes one [[group]] table per section with the commits as
+// [[group.commits]], using git-cliff's commit field names (id, message,
+// group, scope, breaking) so the output can feed git-cliff templates and
+// other TOML-driven release tooling.
+func WriteTOML(w io.Writer, entries []Entry) error {
+	var b strings.Builder
+	for i, s := range Sections(entries) {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[[group]]\nname = %s\n", tomlString(s.Name))
+		for _, e := range s.Entries {
+			fmt.Fprintf(&b, "\n[[group.commits]]\nid = %s\nmessage = %s\ngroup = %s\n", tomlString(e.Hash), tomlString(e.Subject), tomlString(s.Name))
+			if e.Scope != "" {
+				fmt.Fprintf(&b, "scope = %s\n", tomlString(e.Scope))
+			}
+			fmt.Fprintf(&b, "breaking = %t\n", e.Breaking)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// WriteJSON writes the entries as a JSON array of conventional-commits-parser
+// commit objects.
+func WriteJSON(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
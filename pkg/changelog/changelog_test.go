@@ -0,0 +1,156 @@
+package changelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		msg                 string
+		typ, scope, subject string
+		breaking            bool
+		body                string
+	}{
+		{"feat(auth): add login\n\nUses tokens.", "feat", "auth", "add login", false, "Uses tokens."},
+		{"fix!: drop v1 API", "fix", "", "drop v1 API", true, ""},
+		{"refactor: split parser\n\nDetails.\n\nBREAKING CHANGE: Parse now returns an error", "refactor", "", "split parser", true, "Details."},
+		{"Update README", "", "", "Update README", false, ""},
+	}
+	for _, tt := range tests {
+		e := Parse(gitdiff.Commit{Hash: "abc", Message: tt.msg})
+		if e.Type != tt.typ || e.Scope != tt.scope || e.Subject != tt.subject || e.Breaking != tt.breaking || e.Body != tt.body {
+			t.Errorf("Parse(%q) = %+v", tt.msg, e)
+		}
+	}
+	e := Parse(gitdiff.Commit{Message: "refactor: x\n\nBREAKING CHANGE: Parse now returns an error"})
+	if len(e.Notes) != 1 || e.Notes[0].Text != "Parse now returns an error" || e.Footer != "BREAKING CHANGE: Parse now returns an error" {
+		t.Errorf("breaking note = %+v, footer %q", e.Notes, e.Footer)
+	}
+}
+
+func entries() []Entry {
+	var out []Entry
+	for i, msg := range []string{"fix(ui): align button", "feat: add \"export\"", "chore: bump deps", "Tweak things", "feat(api)!: remove v1"} {
+		out = append(out, Parse(gitdiff.Commit{Hash: string(rune('a' + i)), Message: msg}))
+	}
+	return out
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteMarkdown(&b, "unreleased", entries()); err != nil {
+		t.Fatal(err)
+	}
+	want := `## unreleased
+
+### Features
+
+- add "export"
+- *(api)* [**breaking**] remove v1
+
+### Bug Fixes
+
+- *(ui)* align button
+
+### Miscellaneous Tasks
+
+- bump deps
+
+### Other
+
+- Tweak things
+`
+	if b.String() != want {
+		t.Errorf("markdown =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteReleaseNotes(t *testing.T) {
+	var b bytes.Buffer
+	describe := func(s Section) string {
+		if s.Name == "Features" {
+			return fmt.Sprintf("You can now export data (%d changes).\n", len(s.Entries))
+		}
+		return ""
+	}
+	if err := WriteReleaseNotes(&b, "v2.0.0", entries(), describe); err != nil {
+		t.Fatal(err)
+	}
+	want := `## v2.0.0
+
+### Breaking Changes
+
+- remove v1
+
+### Features
+
+You can now export data (2 changes).
+
+### Bug Fixes
+
+- *(ui)* align button
+
+### Miscellaneous Tasks
+
+- bump deps
+
+### Other
+
+- Tweak things
+`
+	if b.String() != want {
+		t.Errorf("release notes =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteTOML(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteTOML(&b, entries()[:2]); err != nil {
+		t.Fatal(err)
+	}
+	want := `[[group]]
+name = "Features"
+
+[[group.commits]]
+id = "b"
+message = "add \"export\""
+group = "Features"
+breaking = false
+
+[[group]]
+name = "Bug Fixes"
+
+[[group.commits]]
+id = "a"
+message = "align button"
+group = "Bug Fixes"
+scope = "ui"
+breaking = false
+`
+	if b.String() != want {
+		t.Errorf("TOML =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteJSON(&b, entries()[4:]); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	c := got[0]
+	if c["type"] != "feat" || c["scope"] != "api" || c["subject"] != "remove v1" || c["header"] != "feat(api)!: remove v1" || c["revert"] != nil {
+		t.Errorf("commit = %v", c)
+	}
+	if notes := c["notes"].([]interface{}); len(notes) != 1 {
+		t.Errorf("notes = %v", notes)
+	}
+}
@@ -0,0 +1,128 @@
+// Package changes is the structured form of the summarizer's answer: the
+// title, the key points of the change and the files it touches, for the
+// style stage and for anything that reads a summary programmatically.
+package changes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// maxFiles bounds how many files Text lists.
+const maxFiles = 30
+
+// bulletRe matches a list item's marker: "-", "*", "•" or "1." / "1)".
+var bulletRe = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s+`)
+
+// Summary is a structured change list.
+type Summary struct {
+	Title string `json:"title"`
+	// Points are the key changes, one sentence or list item each.
+	Points []string `json:"points,omitempty"`
+	// Files are the changed files, from the diff rather than the model.
+	Files []File `json:"files,omitempty"`
+}
+
+// File is one changed file.
+type File struct {
+	Path string `json:"path"`
+	// Kind is "added", "deleted" or "modified".
+	Kind    string `json:"kind"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+}
+
+func (f File) String() string {
+	return fmt.Sprintf("%s (%s, +%d -%d)", f.Path, f.Kind, f.Added, f.Removed)
+}
+
+// Parse reads a summarizer's answer: the title is the first non-blank
+// line, and each list item, or each paragraph of a body not written as a
+// list, is a key point. "Title:" and "Body:" labels are dropped.
+func Parse(text string) Summary {
+	var s Summary
+	lines := strings.Split(format.StripLabels(strings.ReplaceAll(text, "\r\n", "\n")), "\n")
+	for len(lines) > 0 && s.Title == "" {
+		s.Title = strings.TrimSpace(lines[0])
+		lines = lines[1:]
+	}
+	joining := false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			joining = false
+		case bulletRe.MatchString(line):
+			s.Points = append(s.Points, strings.TrimSpace(bulletRe.ReplaceAllString(line, "")))
+			joining = true
+		case joining:
+			s.Points[len(s.Points)-1] += " " + trimmed
+		default:
+			s.Points = append(s.Points, trimmed)
+			joining = true
+		}
+	}
+	return s
+}
+
+// Rest returns the key points full has after those of s, as list items,
+// and reports whether full starts with s: the same title and points.
+func (s Summary) Rest(full *Summary) (string, bool) {
+	if full == nil || full.Title != s.Title || len(full.Points) < len(s.Points) {
+		return "", false
+	}
+	for i, p := range s.Points {
+		if full.Points[i] != p {
+			return "", false
+		}
+	}
+	var b strings.Builder
+	for _, p := range full.Points[len(s.Points):] {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+	return b.String(), true
+}
+
+// Files lists the files of a diff's stats.
+func Files(stats []gitdiff.FileStat) []File {
+	files := make([]File, 0, len(stats))
+	for _, st := range stats {
+		kind := "modified"
+		switch st.Status {
+		case 'A':
+			kind = "added"
+		case 'D':
+			kind = "deleted"
+		}
+		files = append(files, File{Path: st.Path, Kind: kind, Added: st.Added, Removed: st.Removed})
+	}
+	return files
+}
+
+// Text renders s for the style stage: the title, the key points as a
+// list and, set apart as context, the changed files.
+func (s Summary) Text() string {
+	var b strings.Builder
+	b.WriteString(s.Title)
+	if len(s.Points) > 0 {
+		b.WriteString("\n\n")
+		for _, p := range s.Points {
+			fmt.Fprintf(&b, "- %s\n", p)
+		}
+	}
+	if len(s.Files) > 0 {
+		b.WriteString("\nChanged files (context only; don't list them in the message):\n")
+		for i, f := range s.Files {
+			if i == maxFiles {
+				fmt.Fprintf(&b, "- ... and %d more\n", len(s.Files)-maxFiles)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", f)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
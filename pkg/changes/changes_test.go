@@ -0,0 +1,56 @@
+package changes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestParse(t *testing.T) {
+	for text, want := range map[string]Summary{
+		"Title: Add widgets\n\nBody:\n- Register the widget\n  type with the server.\n* Add tests\n": {
+			Title: "Add widgets", Points: []string{"Register the widget type with the server.", "Add tests"}},
+		"\nFix login\n\nCheck the token\nbefore the session.\n\n1. Drop the cache": {
+			Title: "Fix login", Points: []string{"Check the token before the session.", "Drop the cache"}},
+		"Bump the version": {Title: "Bump the version"},
+	} {
+		if got := Parse(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", text, got, want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	s := Summary{Title: "Add widgets", Points: []string{"Register the widget type"},
+		Files: Files([]gitdiff.FileStat{{Path: "widget.go", Status: 'A', Added: 40}, {Path: "old.go", Status: 'D', Removed: 9}, {Path: "main.go", Status: 'M', Added: 2, Removed: 1}})}
+	want := "Add widgets\n\n- Register the widget type\n\nChanged files (context only; don't list them in the message):\n" +
+		"- widget.go (added, +40 -0)\n- old.go (deleted, +0 -9)\n- main.go (modified, +2 -1)"
+	if got := s.Text(); got != want {
+		t.Errorf("Text() = %q, want %q", got, want)
+	}
+	for i := 0; i < maxFiles; i++ {
+		s.Files = append(s.Files, File{Path: "x.go", Kind: "modified"})
+	}
+	if got := s.Text(); !strings.HasSuffix(got, "- ... and 3 more") {
+		t.Errorf("Text() with many files ends %q", got[len(got)-40:])
+	}
+}
+
+func TestRest(t *testing.T) {
+	start := Summary{Title: "Add widgets", Points: []string{"Register the widget type."}}
+	full := &Summary{Title: "Add widgets", Points: []string{"Register the widget type.", "Document it."}}
+	if rest, ok := start.Rest(full); !ok || rest != "- Document it.\n" {
+		t.Errorf("Rest = %q, %v", rest, ok)
+	}
+	for _, other := range []*Summary{
+		nil,
+		{Title: "Add widget support", Points: full.Points},
+		{Title: "Add widgets", Points: []string{"Register the widget type. Document it."}},
+	} {
+		if rest, ok := start.Rest(other); ok {
+			t.Errorf("Rest(%+v) = %q, true; want false", other, rest)
+		}
+	}
+}
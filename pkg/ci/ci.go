@@ -0,0 +1,155 @@
+// Package ci finds the commits a CI job covers and reports message check
+// results as GitHub Actions annotations or JUnit XML (which GitLab shows in
+// merge requests).
+package ci
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/lint"
+)
+
+// Range returns the revision range ("base..head") of the push or pull
+// request being built, from the GitHub Actions or GitLab CI environment.
+// It returns false outside CI and for pushes that create a branch, which
+// have no previous commit.
+func Range() (string, bool) {
+	switch {
+	case os.Getenv("GITHUB_BASE_REF") != "":
+		return "origin/" + os.Getenv("GITHUB_BASE_REF") + "..HEAD", true
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		var event struct {
+			Before string `json:"before"`
+			After  string `json:"after"`
+		}
+		data, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+		if err != nil || json.Unmarshal(data, &event) != nil || isZero(event.Before) || event.After == "" {
+			return "", false
+		}
+		return event.Before + ".." + event.After, true
+	case os.Getenv("CI_MERGE_REQUEST_DIFF_BASE_SHA") != "":
+		return os.Getenv("CI_MERGE_REQUEST_DIFF_BASE_SHA") + "..HEAD", true
+	case os.Getenv("GITLAB_CI") == "true":
+		before, sha := os.Getenv("CI_COMMIT_BEFORE_SHA"), os.Getenv("CI_COMMIT_SHA")
+		if isZero(before) || sha == "" {
+			return "", false
+		}
+		return before + ".." + sha, true
+	}
+	return "", false
+}
+
+// isZero reports whether sha is empty or git's all-zero null object ID.
+func isZero(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
+
+// Result is the outcome of checking one commit message.
+type Result struct {
+	Hash     string
+	Subject  string
+	Problems []lint.Problem
+}
+
+// Failed counts the results with problems.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if len(r.Problems) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func short(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// WriteText writes one line per problem, e.g.
+// "abc1234 Add login: subject is 80 characters, limit is 72 (subject-length)".
+func WriteText(w io.Writer, results []Result) {
+	for _, r := range results {
+		for _, p := range r.Problems {
+			fmt.Fprintf(w, "%s %s: %s\n", short(r.Hash), r.Subject, p)
+		}
+	}
+}
+
+// WriteAnnotations writes GitHub Actions workflow commands that show each
+// problem as an error annotation on the run.
+func WriteAnnotations(w io.Writer, results []Result) {
+	for _, r := range results {
+		for _, p := range r.Problems {
+			title := fmt.Sprintf("Commit %s: %s", short(r.Hash), p.Rule)
+			fmt.Fprintf(w, "::error title=%s::%s\n", escapeProperty(title), escapeData(r.Subject+": "+p.Message))
+		}
+	}
+}
+
+// Notice writes a GitHub Actions notice annotation.
+func Notice(w io.Writer, title, msg string) {
+	fmt.Fprintf(w, "::notice title=%s::%s\n", escapeProperty(title), escapeData(msg))
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as a JUnit XML test suite with one test
+// case per commit.
+func WriteJUnit(w io.Writer, results []Result) error {
+	suite := junitSuite{Name: "commit-messages", Tests: len(results), Failures: Failed(results)}
+	for _, r := range results {
+		c := junitCase{Name: short(r.Hash) + " " + r.Subject, ClassName: "commit-writer"}
+		if len(r.Problems) > 0 {
+			var lines []string
+			for _, p := range r.Problems {
+				lines = append(lines, p.String())
+			}
+			c.Failure = &junitFailure{Message: r.Problems[0].String(), Text: strings.Join(lines, "\n")}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
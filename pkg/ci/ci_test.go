@@ -0,0 +1,80 @@
+package ci
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/lint"
+)
+
+func TestRange(t *testing.T) {
+	for _, env := range []string{"GITHUB_BASE_REF", "GITHUB_ACTIONS", "GITHUB_EVENT_PATH", "CI_MERGE_REQUEST_DIFF_BASE_SHA", "GITLAB_CI", "CI_COMMIT_BEFORE_SHA", "CI_COMMIT_SHA"} {
+		t.Setenv(env, "")
+	}
+	if r, ok := Range(); ok {
+		t.Errorf("Range outside CI = %q", r)
+	}
+
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv("CI_COMMIT_BEFORE_SHA", "0000000000000000000000000000000000000000")
+	t.Setenv("CI_COMMIT_SHA", "bbb")
+	if r, ok := Range(); ok {
+		t.Errorf("Range for a new GitLab branch = %q", r)
+	}
+	t.Setenv("CI_COMMIT_BEFORE_SHA", "aaa")
+	if r, _ := Range(); r != "aaa..bbb" {
+		t.Errorf("GitLab push range = %q", r)
+	}
+	t.Setenv("CI_MERGE_REQUEST_DIFF_BASE_SHA", "ccc")
+	if r, _ := Range(); r != "ccc..HEAD" {
+		t.Errorf("GitLab merge request range = %q", r)
+	}
+
+	event := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(event, []byte(`{"before":"111","after":"222"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_EVENT_PATH", event)
+	if r, _ := Range(); r != "111..222" {
+		t.Errorf("GitHub push range = %q", r)
+	}
+	t.Setenv("GITHUB_BASE_REF", "main")
+	if r, _ := Range(); r != "origin/main..HEAD" {
+		t.Errorf("GitHub pull request range = %q", r)
+	}
+}
+
+var results = []Result{
+	{Hash: "0123456789abcdef", Subject: "Add login"},
+	{Hash: "fedcba9876543210", Subject: "wip, 100%", Problems: []lint.Problem{{Rule: "subject-pattern", Line: 1, Message: "subject does not match ^feat"}}},
+}
+
+func TestWriteAnnotations(t *testing.T) {
+	var b bytes.Buffer
+	WriteAnnotations(&b, results)
+	want := "::error title=Commit fedcba9%3A subject-pattern::wip, 100%25: subject does not match ^feat\n"
+	if b.String() != want {
+		t.Errorf("annotations = %q, want %q", b.String(), want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteJUnit(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`<testsuite name="commit-messages" tests="2" failures="1">`,
+		`<testcase name="0123456 Add login" classname="commit-writer"></testcase>`,
+		`<failure message="line 1: subject does not match ^feat (subject-pattern)">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("JUnit output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+// Package classify recognizes diffs that only touch one kind of file, such
+// as tests, documentation or dependency manifests, so the message can say so instead of the model
+// describing the change as a new feature.
+package classify
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/deps"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Kind is the conventional commit type of a single-purpose diff, or None.
+type Kind string
+
+const (
+	None Kind = ""
+	Test Kind = "test"
+	Docs Kind = "docs"
+	Deps Kind = "deps"
+	// Style is a formatting-only change. It is found by comparing the
+	// change with whitespace ignored, not from file names; see
+	// gitdiff.WhitespaceOnly.
+	Style Kind = "style"
+)
+
+// testDirs are directory names whose files are all tests or test fixtures.
+var testDirs = map[string]bool{
+	"test":      true,
+	"tests":     true,
+	"__tests__": true,
+	"testdata":  true,
+	"spec":      true,
+	"e2e":       true,
+}
+
+// testSuffixes are file name endings used for tests across common languages.
+var testSuffixes = []string{
+	"_test.go",
+	"_test.py",
+	"_spec.rb",
+	"_test.rb",
+	"Test.java",
+	"Tests.java",
+	"Test.kt",
+	"Tests.cs",
+	"_test.rs",
+	"_test.exs",
+}
+
+// IsTest reports whether a repo path is a test file or lives in a test
+// directory.
+func IsTest(name string) bool {
+	dir, base := path.Split(name)
+	for _, part := range strings.Split(strings.TrimSuffix(dir, "/"), "/") {
+		if testDirs[part] {
+			return true
+		}
+	}
+	for _, s := range testSuffixes {
+		if strings.HasSuffix(base, s) {
+			return true
+		}
+	}
+	// foo.test.ts, foo.spec.js, test_foo.py
+	if strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") {
+		return true
+	}
+	return strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py")
+}
+
+// docExts are documentation file extensions. Plain .txt is left out since
+// it is as often data (requirements.txt) as prose.
+var docExts = map[string]bool{
+	".md":       true,
+	".markdown": true,
+	".mdx":      true,
+	".rst":      true,
+	".adoc":     true,
+	".asciidoc": true,
+}
+
+// docNames are documentation file names, with or without an extension, at
+// any depth.
+var docNames = []string{"README", "CHANGELOG", "CONTRIBUTING", "AUTHORS", "LICENSE", "NOTICE", "CODE_OF_CONDUCT", "SECURITY"}
+
+// IsDocs reports whether a repo path is documentation: Markdown and other
+// markup files, well-known files like README, or anything under a top-level
+// docs or doc directory.
+func IsDocs(name string) bool {
+	if strings.HasPrefix(name, "docs/") || strings.HasPrefix(name, "doc/") {
+		return true
+	}
+	base := path.Base(name)
+	ext := path.Ext(base)
+	if docExts[strings.ToLower(ext)] {
+		return true
+	}
+	stem := strings.ToUpper(strings.TrimSuffix(base, ext))
+	for _, n := range docNames {
+		if stem == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff classifies a diff by the files it touches.
+func Diff(stats []gitdiff.FileStat) Kind {
+	if len(stats) == 0 {
+		return None
+	}
+	for _, check := range []struct {
+		kind Kind
+		is   func(string) bool
+	}{{Test, IsTest}, {Docs, IsDocs}, {Deps, deps.IsManifest}} {
+		all := true
+		for _, s := range stats {
+			if !check.is(s.Path) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return check.kind
+		}
+	}
+	return None
+}
+
+// Type returns the conventional commit type for the title, scoped to the
+// document when a docs change touches a single file, e.g. "docs(readme)".
+// Dependency changes are "build(deps)".
+func (k Kind) Type(stats []gitdiff.FileStat) string {
+	if k == Deps {
+		return "build(deps)"
+	}
+	if k == Docs && len(stats) == 1 {
+		base := path.Base(stats[0].Path)
+		if scope := strings.ToLower(strings.TrimSuffix(base, path.Ext(base))); scope != "" {
+			return "docs(" + scope + ")"
+		}
+	}
+	return string(k)
+}
+
+// Hint is the summarizer note for a kind; None has none.
+func (k Kind) Hint(stats []gitdiff.FileStat) string {
+	switch k {
+	case Test:
+		return fmt.Sprintf(`Only tests changed. Start the title with "%s: " and describe what the tests cover or which behavior they verify. Do not describe the tested code as a new feature.`, k.Type(stats))
+	case Docs:
+		return fmt.Sprintf(`Only documentation changed. Start the title with "%s: " and say which documents or topics were updated. Do not describe it as a code change.`, k.Type(stats))
+	case Style:
+		return fmt.Sprintf(`Only whitespace and formatting changed; the code behaves exactly as before. Start the title with "%s: ", say it is a reformat, and do not describe any functional change.`, k.Type(stats))
+	case Deps:
+		return fmt.Sprintf(`Only dependency manifests and lock files changed. Start the title with "%s: " and name the dependencies and versions that changed.`, k.Type(stats))
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package classify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestIsTest(t *testing.T) {
+	tests := map[string]bool{
+		"pkg/format/format_test.go":  true,
+		"pkg/gosem/testdata/a.go":    true,
+		"tests/integration/run.sh":   true,
+		"web/src/__tests__/App.tsx":  true,
+		"web/src/App.test.tsx":       true,
+		"web/src/cart.spec.js":       true,
+		"app/test_models.py":         true,
+		"src/test/java/FooTest.java": true,
+		"pkg/format/format.go":       false,
+		"cmd/testtool/main.go":       false,
+		"docs/testing.md":            false,
+		"src/main/java/Contest.java": false,
+		"app/models_latest.py":       false,
+	}
+	for name, want := range tests {
+		if got := IsTest(name); got != want {
+			t.Errorf("IsTest(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestIsDocs(t *testing.T) {
+	tests := map[string]bool{
+		"README.md":              true,
+		"pkg/tracker/README":     true,
+		"LICENSE.txt":            true,
+		"docs/arch/overview.png": true,
+		"guide.rst":              true,
+		"requirements.txt":       false,
+		"cmd/docs/main.go":       false,
+		"pkg/format/format.go":   false,
+	}
+	for name, want := range tests {
+		if got := IsDocs(name); got != want {
+			t.Errorf("IsDocs(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	stats := func(paths ...string) []gitdiff.FileStat {
+		var s []gitdiff.FileStat
+		for _, p := range paths {
+			s = append(s, gitdiff.FileStat{Path: p})
+		}
+		return s
+	}
+	tests := []struct {
+		paths []string
+		kind  Kind
+		typ   string
+	}{
+		{[]string{"a_test.go", "testdata/golden.txt"}, Test, "test"},
+		{[]string{"README.md"}, Docs, "docs(readme)"},
+		{[]string{"docs/install.md", "docs/img/arch.png", "CHANGELOG"}, Docs, "docs"},
+		{[]string{"a_test.go", "a.go"}, None, ""},
+		{[]string{"a_test.go", "README.md"}, None, ""},
+	}
+	for _, tt := range tests {
+		s := stats(tt.paths...)
+		k := Diff(s)
+		if k != tt.kind || k.Type(s) != tt.typ {
+			t.Errorf("Diff(%v) = %q, type %q; want %q, %q", tt.paths, k, k.Type(s), tt.kind, tt.typ)
+		}
+		if hint := k.Hint(s); (hint == "") != (k == None) || (k != None && !strings.Contains(hint, `"`+tt.typ+`: "`)) {
+			t.Errorf("Hint for %v = %q", tt.paths, hint)
+		}
+	}
+	if k := Diff(nil); k != None {
+		t.Errorf("Diff(empty) = %q", k)
+	}
+}
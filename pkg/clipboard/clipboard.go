@@ -0,0 +1,59 @@
+// Package clipboard puts text on the system clipboard with the platform's
+// own tools.
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Copy puts text on the clipboard: with pbcopy on macOS, PowerShell's
+// Set-Clipboard on Windows, and wl-copy, xclip or xsel elsewhere.
+func Copy(text string) error {
+	args, err := command(runtime.GOOS, os.Getenv("WAYLAND_DISPLAY") != "", exec.LookPath)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("%s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// command returns the clipboard command for goos, preferring wl-copy under
+// Wayland; lookPath finds the installed tools.
+func command(goos string, wayland bool, lookPath func(string) (string, error)) ([]string, error) {
+	switch goos {
+	case "darwin":
+		return []string{"pbcopy"}, nil
+	case "windows":
+		// Read stdin as UTF-8 and keep its line breaks.
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command",
+			"[Console]::InputEncoding = [Text.Encoding]::UTF8; Set-Clipboard -Value ([Console]::In.ReadToEnd())"}, nil
+	}
+	candidates := [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+	if wayland {
+		candidates = append([][]string{{"wl-copy"}}, candidates...)
+	}
+	for _, c := range candidates {
+		if _, err := lookPath(c[0]); err == nil {
+			return c, nil
+		}
+	}
+	return nil, errors.New("no clipboard tool found; install wl-clipboard (Wayland), xclip or xsel")
+}
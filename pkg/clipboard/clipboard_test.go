@@ -0,0 +1,38 @@
+package clipboard
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	installed := func(names ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			for _, n := range names {
+				if n == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+	}
+	tests := []struct {
+		goos    string
+		wayland bool
+		tools   []string
+		want    []string
+	}{
+		{"darwin", false, nil, []string{"pbcopy"}},
+		{"linux", true, []string{"wl-copy", "xclip"}, []string{"wl-copy"}},
+		{"linux", false, []string{"wl-copy", "xclip"}, []string{"xclip", "-selection", "clipboard"}},
+		{"freebsd", true, []string{"xsel"}, []string{"xsel", "--clipboard", "--input"}},
+		{"linux", false, nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := command(tt.goos, tt.wayland, installed(tt.tools...))
+		if !reflect.DeepEqual(got, tt.want) || (err != nil) != (tt.want == nil) {
+			t.Errorf("command(%s, wayland=%v, %v) = %q, %v; want %q", tt.goos, tt.wayland, tt.tools, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package config loads the user configuration file and the system-wide
+// organization policy.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/lint"
+	"github.com/kylegalloway/commit-writer/pkg/middleware"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+	"github.com/kylegalloway/commit-writer/pkg/risk"
+	"github.com/kylegalloway/commit-writer/pkg/tier"
+)
+
+// Config is the optional JSON configuration file.
+type Config struct {
+	// DenyPaths lists path globs whose diff content is never included in a
+	// prompt; only the file name and line counts are sent.
+	DenyPaths []string `json:"deny_paths,omitempty"`
+	// LocalOnly refuses to send data to anything but a loopback address.
+	// It cannot be switched off from the command line.
+	LocalOnly bool `json:"local_only,omitempty"`
+	// AuditLog is the path of an append-only JSONL log of every prompt and
+	// response exchanged with a model.
+	AuditLog string `json:"audit_log,omitempty"`
+	// History configures the log of generated messages read by
+	// "commit-writer last" and "commit-writer history".
+	History HistoryConfig `json:"history,omitempty"`
+	// Anonymize configures the pseudonymization applied by --anonymize.
+	Anonymize AnonymizeConfig `json:"anonymize,omitempty"`
+	// Keychain looks up provider API keys in the OS credential store when
+	// they are not set in the environment.
+	Keychain bool `json:"keychain,omitempty"`
+	// Provider selects the model backend: "ollama" (the default) or the name
+	// of a commit-writer-<name> provider plugin on PATH.
+	Provider string `json:"provider,omitempty"`
+	// Forge is the code host used by "commit-writer pr": "github" or
+	// "gitlab". Empty detects it from the origin remote, which fails for
+	// self-hosted GitLab on a domain without "gitlab" in it.
+	Forge string `json:"forge,omitempty"`
+	// PostProcessors and Validators name plugins run, in order, on every
+	// generated message.
+	PostProcessors []string `json:"post_processors,omitempty"`
+	Validators     []string `json:"validators,omitempty"`
+	// Pipeline replaces the built-in summarize-then-style stages.
+	Pipeline []prompt.Stage `json:"pipeline,omitempty"`
+	// Params set generation parameters, such as temperature and num_ctx,
+	// by pipeline stage name.
+	Params prompt.StageParams `json:"params,omitempty"`
+	// Middleware maps a hook point (after_diff, after_summary, before_write)
+	// to shell commands that rewrite the artifact passed on stdin.
+	Middleware middleware.Hooks `json:"middleware,omitempty"`
+	// Tracker configures the issue tracker --ticket looks up.
+	Tracker TrackerConfig `json:"tracker,omitempty"`
+	// Webhook is a Slack-compatible incoming webhook URL that receives every
+	// generated message.
+	Webhook string `json:"webhook,omitempty"`
+	// GoSemantic describes changed Go files by declaration, like
+	// --go-semantic.
+	GoSemantic bool `json:"go_semantic,omitempty"`
+	// APIChanges lists Go API changes in the body, like --api-changes.
+	APIChanges bool `json:"api_changes,omitempty"`
+	// Todos lists added TODO comments in the body, like --todos.
+	Todos bool `json:"todos,omitempty"`
+	// Verify checks the final message against the diff, like --verify.
+	Verify bool `json:"verify,omitempty"`
+	// SemverTrailer adds the inferred release bump as a trailer, like
+	// --semver-trailer.
+	SemverTrailer bool `json:"semver_trailer,omitempty"`
+	// Risk configures the risk note added by --risk.
+	Risk RiskConfig `json:"risk,omitempty"`
+	// Security configures the security note added by --security.
+	Security SecurityConfig `json:"security,omitempty"`
+	// Personas are named voices selected with --persona.
+	Personas map[string]Persona `json:"personas,omitempty"`
+	// Clean adds cleanups for the models' output, such as preambles to
+	// remove.
+	Clean format.CleanRules `json:"clean,omitempty"`
+	// Rules are the message checks "commit-writer ci" enforces.
+	Rules lint.Rules `json:"rules,omitempty"`
+	// ModelTiers pick the models by the size of the diff.
+	ModelTiers tier.Tiers `json:"model_tiers,omitempty"`
+	// Models set stop sequences and a prompt template per model name.
+	Models prompt.ModelParams `json:"models,omitempty"`
+	// Repos turns commit-writer off in some repositories, by directory or
+	// remote URL.
+	Repos RepoFilter `json:"repos,omitempty"`
+}
+
+// Persona is a named voice for the style stage, e.g. shared across a team
+// as a config snippet.
+type Persona struct {
+	// Tone is a tone description or weighted blend, as for --tone.
+	Tone string `json:"tone,omitempty"`
+	// Intensity is the default --intensity, from 0 to 1.
+	Intensity *float64 `json:"intensity,omitempty"`
+	// Examples are commit messages in the persona's voice, as in a
+	// --style-examples file.
+	Examples []string `json:"examples,omitempty"`
+	// Quirks are formatting rules for the style model, such as "end the
+	// title with an emoji".
+	Quirks []string `json:"quirks,omitempty"`
+}
+
+// Validate checks the persona's tone and intensity.
+func (p Persona) Validate() error {
+	if p.Tone == "" && len(p.Examples) == 0 && len(p.Quirks) == 0 {
+		return errors.New("needs a tone, examples or quirks")
+	}
+	intensity := 1.0
+	if p.Intensity != nil {
+		intensity = *p.Intensity
+	}
+	if p.Tone != "" || p.Intensity != nil {
+		if _, err := prompt.DescribeTone(p.Tone, intensity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RiskConfig controls the risk note appended to the message body.
+type RiskConfig struct {
+	// Enabled adds the note on every run, like --risk.
+	Enabled bool `json:"enabled,omitempty"`
+	// Rules are checked in addition to the built-in ones for auth code,
+	// migrations, public API and CI configuration.
+	Rules risk.Rules `json:"rules,omitempty"`
+}
+
+// SecurityConfig controls the security note appended to the message body.
+type SecurityConfig struct {
+	// Enabled adds the note on every run, like --security.
+	Enabled bool `json:"enabled,omitempty"`
+	// Rules are checked in addition to the built-in ones for cryptography,
+	// auth and permission checks, CORS, SQL built from strings and TLS
+	// verification.
+	Rules risk.Rules `json:"rules,omitempty"`
+}
+
+// TrackerConfig controls ticket lookup from the branch name.
+type TrackerConfig struct {
+	// Enabled looks up the ticket on every run, like --ticket.
+	Enabled bool `json:"enabled,omitempty"`
+	// Provider is "jira" (the default), "linear" or "azure".
+	Provider string       `json:"provider,omitempty"`
+	Jira     JiraConfig   `json:"jira,omitempty"`
+	Linear   LinearConfig `json:"linear,omitempty"`
+	Azure    AzureConfig  `json:"azure,omitempty"`
+}
+
+// AzureConfig configures Azure Boards work item linking. The work item is
+// only fetched when Organization and Project are set; the personal access
+// token comes from AZURE_DEVOPS_EXT_PAT or, with keychain enabled, the
+// "azure-devops" keychain entry.
+type AzureConfig struct {
+	// URL is the server root for Azure DevOps Server; the cloud service is
+	// used when empty.
+	URL          string `json:"url,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Project      string `json:"project,omitempty"`
+	// Footer is a word put before the AB#1234 mention, e.g. "Fixes" to
+	// close the work item; by default the mention only links it.
+	Footer string `json:"footer,omitempty"`
+}
+
+// LinearConfig configures Linear lookups. The API key comes from
+// LINEAR_API_KEY or, with keychain enabled, the "linear" keychain entry.
+type LinearConfig struct {
+	// Teams restricts which issue keys are recognized in branch names, and
+	// allows the lower-case keys of Linear's generated branch names.
+	Teams []string `json:"teams,omitempty"`
+	// Footer is the magic word put before the issue key in the message
+	// footer: "Fixes" by default, "none" to leave the footer out.
+	Footer string `json:"footer,omitempty"`
+}
+
+// JiraConfig locates a Jira site. The API token comes from JIRA_API_TOKEN
+// or, with keychain enabled, the "jira" keychain entry.
+type JiraConfig struct {
+	// URL is the site root; JIRA_URL is used when empty.
+	URL string `json:"url,omitempty"`
+	// Email selects basic auth for Jira Cloud; JIRA_EMAIL is used when
+	// empty. Without an email the token is sent as a bearer token.
+	Email string `json:"email,omitempty"`
+	// Projects restricts which keys are recognized in branch names, and
+	// allows lower-case keys such as "proj-123".
+	Projects []string `json:"projects,omitempty"`
+}
+
+// HistoryConfig controls where generated messages are kept.
+type HistoryConfig struct {
+	// Path replaces the default per-user history file.
+	Path string `json:"path,omitempty"`
+	// Disabled stops recording generated messages.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// AnonymizeConfig controls which identifiers are pseudonymized before
+// content is sent to a model. Emails are always replaced when enabled.
+type AnonymizeConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Terms maps project codenames and other sensitive words to their
+	// replacement. An empty replacement gets a generated pseudonym.
+	Terms map[string]string `json:"terms,omitempty"`
+	// Domains lists internal DNS suffixes in addition to the built-in
+	// .internal, .corp, .local, .lan and .intranet.
+	Domains []string `json:"domains,omitempty"`
+}
+
+// Stages returns every stage a run may use: the configured or default
+// pipeline and that of "commit-writer pr".
+func (c Config) Stages() []prompt.Stage {
+	stages := c.Pipeline
+	if len(stages) == 0 {
+		stages = prompt.DefaultPipeline
+	}
+	return append(append([]prompt.Stage{}, stages...), prompt.PullRequestPipeline...)
+}
+
+// DefaultPath returns the per-user config location, e.g.
+// ~/.config/commit-writer/config.json on Linux.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "commit-writer", "config.json")
+}
+
+// Load reads the config file at path. A missing file is only an error
+// when the path was given explicitly.
+func Load(path string, explicit bool) (Config, error) {
+	var cfg Config
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if err := prompt.ValidatePipeline(cfg.Pipeline); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Params.Validate(cfg.Stages()); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: params: %w", path, err)
+	}
+	if err := cfg.Middleware.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Clean.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Rules.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Models.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: models: %w", path, err)
+	}
+	if err := cfg.ModelTiers.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Risk.Rules.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if err := cfg.Security.Rules.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: security: %w", path, err)
+	}
+	for name, p := range cfg.Personas {
+		if err := p.Validate(); err != nil {
+			return cfg, fmt.Errorf("invalid config %s: persona %q: %w", path, name, err)
+		}
+	}
+	return cfg, nil
+}
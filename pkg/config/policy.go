@@ -0,0 +1,131 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Policy is the read-only, system-wide configuration for managed machines.
+// Anything it sets overrides the user config file and command-line flags.
+type Policy struct {
+	// LocalOnly forces --local-only.
+	LocalOnly bool `json:"local_only,omitempty"`
+	// Redact forces secret redaction; --no-redact is ignored.
+	Redact bool `json:"redact,omitempty"`
+	// DenyPaths are added to the sensitive path list.
+	DenyPaths []string `json:"deny_paths,omitempty"`
+	// AuditLog forces an audit log location, replacing the user's choice.
+	AuditLog string `json:"audit_log,omitempty"`
+	// AllowedProviders restricts which model providers may be used; empty
+	// allows all.
+	AllowedProviders []string `json:"allowed_providers,omitempty"`
+	// ForbiddenTones lists case-insensitive substrings not allowed in --tone.
+	ForbiddenTones []string `json:"forbidden_tones,omitempty"`
+	// Repos change the policy for some repositories; the first rule whose
+	// patterns match the current repository applies.
+	Repos []RepoPolicy `json:"repos,omitempty"`
+}
+
+// RepoPolicy is a policy rule for the repositories matching any of Match,
+// with the patterns of Repo.Match, e.g. "github.com/opensource/**" or
+// "gitlab.corp/**". Settings left out keep the policy's.
+type RepoPolicy struct {
+	Match []string `json:"match"`
+	// LocalOnly replaces the policy's local_only, so a rule can also allow
+	// remote models where the policy otherwise forbids them.
+	LocalOnly *bool `json:"local_only,omitempty"`
+	// Redact replaces the policy's redact.
+	Redact *bool `json:"redact,omitempty"`
+	// DenyPaths are added to the policy's.
+	DenyPaths []string `json:"deny_paths,omitempty"`
+	// AllowedProviders replace the policy's.
+	AllowedProviders []string `json:"allowed_providers,omitempty"`
+}
+
+// SystemPolicyPath returns the platform location of the organization policy
+// file.
+func SystemPolicyPath() string {
+	switch runtime.GOOS {
+	case "windows":
+		dir := os.Getenv("ProgramData")
+		if dir == "" {
+			dir = `C:\ProgramData`
+		}
+		return filepath.Join(dir, "commit-writer", "policy.json")
+	case "darwin":
+		return "/Library/Application Support/commit-writer/policy.json"
+	default:
+		return "/etc/commit-writer/policy.json"
+	}
+}
+
+// LoadPolicy reads the policy file at path. A missing file means no policy;
+// any other failure is returned so callers fail closed.
+func LoadPolicy(path string) (Policy, bool, error) {
+	var p Policy
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, false, nil
+	} else if err != nil {
+		return p, false, fmt.Errorf("failed to read policy %s: %w", path, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, false, fmt.Errorf("failed to parse policy %s: %w", path, err)
+	}
+	return p, true, nil
+}
+
+// ForRepo returns the policy for repository r: p with the first matching
+// rule in Repos applied, and the pattern that matched.
+func (p Policy) ForRepo(r Repo) (Policy, string) {
+	for _, rule := range p.Repos {
+		pattern, ok := r.Match(rule.Match)
+		if !ok {
+			continue
+		}
+		if rule.LocalOnly != nil {
+			p.LocalOnly = *rule.LocalOnly
+		}
+		if rule.Redact != nil {
+			p.Redact = *rule.Redact
+		}
+		p.DenyPaths = append(append([]string{}, p.DenyPaths...), rule.DenyPaths...)
+		if rule.AllowedProviders != nil {
+			p.AllowedProviders = rule.AllowedProviders
+		}
+		return p, pattern
+	}
+	return p, ""
+}
+
+// CheckProvider returns an error if the policy does not allow provider.
+func (p Policy) CheckProvider(provider string) error {
+	if len(p.AllowedProviders) == 0 {
+		return nil
+	}
+	for _, a := range p.AllowedProviders {
+		if strings.EqualFold(a, provider) {
+			return nil
+		}
+	}
+	return fmt.Errorf("provider %q is not allowed by policy (allowed: %s)", provider, strings.Join(p.AllowedProviders, ", "))
+}
+
+// CheckTone returns an error if tone contains a forbidden phrase.
+func (p Policy) CheckTone(tone string) error {
+	lower := strings.ToLower(tone)
+	for _, f := range p.ForbiddenTones {
+		if f != "" && strings.Contains(lower, strings.ToLower(f)) {
+			return fmt.Errorf("tone %q is not allowed by policy (contains %q)", tone, f)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyForRepo(t *testing.T) {
+	yes, no := true, false
+	p := Policy{
+		LocalOnly:        true,
+		DenyPaths:        []string{"keys/**"},
+		AllowedProviders: []string{"ollama"},
+		Repos: []RepoPolicy{
+			{Match: []string{"github.com/opensource/*"}, LocalOnly: &no, AllowedProviders: []string{"ollama", "openai"}},
+			{Match: []string{"gitlab.corp/**"}, Redact: &yes, DenyPaths: []string{"deploy/**"}},
+			{Match: []string{"*"}, DenyPaths: []string{"never/**"}},
+		},
+	}
+
+	oss, pattern := p.ForRepo(Repo{Root: "/src/cli", Remotes: []string{"git@github.com:opensource/cli.git"}})
+	if pattern != "github.com/opensource/*" || oss.LocalOnly || oss.CheckProvider("openai") != nil {
+		t.Errorf("open source repo: %q, %+v", pattern, oss)
+	}
+
+	corp, pattern := p.ForRepo(Repo{Root: "/src/api", Remotes: []string{"https://gitlab.corp/team/api.git"}})
+	if pattern != "gitlab.corp/**" || !corp.LocalOnly || !corp.Redact || !reflect.DeepEqual(corp.DenyPaths, []string{"keys/**", "deploy/**"}) {
+		t.Errorf("internal repo: %q, %+v", pattern, corp)
+	}
+	if !reflect.DeepEqual(p.DenyPaths, []string{"keys/**"}) {
+		t.Errorf("ForRepo changed the policy's deny paths: %q", p.DenyPaths)
+	}
+
+	if _, pattern := (Policy{}).ForRepo(Repo{Root: "/src/api"}); pattern != "" {
+		t.Errorf("policy without rules matched %q", pattern)
+	}
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Repo identifies the repository a run is in, for settings that only
+// apply to some repositories.
+type Repo struct {
+	// Root is the repository's top-level directory.
+	Root string
+	// Remotes are the URLs of its remotes.
+	Remotes []string
+}
+
+// RepoFilter turns commit-writer off in some repositories.
+type RepoFilter struct {
+	// Disable lists repository patterns where commit-writer does nothing;
+	// the git hook passes without touching the message.
+	Disable []string `json:"disable,omitempty"`
+	// Enable lists exceptions to Disable, e.g. Disable ["*"] and Enable
+	// only the repositories commit-writer may be used in.
+	Enable []string `json:"enable,omitempty"`
+}
+
+// Disabled reports whether f turns commit-writer off in r, and the
+// pattern that does.
+func (f RepoFilter) Disabled(r Repo) (string, bool) {
+	pattern, ok := r.Match(f.Disable)
+	if !ok {
+		return "", false
+	}
+	if _, ok := r.Match(f.Enable); ok {
+		return "", false
+	}
+	return pattern, true
+}
+
+// Match returns the first of patterns that matches r. A pattern starting
+// with "/", "~" or a drive letter is a directory glob matching the root
+// directory or any directory above it, such as "~/work/client-*"; any
+// other is a glob, with "**" for any number of segments, matched against
+// each remote URL without its scheme, user, port and ".git", such as
+// "github.com/acme/**" for git@github.com:acme/tools/cli.git. "*" matches
+// every repository.
+func (r Repo) Match(patterns []string) (string, bool) {
+	for _, p := range patterns {
+		if p == "*" {
+			return p, true
+		}
+		if isDirPattern(p) {
+			if r.Root != "" && matchDir(expandHome(p), filepath.ToSlash(r.Root)) {
+				return p, true
+			}
+			continue
+		}
+		for _, remote := range r.Remotes {
+			if gitdiff.MatchPath(RemoteKey(p), RemoteKey(remote)) {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// RemoteKey reduces a remote URL to host and path, e.g. "github.com/acme/cli"
+// for https://me@github.com:443/acme/cli.git or git@github.com:acme/cli.git.
+func RemoteKey(url string) string {
+	u := strings.TrimSpace(url)
+	scp := true
+	if _, rest, ok := strings.Cut(u, "://"); ok {
+		u, scp = rest, false
+	}
+	if i := strings.Index(u, "@"); i >= 0 && (strings.IndexByte(u, '/') < 0 || i < strings.IndexByte(u, '/')) {
+		u = u[i+1:]
+	}
+	host, rest, _ := strings.Cut(u, "/")
+	if scp {
+		// git@host:owner/repo
+		if h, p, ok := strings.Cut(host, ":"); ok {
+			host, rest = h, strings.TrimPrefix(p+"/"+rest, "/")
+		}
+	} else if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+	key := strings.ToLower(host)
+	if rest = strings.TrimSuffix(strings.TrimSuffix(rest, "/"), ".git"); rest != "" {
+		key += "/" + rest
+	}
+	return key
+}
+
+// isDirPattern reports whether a pattern names directories rather than
+// remotes.
+func isDirPattern(p string) bool {
+	return strings.HasPrefix(p, "/") || strings.HasPrefix(p, "~") ||
+		len(p) >= 2 && p[1] == ':' && (p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z')
+}
+
+// expandHome replaces a leading "~" with the home directory and returns
+// the pattern with forward slashes.
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = home + p[1:]
+		}
+	}
+	return filepath.ToSlash(p)
+}
+
+// matchDir reports whether the glob matches dir or a directory above it.
+func matchDir(pattern, dir string) bool {
+	pattern = "/" + strings.Trim(pattern, "/")
+	for d := dir; ; {
+		if gitdiff.MatchPath(pattern, strings.TrimPrefix(d, "/")) {
+			return true
+		}
+		parent := path.Dir(d)
+		if parent == d {
+			return false
+		}
+		d = parent
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoteKey(t *testing.T) {
+	for url, want := range map[string]string{
+		"git@github.com:acme/cli.git":             "github.com/acme/cli",
+		"https://me@GitHub.com:443/acme/cli.git/": "github.com/acme/cli",
+		"ssh://git@gitlab.corp:2222/team/sub/app": "gitlab.corp/team/sub/app",
+		"github.com/acme/*":                       "github.com/acme/*",
+		"https://gitlab.corp":                     "gitlab.corp",
+	} {
+		if got := RemoteKey(url); got != want {
+			t.Errorf("RemoteKey(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestRepoFilter(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	client := Repo{Root: filepath.Join(home, "work", "client-a", "api"), Remotes: []string{"git@gitlab.corp:client-a/api.git"}}
+	oss := Repo{Root: "/src/cli", Remotes: []string{"https://github.com/acme/cli.git"}}
+	local := Repo{Root: "/src/scratch"}
+	for _, tc := range []struct {
+		filter RepoFilter
+		repo   Repo
+		want   string
+	}{
+		{RepoFilter{Disable: []string{"~/work/client-*"}}, client, "~/work/client-*"},
+		{RepoFilter{Disable: []string{"~/work/client-*"}}, oss, ""},
+		{RepoFilter{Disable: []string{"gitlab.corp/**"}}, client, "gitlab.corp/**"},
+		{RepoFilter{Disable: []string{"https://github.com/acme/*"}}, oss, "https://github.com/acme/*"},
+		{RepoFilter{Disable: []string{"gitlab.corp/**"}}, local, ""},
+		{RepoFilter{Disable: []string{"*"}, Enable: []string{"github.com/acme/**"}}, oss, ""},
+		{RepoFilter{Disable: []string{"*"}, Enable: []string{"github.com/acme/**"}}, local, "*"},
+		{RepoFilter{Enable: []string{"*"}}, client, ""},
+	} {
+		pattern, off := tc.filter.Disabled(tc.repo)
+		if pattern != tc.want || off != (tc.want != "") {
+			t.Errorf("%+v.Disabled(%s) = %q, %v; want %q", tc.filter, tc.repo.Root, pattern, off, tc.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Package conflict describes how the conflicts of a merge were resolved, so
+// the message of a merge commit can explain the resolution instead of
+// retelling what each branch changed.
+package conflict
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Choice is how a conflicted file was resolved.
+type Choice string
+
+const (
+	// Ours keeps the checked-out branch's version of the file.
+	Ours Choice = "ours"
+	// Theirs takes the merged branch's version.
+	Theirs Choice = "theirs"
+	// Combined mixes lines from both sides, possibly with new ones.
+	Combined Choice = "combined"
+	// Deleted removes the file.
+	Deleted Choice = "deleted"
+)
+
+// Resolution is how one conflicted file ended up.
+type Resolution struct {
+	Path   string
+	Choice Choice
+	// FromOurs, FromTheirs and New count the lines of a Combined result
+	// found only in our version, only in theirs, or in neither.
+	FromOurs   int
+	FromTheirs int
+	New        int
+	// Markers is set when the result still contains conflict markers.
+	Markers bool
+}
+
+// String describes the resolution for the summarizer, e.g. "api.go:
+// combined both versions (4 lines only from HEAD, 2 only from the merged
+// branch, 1 new)".
+func (r Resolution) String() string {
+	var s string
+	switch r.Choice {
+	case Ours:
+		s = "kept HEAD's version"
+	case Theirs:
+		s = "took the merged branch's version"
+	case Deleted:
+		s = "deleted the file"
+	default:
+		s = fmt.Sprintf("combined both versions (%d lines only from HEAD, %d only from the merged branch, %d new)", r.FromOurs, r.FromTheirs, r.New)
+	}
+	if r.Markers {
+		s += "; conflict markers are still present"
+	}
+	return r.Path + ": " + s
+}
+
+// Files returns the conflicted paths git lists in a merge's MERGE_MSG,
+// under "Conflicts:" and usually commented out with "#".
+func Files(mergeMsg string) []string {
+	var files []string
+	in := false
+	for _, line := range strings.Split(mergeMsg, "\n") {
+		trimmed := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		switch {
+		case trimmed == "Conflicts:":
+			in = true
+		case !in:
+		case strings.HasPrefix(strings.TrimPrefix(line, "#"), "\t") && trimmed != "":
+			files = append(files, trimmed)
+		case trimmed != "":
+			in = false
+		}
+	}
+	return files
+}
+
+// Subject returns the first line of a MERGE_MSG that is not a comment,
+// e.g. "Merge branch 'feature' into main".
+func Subject(mergeMsg string) string {
+	for _, line := range strings.Split(mergeMsg, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// Resolve compares the resolved content of path with our and their
+// versions. Nil means the file is absent on that side; a nil result is a
+// deletion.
+func Resolve(path string, ours, theirs, result []byte) Resolution {
+	r := Resolution{Path: path, Markers: hasMarkers(result)}
+	switch {
+	case result == nil:
+		r.Choice = Deleted
+	case ours != nil && bytes.Equal(result, ours):
+		r.Choice = Ours
+	case theirs != nil && bytes.Equal(result, theirs):
+		r.Choice = Theirs
+	default:
+		r.Choice = Combined
+		inOurs, inTheirs := lineSet(ours), lineSet(theirs)
+		for _, line := range lines(result) {
+			switch {
+			case strings.TrimSpace(line) == "":
+			case inOurs[line] && !inTheirs[line]:
+				r.FromOurs++
+			case inTheirs[line] && !inOurs[line]:
+				r.FromTheirs++
+			case !inOurs[line] && !inTheirs[line]:
+				r.New++
+			}
+		}
+	}
+	return r
+}
+
+// hasMarkers reports whether content still has a conflict's opening and
+// closing markers.
+func hasMarkers(content []byte) bool {
+	var open bool
+	for _, line := range lines(content) {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<< "):
+			open = true
+		case open && strings.HasPrefix(line, ">>>>>>> "):
+			return true
+		}
+	}
+	return false
+}
+
+func lines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// lineSet holds the non-blank lines of content.
+func lineSet(content []byte) map[string]bool {
+	set := map[string]bool{}
+	for _, line := range lines(content) {
+		if strings.TrimSpace(line) != "" {
+			set[line] = true
+		}
+	}
+	return set
+}
+
+// Hint tells the summarizer that the diff concludes a merge and how each
+// conflict was resolved. It is empty without resolutions.
+func Hint(subject string, resolutions []Resolution) string {
+	if len(resolutions) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("This commit concludes a merge")
+	if subject != "" {
+		fmt.Fprintf(&b, " (%q)", subject)
+	}
+	b.WriteString(". The diff shows only the files that had conflicts, against HEAD. Describe how the conflicts were resolved and why, not the changes either branch made; start the title with \"Merge\". Resolutions:")
+	for _, r := range resolutions {
+		b.WriteString("\n- " + r.String())
+	}
+	return b.String()
+}
+
+// Message is the commit message for a merge without a model: the merge's
+// subject and, unless titleOnly, the resolution of each conflict.
+func Message(subject string, resolutions []Resolution, titleOnly bool) string {
+	if subject == "" {
+		subject = "Merge and resolve conflicts"
+	}
+	if titleOnly || len(resolutions) == 0 {
+		return subject
+	}
+	var b strings.Builder
+	b.WriteString(subject + "\n\nResolved conflicts:")
+	for _, r := range resolutions {
+		b.WriteString("\n- " + r.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package conflict
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFiles(t *testing.T) {
+	msg := "Merge branch 'feature'\n\n# Conflicts:\n#\tapi.go\n#\tdocs/my guide.md\n#\n# It looks like you may be committing a merge.\n"
+	if got := Files(msg); !reflect.DeepEqual(got, []string{"api.go", "docs/my guide.md"}) {
+		t.Errorf("commented Files = %q", got)
+	}
+	// Older git wrote the list uncommented.
+	if got := Files("Merge branch 'feature'\n\nConflicts:\n\tapi.go\n"); !reflect.DeepEqual(got, []string{"api.go"}) {
+		t.Errorf("plain Files = %q", got)
+	}
+	if got := Files("Merge branch 'feature'\n"); got != nil {
+		t.Errorf("clean merge Files = %q", got)
+	}
+	if got := Subject("# comment\n\nMerge branch 'feature' into main\n"); got != "Merge branch 'feature' into main" {
+		t.Errorf("Subject = %q", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ours := []byte("a\nours\n\nz\n")
+	theirs := []byte("a\ntheirs\n\nz\n")
+	for _, tt := range []struct {
+		name   string
+		result []byte
+		want   Resolution
+	}{
+		{"ours", ours, Resolution{Path: "f", Choice: Ours}},
+		{"theirs", theirs, Resolution{Path: "f", Choice: Theirs}},
+		{"deleted", nil, Resolution{Path: "f", Choice: Deleted}},
+		{"combined", []byte("a\nours\ntheirs\n\nnew\nz\n"), Resolution{Path: "f", Choice: Combined, FromOurs: 1, FromTheirs: 1, New: 1}},
+		{"markers", []byte("a\n<<<<<<< HEAD\nours\n=======\ntheirs\n>>>>>>> feature\nz\n"), Resolution{Path: "f", Choice: Combined, FromOurs: 1, FromTheirs: 1, New: 3, Markers: true}},
+	} {
+		if got := Resolve("f", ours, theirs, tt.result); got != tt.want {
+			t.Errorf("%s: Resolve = %+v", tt.name, got)
+		}
+	}
+	// A file added on their side only is theirs, not ours.
+	if got := Resolve("f", nil, []byte("x\n"), []byte("x\n")); got.Choice != Theirs {
+		t.Errorf("added file = %+v", got)
+	}
+}
+
+func TestHintAndMessage(t *testing.T) {
+	rs := []Resolution{{Path: "api.go", Choice: Ours}, {Path: "db.go", Choice: Combined, FromOurs: 2, FromTheirs: 1}}
+	hint := Hint("Merge branch 'feature'", rs)
+	for _, want := range []string{`"Merge branch 'feature'"`, "not the changes either branch made", "- api.go: kept HEAD's version", "- db.go: combined both versions (2 lines only from HEAD, 1 only from the merged branch, 0 new)"} {
+		if !strings.Contains(hint, want) {
+			t.Errorf("Hint missing %q:\n%s", want, hint)
+		}
+	}
+	if Hint("Merge", nil) != "" {
+		t.Error("Hint without resolutions should be empty")
+	}
+	if got := Message("Merge branch 'feature'", rs[:1], false); got != "Merge branch 'feature'\n\nResolved conflicts:\n- api.go: kept HEAD's version" {
+		t.Errorf("Message = %q", got)
+	}
+	if got := Message("", rs, true); got != "Merge and resolve conflicts" {
+		t.Errorf("title-only Message = %q", got)
+	}
+}
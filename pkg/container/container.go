@@ -0,0 +1,306 @@
+// Package container finds Ollama when commit-writer and Ollama do not run
+// side by side: one of them is in a Docker or Podman container and the
+// other on the host, where "localhost" means two different machines.
+package container
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ports are the ports Ollama is commonly served or published on: its
+// default and the usual second instance.
+var Ports = []string{"11434", "11435"}
+
+// HostNames name the container's host from inside a container: Docker
+// Desktop's (or Linux with --add-host=host.docker.internal:host-gateway)
+// and Podman's.
+var HostNames = []string{"host.docker.internal", "host.containers.internal"}
+
+// Inside reports whether this process runs in a container.
+func Inside() bool {
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	data, _ := os.ReadFile("/proc/1/cgroup")
+	return containerCgroup(string(data))
+}
+
+// containerCgroup reports whether a /proc/1/cgroup listing belongs to a
+// container runtime.
+func containerCgroup(cgroup string) bool {
+	for _, runtime := range []string{"docker", "kubepods", "containerd", "libpod"} {
+		if strings.Contains(cgroup, runtime) {
+			return true
+		}
+	}
+	return false
+}
+
+// Candidates returns the base URLs Ollama may answer on: localhost on
+// each of Ports and, inside a container, the host's names and the default
+// gateway, which is the host on Docker's bridge network.
+func Candidates(inside bool) []string {
+	hosts := []string{"localhost"}
+	if inside {
+		hosts = append(hosts, HostNames...)
+		if gw := gateway(); gw != "" {
+			hosts = append(hosts, gw)
+		}
+	}
+	var urls []string
+	for _, h := range hosts {
+		for _, p := range Ports {
+			urls = append(urls, "http://"+net.JoinHostPort(h, p))
+		}
+	}
+	return urls
+}
+
+// gateway returns the default route's gateway from /proc/net/route, or
+// "" when there is none or the file does not exist.
+func gateway() string {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return ""
+	}
+	return defaultGateway(string(data))
+}
+
+// defaultGateway parses a /proc/net/route table, whose addresses are
+// little-endian hex.
+func defaultGateway(table string) string {
+	sc := bufio.NewScanner(strings.NewReader(table))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+		if !ip.IsUnspecified() {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// Find probes the base URLs at once and returns the first, in order, whose
+// /api/tags answers like Ollama, or "" when none does. client may be nil.
+func Find(ctx context.Context, client *http.Client, candidates []string) string {
+	if client == nil {
+		client = &http.Client{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	ok := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, base := range candidates {
+		wg.Add(1)
+		go func(i int, base string) {
+			defer wg.Done()
+			ok[i] = answers(ctx, client, base)
+		}(i, base)
+	}
+	wg.Wait()
+	for i, base := range candidates {
+		if ok[i] {
+			return base
+		}
+	}
+	return ""
+}
+
+// lastPath returns where Remember keeps the address Ollama was last found
+// on, e.g. ~/.cache/commit-writer/ollama-url on Linux.
+func lastPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "commit-writer", "ollama-url")
+}
+
+// Last returns the base URL Remember stored, or "" when there is none.
+func Last() string {
+	path := lastPath()
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Remember stores base, the address Ollama was found on, so the next run
+// can try it before probing every candidate again. An empty base forgets
+// it.
+func Remember(base string) error {
+	path := lastPath()
+	if path == "" {
+		return nil
+	}
+	if base == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(base+"\n"), 0644)
+}
+
+// answers reports whether base serves Ollama's model list.
+func answers(ctx context.Context, client *http.Client, base string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/tags", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Ollama is a running container of the ollama/ollama image.
+type Ollama struct {
+	Name string
+	// HostPorts are the host ports Ollama's port 11434 is published on;
+	// none when it is not published.
+	HostPorts []string
+}
+
+// dockerPS lists running containers as "name\timage\tports" lines.
+var dockerPS = func(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", "ps", "--format", "{{.Names}}\t{{.Image}}\t{{.Ports}}").Output()
+	return string(out), err
+}
+
+// Running returns the running Ollama containers, or none when docker is
+// not installed or its daemon is not reachable.
+func Running(ctx context.Context) []Ollama {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	out, err := dockerPS(ctx)
+	if err != nil {
+		return nil
+	}
+	var found []Ollama
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 3)
+		if len(fields) < 2 || !strings.Contains(fields[1], "ollama") {
+			continue
+		}
+		o := Ollama{Name: fields[0]}
+		if len(fields) == 3 {
+			o.HostPorts = hostPorts(fields[2])
+		}
+		found = append(found, o)
+	}
+	return found
+}
+
+// hostPorts returns the host ports docker ps shows published for 11434,
+// e.g. "11435" from "0.0.0.0:11435->11434/tcp, :::11435->11434/tcp".
+func hostPorts(ports string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, p := range strings.Split(ports, ",") {
+		host, target, ok := strings.Cut(strings.TrimSpace(p), "->")
+		if !ok || target != "11434/tcp" {
+			continue
+		}
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[i+1:]
+		}
+		if host != "" && !seen[host] {
+			seen[host] = true
+			out = append(out, host)
+		}
+	}
+	return out
+}
+
+// Hint explains why Ollama at rawURL may be unreachable when commit-writer
+// or Ollama runs in a container, or returns "" when neither seems to.
+func Hint(ctx context.Context, rawURL string) string {
+	return hint(ctx, rawURL, Inside())
+}
+
+func hint(ctx context.Context, rawURL string, inside bool) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "11434"
+	}
+	hostName := false
+	for _, h := range HostNames {
+		hostName = hostName || strings.EqualFold(host, h)
+	}
+	switch {
+	case inside && loopback(host):
+		return fmt.Sprintf("commit-writer runs in a container, where %s is the container itself; to reach Ollama on the host use --ollama http://host.docker.internal:%s, start Ollama with OLLAMA_HOST=0.0.0.0 so it listens beyond the host's loopback, and on Linux run the container with --add-host=host.docker.internal:host-gateway", host, port)
+	case inside && hostName:
+		if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+			return fmt.Sprintf("%s does not resolve in this container; on Linux run it with --add-host=%s:host-gateway", host, host)
+		}
+		return fmt.Sprintf("the host did not answer on port %s; Ollama listens only on the host's loopback unless started with OLLAMA_HOST=0.0.0.0", port)
+	case !inside && hostName:
+		return fmt.Sprintf("%s only resolves inside containers, and commit-writer is not running in one; use --ollama http://localhost:%s", host, port)
+	case !inside && loopback(host):
+		for _, o := range Running(ctx) {
+			if len(o.HostPorts) == 0 {
+				return fmt.Sprintf("Ollama runs in the Docker container %s without a published port; recreate it with -p %s:11434", o.Name, port)
+			}
+			if !contains(o.HostPorts, port) {
+				return fmt.Sprintf("Ollama runs in the Docker container %s, published on port %s; use --ollama http://localhost:%s", o.Name, o.HostPorts[0], o.HostPorts[0])
+			}
+		}
+	}
+	return ""
+}
+
+// loopback reports whether host names this machine.
+func loopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
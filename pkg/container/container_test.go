@@ -0,0 +1,101 @@
+package container
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultGateway(t *testing.T) {
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\n" +
+		"eth0\t00000000\t010011AC\t0003\t0\t0\t0\t00000000\n"
+	if got := defaultGateway(table); got != "172.17.0.1" {
+		t.Errorf("defaultGateway = %q", got)
+	}
+	if got := defaultGateway("Iface\tDestination\tGateway\n"); got != "" {
+		t.Errorf("defaultGateway(no default route) = %q", got)
+	}
+}
+
+func TestHostPorts(t *testing.T) {
+	for ports, want := range map[string][]string{
+		"0.0.0.0:11435->11434/tcp, :::11435->11434/tcp": {"11435"},
+		"127.0.0.1:11434->11434/tcp":                    {"11434"},
+		"11434/tcp":                                     nil,
+		"":                                              nil,
+	} {
+		if got := hostPorts(ports); !reflect.DeepEqual(got, want) {
+			t.Errorf("hostPorts(%q) = %q, want %q", ports, got, want)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	defer dead.Close()
+	if got := Find(context.Background(), nil, []string{dead.URL, "http://127.0.0.1:1", srv.URL}); got != srv.URL {
+		t.Errorf("Find = %q, want %q", got, srv.URL)
+	}
+	if got := Find(context.Background(), nil, []string{dead.URL}); got != "" {
+		t.Errorf("Find(no Ollama) = %q", got)
+	}
+}
+
+func TestRemember(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if got := Last(); got != "" {
+		t.Errorf("Last() before Remember = %q", got)
+	}
+	if err := Remember("http://172.17.0.1:11434"); err != nil {
+		t.Fatal(err)
+	}
+	if got := Last(); got != "http://172.17.0.1:11434" {
+		t.Errorf("Last() = %q", got)
+	}
+	if err := Remember(""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Last(); got != "" {
+		t.Errorf("Last() after forgetting = %q", got)
+	}
+}
+
+func TestHint(t *testing.T) {
+	defer func(ps func(context.Context) (string, error)) { dockerPS = ps }(dockerPS)
+	dockerPS = func(context.Context) (string, error) {
+		return "web\tnginx\t0.0.0.0:80->80/tcp\nllm\tollama/ollama:latest\t0.0.0.0:11500->11434/tcp\n", nil
+	}
+	ctx := context.Background()
+	for _, tc := range []struct {
+		url    string
+		inside bool
+		want   string
+	}{
+		{"http://localhost:11434/api/generate", true, "--ollama http://host.docker.internal:11434"},
+		{"http://host.docker.internal:11434/api/generate", false, "only resolves inside containers"},
+		{"http://127.0.0.1:11434/api/generate", false, "published on port 11500; use --ollama http://localhost:11500"},
+		{"http://localhost:11500/api/generate", false, ""},
+		{"http://gpu-box:11434/api/generate", false, ""},
+	} {
+		got := hint(ctx, tc.url, tc.inside)
+		if tc.want == "" && got != "" || !strings.Contains(got, tc.want) {
+			t.Errorf("hint(%s, inside=%v) = %q, want %q", tc.url, tc.inside, got, tc.want)
+		}
+	}
+
+	dockerPS = func(context.Context) (string, error) { return "llm\tollama/ollama\t11434/tcp\n", nil }
+	if got := hint(ctx, "http://localhost:11434", false); !strings.Contains(got, "without a published port") {
+		t.Errorf("hint(unpublished) = %q", got)
+	}
+}
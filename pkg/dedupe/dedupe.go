@@ -0,0 +1,77 @@
+// Package dedupe spots generated subjects that repeat a recent commit, such
+// as a tenth "Update config", so the model can be asked for a more specific
+// one.
+package dedupe
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/kylegalloway/commit-writer/pkg/changelog"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Threshold is the share of words two subjects must have in common, out of
+// all the words in either, to count as near-duplicates.
+const Threshold = 0.8
+
+// Normalize reduces a subject to its lower-case words, without "Title:"
+// labels, emoji, :shortcodes:, type prefix or punctuation, so
+// "feat(cfg): Update config." and "✨ update config" compare equal.
+func Normalize(subject string) string {
+	subject = strings.TrimSpace(subject)
+	if len(subject) >= 6 && strings.EqualFold(subject[:6], "title:") {
+		subject = subject[6:]
+	}
+	var words []string
+	for _, w := range strings.Fields(subject) {
+		if len(w) > 2 && strings.HasPrefix(w, ":") && strings.HasSuffix(w, ":") || strings.IndexFunc(w, isWord) < 0 {
+			continue
+		}
+		words = append(words, w)
+	}
+	subject = changelog.Parse(gitdiff.Commit{Message: strings.Join(words, " ")}).Subject
+	return strings.Join(strings.FieldsFunc(strings.ToLower(subject), func(r rune) bool { return !isWord(r) }), " ")
+}
+
+func isWord(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Similar reports whether two subjects are the same or nearly so once
+// normalized.
+func Similar(a, b string) bool {
+	a, b = Normalize(a), Normalize(b)
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	wa, wb := set(a), set(b)
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	return float64(common)/float64(len(wa)+len(wb)-common) >= Threshold
+}
+
+func set(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		words[w] = true
+	}
+	return words
+}
+
+// Find returns the first of recent that subject nearly duplicates.
+func Find(subject string, recent []string) (string, bool) {
+	for _, r := range recent {
+		if Similar(subject, r) {
+			return r, true
+		}
+	}
+	return "", false
+}
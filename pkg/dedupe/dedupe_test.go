@@ -0,0 +1,37 @@
+package dedupe
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"feat(cfg): Update config.":   "update config",
+		"✨ update config":             "update config",
+		":wrench: chore: Bump the CI": "bump the ci",
+		"Title: Fix PROJ-12 crash":    "fix proj 12 crash",
+		"":                            "",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSimilar(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"chore: update config", "Update config", true},
+		{"Add retry to the upload client", "Add retries to the upload client", false},
+		{"Add retry logic to the upload client", "Add retry logic to upload client", true},
+		{"Update config", "Update config for the staging cluster", false},
+		{"!!!", "...", false},
+	} {
+		if got := Similar(tt.a, tt.b); got != tt.want {
+			t.Errorf("Similar(%q, %q) = %v", tt.a, tt.b, got)
+		}
+	}
+	if dup, ok := Find("docs: update README", []string{"Fix login", "Update README"}); !ok || dup != "Update README" {
+		t.Errorf("Find = %q, %v", dup, ok)
+	}
+}
@@ -0,0 +1,237 @@
+// Package deps reads dependency changes out of go.mod, package.json and
+// Cargo.toml hunks, so a bump can be described with its exact versions
+// instead of the model guessing from lock file hash noise.
+package deps
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Change is one added, removed or bumped dependency.
+type Change struct {
+	// Manifest is the path of the go.mod, package.json or Cargo.toml.
+	Manifest string
+	Name     string
+	// From is empty for an added dependency, To for a removed one.
+	From, To string
+}
+
+func (c Change) String() string {
+	switch {
+	case c.From == "":
+		return fmt.Sprintf("add %s %s", c.Name, c.To)
+	case c.To == "":
+		return fmt.Sprintf("remove %s %s", c.Name, c.From)
+	}
+	return fmt.Sprintf("bump %s from %s to %s", c.Name, c.From, c.To)
+}
+
+// lockFiles are generated from the manifests and only carry hashes.
+var lockFiles = map[string]bool{
+	"go.sum":              true,
+	"go.work.sum":         true,
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"Cargo.lock":          true,
+}
+
+// IsManifest reports whether a repo path is a supported manifest or a lock
+// file.
+func IsManifest(name string) bool {
+	base := path.Base(name)
+	return parsers[base] != nil || lockFiles[base]
+}
+
+// parser reads one changed manifest line (without the +/- marker) and
+// returns the dependency and version it names. ok is false for lines that
+// change something other than a dependency; structural lines such as
+// "require (" or "}" return an empty name and ok.
+type parser func(line string) (name, version string, ok bool)
+
+var parsers = map[string]parser{
+	"go.mod":       goMod,
+	"package.json": packageJSON,
+	"Cargo.toml":   cargoToml,
+}
+
+var (
+	goRequireRe   = regexp.MustCompile(`^(?:require\s+)?(\S+)\s+(v\S+)(?:\s*//.*)?$`)
+	goDirectiveRe = regexp.MustCompile(`^(go|toolchain)\s+(\S+)$`)
+	jsonDepRe     = regexp.MustCompile(`^"([^"]+)"\s*:\s*"([^"]*)",?$`)
+	jsonVersionRe = regexp.MustCompile(`^(npm:\S+@|workspace:)?[\^~<>=v\s]*\d|^(\*|latest|next)$`)
+	tomlDepRe     = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=\s*"([^"]*)"$`)
+	tomlTableRe   = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=\s*\{.*\bversion\s*=\s*"([^"]*)".*\}$`)
+)
+
+func structural(line string) bool {
+	switch line {
+	case "", "(", ")", "{", "}", "},", "[", "]", "],":
+		return true
+	}
+	return strings.HasSuffix(line, "{") || strings.HasSuffix(line, "(") || strings.HasPrefix(line, "//")
+}
+
+func goMod(line string) (string, string, bool) {
+	if structural(line) || line == "require (" {
+		return "", "", true
+	}
+	if m := goDirectiveRe.FindStringSubmatch(line); m != nil {
+		return m[1], m[2], true
+	}
+	if m := goRequireRe.FindStringSubmatch(line); m != nil && m[1] != "module" && m[1] != "replace" && m[1] != "exclude" && m[1] != "retract" {
+		return m[1], m[2], true
+	}
+	return "", "", false
+}
+
+func packageJSON(line string) (string, string, bool) {
+	if structural(line) {
+		return "", "", true
+	}
+	m := jsonDepRe.FindStringSubmatch(line)
+	if m == nil || m[1] == "version" || !jsonVersionRe.MatchString(m[2]) {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// cargoPackageKeys are [package] keys that look like dependencies.
+var cargoPackageKeys = map[string]bool{
+	"name": true, "version": true, "edition": true, "rust-version": true, "description": true,
+	"license": true, "repository": true, "homepage": true, "documentation": true, "readme": true,
+	"resolver": true,
+}
+
+func cargoToml(line string) (string, string, bool) {
+	if structural(line) || strings.HasPrefix(line, "#") {
+		return "", "", true
+	}
+	for _, re := range []*regexp.Regexp{tomlDepRe, tomlTableRe} {
+		if m := re.FindStringSubmatch(line); m != nil && !cargoPackageKeys[m[1]] {
+			return m[1], m[2], true
+		}
+	}
+	return "", "", false
+}
+
+// Parse returns the dependency changes in a unified diff, in manifest
+// order. explained reports whether the diff only touches manifests and lock
+// files and every changed manifest line is one of the returned changes, so
+// the changes describe the whole diff.
+func Parse(diff string) (changes []Change, explained bool) {
+	explained = true
+	for _, chunk := range gitdiff.SplitFiles(diff) {
+		stats := gitdiff.ParseStat(chunk)
+		if len(stats) == 0 {
+			continue
+		}
+		name := stats[0].Path
+		parse := parsers[path.Base(name)]
+		if parse == nil {
+			if !lockFiles[path.Base(name)] {
+				explained = false
+			}
+			continue
+		}
+		var order []string
+		removed, added := map[string]string{}, map[string]string{}
+		inHunk := false
+		for _, line := range strings.Split(chunk, "\n") {
+			if strings.HasPrefix(line, "@@") {
+				inHunk = true
+				continue
+			}
+			if !inHunk || line == "" || (line[0] != '+' && line[0] != '-') {
+				continue
+			}
+			dep, version, ok := parse(strings.TrimSpace(line[1:]))
+			if !ok {
+				explained = false
+				continue
+			}
+			if dep == "" {
+				continue
+			}
+			if _, seen := removed[dep]; !seen {
+				if _, seen := added[dep]; !seen {
+					order = append(order, dep)
+				}
+			}
+			if line[0] == '-' {
+				removed[dep] = version
+			} else {
+				added[dep] = version
+			}
+		}
+		for _, dep := range order {
+			from, to := removed[dep], added[dep]
+			if from == to {
+				continue // e.g. only "// indirect" changed
+			}
+			changes = append(changes, Change{Manifest: name, Name: dep, From: from, To: to})
+		}
+	}
+	return changes, explained && len(changes) > 0
+}
+
+// CollapseLocks replaces the hunks of lock files with their line counts.
+func CollapseLocks(diff string) string {
+	var b strings.Builder
+	for _, chunk := range gitdiff.SplitFiles(diff) {
+		stats := gitdiff.ParseStat(chunk)
+		if len(stats) == 0 || !lockFiles[path.Base(stats[0].Path)] {
+			b.WriteString(chunk)
+			continue
+		}
+		st := stats[0]
+		for _, line := range strings.SplitAfter(chunk, "\n") {
+			if strings.HasPrefix(line, "diff --git ") || strings.HasPrefix(line, "new file mode") ||
+				strings.HasPrefix(line, "deleted file mode") {
+				b.WriteString(line)
+			}
+		}
+		fmt.Fprintf(&b, "[lock file updated: +%d/-%d lines]\n", st.Added, st.Removed)
+	}
+	return b.String()
+}
+
+// Hint lists the changes for the summarizer.
+func Hint(changes []Change) string {
+	var b strings.Builder
+	b.WriteString("Dependency changes (use these exact names and versions):")
+	for _, c := range changes {
+		fmt.Fprintf(&b, "\n- %s (%s)", c, c.Manifest)
+	}
+	return b.String()
+}
+
+// Message builds the commit message for a diff that only changes
+// dependencies. notes maps a change's index to notable upstream changes.
+func Message(changes []Change, notes map[int]string, titleOnly bool) string {
+	title := "build(deps): " + changes[0].String()
+	if len(changes) > 1 {
+		title = fmt.Sprintf("build(deps): update %d dependencies", len(changes))
+	}
+	if titleOnly {
+		return title
+	}
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	for i, c := range changes {
+		s := c.String()
+		fmt.Fprintf(&b, "\n- %s%s in %s.", strings.ToUpper(s[:1]), s[1:], c.Manifest)
+		if n := notes[i]; n != "" {
+			for _, line := range strings.Split(n, "\n") {
+				b.WriteString("\n  " + line)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package deps
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const goModDiff = `diff --git a/go.mod b/go.mod
+index 1111111..2222222 100644
+--- a/go.mod
++++ b/go.mod
+@@ -1,9 +1,10 @@
+ module example.com/shop
+ 
+-go 1.21
++go 1.22
+ 
+ require (
+-	github.com/acme/cart v1.2.3
++	github.com/acme/cart v1.3.0
++	github.com/acme/pay v0.4.0
+-	golang.org/x/text v0.14.0 // indirect
++	golang.org/x/text v0.14.0
+ )
+diff --git a/go.sum b/go.sum
+index 3333333..4444444 100644
+--- a/go.sum
++++ b/go.sum
+@@ -1,2 +1,2 @@
+-github.com/acme/cart v1.2.3 h1:aaaa=
++github.com/acme/cart v1.3.0 h1:bbbb=
+`
+
+const packageJSONDiff = `diff --git a/web/package.json b/web/package.json
+index 1111111..2222222 100644
+--- a/web/package.json
++++ b/web/package.json
+@@ -5,7 +5,7 @@
+   "dependencies": {
+-    "react": "^18.2.0",
++    "react": "^18.3.1",
+-    "left-pad": "1.3.0"
+   },
+`
+
+const cargoDiff = `diff --git a/Cargo.toml b/Cargo.toml
+index 1111111..2222222 100644
+--- a/Cargo.toml
++++ b/Cargo.toml
+@@ -1,6 +1,6 @@
+ [dependencies]
+-serde = { version = "1.0.190", features = ["derive"] }
++serde = { version = "1.0.200", features = ["derive"] }
+-tokio = "1.34"
++tokio = "1.37"
+`
+
+func TestParse(t *testing.T) {
+	changes, explained := Parse(goModDiff + packageJSONDiff + cargoDiff)
+	want := []Change{
+		{Manifest: "go.mod", Name: "go", From: "1.21", To: "1.22"},
+		{Manifest: "go.mod", Name: "github.com/acme/cart", From: "v1.2.3", To: "v1.3.0"},
+		{Manifest: "go.mod", Name: "github.com/acme/pay", To: "v0.4.0"},
+		{Manifest: "web/package.json", Name: "react", From: "^18.2.0", To: "^18.3.1"},
+		{Manifest: "web/package.json", Name: "left-pad", From: "1.3.0"},
+		{Manifest: "Cargo.toml", Name: "serde", From: "1.0.190", To: "1.0.200"},
+		{Manifest: "Cargo.toml", Name: "tokio", From: "1.34", To: "1.37"},
+	}
+	if !reflect.DeepEqual(changes, want) || !explained {
+		t.Errorf("Parse = %+v, %v\nwant %+v, true", changes, explained, want)
+	}
+}
+
+func TestParseUnexplained(t *testing.T) {
+	tests := map[string]string{
+		"script": `diff --git a/package.json b/package.json
+--- a/package.json
++++ b/package.json
+@@ -1,3 +1,3 @@
+-    "react": "^18.2.0",
++    "react": "^18.3.1",
+-    "build": "tsc",
++    "build": "tsc -b",
+`,
+		"own version": `diff --git a/Cargo.toml b/Cargo.toml
+--- a/Cargo.toml
++++ b/Cargo.toml
+@@ -1,3 +1,3 @@
+-version = "0.1.0"
++version = "0.2.0"
+-tokio = "1.34"
++tokio = "1.37"
+`,
+		"code": goModDiff + `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package main
++package main // shop
+`,
+	}
+	for name, diff := range tests {
+		changes, explained := Parse(diff)
+		if len(changes) == 0 || explained {
+			t.Errorf("%s: Parse = %+v, %v; want changes but not explained", name, changes, explained)
+		}
+	}
+}
+
+func TestCollapseLocks(t *testing.T) {
+	out := CollapseLocks(goModDiff)
+	if strings.Contains(out, "h1:") || !strings.Contains(out, "diff --git a/go.sum b/go.sum\n[lock file updated: +1/-1 lines]\n") {
+		t.Errorf("CollapseLocks:\n%s", out)
+	}
+	if !strings.Contains(out, "+go 1.22") {
+		t.Error("CollapseLocks dropped the manifest hunk")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	one := []Change{{Manifest: "go.mod", Name: "github.com/acme/cart", From: "v1.2.3", To: "v1.3.0"}}
+	got := Message(one, map[int]string{0: "- Faster checkout\n- Fix rounding"}, false)
+	want := "build(deps): bump github.com/acme/cart from v1.2.3 to v1.3.0\n\n- Bump github.com/acme/cart from v1.2.3 to v1.3.0 in go.mod.\n  - Faster checkout\n  - Fix rounding"
+	if got != want {
+		t.Errorf("Message = %q, want %q", got, want)
+	}
+	two := append(one, Change{Manifest: "go.mod", Name: "github.com/acme/pay", To: "v0.4.0"})
+	if got := Message(two, nil, true); got != "build(deps): update 2 dependencies" {
+		t.Errorf("Message(title only) = %q", got)
+	}
+	if h := Hint(two); !strings.Contains(h, "- add github.com/acme/pay v0.4.0 (go.mod)") {
+		t.Errorf("Hint = %q", h)
+	}
+}
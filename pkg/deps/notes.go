@@ -0,0 +1,98 @@
+package deps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GitHubAPI is the GitHub REST API root.
+const GitHubAPI = "https://api.github.com"
+
+// maxNotes bounds how many lines of release notes are kept per change.
+const maxNotes = 5
+
+// ReleaseNotes fetches the GitHub release notes of bumped Go modules
+// hosted on github.com.
+type ReleaseNotes struct {
+	// URL is the API root; GitHubAPI when empty.
+	URL string
+	// Token is sent as a bearer token when set, for private repositories
+	// and a higher rate limit.
+	Token string
+	// Client defaults to an HTTP client with a 10 second timeout.
+	Client *http.Client
+}
+
+// Notes returns up to five notable lines from the release notes of c's
+// new version, or "" when c is not a bump of a github.com module or the
+// release has no notes.
+func (r *ReleaseNotes) Notes(ctx context.Context, c Change) (string, error) {
+	parts := strings.Split(c.Name, "/")
+	if c.From == "" || c.To == "" || len(parts) < 3 || parts[0] != "github.com" || !strings.HasSuffix(c.Manifest, "go.mod") {
+		return "", nil
+	}
+	base := r.URL
+	if base == "" {
+		base = GitHubAPI
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", strings.TrimSuffix(base, "/"), parts[1], parts[2], c.To)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("release notes for %s %s: %w", c.Name, c.To, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("release notes for %s %s: %s: %s", c.Name, c.To, resp.Status, strings.TrimSpace(string(body)))
+	}
+	var release struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", fmt.Errorf("release notes for %s %s: %w", c.Name, c.To, err)
+	}
+	return notable(release.Body), nil
+}
+
+// notable keeps the first list items of release notes, or the first line
+// when there are none.
+func notable(body string) string {
+	var items []string
+	first := ""
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if first == "" && line != "" && !strings.HasPrefix(line, "#") {
+			first = line
+		}
+		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
+			items = append(items, "- "+strings.TrimSpace(line[2:]))
+			if len(items) == maxNotes {
+				break
+			}
+		}
+	}
+	if len(items) == 0 {
+		return first
+	}
+	return strings.Join(items, "\n")
+}
@@ -0,0 +1,49 @@
+package deps
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/acme/cart/releases/tags/v1.3.0":
+			_, _ = w.Write([]byte(`{"body":"## What's changed\r\n* Faster checkout by @a\r\n* Fix rounding\r\n\r\n**Full Changelog**: ..."}`))
+		case "/repos/acme/pay/releases/tags/v2.0.0":
+			_, _ = w.Write([]byte(`{"body":"Drops Go 1.20 support."}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	rn := &ReleaseNotes{URL: srv.URL, Token: "tok"}
+	tests := []struct {
+		change Change
+		want   string
+	}{
+		{Change{Manifest: "go.mod", Name: "github.com/acme/cart", From: "v1.2.3", To: "v1.3.0"}, "- Faster checkout by @a\n- Fix rounding"},
+		{Change{Manifest: "go.mod", Name: "github.com/acme/pay/v2", From: "v1.9.0", To: "v2.0.0"}, "Drops Go 1.20 support."},
+		{Change{Manifest: "go.mod", Name: "github.com/acme/cart", From: "v1.3.0", To: "v1.4.0"}, ""},
+		{Change{Manifest: "go.mod", Name: "golang.org/x/text", From: "v0.14.0", To: "v0.15.0"}, ""},
+		{Change{Manifest: "package.json", Name: "react", From: "^18.2.0", To: "^18.3.1"}, ""},
+	}
+	for _, tt := range tests {
+		got, err := rn.Notes(context.Background(), tt.change)
+		if err != nil || got != tt.want {
+			t.Errorf("Notes(%s) = %q, %v; want %q", tt.change.Name, got, err, tt.want)
+		}
+	}
+
+	rn.Token = "bad"
+	if _, err := rn.Notes(context.Background(), tests[0].change); err == nil {
+		t.Error("Notes with a rejected token succeeded")
+	}
+}
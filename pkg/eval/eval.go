@@ -0,0 +1,169 @@
+// Package eval scores generated commit messages against a rubric, with a
+// model as the judge, so models, tones and prompts can be compared on real
+// diffs before one becomes the default.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Score is the judge's rating of one message on each criterion, from 1
+// (poor) to 5 (excellent).
+type Score struct {
+	// Accuracy is whether every claim is supported by the diff and the
+	// main changes are covered.
+	Accuracy int `json:"accuracy"`
+	// Length is whether the title is short and the body as long as the
+	// change needs.
+	Length int `json:"length"`
+	// Conventions is whether the message follows commit conventions and
+	// the repository's own.
+	Conventions int `json:"conventions"`
+	// Note is the judge's one-sentence comment.
+	Note string `json:"note,omitempty"`
+}
+
+// Mean is the average of the three criteria.
+func (s Score) Mean() float64 {
+	return float64(s.Accuracy+s.Length+s.Conventions) / 3
+}
+
+var lineRe = regexp.MustCompile(`(?im)^[\s*#-]*(accuracy|length|conventions|note)\**\s*:\**\s*(.*)$`)
+
+// Parse reads a judge's reply in the format prompt.Judge asks for, e.g.
+// "ACCURACY: 4", tolerating Markdown emphasis and "4/5".
+func Parse(reply string) (Score, error) {
+	var s Score
+	found := map[string]bool{}
+	for _, m := range lineRe.FindAllStringSubmatch(reply, -1) {
+		key, value := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		if key == "note" {
+			if !strings.EqualFold(strings.TrimRight(value, "."), "none") {
+				s.Note = value
+			}
+			continue
+		}
+		value, _, _ = strings.Cut(value, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(strings.Fields(value + " x")[0]))
+		if err != nil || n < 1 || n > 5 {
+			return s, fmt.Errorf("judge gave %s an invalid score %q", key, m[2])
+		}
+		switch key {
+		case "accuracy":
+			s.Accuracy = n
+		case "length":
+			s.Length = n
+		default:
+			s.Conventions = n
+		}
+	}
+	for _, key := range []string{"accuracy", "length", "conventions"} {
+		if !found[key] {
+			return s, fmt.Errorf("judge reply has no %s score", key)
+		}
+	}
+	return s, nil
+}
+
+// Case is one evaluated change.
+type Case struct {
+	// Source names the change: a commit hash or a diff file.
+	Source string `json:"source"`
+	// Original is the committed subject, for past commits.
+	Original string `json:"original,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Score    *Score `json:"score,omitempty"`
+	// Error is set when generating or judging the message failed.
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the outcome of an evaluation run.
+type Report struct {
+	SummarizerModel string `json:"summarizer_model"`
+	StyleModel      string `json:"style_model"`
+	Tone            string `json:"tone"`
+	JudgeModel      string `json:"judge_model"`
+	Cases           []Case `json:"cases"`
+	// Scored counts the cases with a score, and Mean averages their
+	// scores per criterion.
+	Scored int   `json:"scored"`
+	Mean   Means `json:"mean"`
+}
+
+// Means are average scores.
+type Means struct {
+	Accuracy    float64 `json:"accuracy"`
+	Length      float64 `json:"length"`
+	Conventions float64 `json:"conventions"`
+	Overall     float64 `json:"overall"`
+}
+
+// Summarize fills in Scored and Mean from the cases.
+func (r *Report) Summarize() {
+	r.Scored, r.Mean = 0, Means{}
+	for _, c := range r.Cases {
+		if c.Score == nil {
+			continue
+		}
+		r.Scored++
+		r.Mean.Accuracy += float64(c.Score.Accuracy)
+		r.Mean.Length += float64(c.Score.Length)
+		r.Mean.Conventions += float64(c.Score.Conventions)
+	}
+	if r.Scored == 0 {
+		return
+	}
+	n := float64(r.Scored)
+	r.Mean.Accuracy /= n
+	r.Mean.Length /= n
+	r.Mean.Conventions /= n
+	r.Mean.Overall = (r.Mean.Accuracy + r.Mean.Length + r.Mean.Conventions) / 3
+}
+
+// WriteJSON writes the report as indented JSON.
+func WriteJSON(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteMarkdown writes the report as a Markdown table with the averages
+// first.
+func WriteMarkdown(w io.Writer, r Report) {
+	fmt.Fprintf(w, "# Evaluation: %s -> %s, tone %q, judged by %s\n\n", r.SummarizerModel, r.StyleModel, r.Tone, r.JudgeModel)
+	fmt.Fprintf(w, "%d of %d messages scored. Mean accuracy %.2f, length %.2f, conventions %.2f, overall %.2f (1-5).\n\n",
+		r.Scored, len(r.Cases), r.Mean.Accuracy, r.Mean.Length, r.Mean.Conventions, r.Mean.Overall)
+	fmt.Fprintln(w, "| Change | Committed title | Generated title | Accuracy | Length | Conventions | Note |")
+	fmt.Fprintln(w, "|---|---|---|---|---|---|---|")
+	for _, c := range r.Cases {
+		title := strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+		scores, note := "- | - | -", ""
+		if c.Score != nil {
+			scores, note = fmt.Sprintf("%d | %d | %d", c.Score.Accuracy, c.Score.Length, c.Score.Conventions), c.Score.Note
+		} else {
+			note = "error: " + c.Error
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s |\n", cell(c.Source), orDash(cell(c.Original)), orDash(cell(title)), scores, cell(note))
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// cell escapes text for a Markdown table cell.
+func cell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
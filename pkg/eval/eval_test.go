@@ -0,0 +1,50 @@
+package eval
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse("Here you go:\n**ACCURACY:** 4/5\n- Length: 5\nconventions: 3 (no blank line)\nNOTE: The body invents a test.\nAccuracy: 1")
+	want := Score{Accuracy: 4, Length: 5, Conventions: 3, Note: "The body invents a test."}
+	if err != nil || got != want {
+		t.Errorf("Parse = %+v, %v; want %+v", got, err, want)
+	}
+	if got, err := Parse("ACCURACY: 5\nLENGTH: 5\nCONVENTIONS: 5\nNOTE: none"); err != nil || got.Note != "" {
+		t.Errorf("Parse with no note = %+v, %v", got, err)
+	}
+	for reply, wantErr := range map[string]string{
+		"ACCURACY: 4\nLENGTH: 5":                 "no conventions score",
+		"ACCURACY: 9\nLENGTH: 5\nCONVENTIONS: 5": "invalid score",
+		"ACCURACY: good\nLENGTH: 5":              "invalid score",
+	} {
+		if _, err := Parse(reply); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Parse(%q) = %v, want error containing %q", reply, err, wantErr)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := Report{SummarizerModel: "summ", StyleModel: "style", Tone: "dry", JudgeModel: "judge", Cases: []Case{
+		{Source: "3f2a9c1", Original: "Add coupons", Message: "Add coupon codes\n\nBody", Score: &Score{Accuracy: 5, Length: 4, Conventions: 3}},
+		{Source: "fix.diff", Message: "Fix | pipe", Score: &Score{Accuracy: 3, Length: 4, Conventions: 5, Note: "Vague."}},
+		{Source: "b00b1e5", Original: "Drop cache", Error: "model not found"},
+	}}
+	r.Summarize()
+	if r.Scored != 2 || r.Mean != (Means{Accuracy: 4, Length: 4, Conventions: 4, Overall: 4}) {
+		t.Errorf("Summarize: scored %d, mean %+v", r.Scored, r.Mean)
+	}
+	var b strings.Builder
+	WriteMarkdown(&b, r)
+	for _, want := range []string{
+		"2 of 3 messages scored. Mean accuracy 4.00, length 4.00, conventions 4.00, overall 4.00 (1-5).",
+		"| 3f2a9c1 | Add coupons | Add coupon codes | 5 | 4 | 3 |  |",
+		"| fix.diff | - | Fix \\| pipe | 3 | 4 | 5 | Vague. |",
+		"| b00b1e5 | Drop cache | - | - | - | - | error: model not found |",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package forge opens pull requests (GitHub) and merge requests (GitLab)
+// through the hosts' CLIs, fills in the repository's description templates
+// and sets GitHub release notes.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Forge is a code hosting service.
+type Forge interface {
+	// Create opens pr for the current branch and returns its URL.
+	Create(ctx context.Context, pr PullRequest) (string, error)
+	// Template returns the description template named name ("" for the
+	// default) from the repository at root, or "" when there is none.
+	Template(root, name string) (string, error)
+}
+
+// New returns the forge called name: "github" or "gitlab".
+func New(name string) (Forge, error) {
+	switch name {
+	case "github":
+		return GitHub{}, nil
+	case "gitlab":
+		return GitLab{}, nil
+	}
+	return nil, fmt.Errorf("unknown forge %q (want github or gitlab)", name)
+}
+
+// Detect guesses the forge from the origin remote URL: "gitlab" when the
+// host name contains "gitlab", else "github".
+func Detect() string {
+	out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err == nil && strings.Contains(strings.ToLower(string(out)), "gitlab") {
+		return "gitlab"
+	}
+	return "github"
+}
+
+// Placeholder marks where MergeTemplate puts the generated description.
+const Placeholder = "<!-- commit-writer -->"
+
+// MergeTemplate combines a description template with the generated body.
+// The body replaces Placeholder when the template has one; otherwise it goes
+// above the template so checklists and headings stay for the author.
+func MergeTemplate(tmpl, body string) string {
+	tmpl = strings.TrimSpace(tmpl)
+	switch {
+	case tmpl == "":
+		return body
+	case strings.Contains(tmpl, Placeholder):
+		return strings.Replace(tmpl, Placeholder, body, 1)
+	}
+	return body + "\n\n" + tmpl
+}
+
+// readTemplate returns the first of paths (relative to root) that exists.
+func readTemplate(root string, paths ...string) (string, error) {
+	for _, p := range paths {
+		data, err := os.ReadFile(filepath.Join(root, p))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// namedTemplate reads <dir>/<name>.md; with an empty name it uses
+// <dir>/<def>.md, or the only template in dir if there is exactly one.
+func namedTemplate(root, dir, name, def string) (string, error) {
+	if name != "" {
+		t, err := readTemplate(root, filepath.Join(dir, name+".md"))
+		if err == nil && t == "" {
+			return "", fmt.Errorf("template %q not found in %s", name, dir)
+		}
+		return t, err
+	}
+	if t, err := readTemplate(root, filepath.Join(dir, def+".md")); t != "" || err != nil {
+		return t, err
+	}
+	matches, _ := filepath.Glob(filepath.Join(root, dir, "*.md"))
+	sort.Strings(matches)
+	if len(matches) == 1 {
+		return readTemplate("", matches[0])
+	}
+	return "", nil
+}
+
+// PullRequest is a pull request to open from the current branch.
+type PullRequest struct {
+	Title string
+	Body  string
+	// Base is the branch to merge into; empty uses the repository default.
+	Base  string
+	Draft bool
+}
+
+// SplitMessage splits a generated message into its first line (the title)
+// and the rest (the body).
+func SplitMessage(msg string) (title, body string) {
+	msg = strings.TrimSpace(msg)
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		return strings.TrimSpace(msg[:i]), strings.TrimSpace(msg[i+1:])
+	}
+	return msg, ""
+}
+
+// GitHub opens pull requests with the gh CLI, which handles authentication.
+type GitHub struct{}
+
+// Template returns .github/PULL_REQUEST_TEMPLATE/<name>.md when a name is
+// given, else the repository's single pull request template.
+func (GitHub) Template(root, name string) (string, error) {
+	if name != "" {
+		return namedTemplate(root, ".github/PULL_REQUEST_TEMPLATE", name, "")
+	}
+	return readTemplate(root,
+		".github/pull_request_template.md", ".github/PULL_REQUEST_TEMPLATE.md",
+		"pull_request_template.md", "PULL_REQUEST_TEMPLATE.md",
+		"docs/pull_request_template.md", "docs/PULL_REQUEST_TEMPLATE.md")
+}
+
+// Create opens pr for the current branch and returns its URL. The branch
+// must already be pushed.
+func (GitHub) Create(ctx context.Context, pr PullRequest) (string, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return "", fmt.Errorf("gh CLI not found on PATH; install it from https://cli.github.com and run 'gh auth login'")
+	}
+	args := []string{"pr", "create", "--title", pr.Title, "--body-file", "-"}
+	if pr.Base != "" {
+		// The diff is taken against origin/<branch>; gh wants the branch.
+		args = append(args, "--base", strings.TrimPrefix(pr.Base, "origin/"))
+	}
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	cmd.Stdin = strings.NewReader(pr.Body)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gh pr create failed: %w", err)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// GitLab opens merge requests with the glab CLI, which handles
+// authentication and self-hosted instances.
+type GitLab struct{}
+
+// Template returns .gitlab/merge_request_templates/<name>.md, defaulting to
+// Default.md (GitLab's default template name) or the only template there.
+func (GitLab) Template(root, name string) (string, error) {
+	return namedTemplate(root, ".gitlab/merge_request_templates", name, "Default")
+}
+
+// Create opens pr as a merge request for the current branch and returns its
+// URL. The branch must already be pushed.
+func (GitLab) Create(ctx context.Context, pr PullRequest) (string, error) {
+	if _, err := exec.LookPath("glab"); err != nil {
+		return "", fmt.Errorf("glab CLI not found on PATH; install it from https://gitlab.com/gitlab-org/cli and run 'glab auth login'")
+	}
+	args := []string{"mr", "create", "--title", pr.Title, "--description", pr.Body, "--yes"}
+	if pr.Base != "" {
+		args = append(args, "--target-branch", strings.TrimPrefix(pr.Base, "origin/"))
+	}
+	if pr.Draft {
+		args = append(args, "--draft")
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "glab", args...)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("glab mr create failed: %w", err)
+	}
+	// glab prints progress before the URL; the URL is the last line.
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
@@ -0,0 +1,195 @@
+package forge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		in, title, body string
+	}{
+		{"Add thing", "Add thing", ""},
+		{"\nAdd thing\n\n## Summary\nText\n", "Add thing", "## Summary\nText"},
+	}
+	for _, tt := range tests {
+		title, body := SplitMessage(tt.in)
+		if title != tt.title || body != tt.body {
+			t.Errorf("SplitMessage(%q) = %q, %q", tt.in, title, body)
+		}
+	}
+}
+
+// fakeCLI installs a shell script named name on PATH that records its
+// arguments and stdin in dir and prints out.
+func fakeCLI(t *testing.T, name, out string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLI is a shell script")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho \"$@\" > " + dir + "/args\ncat > " + dir + "/stdin\necho '" + out + "'\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return dir
+}
+
+func TestGitHubCreate(t *testing.T) {
+	dir := fakeCLI(t, "gh", "https://github.com/o/r/pull/7")
+	url, err := GitHub{}.Create(context.Background(), PullRequest{Title: "Add thing", Body: "## Summary", Base: "origin/main", Draft: true})
+	if err != nil || url != "https://github.com/o/r/pull/7" {
+		t.Fatalf("Create = %q, %v", url, err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if got := strings.TrimSpace(string(args)); got != "pr create --title Add thing --body-file - --base main --draft" {
+		t.Errorf("gh args = %q", got)
+	}
+	if stdin, _ := os.ReadFile(filepath.Join(dir, "stdin")); string(stdin) != "## Summary" {
+		t.Errorf("gh stdin = %q", stdin)
+	}
+}
+
+func TestMergeTemplate(t *testing.T) {
+	tests := []struct {
+		name, tmpl, want string
+	}{
+		{"no template", "", "Body"},
+		{"placeholder", "## What\n" + Placeholder + "\n\n## Checklist\n- [ ] tests", "## What\nBody\n\n## Checklist\n- [ ] tests"},
+		{"prepend", "## Checklist\n- [ ] tests\n", "Body\n\n## Checklist\n- [ ] tests"},
+	}
+	for _, tt := range tests {
+		if got := MergeTemplate(tt.tmpl, "Body"); got != tt.want {
+			t.Errorf("%s: MergeTemplate = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestTemplates(t *testing.T) {
+	gitlabDir := ".gitlab/merge_request_templates/"
+	tests := []struct {
+		name     string
+		forge    Forge
+		files    map[string]string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{"github default", GitHub{}, map[string]string{".github/pull_request_template.md": "gh"}, "", "gh", false},
+		{"github named", GitHub{}, map[string]string{".github/PULL_REQUEST_TEMPLATE/bug.md": "bug"}, "bug", "bug", false},
+		{"github none", GitHub{}, nil, "", "", false},
+		{"gitlab Default.md", GitLab{}, map[string]string{gitlabDir + "Default.md": "def", gitlabDir + "Bug.md": "bug"}, "", "def", false},
+		{"gitlab single", GitLab{}, map[string]string{gitlabDir + "Feature.md": "feat"}, "", "feat", false},
+		{"gitlab ambiguous", GitLab{}, map[string]string{gitlabDir + "A.md": "a", gitlabDir + "B.md": "b"}, "", "", false},
+		{"gitlab named", GitLab{}, map[string]string{gitlabDir + "A.md": "a", gitlabDir + "B.md": "b"}, "B", "b", false},
+		{"gitlab missing name", GitLab{}, nil, "Nope", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.forge.Template(writeFiles(t, tt.files), tt.template)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Template = %q, %v; want %q (error %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGitLabCreate(t *testing.T) {
+	dir := fakeCLI(t, "glab", "Creating merge request...\nhttps://gitlab.com/o/r/-/merge_requests/3")
+	url, err := GitLab{}.Create(context.Background(), PullRequest{Title: "Add thing", Body: "Body", Base: "origin/dev"})
+	if err != nil || url != "https://gitlab.com/o/r/-/merge_requests/3" {
+		t.Fatalf("Create = %q, %v", url, err)
+	}
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if got := strings.TrimSpace(string(args)); got != "mr create --title Add thing --description Body --yes --target-branch dev" {
+		t.Errorf("glab args = %q", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("bitbucket"); err == nil {
+		t.Error("New accepted an unknown forge")
+	}
+}
+
+func TestGitHubRepo(t *testing.T) {
+	for remote, want := range map[string]string{
+		"https://github.com/o/r.git":     "o/r",
+		"git@github.com:o/r.git":         "o/r",
+		"ssh://git@github.com/o/r":       "o/r",
+		"https://gitlab.com/o/r.git":     "",
+		"https://github.com/o/r/extra":   "",
+		"https://github.com/o/r.git/":    "o/r",
+		"git@github.example.com:o/r.git": "",
+	} {
+		if got := githubRepo(remote); got != want {
+			t.Errorf("githubRepo(%q) = %q, want %q", remote, got, want)
+		}
+	}
+}
+
+func TestReleaseSetBody(t *testing.T) {
+	var calls []string
+	exists := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+		}
+		switch {
+		case r.Method == http.MethodGet && !exists:
+			http.NotFound(w, r)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"id": 42}`)
+		default:
+			var in map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in["body"] != "## v1.2.0" {
+				t.Errorf("%s body = %v, %v", r.Method, in, err)
+			}
+			fmt.Fprint(w, `{"html_url": "https://github.com/o/r/releases/tag/v1.2.0"}`)
+		}
+	}))
+	defer srv.Close()
+
+	rel := &Release{URL: srv.URL, Token: "tok", Repo: "o/r"}
+	u, err := rel.SetBody(context.Background(), "v1.2.0", "## v1.2.0")
+	if err != nil || u != "https://github.com/o/r/releases/tag/v1.2.0" {
+		t.Fatalf("SetBody = %q, %v", u, err)
+	}
+	exists = false
+	if _, err := rel.SetBody(context.Background(), "v1.2.0", "## v1.2.0"); err != nil {
+		t.Fatalf("SetBody (new release): %v", err)
+	}
+	want := []string{"GET /repos/o/r/releases/tags/v1.2.0", "PATCH /repos/o/r/releases/42", "GET /repos/o/r/releases/tags/v1.2.0", "POST /repos/o/r/releases"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+	if _, err := (&Release{Repo: "o/r"}).SetBody(context.Background(), "v1", ""); err == nil {
+		t.Error("SetBody without a token succeeded")
+	}
+}
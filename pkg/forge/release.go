@@ -0,0 +1,126 @@
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// GitHubAPI is the GitHub REST API root.
+const GitHubAPI = "https://api.github.com"
+
+// Release sets the bodies of GitHub releases through the REST API.
+type Release struct {
+	// URL is the API root; GitHubAPI when empty.
+	URL string
+	// Token is sent as a bearer token; it needs write access to the
+	// repository's contents.
+	Token string
+	// Repo is "owner/name".
+	Repo string
+	// Client defaults to an HTTP client with a 30 second timeout.
+	Client *http.Client
+}
+
+// SetBody replaces the body of the release for tag, creating a draft
+// release when there is none yet, and returns the release's URL.
+func (r *Release) SetBody(ctx context.Context, tag, body string) (string, error) {
+	if r.Token == "" {
+		return "", errors.New("setting a GitHub release needs a token in GITHUB_TOKEN or GH_TOKEN")
+	}
+	var existing struct {
+		ID int64 `json:"id"`
+	}
+	status, err := r.do(ctx, http.MethodGet, "releases/tags/"+url.PathEscape(tag), nil, &existing)
+	var release struct {
+		URL string `json:"html_url"`
+	}
+	switch {
+	case err != nil && status != http.StatusNotFound:
+		return "", err
+	case status == http.StatusNotFound:
+		_, err = r.do(ctx, http.MethodPost, "releases", map[string]interface{}{"tag_name": tag, "name": tag, "body": body, "draft": true}, &release)
+	default:
+		_, err = r.do(ctx, http.MethodPatch, fmt.Sprintf("releases/%d", existing.ID), map[string]interface{}{"body": body}, &release)
+	}
+	if err != nil {
+		return "", err
+	}
+	return release.URL, nil
+}
+
+// do sends a request to the repository's API path and decodes the JSON
+// response into out. It returns the response status, if any.
+func (r *Release) do(ctx context.Context, method, path string, in, out interface{}) (int, error) {
+	base := r.URL
+	if base == "" {
+		base = GitHubAPI
+	}
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(b)
+	}
+	u := fmt.Sprintf("%s/repos/%s/%s", strings.TrimSuffix(base, "/"), r.Repo, path)
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+r.Token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("GitHub release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("GitHub release: %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("GitHub release: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// GitHubRepo returns the "owner/name" of the origin remote when it is on
+// GitHub, or "" otherwise.
+func GitHubRepo() string {
+	out, err := exec.Command("git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	return githubRepo(strings.TrimSpace(string(out)))
+}
+
+// githubRepo parses a GitHub remote URL in the https, ssh or scp-like
+// form.
+func githubRepo(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+	for _, prefix := range []string{"https://github.com/", "http://github.com/", "ssh://git@github.com/", "git@github.com:"} {
+		if strings.HasPrefix(remote, prefix) {
+			if parts := strings.Split(remote[len(prefix):], "/"); len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+				return parts[0] + "/" + parts[1]
+			}
+		}
+	}
+	return ""
+}
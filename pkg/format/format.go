@@ -0,0 +1,389 @@
+// Package format cleans model output and shapes it into commit messages.
+package format
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// CleanModelOutput normalizes model text by removing reasoning models'
+// chain of thought and code fences, unquoting JSON-encoded strings and
+// normalizing newlines.
+func CleanModelOutput(s string) string {
+	s = stripReasoning(strings.TrimSpace(s))
+	// If the entire body is a JSON string like: "...\n...", try to unquote it.
+	if len(s) >= 2 && ((s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'')) {
+		if unq, err := strconv.Unquote(s); err == nil {
+			s = unq
+		}
+	}
+
+	// Remove triple-backtick fenced blocks, keeping the inner content if present.
+	// Replace any ```lang\n...``` occurrences with the inner text.
+	fenceRe := regexp.MustCompile("(?s)```[a-zA-Z0-9_-]*\\n(.*?)```")
+	if fenceRe.MatchString(s) {
+		s = fenceRe.ReplaceAllString(s, "$1")
+	}
+	// Also remove any remaining ``` markers
+	s = strings.ReplaceAll(s, "```", "")
+
+	// Normalize CRLF
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+
+	return strings.TrimSpace(s)
+}
+
+var (
+	// thinkRe matches a reasoning block such as deepseek-r1's and qwq's
+	// <think>...</think>.
+	thinkRe      = regexp.MustCompile(`(?is)<(think|thinking|reasoning)>.*?</(?:think|thinking|reasoning)>`)
+	thinkStartRe = regexp.MustCompile(`(?i)^<(?:think|thinking|reasoning)>`)
+	// thinkEndRe matches a closing tag whose opening tag was part of the
+	// prompt template, so only the end of the reasoning is in the output.
+	thinkEndRe = regexp.MustCompile(`(?is)^.*</(?:think|thinking|reasoning)>`)
+	// reasoningRe matches a "Reasoning:" style preamble.
+	reasoningRe = regexp.MustCompile(`(?i)^(?:reasoning|thinking|thought process|chain of thought)\s*:`)
+	// answerRe matches the line that ends such a preamble.
+	answerRe = regexp.MustCompile(`(?im)^(?:final answer|answer|commit message|output)\s*:[ \t]*\n?`)
+)
+
+// stripReasoning removes a reasoning model's chain of thought: <think>
+// blocks, and a leading "Reasoning:" paragraph, or everything up to an
+// "Answer:" line after it.
+func stripReasoning(s string) string {
+	s = thinkRe.ReplaceAllString(s, "")
+	s = strings.TrimSpace(thinkEndRe.ReplaceAllString(s, ""))
+	// A block cut off by the token limit leaves no answer.
+	if thinkStartRe.MatchString(s) {
+		return ""
+	}
+	if !reasoningRe.MatchString(s) {
+		return s
+	}
+	if loc := answerRe.FindStringIndex(s); loc != nil {
+		return strings.TrimSpace(s[loc[1]:])
+	}
+	if i := strings.Index(s, "\n\n"); i >= 0 {
+		return strings.TrimSpace(s[i:])
+	}
+	return s
+}
+
+// CleanRules are user-defined cleanups applied after CleanModelOutput, for
+// the noise a model family adds, such as a "Here is your commit message:"
+// preamble.
+type CleanRules struct {
+	// Prefixes are removed from the start of the output, ignoring case and
+	// in any order, e.g. "Here is the rewritten commit:".
+	Prefixes []string `json:"prefixes,omitempty"`
+	// Strip are regular expressions whose matches are removed, e.g.
+	// "(?m)^Note:.*$".
+	Strip []string `json:"strip,omitempty"`
+	// Replace rewrites the matches of each pattern, in order.
+	Replace []Replacement `json:"replace,omitempty"`
+}
+
+// Replacement rewrites the matches of Pattern, a regular expression, with
+// With, which may refer to groups as $1.
+type Replacement struct {
+	Pattern string `json:"pattern"`
+	With    string `json:"with"`
+}
+
+// Validate checks that every pattern compiles.
+func (r CleanRules) Validate() error {
+	for _, p := range r.Strip {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("clean strip %q: %w", p, err)
+		}
+	}
+	for _, rep := range r.Replace {
+		if _, err := regexp.Compile(rep.Pattern); err != nil {
+			return fmt.Errorf("clean replace %q: %w", rep.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// Apply removes the configured prefixes, then strips and replaces the
+// patterns in order. Invalid patterns are skipped; see Validate.
+func (r CleanRules) Apply(s string) string {
+	s = strings.TrimSpace(s)
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, p := range r.Prefixes {
+			if p != "" && len(s) >= len(p) && strings.EqualFold(s[:len(p)], p) {
+				s, trimmed = strings.TrimSpace(s[len(p):]), true
+			}
+		}
+	}
+	for _, p := range r.Strip {
+		if re, err := regexp.Compile(p); err == nil {
+			s = re.ReplaceAllString(s, "")
+		}
+	}
+	for _, rep := range r.Replace {
+		if re, err := regexp.Compile(rep.Pattern); err == nil {
+			s = re.ReplaceAllString(s, rep.With)
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// StripLabels removes "Title:" and "Body:" prefixes from commit message lines
+func StripLabels(s string) string {
+	lines := strings.Split(s, "\n")
+	var result []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		// Remove "Title:" prefix (case-insensitive)
+		if strings.HasPrefix(strings.ToLower(trimmed), "title:") {
+			result = append(result, strings.TrimSpace(trimmed[6:]))
+			continue
+		}
+		// Remove "Body:" prefix (case-insensitive)
+		if strings.HasPrefix(strings.ToLower(trimmed), "body:") {
+			result = append(result, strings.TrimSpace(trimmed[5:]))
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// conventionalRe matches a title that already has a conventional commit
+// type, e.g. "fix(api): ..." or "feat!: ...".
+var conventionalRe = regexp.MustCompile(`^[a-zA-Z]+(\([^)]*\))?!?: `)
+
+// WithType prefixes the title (the first non-empty line, after an optional
+// "Title:" label) with a conventional commit type such as "test", unless
+// it already has one. The title's first letter is lower-cased to match,
+// except in acronyms.
+func WithType(msg, typ string) string {
+	lines := strings.Split(msg, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		label := ""
+		if strings.HasPrefix(strings.ToLower(trimmed), "title:") {
+			label, trimmed = trimmed[:6]+" ", strings.TrimSpace(trimmed[6:])
+		}
+		if conventionalRe.MatchString(trimmed) {
+			return msg
+		}
+		if len(trimmed) > 1 && !(trimmed[1] >= 'A' && trimmed[1] <= 'Z') {
+			trimmed = strings.ToLower(trimmed[:1]) + trimmed[1:]
+		}
+		lines[i] = label + typ + ": " + trimmed
+		break
+	}
+	return strings.Join(lines, "\n")
+}
+
+// blank reports whether a message line is empty but for a label.
+func blank(line string) bool {
+	return strings.TrimSpace(StripLabels(line)) == ""
+}
+
+// bodyStart returns the index of the line after the title, the first
+// non-blank line.
+func bodyStart(lines []string) int {
+	for i, line := range lines {
+		if !blank(line) {
+			return i + 1
+		}
+	}
+	return len(lines)
+}
+
+// BodyLines counts the non-blank lines after msg's title.
+func BodyLines(msg string) int {
+	lines := strings.Split(msg, "\n")
+	n := 0
+	for _, line := range lines[bodyStart(lines):] {
+		if !blank(line) {
+			n++
+		}
+	}
+	return n
+}
+
+// TrimBody keeps the title and the first max non-blank lines of msg's
+// body, with the blank lines between them, and drops the rest.
+func TrimBody(msg string, max int) string {
+	lines := strings.Split(msg, "\n")
+	i, n := bodyStart(lines), 0
+	for ; i < len(lines); i++ {
+		if blank(lines[i]) {
+			continue
+		}
+		if n == max {
+			break
+		}
+		n++
+	}
+	return strings.TrimRight(strings.Join(lines[:i], "\n"), " \t\n")
+}
+
+// AddFooter appends footer lines such as "Fixes ENG-123" to msg as a final
+// paragraph, skipping lines the message already contains.
+func AddFooter(msg string, lines ...string) string {
+	msg = strings.TrimRight(msg, "\n")
+	present := make(map[string]bool)
+	for _, l := range strings.Split(msg, "\n") {
+		present[strings.TrimSpace(l)] = true
+	}
+	var add []string
+	for _, l := range lines {
+		if l != "" && !present[l] {
+			add = append(add, l)
+			present[l] = true
+		}
+	}
+	if len(add) == 0 {
+		return msg
+	}
+	return msg + "\n\n" + strings.Join(add, "\n")
+}
+
+// PorcelainVersion is the first --porcelain field. It changes only when the
+// field layout does.
+const PorcelainVersion = "1"
+
+// Porcelain encodes a message for --porcelain as NUL-terminated fields:
+// the format version, the state ("ok", or "offline" for the diffstat
+// fallback), the title and the body (possibly empty or multi-line).
+func Porcelain(title, body string, offline bool) string {
+	state := "ok"
+	if offline {
+		state = "offline"
+	}
+	fields := []string{PorcelainVersion, state, title, body}
+	return strings.Join(fields, "\x00") + "\x00"
+}
+
+// ansiRe matches ANSI escape sequences: CSI sequences such as colors and
+// cursor moves, OSC sequences such as hyperlinks and titles, and two-byte
+// escapes.
+var ansiRe = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// PlainText removes ANSI escape sequences and control characters other
+// than newlines and tabs, for screen readers and dumb terminals. A
+// carriage return, which would overwrite the line on a terminal, becomes a
+// newline.
+func PlainText(s string) string {
+	s = ansiRe.ReplaceAllString(strings.ReplaceAll(s, "\r\n", "\n"), "")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\r':
+			return '\n'
+		case r == '\n' || r == '\t':
+			return r
+		case r < 0x20 || r == 0x7f || r >= 0x80 && r < 0xa0:
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// commonDir returns the deepest directory shared by all paths, or "" if none.
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	prefix := strings.Split(path.Dir(paths[0]), "/")
+	for _, p := range paths[1:] {
+		parts := strings.Split(path.Dir(p), "/")
+		n := 0
+		for n < len(prefix) && n < len(parts) && prefix[n] == parts[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	dir := strings.Join(prefix, "/")
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// Reformatted builds the message for a formatting-only change, e.g.
+// "style: reformat 3 files with gofmt" with the files listed in the body.
+func Reformatted(stats []gitdiff.FileStat, titleOnly bool) string {
+	what := fmt.Sprintf("%d files", len(stats))
+	if len(stats) == 1 {
+		what = stats[0].Path
+	}
+	gofmt := len(stats) > 0
+	for _, s := range stats {
+		if !strings.HasSuffix(s.Path, ".go") {
+			gofmt = false
+		}
+	}
+	title := "style: reformat " + what
+	if gofmt {
+		title += " with gofmt"
+	}
+	if titleOnly {
+		return title
+	}
+	var b strings.Builder
+	b.WriteString(title + "\n\nWhitespace and formatting only; no functional change.")
+	if len(stats) > 1 {
+		b.WriteString("\n")
+		for _, s := range stats {
+			fmt.Fprintf(&b, "\n- %s", s.Path)
+		}
+	}
+	return b.String()
+}
+
+// Heuristic builds a basic commit message from diff stats without any
+// LLM, e.g. "Update 3 files in pkg/foo (+120/-45)". Used when Ollama is down.
+func Heuristic(stats []gitdiff.FileStat, titleOnly bool) string {
+	if len(stats) == 0 {
+		return "Update files"
+	}
+
+	var added, removed int
+	paths := make([]string, 0, len(stats))
+	for _, s := range stats {
+		added += s.Added
+		removed += s.Removed
+		paths = append(paths, s.Path)
+	}
+	counts := fmt.Sprintf("(+%d/-%d)", added, removed)
+
+	var title string
+	if len(stats) == 1 {
+		verb := "Update"
+		switch stats[0].Status {
+		case 'A':
+			verb = "Add"
+		case 'D':
+			verb = "Remove"
+		}
+		title = fmt.Sprintf("%s %s %s", verb, stats[0].Path, counts)
+	} else if dir := commonDir(paths); dir != "" {
+		title = fmt.Sprintf("Update %d files in %s %s", len(stats), dir, counts)
+	} else {
+		title = fmt.Sprintf("Update %d files %s", len(stats), counts)
+	}
+	if titleOnly || len(stats) == 1 {
+		return title
+	}
+
+	var b strings.Builder
+	b.WriteString(title + "\n")
+	for _, s := range stats {
+		fmt.Fprintf(&b, "\n- %s (+%d/-%d)", s.Path, s.Added, s.Removed)
+	}
+	return b.String()
+}
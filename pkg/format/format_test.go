@@ -0,0 +1,188 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestCleanModelOutput(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "  Add thing  ", "Add thing"},
+		{"json string", `"Add thing\n\nBody"`, "Add thing\n\nBody"},
+		{"fenced with lang", "```text\nAdd thing\n```", "Add thing"},
+		{"stray fence", "Add ``` thing", "Add  thing"},
+		{"crlf", "Add thing\r\n\r\nBody", "Add thing\n\nBody"},
+		{"invalid quote kept", `"Add \q"`, `"Add \q"`},
+		{"think block", "<think>\nThe diff adds a flag.\n</think>\n\nAdd thing", "Add thing"},
+		{"think end only", "The user wants a title.\n</think>\nAdd thing", "Add thing"},
+		{"think cut off", "<thinking>Maybe I should", ""},
+		{"reasoning with answer", "Reasoning: the diff adds a flag.\n\nIt is small.\nAnswer:\nAdd thing\n\nBody", "Add thing\n\nBody"},
+		{"reasoning paragraph", "Reasoning: the diff adds a flag.\n\nAdd thing", "Add thing"},
+		{"reasoning word kept", "Explain the reasoning: why X", "Explain the reasoning: why X"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanModelOutput(tt.in); got != tt.want {
+				t.Errorf("CleanModelOutput(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanRules(t *testing.T) {
+	rules := CleanRules{
+		Prefixes: []string{"Here is your commit message:", "Sure!"},
+		Strip:    []string{`(?m)^Note:.*\n?`},
+		Replace:  []Replacement{{Pattern: `\bcolour\b`, With: "color"}, {Pattern: `(?m)^\* `, With: "- "}},
+	}
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	in := "sure! here is your commit message:\n\nFix colour parsing\n\n* Handle hex\nNote: I kept it short."
+	if got, want := rules.Apply(in), "Fix color parsing\n\n- Handle hex"; got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+	if err := (CleanRules{Replace: []Replacement{{Pattern: "("}}}).Validate(); err == nil {
+		t.Error("Validate accepted an invalid pattern")
+	}
+}
+
+func TestStripLabels(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"both labels", "Title: Add thing\n\nBody: Does stuff", "Add thing\n\nDoes stuff"},
+		{"case insensitive", "TITLE:Add thing", "Add thing"},
+		{"indented label", "  body:  text", "text"},
+		{"no labels", "Add thing\n\n- detail", "Add thing\n\n- detail"},
+		{"label mid-line kept", "Fix Title: handling", "Fix Title: handling"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripLabels(tt.in); got != tt.want {
+				t.Errorf("StripLabels(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddFooter(t *testing.T) {
+	tests := []struct {
+		name, msg string
+		lines     []string
+		want      string
+	}{
+		{"appends paragraph", "Fix login\n\nBody", []string{"Fixes ENG-1"}, "Fix login\n\nBody\n\nFixes ENG-1"},
+		{"title only", "Fix login\n", []string{"Fixes ENG-1"}, "Fix login\n\nFixes ENG-1"},
+		{"already present", "Fix login\n\nFixes ENG-1", []string{"Fixes ENG-1"}, "Fix login\n\nFixes ENG-1"},
+		{"several", "Fix", []string{"A", "", "B", "A"}, "Fix\n\nA\nB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AddFooter(tt.msg, tt.lines...); got != tt.want {
+				t.Errorf("AddFooter(%q, %q) = %q, want %q", tt.msg, tt.lines, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyLines(t *testing.T) {
+	msg := "Title: Fix login\n\nBody: Check the token\nbefore the session.\n\n- a\n- b\n"
+	if n := BodyLines(msg); n != 4 {
+		t.Errorf("BodyLines = %d, want 4", n)
+	}
+	if n := BodyLines("Fix login\n"); n != 0 {
+		t.Errorf("BodyLines(title only) = %d", n)
+	}
+	for max, want := range map[int]string{
+		0: "Title: Fix login",
+		2: "Title: Fix login\n\nBody: Check the token\nbefore the session.",
+		3: "Title: Fix login\n\nBody: Check the token\nbefore the session.\n\n- a",
+		9: "Title: Fix login\n\nBody: Check the token\nbefore the session.\n\n- a\n- b",
+	} {
+		if got := TrimBody(msg, max); got != want {
+			t.Errorf("TrimBody(%d) = %q, want %q", max, got, want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	in := "\x1b[1;32mFix login\x1b[0m\r\n\r\nSee \x1b]8;;https://x.test\x07the docs\x1b]8;;\x07.\tDone\rOK\x07"
+	if got, want := PlainText(in), "Fix login\n\nSee the docs.\tDone\nOK"; got != want {
+		t.Errorf("PlainText = %q, want %q", got, want)
+	}
+}
+
+func TestWithType(t *testing.T) {
+	tests := []struct {
+		name, msg, want string
+	}{
+		{"plain", "Add cart tests\n\nBody", "test: add cart tests\n\nBody"},
+		{"label", "\nTitle: Cover login\nBody: x", "\nTitle: test: cover login\nBody: x"},
+		{"acronym", "API tests for cart", "test: API tests for cart"},
+		{"already typed", "fix(cart): handle empty", "fix(cart): handle empty"},
+		{"breaking", "Title: feat!: drop v1", "Title: feat!: drop v1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WithType(tt.msg, "test"); got != tt.want {
+				t.Errorf("WithType(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReformatted(t *testing.T) {
+	goFiles := []gitdiff.FileStat{{Path: "a.go"}, {Path: "b/b.go"}}
+	want := "style: reformat 2 files with gofmt\n\nWhitespace and formatting only; no functional change.\n\n- a.go\n- b/b.go"
+	if got := Reformatted(goFiles, false); got != want {
+		t.Errorf("Reformatted = %q, want %q", got, want)
+	}
+	if got := Reformatted([]gitdiff.FileStat{{Path: "web/app.ts"}}, true); got != "style: reformat web/app.ts" {
+		t.Errorf("Reformatted(title only) = %q", got)
+	}
+}
+
+func TestPorcelain(t *testing.T) {
+	if got := Porcelain("Add login", "Line 1\nLine 2", false); got != "1\x00ok\x00Add login\x00Line 1\nLine 2\x00" {
+		t.Errorf("Porcelain = %q", got)
+	}
+	if got := Porcelain("Update a.txt", "", true); got != "1\x00offline\x00Update a.txt\x00\x00" {
+		t.Errorf("Porcelain offline = %q", got)
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	tests := []struct {
+		name      string
+		stats     []gitdiff.FileStat
+		titleOnly bool
+		want      string
+	}{
+		{"no files", nil, false, "Update files"},
+		{"single added", []gitdiff.FileStat{{Path: "a.go", Status: 'A', Added: 3}}, false, "Add a.go (+3/-0)"},
+		{"single deleted", []gitdiff.FileStat{{Path: "a.go", Status: 'D', Removed: 2}}, false, "Remove a.go (+0/-2)"},
+		{
+			"common dir",
+			[]gitdiff.FileStat{{Path: "pkg/foo/a.go", Added: 1}, {Path: "pkg/foo/b.go", Removed: 1}},
+			false,
+			"Update 2 files in pkg/foo (+1/-1)\n\n- pkg/foo/a.go (+1/-0)\n- pkg/foo/b.go (+0/-1)",
+		},
+		{
+			"no common dir title only",
+			[]gitdiff.FileStat{{Path: "a.go", Added: 1}, {Path: "b/c.go", Added: 2}},
+			true,
+			"Update 2 files (+3/-0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Heuristic(tt.stats, tt.titleOnly); got != tt.want {
+				t.Errorf("Heuristic() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/deps"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/gosem"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+	"github.com/kylegalloway/commit-writer/pkg/risk"
+	"github.com/kylegalloway/commit-writer/pkg/semver"
+	"github.com/kylegalloway/commit-writer/pkg/todo"
+)
+
+// apiHint heads the list of API changes given to the summarizer.
+const apiHint = "Exported Go API changes (exact; describe these accurately and claim no other API changes):"
+
+// annotate adds the API changes, the TODO list, the risk note, the release
+// bump and the footer to a finished message.
+func (g *Generator) annotate(ctx context.Context, res *Result, diff string, stats []gitdiff.FileStat, useModel bool) {
+	// With a loaded summary no stage needed the diff yet.
+	if diff == "" && (g.cfg.Todos || g.cfg.Risk || g.cfg.Security) {
+		var err error
+		if diff, err = g.prepareDiff(ctx, res); err != nil {
+			g.cfg.Warn("%v; skipping the TODO list, security and risk notes", err)
+		}
+	}
+	if g.cfg.APIChanges && !g.cfg.TitleOnly && len(res.API) > 0 {
+		res.Message = strings.TrimRight(res.Message, "\n") + "\n\n" + g.scrub(gosem.APIReport("API changes:", res.API))
+	}
+	if diff != "" {
+		g.listTodos(res, diff)
+		g.flagSecurity(res, diff)
+		g.annotateRisk(ctx, res, diff, useModel)
+	}
+	res.Semver = semver.Infer(res.Message, res.API)
+	g.debugf("semver: %s (%s)", res.Semver.Bump, strings.Join(res.Semver.Reasons, "; "))
+	if g.cfg.TitleOnly {
+		return
+	}
+	if len(g.cfg.Footer) > 0 {
+		res.Message = format.AddFooter(res.Message, g.cfg.Footer...)
+	}
+	// The trailer gets a paragraph of its own: git only parses trailers
+	// from a final paragraph that holds nothing else, and footers like
+	// "Fixes ENG-123" are not trailers.
+	if g.cfg.SemverTrailer {
+		res.Message = strings.TrimRight(res.Message, "\n") + "\n\n" + semver.Trailer + ": " + string(res.Semver.Bump)
+	}
+}
+
+// listTodos adds the TODO, FIXME and XXX comments the diff adds to the
+// message body when Todos is set.
+func (g *Generator) listTodos(res *Result, diff string) {
+	if !g.cfg.Todos {
+		return
+	}
+	if res.Todos = todo.Find(diff); len(res.Todos) > 0 {
+		g.cfg.Status("Found %d added TODO comment(s)", len(res.Todos))
+	}
+	if section := todo.Section(res.Todos); section != "" && !g.cfg.TitleOnly {
+		res.Message = strings.TrimRight(res.Message, "\n") + "\n\n" + section
+	}
+}
+
+// apiChanges lists the API changes in the changed Go packages that can
+// declare public API. The changed files of each package are compared
+// together, so moving a declaration between them is not a change.
+// Sensitive paths are skipped.
+func (g *Generator) apiChanges(stats []gitdiff.FileStat) []gosem.APIChange {
+	from, to, ok := g.revisions()
+	if !ok {
+		return nil
+	}
+	var dirs []string
+	byDir := map[string][]gitdiff.FileStat{}
+	for _, st := range stats {
+		if !semver.PublicGoFile(st.Path) || g.denied(st.Path) || st.OldPath != "" && g.denied(st.OldPath) {
+			continue
+		}
+		dir := path.Dir(st.Path)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], st)
+	}
+	var api []gosem.APIChange
+	for _, dir := range dirs {
+		var before, after [][]byte
+		name, failed := "", false
+		for _, st := range byDir[dir] {
+			b, a, err := g.fileVersions(st, from, to)
+			if err != nil {
+				g.debugf("api: %s: %v", st.Path, err)
+				failed = true
+				break
+			}
+			before, after = append(before, b), append(after, a)
+			if name == "" {
+				if name = gosem.PackageName(a); name == "" {
+					name = gosem.PackageName(b)
+				}
+			}
+		}
+		if failed || name == "main" {
+			continue
+		}
+		changes, err := gosem.ComparePackage(before, after)
+		if err != nil {
+			g.debugf("api: %s: %v", dir, err)
+			continue
+		}
+		label := g.importPath(dir, from, to)
+		if label == "" {
+			label = name
+		}
+		for _, c := range changes {
+			if c.Surface() {
+				api = append(api, gosem.APIChange{Package: label, Change: c})
+			}
+		}
+	}
+	return api
+}
+
+// importPath returns the import path of the package in dir, from the
+// nearest go.mod at either revision, or "" when none declares a module.
+func (g *Generator) importPath(dir, from, to string) string {
+	for d := dir; ; d = path.Dir(d) {
+		for _, rev := range []string{to, from} {
+			data, err := gitdiff.Show(rev, path.Join(d, "go.mod"))
+			if err != nil {
+				continue
+			}
+			if mod := gosem.ModulePath(data); mod != "" {
+				if d == "." {
+					return path.Join(mod, dir)
+				}
+				return path.Join(mod, strings.TrimPrefix(dir, d))
+			}
+		}
+		if d == "." {
+			return ""
+		}
+	}
+}
+
+// flagSecurity checks the security rules when Security is set and appends
+// a marked note listing the findings. The model is not involved, so the
+// note only ever states what the rules matched.
+func (g *Generator) flagSecurity(res *Result, diff string) {
+	if !g.cfg.Security {
+		return
+	}
+	rules := append(append(risk.Rules{}, risk.SecurityRules...), g.cfg.SecurityRules...)
+	if res.Security = rules.Check(diff); len(res.Security) == 0 {
+		g.cfg.Status("No security rules matched")
+		return
+	}
+	g.cfg.Status("Security rules matched: %d", len(res.Security))
+	if !g.cfg.TitleOnly {
+		res.Message = strings.TrimRight(res.Message, "\n") + "\n\n" + risk.SecurityNote(res.Security)
+	}
+}
+
+// annotateRisk checks the risk rules when Risk is set and appends a note
+// to the message body for the findings, written by the summarizer model
+// when useModel is set and it answers. Failures only warn.
+func (g *Generator) annotateRisk(ctx context.Context, res *Result, diff string, useModel bool) {
+	cfg := g.cfg
+	if !cfg.Risk {
+		return
+	}
+	rules := append(append(risk.Rules{}, risk.DefaultRules...), cfg.RiskRules...)
+	if res.Risks = rules.Check(diff); len(res.Risks) == 0 {
+		cfg.Status("No risk rules matched")
+		return
+	}
+	cfg.Status("Risk rules matched: %d", len(res.Risks))
+	if cfg.TitleOnly {
+		return
+	}
+	note := risk.Note(res.Risks)
+	if useModel {
+		findings := make([]string, len(res.Risks))
+		for i, f := range res.Risks {
+			findings[i] = "- " + f.String()
+		}
+		cfg.Status("Calling summarizer model '%s' for a risk note", cfg.SummarizerModel)
+		out, err := g.call(ctx, "risk", llm.Request{
+			Model:   cfg.SummarizerModel,
+			Prompt:  prompt.Risk(diff, strings.Join(findings, "\n")),
+			Options: map[string]interface{}{"temperature": 0.0},
+		})
+		out = strings.TrimSpace(strings.SplitN(strings.TrimSpace(out), "\n\n", 2)[0])
+		switch {
+		case err != nil:
+			cfg.Warn("risk note: %v; using the rule findings", err)
+		case out != "":
+			if !strings.HasPrefix(out, "Risk:") {
+				out = "Risk: " + out
+			}
+			note = out
+		}
+	}
+	res.Message = strings.TrimRight(res.Message, "\n") + "\n\n" + note
+}
+
+// releaseNotes fetches notable upstream changes for each bump when
+// ReleaseNotes is set. Failures only warn.
+func (g *Generator) releaseNotes(ctx context.Context, changes []deps.Change) map[int]string {
+	if g.cfg.ReleaseNotes == nil {
+		return nil
+	}
+	notes := map[int]string{}
+	for i, c := range changes {
+		n, err := g.cfg.ReleaseNotes.Notes(ctx, c)
+		if err != nil {
+			g.cfg.Warn("%v", err)
+			continue
+		}
+		if n != "" {
+			notes[i] = n
+		}
+	}
+	return notes
+}
+
+// goSemantic replaces the hunks of each changed Go file with its
+// declaration changes. Omitted, renamed and unparsable files keep their
+// diff chunk.
+func (g *Generator) goSemantic(diff string) string {
+	from, to, ok := g.revisions()
+	if !ok {
+		g.debugf("go-semantic: no base revision for the given diff; keeping hunks")
+		return diff
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, chunk := range gitdiff.SplitFiles(diff) {
+		stats := gitdiff.ParseStat(chunk)
+		if len(stats) == 0 || !strings.HasSuffix(stats[0].Path, ".go") ||
+			strings.Contains(chunk, "\n[content omitted") || strings.Contains(chunk, "\nrename from ") {
+			b.WriteString(chunk)
+			continue
+		}
+		st := stats[0]
+		changes, err := g.goChanges(st, from, to)
+		if err != nil {
+			g.debugf("go-semantic: %s: %v; keeping hunks", st.Path, err)
+			b.WriteString(chunk)
+			continue
+		}
+		for _, line := range strings.SplitAfter(chunk, "\n") {
+			if strings.HasPrefix(line, "diff --git ") || strings.HasPrefix(line, "new file mode") ||
+				strings.HasPrefix(line, "deleted file mode") {
+				b.WriteString(line)
+			}
+		}
+		b.WriteString(gosem.Describe(st.Path, changes))
+		fmt.Fprintf(&b, "[+%d/-%d lines]\n", st.Added, st.Removed)
+		n++
+	}
+	if n > 0 {
+		g.cfg.Status("Described %d Go file(s) by declaration changes", n)
+	}
+	return b.String()
+}
+
+// goChanges compares a Go file between two revisions.
+func (g *Generator) goChanges(st gitdiff.FileStat, from, to string) ([]gosem.Change, error) {
+	before, after, err := g.fileVersions(st, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return gosem.Compare(before, after)
+}
+
+// fileVersions reads a changed file at both revisions; the missing side of
+// an added or deleted file is empty.
+func (g *Generator) fileVersions(st gitdiff.FileStat, from, to string) (before, after []byte, err error) {
+	if st.Status != 'A' {
+		if before, err = gitdiff.Show(from, st.Path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if st.Status != 'D' {
+		if after, err = gitdiff.Show(to, st.Path); err != nil {
+			return nil, nil, err
+		}
+	}
+	return before, after, nil
+}
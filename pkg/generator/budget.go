@@ -0,0 +1,184 @@
+package generator
+
+import (
+	"context"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+)
+
+// Context sizing estimates tokens from bytes, generously for code.
+const (
+	bytesPerToken = 3
+	// ollamaContext is Ollama's default context window; a longer prompt
+	// loses its beginning.
+	ollamaContext = 2048
+	// replyTokens are kept free for the model's answer.
+	replyTokens = 1024
+	// minDiffBytes is the least of a diff a truncated prompt keeps.
+	minDiffBytes = 1024
+)
+
+// fit sizes the context window for prompt p, which contains the diff:
+// num_ctx in opts is raised from Ollama's default to fit p, up to the
+// model's context length, and the diff is truncated when that is still too
+// small, returning the prompt rendered again. Without model details, or
+// with num_ctx set, only the truncation applies, and only when known.
+func (g *Generator) fit(ctx context.Context, model string, st prompt.Stage, vars map[string]string, p, note string, opts map[string]interface{}) (string, error) {
+	info, _ := g.modelInfo(ctx, model)
+	budget := g.cfg.MaxPromptTokens
+	if info.ContextLength == 0 && budget == 0 {
+		return p, nil
+	}
+	limit := info.ContextLength
+	if n, ok := opts["num_ctx"].(int); ok {
+		limit = n
+	}
+	reply := g.replyBudget(opts)
+	need := len(p)/bytesPerToken + reply
+	if budget > 0 {
+		if _, set := opts["num_ctx"]; !set {
+			n := budget + reply
+			if limit > 0 && n > limit {
+				n = limit
+			}
+			opts["num_ctx"] = n
+		}
+		if limit == 0 || budget+reply < limit {
+			limit = budget + reply
+		}
+	} else if _, set := opts["num_ctx"]; !set && need > ollamaContext {
+		n := (need + 1023) / 1024 * 1024
+		if n > limit {
+			n = limit
+		}
+		g.cfg.Status("Setting a %d-token context window for '%s'", n, model)
+		opts["num_ctx"] = n
+	}
+	if need <= limit {
+		return p, nil
+	}
+	diff := vars["diff"]
+	max := (limit-reply)*bytesPerToken - (len(p) - len(diff))
+	if max < minDiffBytes {
+		max = minDiffBytes
+	}
+	if budget > 0 && limit == budget+reply {
+		g.cfg.Warn("the prompt is about %d tokens, over the %d-token prompt budget; truncating the diff", need-reply, budget)
+	} else {
+		g.cfg.Warn("the prompt is about %d tokens but '%s' reads at most %d; truncating the diff", need-reply, model, limit)
+	}
+	short := make(map[string]string, len(vars))
+	for k, v := range vars {
+		short[k] = v
+	}
+	short["diff"] = gitdiff.Truncate(diff, max)
+	out, err := st.Render(short, g.cfg.TitleOnly)
+	if err != nil {
+		return "", err
+	}
+	if note != "" {
+		out += "\n\n" + note
+	}
+	return out, nil
+}
+
+// replyBudget returns the tokens to keep free for the model's answer: the
+// num_predict in opts as capped by MaxOutputTokens, else replyTokens.
+func (g *Generator) replyBudget(opts map[string]interface{}) int {
+	n, ok := opts["num_predict"].(int)
+	if max := g.cfg.MaxOutputTokens; max > 0 && (!ok || n < 0 || n > max) {
+		return max
+	}
+	if ok && n > 0 {
+		return n
+	}
+	return replyTokens
+}
+
+// modelInfo returns what the backend reports about model, asking once per
+// model. It reports false when the backend can't say.
+func (g *Generator) modelInfo(ctx context.Context, model string) (llm.ModelInfo, bool) {
+	if info, ok := g.models[model]; ok {
+		if info == nil {
+			return llm.ModelInfo{}, false
+		}
+		return *info, true
+	}
+	if g.models == nil {
+		g.models = make(map[string]*llm.ModelInfo)
+	}
+	s, ok := g.client.(interface {
+		Show(context.Context, string) (llm.ModelInfo, error)
+	})
+	if !ok {
+		g.models[model] = nil
+		return llm.ModelInfo{}, false
+	}
+	info, err := s.Show(ctx, model)
+	if err != nil {
+		g.debugf("show %s: %v", model, err)
+		g.models[model] = nil
+		return llm.ModelInfo{}, false
+	}
+	g.models[model] = &info
+	return info, true
+}
+
+// Sizes below which a summarizer model tends to miss or invent changes.
+const (
+	minSummarizerParams  = 3.0 // billions
+	minSummarizerContext = 8192
+)
+
+// pickTier replaces the models with those of the model tier covering the
+// diff, if any.
+func (g *Generator) pickTier(stats []gitdiff.FileStat) {
+	if len(g.cfg.ModelTiers) == 0 {
+		return
+	}
+	lines := 0
+	for _, st := range stats {
+		lines += st.Added + st.Removed
+	}
+	t, ok := g.cfg.ModelTiers.Pick(lines)
+	if !ok {
+		return
+	}
+	if t.SummarizerModel != "" {
+		g.cfg.SummarizerModel = t.SummarizerModel
+	}
+	if t.StyleModel != "" {
+		g.cfg.StyleModel = t.StyleModel
+	}
+	g.tiered = true
+	g.cfg.Status("Using summarizer '%s' and style model '%s' for a %d-line diff", g.cfg.SummarizerModel, g.cfg.StyleModel, lines)
+}
+
+// checkModels warns about models unfit for their stage: a summarizer that
+// is small or reads little, and base models without an instruction
+// template, which continue a prompt instead of following it.
+func (g *Generator) checkModels(ctx context.Context, summarize bool) {
+	cfg := g.cfg
+	if info, ok := g.modelInfo(ctx, cfg.SummarizerModel); ok && summarize {
+		if n := info.Parameters(); n > 0 && n < minSummarizerParams && !g.tiered {
+			cfg.Warn("summarizer model '%s' has only %s parameters; summaries from models under %gB often miss or invent changes", cfg.SummarizerModel, info.ParameterSize, minSummarizerParams)
+		}
+		if n := info.ContextLength; n > 0 && n < minSummarizerContext {
+			cfg.Warn("summarizer model '%s' reads at most %d tokens; larger diffs will be truncated", cfg.SummarizerModel, n)
+		}
+	}
+	models := []string{cfg.StyleModel}
+	if summarize && cfg.SummarizerModel != cfg.StyleModel {
+		models = append(models, cfg.SummarizerModel)
+	}
+	for _, m := range models {
+		if s, ok := cfg.Models.For(m); ok && s.Template != "" {
+			continue
+		}
+		if info, ok := g.modelInfo(ctx, m); ok && !info.Instruct() {
+			cfg.Warn("model '%s' has no instruction template; it is likely a base model that won't follow the prompt", m)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+)
+
+// feedbackNote asks the last stage to revise the previous message as the
+// author asks.
+func feedbackNote(previous, feedback string) string {
+	if previous == "" {
+		return "The author asked for these changes to the message: " + feedback
+	}
+	return fmt.Sprintf("Your previous answer was:\n\n%s\n\nThe author asked for these changes: %s\nAnswer again with the message revised accordingly. Keep everything the author did not ask to change, and keep it accurate.", previous, feedback)
+}
+
+// refsNote tells the last stage which names in its message the diff
+// doesn't contain.
+func refsNote(unknown []string) string {
+	return fmt.Sprintf("Your previous answer mentioned %s, which the diff does not contain. Rewrite it without them, naming only files and functions the diff shows.", quoteList(unknown))
+}
+
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// verify has the summarizer model check vars["input"] against the diff and
+// replaces it with the model's correction, if any. Problems only warn; the
+// message is then kept as is.
+func (g *Generator) verify(ctx context.Context, res *Result, vars map[string]string) {
+	cfg := g.cfg
+	if vars["diff"] == "" {
+		cfg.Warn("no diff to check the message against (loaded summary); skipping the factuality check")
+		return
+	}
+	cfg.Status("Calling summarizer model '%s' to check the message against the diff", cfg.SummarizerModel)
+	out, err := g.call(ctx, "verify", llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.Verify(vars["diff"], vars["input"], cfg.TitleOnly),
+		Options: map[string]interface{}{"temperature": 0.0},
+	})
+	if err != nil {
+		cfg.Warn("factuality check: %v; keeping the message as is", err)
+		return
+	}
+	out = strings.TrimSpace(out)
+	if out == "" || strings.EqualFold(strings.TrimRight(out, "."), prompt.Verified) {
+		cfg.Status("Every claim in the message is supported by the diff")
+		return
+	}
+	cfg.Status("Corrected claims the diff does not support")
+	vars["input"] = out
+	res.Corrected = true
+}
+
+// dedupeCommits is how many of the latest commits a title is checked
+// against for duplicates.
+const dedupeCommits = 50
+
+// recentSubjects returns the subjects of the latest commits, or nil when
+// AllowDuplicates is set or the diff has no base revision.
+func (g *Generator) recentSubjects() []string {
+	if g.cfg.AllowDuplicates {
+		return nil
+	}
+	from, _, ok := g.revisions()
+	if !ok {
+		return nil
+	}
+	commits, err := gitdiff.Recent(from, dedupeCommits)
+	if err != nil {
+		g.debugf("recent subjects: %v", err)
+		return nil
+	}
+	subjects := make([]string, len(commits))
+	for i, c := range commits {
+		subjects[i] = c.Subject()
+	}
+	return subjects
+}
+
+// plainTone is the tone that asks for no restyling, only the summary's
+// facts in a conforming format.
+const plainTone = "plain"
+
+// plainConforms reports whether the tone is plain, without examples or
+// quirks, and summary already meets the rules and the style profile's
+// conventions, so the default style stage would have nothing to do.
+func (g *Generator) plainConforms(summary string) bool {
+	cfg := g.cfg
+	if !strings.EqualFold(strings.TrimSpace(cfg.Tone), plainTone) || len(cfg.StyleExamples) > 0 || len(cfg.Quirks) > 0 || len(cfg.Pipeline) > 0 {
+		return false
+	}
+	msg := format.StripLabels(summary)
+	if problems := cfg.Rules.Check(msg); len(problems) > 0 {
+		g.debugf("summary breaks the rules: %v", problems)
+		return false
+	}
+	if cfg.Profile != nil {
+		if problems := cfg.Profile.Check(msg); len(problems) > 0 {
+			g.debugf("summary breaks the style profile: %v", problems)
+			return false
+		}
+	}
+	return true
+}
+
+// title returns the first line of a model's message.
+func title(msg string) string {
+	return strings.SplitN(strings.TrimSpace(msg), "\n", 2)[0]
+}
+
+// shortBodyNote asks the model for a body of at least min lines.
+func shortBodyNote(n, min int) string {
+	return fmt.Sprintf("Your previous answer's body has %d line(s). Answer again with a body of at least %d lines that says more about what the diff changes; do not pad it with anything the diff doesn't show.", n, min)
+}
+
+// duplicateNote asks the model to replace a title that repeats dup.
+func duplicateNote(title, dup string) string {
+	return fmt.Sprintf("Your previous answer's title, %q, nearly repeats the recent commit %q. Answer again with a title that says specifically what this change does, so the two can be told apart.", title, dup)
+}
@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Confidence scores a generated message from 0 (check it carefully) to 1,
+// with the reasons for any doubt.
+type Confidence struct {
+	Score   float64  `json:"score"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// String formats the score and reasons for a status line or comment.
+func (c Confidence) String() string {
+	if len(c.Reasons) == 0 {
+		return fmt.Sprintf("%.2f", c.Score)
+	}
+	return fmt.Sprintf("%.2f (%s)", c.Score, strings.Join(c.Reasons, "; "))
+}
+
+// confidence scores msg from the doubts the checks raised, the message
+// rules it breaks, how many of the changed files it names and, when the
+// backend reports them, how likely the model found its own words.
+func (g *Generator) confidence(msg string, stats []gitdiff.FileStat, doubts []string) Confidence {
+	c := Confidence{Score: 1 - 0.25*float64(len(doubts)), Reasons: doubts}
+	if problems := g.cfg.Rules.Check(format.StripLabels(msg)); len(problems) > 0 {
+		c.Score -= 0.15
+		c.Reasons = append(c.Reasons, fmt.Sprintf("the message breaks %d message rule(s)", len(problems)))
+	}
+	if len(stats) > 0 {
+		named := 0
+		lower := strings.ToLower(msg)
+		for _, st := range stats {
+			base := strings.ToLower(path.Base(st.Path))
+			stem := strings.TrimSuffix(base, path.Ext(base))
+			dir := strings.ToLower(path.Base(path.Dir(st.Path)))
+			if strings.Contains(lower, base) || len(stem) >= 3 && strings.Contains(lower, stem) || dir != "." && strings.Contains(lower, dir) {
+				named++
+			}
+		}
+		if named < len(stats) {
+			c.Score -= 0.2 * float64(len(stats)-named) / float64(len(stats))
+			c.Reasons = append(c.Reasons, fmt.Sprintf("the message names %d of %d changed file(s)", named, len(stats)))
+		}
+	}
+	if g.logprob != nil {
+		p := math.Exp(*g.logprob)
+		c.Score = 0.7*c.Score + 0.3*p
+		if p < 0.6 {
+			c.Reasons = append(c.Reasons, fmt.Sprintf("the model was unsure of its wording (mean token probability %.2f)", p))
+		}
+	}
+	if c.Score < 0 {
+		c.Score = 0
+	}
+	c.Score = math.Round(c.Score*100) / 100
+	return c
+}
@@ -0,0 +1,1456 @@
+// Package generator runs the commit message pipeline: collect the diff, strip
+// sensitive content, summarize it factually with one model, then rewrite the
+// summary in a tone with a second model. The stages can be replaced with a
+// configured prompt.Stage pipeline.
+//
+//	res, err := generator.New(cfg).Generate(ctx)
+package generator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/audit"
+	"github.com/kylegalloway/commit-writer/pkg/changes"
+	"github.com/kylegalloway/commit-writer/pkg/classify"
+	"github.com/kylegalloway/commit-writer/pkg/conflict"
+	"github.com/kylegalloway/commit-writer/pkg/dedupe"
+	"github.com/kylegalloway/commit-writer/pkg/deps"
+	"github.com/kylegalloway/commit-writer/pkg/eval"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/gosem"
+	"github.com/kylegalloway/commit-writer/pkg/lang"
+	"github.com/kylegalloway/commit-writer/pkg/lint"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/middleware"
+	"github.com/kylegalloway/commit-writer/pkg/profile"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+	"github.com/kylegalloway/commit-writer/pkg/redact"
+	"github.com/kylegalloway/commit-writer/pkg/refs"
+	"github.com/kylegalloway/commit-writer/pkg/risk"
+	"github.com/kylegalloway/commit-writer/pkg/semver"
+	"github.com/kylegalloway/commit-writer/pkg/split"
+	"github.com/kylegalloway/commit-writer/pkg/tier"
+	"github.com/kylegalloway/commit-writer/pkg/todo"
+	"github.com/kylegalloway/commit-writer/pkg/trace"
+	"github.com/kylegalloway/commit-writer/pkg/vcs"
+)
+
+// Config describes one generation run.
+type Config struct {
+	// URL is the Ollama generate endpoint; llm.DefaultURL when empty.
+	URL string
+	// APIKey is sent to Ollama as a bearer token when set.
+	APIKey string
+	// Client overrides the Ollama client built from URL, APIKey, Timeout and
+	// LocalOnly, e.g. with a fake in tests.
+	Client          llm.Client
+	SummarizerModel string
+	StyleModel      string
+	// Tone is a tone description or a weighted blend of them, e.g.
+	// "dry:0.7,sarcastic:0.3"; see prompt.ParseTone.
+	Tone string
+	// Intensity, from 0 to 1, scales how far the style stage departs from
+	// the summary; nil means 1. At 0 the summary is the message.
+	Intensity *float64
+	// TitleOnly asks for a single title line instead of title + body.
+	TitleOnly bool
+	// Timeout bounds each model call.
+	Timeout time.Duration
+
+	// LocalOnly refuses to send anything to a non-loopback URL.
+	LocalOnly bool
+	// NoFallback returns an error instead of a diffstat-based message when
+	// Ollama is unreachable.
+	NoFallback bool
+	// NoRedact skips secret redaction. Sensitive paths are filtered anyway.
+	NoRedact bool
+	// DenyPaths are sensitive path globs in addition to
+	// gitdiff.DefaultDenyPaths.
+	DenyPaths []string
+	// Anonymizer, when set, pseudonymizes the diff or loaded summary.
+	Anonymizer *redact.Anonymizer
+	// AuditLog is the path of the prompt audit log; empty disables it.
+	AuditLog string
+
+	// Diff, when set, is used instead of the repository's staged diff.
+	Diff string
+	// VCS reads the repository's diff; nil means git. Outside git the
+	// checks that read history or other revisions (merge resolution,
+	// formatting-only changes, related and recent commits, GoSemantic) are
+	// skipped.
+	VCS vcs.VCS
+	// Paths limits the repository's diff to these repo-relative files, e.g.
+	// one group of a split. It does not apply to a given Diff.
+	Paths []string
+	// DiffFrom is the revision Diff was taken against (e.g. a pull
+	// request's merge base); Diff must then run up to HEAD. Without it
+	// GoSemantic and formatting-only detection leave a given Diff alone.
+	DiffFrom string
+	// Scoped marks a given Diff as hunks picked from the repository's own
+	// staged (or unstaged) diff. The checks that read revisions then run
+	// on the files it names, between HEAD and the index or working tree.
+	Scoped bool
+	// NoClassify turns off detection of single-purpose diffs (e.g. only
+	// tests or docs), dependency changes, file languages and Go API
+	// changes, which otherwise add hints to the summarizer and, with the
+	// default pipeline, a conventional type to the title.
+	NoClassify bool
+	// NoRelated leaves out the latest commits touching the changed files,
+	// which otherwise give the summarizer context on ongoing work.
+	NoRelated bool
+	// AllowDuplicates keeps a title that nearly repeats one of the latest
+	// commit subjects instead of asking the model for another.
+	AllowDuplicates bool
+	// NoRefCheck keeps file names and identifiers that the diff does not
+	// contain in the message. By default the last stage is asked once to
+	// drop them, and the body sentences still naming one are removed.
+	NoRefCheck bool
+	// StyleDocs runs the style pass for docs-only changes too; by default
+	// their factual summary is used as is.
+	StyleDocs bool
+	// ReleaseNotes, when set, adds notable upstream changes to the message
+	// of a diff that only bumps dependencies.
+	ReleaseNotes *deps.ReleaseNotes
+	// GoSemantic replaces the hunks of changed Go files with the
+	// declarations they add, remove or change, read from git.
+	GoSemantic bool
+	// Risk appends a short risk note to the body when a risk rule matches
+	// the diff, written by the summarizer model from the rule findings.
+	Risk bool
+	// RiskRules are checked in addition to risk.DefaultRules.
+	RiskRules risk.Rules
+	// Security appends a marked security note listing the security rules
+	// that match, such as SQL built from strings.
+	Security bool
+	// SecurityRules are checked in addition to risk.SecurityRules.
+	SecurityRules risk.Rules
+	// APIChanges adds an "API changes" paragraph to the body listing the
+	// exported Go declarations the change adds, removes or redefines.
+	APIChanges bool
+	// Todos lists the TODO, FIXME and XXX comments the diff adds in a
+	// paragraph of the body.
+	Todos bool
+	// SemverTrailer adds the inferred release bump to the message as a
+	// semver.Trailer line.
+	SemverTrailer bool
+	// Footer lines, such as a ticket reference, end the message, along
+	// with the semver trailer. Title-only messages get none.
+	Footer []string
+	// Profile is the repository's learned message style, which the prompts
+	// ask the models to follow. Nil uses the built-in conventions.
+	Profile *profile.Profile
+	// StyleExamples are commit messages in the wanted voice, given to the
+	// style stage as few-shot examples.
+	StyleExamples []string
+	// Quirks are the persona's formatting rules for the style stage, such
+	// as "end the title with an emoji".
+	Quirks []string
+	// Ticket is issue tracker context (e.g. a Jira ticket's title and
+	// description) for the summarizer prompt. Secrets and identifiers are
+	// stripped from it like from the diff.
+	Ticket string
+	// Why is the author's own reason for the change, e.g. "working around
+	// upstream bug #42", which the diff cannot show. It is sanitized like
+	// Ticket.
+	Why string
+	// Ask, when set, lets the summarizer model ask the author up to
+	// MaxQuestions questions before summarizing. It returns one answer per
+	// question, empty for a skipped one; the answers are passed on like
+	// Why.
+	Ask func(questions []string) ([]string, error)
+	// MaxQuestions bounds the questions for Ask; 0 means 3.
+	MaxQuestions int
+	// Verify has the summarizer model check the final message against the
+	// diff and correct any claim the diff does not support, such as a
+	// function the style pass made up.
+	Verify bool
+	// Tracer records spans for diff collection, each model call and the
+	// checks; nil records none.
+	Tracer *trace.Tracer
+	// Speculate streams the summary and starts the style pass on its title
+	// and first body line while the summarizer writes the rest, which is
+	// then styled as a continuation. It applies to the default pipeline
+	// with a streaming client and no audit log or after_summary middleware.
+	Speculate bool
+	// Summary, when set, is used instead of running the summarizer.
+	Summary string
+	// SaveSummary writes the summarizer output to this path.
+	SaveSummary string
+	// Feedback, when set, is the author's request for changes to Previous,
+	// an earlier message for the same change, e.g. "shorter". The last
+	// stage is asked to revise Previous accordingly; with Summary set,
+	// that is the only model call. Both are sanitized like Ticket.
+	Feedback string
+	Previous string
+	// Pipeline replaces the default summarize-then-style stages when set.
+	// It must pass prompt.ValidatePipeline.
+	Pipeline []prompt.Stage
+	// Seed, when set, is sent with every model call, so that with
+	// Deterministic the same diff yields the same message.
+	Seed *int
+	// Deterministic sends temperature 0 with every model call, overriding
+	// stage parameters.
+	Deterministic bool
+	// MaxPromptTokens, when set, is the most tokens a prompt may take:
+	// num_ctx defaults to it plus room for the reply, and diffs are
+	// truncated to fit even when the model's context length is unknown.
+	MaxPromptTokens int
+	// MaxOutputTokens, when set, caps num_predict for every model call.
+	MaxOutputTokens int
+	// MinBodyLines and MaxBodyLines, when set, bound the body's non-blank
+	// lines: the prompts ask for that length, a shorter body is asked for
+	// again once and a longer one is cut.
+	MinBodyLines int
+	MaxBodyLines int
+	// Models add stop sequences and replace the prompt template for every
+	// call to a model, by name.
+	Models prompt.ModelParams
+	// Params override the generation parameters of pipeline stages by
+	// name, e.g. the "summary" stage's num_ctx.
+	Params prompt.StageParams
+	// Vars are extra pipeline template fields, e.g. "commits" for the pull
+	// request pipeline.
+	Vars map[string]string
+	// Clean are extra cleanups applied to every model response, after the
+	// client's own.
+	Clean format.CleanRules
+	// Rules are the message rules, as for "commit-writer ci", a summary
+	// must meet to be used as is with the "plain" tone.
+	Rules lint.Rules
+	// ModelTiers replace SummarizerModel and StyleModel by the size of the
+	// diff.
+	ModelTiers tier.Tiers
+	// Middleware runs user commands on the diff after collection and on the
+	// summary once it is produced or loaded.
+	Middleware middleware.Hooks
+
+	// Status receives progress messages; Warn receives non-fatal problems.
+	Status func(format string, args ...interface{})
+	Warn   func(format string, args ...interface{})
+	// Debug logs additional detail through the standard logger.
+	Debug bool
+}
+
+// Stage identifies the pipeline step an *Error came from.
+type Stage string
+
+const (
+	StageLocalOnly Stage = "local-only"
+	StageCheck     Stage = "check"
+	StageDiff      Stage = "diff"
+	StageSummary   Stage = "summary"
+	StageStyle     Stage = "style"
+)
+
+// Error is returned by Generate. Curl, when set, replays the failed request.
+type Error struct {
+	Stage Stage
+	Err   error
+	Curl  string
+	// RequestID is the failed run's request ID.
+	RequestID string
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Result is the outcome of a successful run.
+type Result struct {
+	// Message is the styled commit message, or the diffstat fallback.
+	Message string
+	// Summary is the factual summary the message was styled from.
+	Summary string
+	// Changes is Summary as a structured change list, with the files from
+	// the diff; nil when there is no summary.
+	Changes *changes.Summary
+	// Offline is true when Ollama was unreachable and Message is the
+	// diffstat-based fallback.
+	Offline bool
+	// Omitted lists sensitive files whose content was withheld.
+	Omitted []string
+	// Redactions lists the secrets replaced in the diff.
+	Redactions []redact.Redaction
+	// Kind is the detected single-purpose change type, if any, and Type
+	// its conventional commit type with scope, e.g. "docs(readme)".
+	Kind classify.Kind
+	Type string
+	// Deps lists the dependency changes read from the diff's manifests.
+	Deps []deps.Change
+	// Risks lists the risk rules that matched, when Risk is set.
+	Risks []risk.Finding
+	// Security lists the security rules that matched, when Security is
+	// set.
+	Security []risk.Finding
+	// Semver is the release bump the change calls for.
+	Semver semver.Impact
+	// API lists the exported Go declarations added, removed or redefined
+	// by the change.
+	API []gosem.APIChange
+	// Todos lists the added TODO comments, when Todos is set.
+	Todos []todo.Item
+	// Conflicts lists how each conflicted file was resolved when the
+	// change concludes a merge.
+	Conflicts []conflict.Resolution
+	// DiffHash is the SHA-256 of the diff as collected and normalized (see
+	// gitdiff.Normalize), before filtering and redaction. It is empty when
+	// no diff was needed.
+	DiffHash string
+	// Corrected is set when Verify found claims the diff does not support
+	// and Message is the corrected version.
+	Corrected bool
+	// Confidence is how far Message can be trusted without a close look.
+	Confidence Confidence
+	// RequestID identifies the run; it was sent with every model request.
+	RequestID string
+	// SummarizerModel and StyleModel are the models used, which
+	// ModelTiers may have picked.
+	SummarizerModel string
+	StyleModel      string
+}
+
+// Generator runs the pipeline for a Config.
+type Generator struct {
+	cfg    Config
+	client llm.Client
+	audit  *audit.Log
+	seq    int
+	// depsOnly is set when the diff's dependency changes explain all of it.
+	depsOnly bool
+	// mergeChecked is set once the repository was checked for a merge
+	// being concluded; mergeSubject and conflicts describe that merge.
+	mergeChecked bool
+	mergeSubject string
+	conflicts    []conflict.Resolution
+	// models caches what the backend reports about each model; nil when
+	// it can't say.
+	models map[string]*llm.ModelInfo
+	// logprob is the mean token log probability of the latest pipeline
+	// stage's output, when the backend reports it.
+	logprob *float64
+	// tiered is set when a model tier picked the models, small ones on
+	// purpose.
+	tiered bool
+}
+
+// New returns a Generator for cfg.
+func New(cfg Config) *Generator {
+	if cfg.URL == "" {
+		cfg.URL = llm.DefaultURL
+	}
+	if cfg.Status == nil {
+		cfg.Status = func(string, ...interface{}) {}
+	}
+	if cfg.Warn == nil {
+		cfg.Warn = func(string, ...interface{}) {}
+	}
+	g := &Generator{cfg: cfg, client: cfg.Client}
+	if g.client == nil {
+		g.client = &llm.Ollama{
+			URL:          cfg.URL,
+			APIKey:       cfg.APIKey,
+			Timeout:      cfg.Timeout,
+			LoopbackOnly: cfg.LocalOnly,
+		}
+	}
+	if cfg.AuditLog != "" {
+		g.audit = &audit.Log{Path: cfg.AuditLog, Repo: g.vcs().Root()}
+	}
+	return g
+}
+
+// Check reports whether the model backend is reachable.
+func (g *Generator) Check(ctx context.Context) error {
+	return g.client.Check(ctx)
+}
+
+// ListModels lists the models the backend offers, when it supports that.
+func (g *Generator) ListModels(ctx context.Context) ([]string, error) {
+	if l, ok := g.client.(interface {
+		ListModels(context.Context) ([]string, error)
+	}); ok {
+		return l.ListModels(ctx)
+	}
+	return nil, errors.New("the model provider cannot list models")
+}
+
+// curl returns a shell command replaying req, when the client supports it.
+func (g *Generator) curl(req llm.Request) string {
+	if c, ok := g.client.(interface{ CurlCommand(llm.Request) string }); ok {
+		req.Options = g.pin(req.Options)
+		return c.CurlCommand(req)
+	}
+	return "# (no curl equivalent for this client)"
+}
+
+func (g *Generator) debugf(format string, args ...interface{}) {
+	if g.cfg.Debug {
+		log.Printf(format, args...)
+	}
+}
+
+// Generate produces a commit message for the current repository.
+//
+// Each run has a request ID, taken from ctx (see llm.WithRequestID) or
+// generated, that is sent with every model request and returned in the
+// Result or the *Error.
+func (g *Generator) Generate(ctx context.Context) (*Result, error) {
+	id := llm.RequestID(ctx)
+	if id == "" {
+		id = llm.NewRequestID()
+		ctx = llm.WithRequestID(ctx, id)
+	}
+	g.debugf("request ID: %s", id)
+	ctx, span := g.cfg.Tracer.Start(ctx, "generate")
+	span.Set("request_id", id)
+	res, err := g.generateMessage(ctx)
+	if res != nil {
+		res.RequestID = id
+		span.Set("offline", res.Offline)
+		span.Set("confidence", res.Confidence.String())
+	}
+	var gerr *Error
+	if errors.As(err, &gerr) {
+		gerr.RequestID = id
+	}
+	span.Finish(err)
+	return res, err
+}
+
+// generateMessage is Generate within its span.
+func (g *Generator) generateMessage(ctx context.Context) (*Result, error) {
+	cfg := g.cfg
+	statusf := cfg.Status
+
+	if cfg.LocalOnly {
+		if err := llm.CheckLoopback(cfg.URL); err != nil {
+			return nil, &Error{Stage: StageLocalOnly, Err: err}
+		}
+		statusf("Local-only mode: %s is a loopback address", cfg.URL)
+	}
+
+	// Check Ollama up front so an unreachable server can fall back to a
+	// basic diffstat message instead of failing the commit outright.
+	statusf("Checking Ollama availability at %s (timeout: %v)", cfg.URL, cfg.Timeout)
+	checkCtx, span := cfg.Tracer.Start(ctx, "check")
+	err := g.client.Check(checkCtx)
+	span.Finish(err)
+	if err != nil {
+		g.debugf("checkOllama error: %v", err)
+		if cfg.NoFallback {
+			return nil, &Error{Stage: StageCheck, Err: err}
+		}
+		cfg.Warn("%v", err)
+		if cfg.Summary != "" {
+			statusf("Falling back to the loaded summary")
+		} else {
+			statusf("Falling back to a diffstat-based message")
+		}
+		diff, err := g.gatherDiff()
+		if err != nil {
+			return nil, err
+		}
+		stats := gitdiff.ParseStat(diff)
+		res := &Result{Message: format.Heuristic(stats, cfg.TitleOnly), Offline: true, DiffHash: diffHash(diff)}
+		res.Confidence = Confidence{Score: 0.5, Reasons: []string{"no model was reachable; built from the diffstat"}}
+		switch {
+		case cfg.Summary != "":
+			// A loaded summary is already a factual message.
+			res.Summary, res.Message = cfg.Summary, strings.TrimSpace(cfg.Summary)
+			if cfg.TitleOnly {
+				res.Message = title(res.Message)
+			}
+			res.Confidence.Reasons = []string{"no model was reachable; the loaded summary is the message"}
+		case len(g.conflicts) > 0:
+			res.Conflicts = g.conflicts
+			res.Message = conflict.Message(g.mergeSubject, g.conflicts, cfg.TitleOnly)
+		case !g.detectKind(stats, res):
+		case res.Kind == classify.Style:
+			res.Message = format.Reformatted(stats, cfg.TitleOnly)
+		case res.Type != "":
+			res.Message = format.WithType(res.Message, res.Type)
+		}
+		res.API = g.apiChanges(stats)
+		g.annotate(ctx, res, diff, stats, false)
+		return res, nil
+	}
+	statusf("Ollama reachable")
+
+	stages := cfg.Pipeline
+	if len(stages) == 0 {
+		stages = prompt.DefaultPipeline
+	}
+	// The summary stage is the one replaced by a loaded summary and written
+	// by SaveSummary: the stage named "summary", else the first.
+	summaryIdx := 0
+	for i, st := range stages {
+		if st.Name == "summary" {
+			summaryIdx = i
+			break
+		}
+	}
+
+	intensity := 1.0
+	if cfg.Intensity != nil {
+		intensity = *cfg.Intensity
+	}
+	tone, err := prompt.DescribeTone(cfg.Tone, intensity)
+	if err != nil {
+		return nil, &Error{Stage: StageStyle, Err: err}
+	}
+	res := &Result{}
+	vars := map[string]string{"tone": tone, "ticket": g.sanitize("ticket", g.cfg.Ticket), "why": g.sanitize("reason", g.cfg.Why), "hints": "", "conventions": "", "changes": "",
+		"body_lines": prompt.BodyLines(g.cfg.MinBodyLines, g.cfg.MaxBodyLines)}
+	if cfg.Profile != nil {
+		vars["conventions"] = cfg.Profile.Instructions()
+	}
+	vars["examples"] = prompt.Examples(cfg.StyleExamples)
+	vars["quirks"] = strings.Join(cfg.Quirks, "\n")
+	for k, v := range cfg.Vars {
+		vars[k] = v
+	}
+	first := 0
+	if cfg.Summary != "" {
+		res.Summary = cfg.Summary
+		if cfg.Anonymizer != nil {
+			var n int
+			if res.Summary, n = cfg.Anonymizer.Apply(res.Summary); n > 0 {
+				statusf("Anonymized %d identifier(s) in summary", n)
+			}
+		}
+		if err := g.afterSummary(ctx, res); err != nil {
+			return nil, err
+		}
+		vars[stages[summaryIdx].Name] = res.Summary
+		vars["input"] = res.Summary
+		g.setChanges(res, vars, nil)
+		first = summaryIdx + 1
+	}
+	var stats []gitdiff.FileStat
+	// rawDiff is the diff as prepared; the prompts see small new files in
+	// full instead of as hunks.
+	var rawDiff string
+	for _, st := range stages[first:] {
+		if st.NeedsDiff() {
+			diffCtx, span := cfg.Tracer.Start(ctx, "diff")
+			diff, err := g.prepareDiff(diffCtx, res)
+			span.Set("bytes", len(diff))
+			span.Finish(err)
+			if err != nil {
+				return nil, err
+			}
+			rawDiff, vars["diff"] = diff, gitdiff.ExpandNewFiles(diff, newFileBytes)
+			// A merge's title names the merge, not a conventional type.
+			if stats = gitdiff.ParseStat(diff); len(g.conflicts) == 0 && g.detectKind(stats, res) {
+				statusf("Detected a %s-only change", res.Kind)
+				vars["hints"] = res.Kind.Hint(stats)
+			}
+			if len(res.Deps) > 0 {
+				vars["hints"] = joinHints(vars["hints"], deps.Hint(res.Deps))
+			}
+			res.API = g.apiChanges(stats)
+			if len(g.conflicts) > 0 {
+				res.Conflicts = g.conflicts
+				vars["hints"] = joinHints(g.scrub(conflict.Hint(g.mergeSubject, g.conflicts)), vars["hints"])
+			}
+			if !cfg.NoClassify {
+				vars["hints"] = joinHints(vars["hints"], g.languages(stats))
+				vars["hints"] = joinHints(vars["hints"], g.scrub(gosem.APIReport(apiHint, res.API)))
+			}
+			if !cfg.NoRelated {
+				vars["hints"] = joinHints(vars["hints"], g.related(stats))
+			}
+			g.pickTier(stats)
+			break
+		}
+	}
+	cfg = g.cfg
+	res.SummarizerModel, res.StyleModel = cfg.SummarizerModel, cfg.StyleModel
+	g.checkModels(ctx, first <= summaryIdx)
+	// A dependency bump is fully described by its versions, and a reformat
+	// by its files; the model would only guess at them or invent a
+	// functional change.
+	if res.Kind == classify.Deps && g.depsOnly && len(cfg.Pipeline) == 0 {
+		statusf("Describing the dependency changes without the model")
+		res.Message = deps.Message(res.Deps, g.releaseNotes(ctx, res.Deps), cfg.TitleOnly)
+		res.Confidence = Confidence{Score: 1}
+		g.annotate(ctx, res, rawDiff, stats, true)
+		return res, nil
+	}
+	if res.Kind == classify.Style && len(cfg.Pipeline) == 0 {
+		statusf("Describing the reformat without the model")
+		res.Message = format.Reformatted(stats, cfg.TitleOnly)
+		res.Confidence = Confidence{Score: 1}
+		g.annotate(ctx, res, rawDiff, stats, true)
+		return res, nil
+	}
+	// Docs-only changes are trivial enough that the factual summary is the
+	// message.
+	last := len(stages)
+	if res.Kind == classify.Docs && len(cfg.Pipeline) == 0 && !cfg.StyleDocs {
+		statusf("Skipping the style pass for a docs-only change")
+		last = summaryIdx + 1
+	}
+	if intensity == 0 && len(cfg.Pipeline) == 0 {
+		statusf("Skipping the style pass at intensity 0")
+		last = summaryIdx + 1
+	}
+
+	if cfg.Ask != nil && first <= summaryIdx && vars["diff"] != "" {
+		g.clarify(ctx, vars)
+	}
+	feedback := ""
+	if cfg.Feedback != "" {
+		feedback = feedbackNote(g.sanitize("previous message", cfg.Previous), g.sanitize("feedback", cfg.Feedback))
+	}
+	run := func(ctx context.Context, i int, note string) error {
+		// Retries keep the author's feedback.
+		if i == last-1 {
+			note = joinHints(feedback, note)
+		}
+		errStage := StageStyle
+		if i <= summaryIdx {
+			errStage = StageSummary
+		}
+		out, err := g.runStage(ctx, i, stages[i], vars, errStage, note)
+		if err != nil {
+			return err
+		}
+		if i == summaryIdx {
+			res.Summary = out
+			if err := g.afterSummary(ctx, res); err != nil {
+				return err
+			}
+			out = res.Summary
+			g.saveSummary(out)
+			g.setChanges(res, vars, stats)
+		}
+		vars[stages[i].Name] = out
+		vars["input"] = out
+		return nil
+	}
+	// With the plain tone, a summary that already meets the rules needs no
+	// style pass.
+	if first > summaryIdx && last > first && g.plainConforms(res.Summary) {
+		statusf("Loaded summary already conforms; skipping the style pass for the plain tone")
+		last = first
+	}
+	var doubts []string
+	for i := first; i < last; i++ {
+		stageCtx := ctx
+		var early *earlyStyle
+		if i == summaryIdx && last == i+2 && g.speculates() {
+			early = g.startEarly(ctx, stages[i+1], i+1, vars, feedback, stats)
+			stageCtx = context.WithValue(ctx, partialKey{}, early.partial)
+		}
+		// The builtin style stage reads the summary as a change list.
+		if i == summaryIdx+1 && stages[i].Builtin == "style" && res.Changes != nil && !cfg.TitleOnly {
+			vars["input"] = res.Changes.Text()
+		}
+		if err := run(stageCtx, i, ""); err != nil {
+			if early != nil {
+				early.stop()
+			}
+			return nil, err
+		}
+		if early != nil {
+			if out, ok := early.finish(ctx, res); ok {
+				vars[stages[i+1].Name] = out
+				vars["input"] = out
+				i++
+				continue
+			}
+		}
+		if i == summaryIdx && last > i+1 && g.plainConforms(res.Summary) {
+			statusf("Summary already conforms; skipping the style pass for the plain tone")
+			last = i + 1
+		}
+	}
+	// A title that repeats a recent commit gets one more try, with the
+	// last stage told which commit it repeats.
+	if recent := g.recentSubjects(); len(recent) > 0 && last > first {
+		if dup, ok := dedupe.Find(title(vars["input"]), recent); ok {
+			statusf("Title repeats the recent commit %q; asking for a more specific one", dup)
+			if err := run(ctx, last-1, duplicateNote(title(vars["input"]), dup)); err != nil {
+				return nil, err
+			}
+			if dup, ok := dedupe.Find(title(vars["input"]), recent); ok {
+				cfg.Warn("title still repeats the recent commit %q", dup)
+				doubts = append(doubts, "the title repeats a recent commit")
+			}
+		}
+	}
+	if cfg.Verify && last > first {
+		verifyCtx, span := cfg.Tracer.Start(ctx, "verify")
+		g.verify(verifyCtx, res, vars)
+		span.Set("corrected", res.Corrected)
+		span.Finish(nil)
+	}
+	// File and function names the diff doesn't contain were made up.
+	if !cfg.NoRefCheck && vars["diff"] != "" && last > first {
+		if unknown := refs.Unknown(vars["input"], vars["diff"]); len(unknown) > 0 {
+			statusf("Message mentions %s, which the diff doesn't contain; asking for a corrected one", quoteList(unknown))
+			if err := run(ctx, last-1, refsNote(unknown)); err != nil {
+				return nil, err
+			}
+			if unknown = refs.Unknown(vars["input"], vars["diff"]); len(unknown) > 0 {
+				statusf("Removing what the message still says about %s", quoteList(unknown))
+				vars["input"] = refs.Strip(vars["input"], unknown)
+				doubts = append(doubts, "the model named files or functions the diff doesn't contain")
+				if left := refs.Unknown(title(vars["input"]), vars["diff"]); len(left) > 0 {
+					cfg.Warn("title mentions %s, which the diff doesn't contain", quoteList(left))
+				}
+			}
+		}
+	}
+	// Models follow the asked-for body length loosely.
+	if !cfg.TitleOnly {
+		if n := format.BodyLines(vars["input"]); n < cfg.MinBodyLines && last > first {
+			statusf("Body has %d line(s), fewer than %d; asking for a longer one", n, cfg.MinBodyLines)
+			if err := run(ctx, last-1, shortBodyNote(n, cfg.MinBodyLines)); err != nil {
+				return nil, err
+			}
+			if n := format.BodyLines(vars["input"]); n < cfg.MinBodyLines {
+				cfg.Warn("body still has only %d line(s), fewer than %d", n, cfg.MinBodyLines)
+				doubts = append(doubts, "the body is shorter than asked")
+			}
+		}
+		if n := format.BodyLines(vars["input"]); cfg.MaxBodyLines > 0 && n > cfg.MaxBodyLines {
+			statusf("Cutting the body from %d to %d lines", n, cfg.MaxBodyLines)
+			vars["input"] = format.TrimBody(vars["input"], cfg.MaxBodyLines)
+		}
+	}
+	statusf("Final message generated")
+	res.Message = vars["input"]
+	if res.Type != "" && len(cfg.Pipeline) == 0 {
+		res.Message = format.WithType(res.Message, res.Type)
+	}
+	if res.Corrected {
+		doubts = append(doubts, "the factuality check corrected unsupported claims")
+	}
+	res.Confidence = g.confidence(res.Message, stats, doubts)
+	statusf("Confidence: %s", res.Confidence)
+	g.annotate(ctx, res, rawDiff, stats, true)
+	return res, nil
+}
+
+// Judge has model score message against the eval rubric for the diff,
+// which is collected and sanitized as for Generate.
+func (g *Generator) Judge(ctx context.Context, model, message string) (eval.Score, error) {
+	cfg := g.cfg
+	diff, err := g.prepareDiff(ctx, &Result{})
+	if err != nil {
+		return eval.Score{}, err
+	}
+	conventions := ""
+	if cfg.Profile != nil {
+		conventions = cfg.Profile.Instructions()
+	}
+	cfg.Status("Calling judge model '%s'", model)
+	out, err := g.call(ctx, "judge", llm.Request{
+		Model:   model,
+		Prompt:  prompt.Judge(diff, message, conventions),
+		Options: map[string]interface{}{"temperature": 0.0},
+	})
+	if err != nil {
+		return eval.Score{}, err
+	}
+	return eval.Parse(out)
+}
+
+// Explain has the summarizer model explain a commit, given its message,
+// in plain English. The diff is the given Diff, collected and sanitized as
+// for Generate.
+func (g *Generator) Explain(ctx context.Context, message string) (string, error) {
+	cfg := g.cfg
+	if cfg.LocalOnly {
+		if err := llm.CheckLoopback(cfg.URL); err != nil {
+			return "", &Error{Stage: StageLocalOnly, Err: err}
+		}
+	}
+	diff, err := g.prepareDiff(ctx, &Result{})
+	if err != nil {
+		return "", err
+	}
+	if err := g.client.Check(ctx); err != nil {
+		return "", &Error{Stage: StageCheck, Err: err}
+	}
+	cfg.Status("Calling summarizer model '%s' to explain the commit", cfg.SummarizerModel)
+	req := llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.Explain(diff, g.scrub(message)),
+		Options: map[string]interface{}{"temperature": 0.0},
+	}
+	out, err := g.call(ctx, "explain", req)
+	if err != nil {
+		return "", &Error{Stage: StageSummary, Err: err, Curl: g.curl(req)}
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ReleaseNotes has the summarizer model write the user-facing prose of one
+// group of release notes from its commit messages.
+func (g *Generator) ReleaseNotes(ctx context.Context, group string, messages []string) (string, error) {
+	cfg := g.cfg
+	if cfg.LocalOnly {
+		if err := llm.CheckLoopback(cfg.URL); err != nil {
+			return "", &Error{Stage: StageLocalOnly, Err: err}
+		}
+	}
+	commits := make([]string, len(messages))
+	for i, m := range messages {
+		commits[i] = "- " + strings.ReplaceAll(g.scrub(strings.TrimSpace(m)), "\n", "\n  ")
+	}
+	cfg.Status("Calling summarizer model '%s' for the %s release notes", cfg.SummarizerModel, group)
+	req := llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.ReleaseNotes(group, strings.Join(commits, "\n")),
+		Options: map[string]interface{}{"temperature": 0.0},
+	}
+	out, err := g.call(ctx, "release-notes", req)
+	if err != nil {
+		return "", &Error{Stage: StageSummary, Err: err, Curl: g.curl(req)}
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// newFileBytes is the largest added file whose content the prompts show in
+// full.
+const newFileBytes = 4000
+
+// standupDiffBytes bounds how much of the uncommitted diff Standup shows
+// the model; the commits matter more.
+const standupDiffBytes = 12000
+
+// Standup has the summarizer model turn the author's recent commit
+// messages and uncommitted changes into a few bullets for a standup. When
+// the model is unreachable and NoFallback is unset, the bullets are the
+// commit subjects instead.
+func (g *Generator) Standup(ctx context.Context, messages []string) (string, error) {
+	cfg := g.cfg
+	if cfg.LocalOnly {
+		if err := llm.CheckLoopback(cfg.URL); err != nil {
+			return "", &Error{Stage: StageLocalOnly, Err: err}
+		}
+	}
+	diff := ""
+	if cfg.Diff != "" || g.vcs().HasChanges() {
+		var err error
+		if diff, err = g.prepareDiff(ctx, &Result{}); err != nil {
+			return "", err
+		}
+	}
+	stats := gitdiff.ParseStat(diff)
+	if len(messages) == 0 && len(stats) == 0 {
+		return "", &Error{Stage: StageDiff, Err: errors.New("no commits and no uncommitted changes to report")}
+	}
+	if err := g.client.Check(ctx); err != nil {
+		if cfg.NoFallback {
+			return "", &Error{Stage: StageCheck, Err: err}
+		}
+		cfg.Warn("%v; listing the commits instead", err)
+		var b strings.Builder
+		for _, m := range messages {
+			b.WriteString("- " + strings.SplitN(strings.TrimSpace(m), "\n", 2)[0] + "\n")
+		}
+		if len(stats) > 0 {
+			fmt.Fprintf(&b, "- Working on uncommitted changes to %d file(s)\n", len(stats))
+		}
+		return strings.TrimSpace(b.String()), nil
+	}
+	commits := make([]string, len(messages))
+	for i, m := range messages {
+		commits[i] = "- " + strings.ReplaceAll(g.scrub(strings.TrimSpace(m)), "\n", "\n  ")
+	}
+	cfg.Status("Calling summarizer model '%s' for the standup summary", cfg.SummarizerModel)
+	req := llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.Standup(strings.Join(commits, "\n"), gitdiff.Truncate(diff, standupDiffBytes)),
+		Options: map[string]interface{}{"temperature": 0.0},
+	}
+	out, err := g.call(ctx, "standup", req)
+	if err != nil {
+		return "", &Error{Stage: StageSummary, Err: err, Curl: g.curl(req)}
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Review has the summarizer model write notes for a reviewer of the
+// repository's diff (or the given Diff): what to look at, risks and what to
+// test. The risk and security rules are always checked, and what they
+// match is pointed out to the model.
+func (g *Generator) Review(ctx context.Context) (string, error) {
+	cfg := g.cfg
+	if cfg.LocalOnly {
+		if err := llm.CheckLoopback(cfg.URL); err != nil {
+			return "", &Error{Stage: StageLocalOnly, Err: err}
+		}
+	}
+	diff, err := g.prepareDiff(ctx, &Result{})
+	if err != nil {
+		return "", err
+	}
+	if len(gitdiff.ParseStat(diff)) == 0 {
+		return "", &Error{Stage: StageDiff, Err: errors.New("no changes to review")}
+	}
+	if err := g.client.Check(ctx); err != nil {
+		return "", &Error{Stage: StageCheck, Err: err}
+	}
+	rules := append(append(risk.Rules{}, risk.DefaultRules...), cfg.RiskRules...)
+	rules = append(append(rules, risk.SecurityRules...), cfg.SecurityRules...)
+	var findings []string
+	for _, f := range rules.Check(diff) {
+		findings = append(findings, "- "+f.String())
+	}
+	if len(findings) > 0 {
+		cfg.Status("Risk and security rules matched: %d", len(findings))
+	}
+	cfg.Status("Calling summarizer model '%s' for review notes", cfg.SummarizerModel)
+	req := llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.Review(diff, strings.Join(findings, "\n")),
+		Options: map[string]interface{}{"temperature": 0.0},
+	}
+	out, err := g.call(ctx, "review", req)
+	if err != nil {
+		return "", &Error{Stage: StageSummary, Err: err, Curl: g.curl(req)}
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// defaultQuestions is how many clarifying questions Ask gets without
+// MaxQuestions.
+const defaultQuestions = 3
+
+// clarify asks the author the summarizer model's questions about the diff
+// and adds the answers to the author's reason in vars. Problems only warn;
+// the message is then written without answers.
+func (g *Generator) clarify(ctx context.Context, vars map[string]string) {
+	cfg := g.cfg
+	max := cfg.MaxQuestions
+	if max <= 0 {
+		max = defaultQuestions
+	}
+	cfg.Status("Calling summarizer model '%s' for clarifying questions", cfg.SummarizerModel)
+	out, err := g.call(ctx, "questions", llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.Questions(vars["diff"], vars["ticket"], vars["why"], vars["hints"], max),
+		Options: map[string]interface{}{"temperature": 0.0},
+	})
+	if err != nil {
+		cfg.Warn("clarifying questions: %v; continuing without them", err)
+		return
+	}
+	questions := parseQuestions(out, max)
+	if len(questions) == 0 {
+		cfg.Status("No clarifying questions")
+		return
+	}
+	answers, err := cfg.Ask(questions)
+	if err != nil {
+		cfg.Warn("clarifying questions: %v; continuing without answers", err)
+		return
+	}
+	var qa []string
+	for i, q := range questions {
+		if i < len(answers) && strings.TrimSpace(answers[i]) != "" {
+			qa = append(qa, "Q: "+q+"\nA: "+g.sanitize("answers", answers[i]))
+		}
+	}
+	vars["why"] = joinHints(vars["why"], strings.Join(qa, "\n"))
+}
+
+// questionPrefixRe matches list markers before a question, e.g. "- " or
+// "2) ".
+var questionPrefixRe = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s*`)
+
+// parseQuestions returns the lines of a model's reply that are questions,
+// at most max of them.
+func parseQuestions(reply string, max int) []string {
+	var questions []string
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(questionPrefixRe.ReplaceAllString(strings.TrimSpace(line), ""))
+		if strings.HasSuffix(line, "?") && len(questions) < max {
+			questions = append(questions, line)
+		}
+	}
+	return questions
+}
+
+// setChanges parses the summary into res.Changes, with the files of stats,
+// and gives later stages it as JSON in the "changes" field.
+func (g *Generator) setChanges(res *Result, vars map[string]string, stats []gitdiff.FileStat) {
+	c := changes.Parse(res.Summary)
+	c.Files = changes.Files(stats)
+	res.Changes = &c
+	b, _ := json.Marshal(c)
+	vars["changes"] = string(b)
+}
+
+// Split suggests how to break the repository's diff into cohesive commits
+// by asking the summarizer model to group the changed files. When the
+// model is unreachable or its answer names none of the files, the files
+// are grouped by directory instead.
+func (g *Generator) Split(ctx context.Context) ([]split.Group, error) {
+	cfg := g.cfg
+	if cfg.LocalOnly {
+		if err := llm.CheckLoopback(cfg.URL); err != nil {
+			return nil, &Error{Stage: StageLocalOnly, Err: err}
+		}
+	}
+	diff, err := g.prepareDiff(ctx, &Result{})
+	if err != nil {
+		return nil, err
+	}
+	stats := gitdiff.ParseStat(diff)
+	if len(stats) == 0 {
+		return nil, &Error{Stage: StageDiff, Err: errors.New("no changes to split")}
+	}
+	files := make([]string, len(stats))
+	for i, st := range stats {
+		files[i] = st.Path
+	}
+	if len(files) == 1 {
+		return []split.Group{{Title: "Changes in " + files[0], Files: files}}, nil
+	}
+	if err := g.client.Check(ctx); err != nil {
+		cfg.Warn("%v; grouping files by directory", err)
+		return split.ByDirectory(stats), nil
+	}
+	cfg.Status("Calling summarizer model '%s' to group %d files", cfg.SummarizerModel, len(files))
+	out, err := g.call(ctx, "split", llm.Request{
+		Model:   cfg.SummarizerModel,
+		Prompt:  prompt.Split(diff, files),
+		Options: map[string]interface{}{"temperature": 0.0},
+	})
+	if err != nil {
+		cfg.Warn("grouping failed: %v; grouping files by directory", err)
+		return split.ByDirectory(stats), nil
+	}
+	groups, ok := split.Parse(out, files)
+	if !ok {
+		g.debugf("split: unusable grouping:\n%s", out)
+		cfg.Warn("the model's grouping named none of the changed files; grouping files by directory")
+		return split.ByDirectory(stats), nil
+	}
+	return groups, nil
+}
+
+// languages describes the languages of the changed files, honoring
+// linguist overrides in .gitattributes when run inside the repository.
+func (g *Generator) languages(stats []gitdiff.FileStat) string {
+	paths := make([]string, len(stats))
+	for i, s := range stats {
+		paths[i] = s.Path
+	}
+	attrs, err := gitdiff.CheckAttr(paths, "linguist-language", "linguist-generated", "linguist-vendored")
+	if err != nil {
+		g.debugf("languages: %v", err)
+	}
+	return lang.Hint(lang.Files(paths, attrs))
+}
+
+// relatedCommits is how many of the latest commits touching the changed
+// files the summarizer sees, like "git log --oneline -n 5 -- <paths>".
+const relatedCommits = 5
+
+// related lists the latest commits touching the changed files, so the
+// summarizer knows the ongoing work on them and neither repeats nor
+// contradicts it. It is empty for a given diff without a base revision.
+func (g *Generator) related(stats []gitdiff.FileStat) string {
+	from, _, ok := g.revisions()
+	if !ok || len(stats) == 0 {
+		return ""
+	}
+	paths := make([]string, len(stats))
+	for i, s := range stats {
+		paths[i] = s.Path
+	}
+	commits, err := gitdiff.Recent(from, relatedCommits, paths...)
+	if err != nil || len(commits) == 0 {
+		g.debugf("related commits: %v", err)
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Recent commits to these files, newest first (context only; describe this change without repeating or contradicting them):")
+	for _, c := range commits {
+		hash := c.Hash
+		if len(hash) > 7 {
+			hash = hash[:7]
+		}
+		b.WriteString("\n- " + hash + " " + c.Subject())
+	}
+	return g.scrub(b.String())
+}
+
+// joinHints adds a summarizer note to the existing ones.
+func joinHints(hints, more string) string {
+	if hints == "" || more == "" {
+		return hints + more
+	}
+	return hints + "\n\n" + more
+}
+
+// detectKind records in res whether the diff is single-purpose (e.g. only
+// tests, or only reformatting) and reports whether it is.
+func (g *Generator) detectKind(stats []gitdiff.FileStat, res *Result) bool {
+	if g.cfg.NoClassify {
+		return false
+	}
+	res.Kind = classify.Diff(stats)
+	if len(stats) > 0 && g.formattingOnly() {
+		res.Kind = classify.Style
+	}
+	// A repository that rarely uses type prefixes gets none.
+	if res.Type = res.Kind.Type(stats); g.cfg.Profile != nil && !g.cfg.Profile.UsesTypes() {
+		res.Type = ""
+	}
+	return res.Kind != classify.None
+}
+
+// formattingOnly reports whether the diff disappears when whitespace and
+// blank lines are ignored. A given Diff can only be checked with DiffFrom;
+// a Scoped one is checked as the whole diff it was picked from.
+func (g *Generator) formattingOnly() bool {
+	from := ""
+	switch {
+	case g.cfg.Diff != "" && !g.cfg.Scoped:
+		if g.cfg.DiffFrom == "" {
+			return false
+		}
+		from = g.cfg.DiffFrom
+	case g.vcs().Name() != "git":
+		return false
+	}
+	ok, err := gitdiff.WhitespaceOnly(from, g.cfg.Paths...)
+	if err != nil {
+		g.debugf("formatting check: %v", err)
+		return false
+	}
+	return ok
+}
+
+// vcs returns the configured version control system.
+func (g *Generator) vcs() vcs.VCS {
+	if g.cfg.VCS == nil {
+		return vcs.Git{}
+	}
+	return g.cfg.VCS
+}
+
+// gatherDiff collects the staged (or unstaged) diff.
+func (g *Generator) gatherDiff() (string, error) {
+	if g.cfg.Diff != "" {
+		return g.cfg.Diff, nil
+	}
+	if g.vcs().Name() != "git" {
+		g.cfg.Status("Gathering %s diff", g.vcs().Name())
+		diff, err := g.vcs().Diff(g.cfg.Paths...)
+		if err != nil {
+			return "", &Error{Stage: StageDiff, Err: err}
+		}
+		g.cfg.Status("Diff collected (%d bytes)", len(diff))
+		return diff, nil
+	}
+	g.checkMerge()
+	g.cfg.Status("Gathering git diff (staged or unstaged)")
+	diff, err := gitdiff.Staged(g.cfg.Paths...)
+	if err != nil {
+		g.debugf("getStagedDiff error: %v", err)
+		return "", &Error{Stage: StageDiff, Err: err}
+	}
+	g.cfg.Status("Diff collected (%d bytes)", len(diff))
+	return diff, nil
+}
+
+// checkMerge limits the diff to the conflicted files when the staged
+// changes conclude a merge that had conflicts, and records how each was
+// resolved, so the message describes the resolution rather than both
+// branches' changes.
+func (g *Generator) checkMerge() {
+	if g.mergeChecked || g.cfg.NoClassify {
+		return
+	}
+	g.mergeChecked = true
+	msg, ok := gitdiff.MergeMsg()
+	if !ok || !gitdiff.HasStaged() {
+		return
+	}
+	files := conflict.Files(msg)
+	if len(g.cfg.Paths) > 0 {
+		files = intersect(files, g.cfg.Paths)
+	}
+	if len(files) == 0 {
+		return
+	}
+	for _, f := range files {
+		r := conflict.Resolve(f, version("HEAD", f), version("MERGE_HEAD", f), version(":", f))
+		if r.Markers {
+			g.cfg.Warn("%s still contains conflict markers", f)
+		}
+		g.conflicts = append(g.conflicts, r)
+	}
+	g.mergeSubject = conflict.Subject(msg)
+	g.cfg.Paths = files
+	g.cfg.Status("Concluding a merge with %d conflicted file(s); describing the resolution", len(files))
+}
+
+// version returns a file's content at rev (see gitdiff.Show), or nil when
+// it does not exist there.
+func version(rev, name string) []byte {
+	content, err := gitdiff.Show(rev, name)
+	if err != nil {
+		return nil
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return content
+}
+
+// intersect returns the paths in a that are also in b.
+func intersect(a, b []string) []string {
+	in := map[string]bool{}
+	for _, p := range b {
+		in[p] = true
+	}
+	var both []string
+	for _, p := range a {
+		if in[p] {
+			both = append(both, p)
+		}
+	}
+	return both
+}
+
+// prepareDiff collects the diff and strips sensitive paths, secrets and
+// identifiers from it, recording what was removed in res.
+func (g *Generator) prepareDiff(ctx context.Context, res *Result) (string, error) {
+	cfg := g.cfg
+	statusf := cfg.Status
+
+	diff, err := g.gatherDiff()
+	if err != nil {
+		return "", err
+	}
+	res.DiffHash = diffHash(diff)
+	// Middleware sees the raw diff; the built-in filters below still apply
+	// to whatever it returns.
+	if len(cfg.Middleware[middleware.AfterDiff]) > 0 {
+		statusf("Running %s middleware", middleware.AfterDiff)
+		if diff, err = cfg.Middleware.Run(ctx, middleware.AfterDiff, diff); err != nil {
+			return "", &Error{Stage: StageDiff, Err: err}
+		}
+	}
+	diff = gitdiff.Normalize(diff)
+	// Sensitive paths are always filtered, independent of NoRedact.
+	diff, res.Omitted = gitdiff.OmitSensitive(diff, g.denyPaths())
+	if len(res.Omitted) > 0 {
+		statusf("Omitted content of %d sensitive file(s): %s", len(res.Omitted), strings.Join(res.Omitted, ", "))
+	}
+	if cfg.GoSemantic {
+		diff = g.goSemantic(diff)
+	}
+	if !cfg.NoRedact {
+		diff, res.Redactions = redact.Secrets(diff)
+		if len(res.Redactions) > 0 {
+			statusf("Redacted %d secret(s) from diff: %s", len(res.Redactions), redact.Describe(res.Redactions))
+		}
+	}
+	if cfg.Anonymizer != nil {
+		var n int
+		if diff, n = cfg.Anonymizer.Apply(diff); n > 0 {
+			statusf("Anonymized %d identifier(s) in diff", n)
+		}
+	}
+	// Versions are read after redaction so nothing redacted can reach the
+	// message through them.
+	if !cfg.NoClassify {
+		if res.Deps, g.depsOnly = deps.Parse(diff); len(res.Deps) > 0 {
+			statusf("Found %d dependency change(s)", len(res.Deps))
+			diff = deps.CollapseLocks(diff)
+		}
+	}
+	if g.audit != nil {
+		g.audit.DiffHash = diffHash(diff)
+	}
+	return diff, nil
+}
+
+// diffHash hashes diff as normalized, so diffs that differ only in index
+// lines, timestamps or whitespace noise hash the same.
+func diffHash(diff string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(gitdiff.Normalize(diff))))
+}
+
+// denyPaths returns the sensitive path globs.
+func (g *Generator) denyPaths() []string {
+	return append(append([]string{}, gitdiff.DefaultDenyPaths...), g.cfg.DenyPaths...)
+}
+
+// denied reports whether a repo path is sensitive.
+func (g *Generator) denied(name string) bool {
+	for _, p := range g.denyPaths() {
+		if gitdiff.MatchPath(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// revisions returns the gitdiff.Show revisions the diff runs between. ok
+// is false for a given Diff without DiffFrom or Scoped and outside git.
+func (g *Generator) revisions() (from, to string, ok bool) {
+	if g.cfg.Diff != "" && !g.cfg.Scoped {
+		return g.cfg.DiffFrom, "HEAD", g.cfg.DiffFrom != ""
+	}
+	if g.vcs().Name() != "git" {
+		return "", "", false
+	}
+	if gitdiff.HasStaged() {
+		return "HEAD", ":", true
+	}
+	return "HEAD", "", true
+}
+
+// scrub redacts secrets and applies the anonymizer to text read from the
+// repository outside the diff.
+func (g *Generator) scrub(s string) string {
+	if !g.cfg.NoRedact {
+		s, _ = redact.Secrets(s)
+	}
+	if g.cfg.Anonymizer != nil {
+		s, _ = g.cfg.Anonymizer.Apply(s)
+	}
+	return s
+}
+
+// sanitize redacts secrets from and anonymizes text given for the prompts,
+// such as the ticket context, reporting what it replaced in what.
+func (g *Generator) sanitize(what, text string) string {
+	cfg := g.cfg
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	if !cfg.NoRedact {
+		var found []redact.Redaction
+		if text, found = redact.Secrets(text); len(found) > 0 {
+			cfg.Status("Redacted %d secret(s) from %s: %s", len(found), what, redact.Describe(found))
+		}
+	}
+	if cfg.Anonymizer != nil {
+		var n int
+		if text, n = cfg.Anonymizer.Apply(text); n > 0 {
+			cfg.Status("Anonymized %d identifier(s) in %s", n, what)
+		}
+	}
+	return text
+}
+
+// afterSummary runs the after_summary middleware on res.Summary.
+func (g *Generator) afterSummary(ctx context.Context, res *Result) error {
+	if len(g.cfg.Middleware[middleware.AfterSummary]) == 0 {
+		return nil
+	}
+	g.cfg.Status("Running %s middleware", middleware.AfterSummary)
+	out, err := g.cfg.Middleware.Run(ctx, middleware.AfterSummary, res.Summary)
+	if err != nil {
+		return &Error{Stage: StageSummary, Err: err}
+	}
+	res.Summary = strings.TrimSpace(out)
+	return nil
+}
+
+// runStage renders and sends one pipeline stage, with note (if any) after
+// its prompt, retrying once on error.
+func (g *Generator) runStage(ctx context.Context, i int, st prompt.Stage, vars map[string]string, errStage Stage, note string) (string, error) {
+	cfg := g.cfg
+	statusf := cfg.Status
+
+	model, temp := st.Model, 0.9
+	if i == 0 {
+		temp = 0.0
+	}
+	if model == "" {
+		model = cfg.StyleModel
+		if i == 0 || st.Builtin == "summary" {
+			model = cfg.SummarizerModel
+		}
+	}
+	params := st.Params().Merge(cfg.Params[st.Name])
+	p, err := st.Render(vars, cfg.TitleOnly)
+	if err != nil {
+		return "", &Error{Stage: errStage, Err: err}
+	}
+	if note != "" {
+		p += "\n\n" + note
+	}
+
+	switch st.Builtin {
+	case "summary":
+		statusf("Calling summarizer model '%s'", model)
+	case "style":
+		statusf("Calling style model '%s' with tone: %s", model, cfg.Tone)
+	default:
+		statusf("Running pipeline stage '%s' with model '%s'", st.Name, model)
+	}
+	opts := params.Options(temp)
+	if st.NeedsDiff() {
+		if p, err = g.fit(ctx, model, st, vars, p, note, opts); err != nil {
+			return "", &Error{Stage: errStage, Err: err}
+		}
+	}
+	req := llm.Request{
+		Model:   model,
+		Prompt:  p,
+		Stream:  false,
+		Options: opts,
+	}
+	// The final stage's log probabilities feed the confidence score.
+	_, req.Logprobs = g.client.(llm.LogprobClient)
+
+	var out string
+	var lastErr error
+	for attempt := 1; attempt <= 2; attempt++ {
+		out, lastErr = g.call(ctx, st.Name, req)
+		if lastErr != nil {
+			g.debugf("%s call error (attempt %d): %v", st.Name, attempt, lastErr)
+			continue
+		}
+		statusf("Stage '%s' done (attempt %d)", st.Name, attempt)
+		break
+	}
+	if lastErr != nil {
+		if st.Builtin == "" {
+			lastErr = fmt.Errorf("pipeline stage %q: %w", st.Name, lastErr)
+		}
+		return "", &Error{Stage: errStage, Err: lastErr, Curl: g.curl(req)}
+	}
+	return out, nil
+}
+
+// saveSummary writes the summary to SaveSummary when requested.
+func (g *Generator) saveSummary(summary string) {
+	cfg := g.cfg
+	if cfg.SaveSummary == "" {
+		return
+	}
+	cfg.Status("Saving summary to %s", cfg.SaveSummary)
+	if err := os.WriteFile(cfg.SaveSummary, []byte(summary), 0644); err != nil {
+		cfg.Warn("failed to save summary: %v", err)
+		g.debugf("save summary error: %v", err)
+	} else {
+		cfg.Status("Summary saved successfully")
+	}
+}
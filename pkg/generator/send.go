@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/audit"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+)
+
+// call sends one request, with its options pinned as configured, and
+// applies the Clean rules to the response.
+func (g *Generator) call(ctx context.Context, stage string, req llm.Request) (string, error) {
+	req.Options = g.pin(req.Options)
+	req = g.forModel(req)
+	out, err := g.send(ctx, stage, req)
+	if err != nil {
+		return "", err
+	}
+	return g.cfg.Clean.Apply(out), nil
+}
+
+// forModel applies the Models settings for req's model: its stop sequences
+// are added to the request's and its template replaces the model's.
+func (g *Generator) forModel(req llm.Request) llm.Request {
+	s, ok := g.cfg.Models.For(req.Model)
+	if !ok {
+		return req
+	}
+	if len(s.Stop) > 0 {
+		opts := make(map[string]interface{}, len(req.Options)+1)
+		for k, v := range req.Options {
+			opts[k] = v
+		}
+		stop, _ := opts["stop"].([]string)
+		opts["stop"] = append(append([]string{}, stop...), s.Stop...)
+		req.Options = opts
+	}
+	if s.Template != "" {
+		req.Template = s.Template
+	}
+	return req
+}
+
+// pin returns opts with the Seed, Deterministic and token budget settings
+// applied.
+func (g *Generator) pin(opts map[string]interface{}) map[string]interface{} {
+	if g.cfg.Seed == nil && !g.cfg.Deterministic && g.cfg.MaxPromptTokens == 0 && g.cfg.MaxOutputTokens == 0 {
+		return opts
+	}
+	pinned := map[string]interface{}{}
+	for k, v := range opts {
+		pinned[k] = v
+	}
+	if g.cfg.Seed != nil {
+		pinned["seed"] = *g.cfg.Seed
+	}
+	if g.cfg.Deterministic {
+		pinned["temperature"] = 0.0
+	}
+	if max := g.cfg.MaxOutputTokens; max > 0 {
+		if n, ok := opts["num_predict"].(int); !ok || n < 0 || n > max {
+			pinned["num_predict"] = max
+		}
+	}
+	if _, set := pinned["num_ctx"]; !set && g.cfg.MaxPromptTokens > 0 {
+		pinned["num_ctx"] = g.cfg.MaxPromptTokens + g.replyBudget(opts)
+	}
+	return pinned
+}
+
+// generate sends req to the client, keeping the mean token log probability
+// when req asks for it and the client reports it.
+func (g *Generator) generate(ctx context.Context, req llm.Request) (string, error) {
+	if partial, ok := ctx.Value(partialKey{}).(func(string)); ok {
+		if sc, ok := g.client.(llm.StreamClient); ok {
+			return sc.GenerateStream(ctx, req, partial)
+		}
+	}
+	lc, ok := g.client.(llm.LogprobClient)
+	if !req.Logprobs || !ok {
+		return g.client.Generate(ctx, req)
+	}
+	out, mean, ok, err := lc.GenerateLogprob(ctx, req)
+	g.logprob = nil
+	if ok {
+		g.logprob = &mean
+	}
+	return out, err
+}
+
+// send sends one request to Ollama in a span named for the stage.
+func (g *Generator) send(ctx context.Context, stage string, req llm.Request) (string, error) {
+	ctx, span := g.cfg.Tracer.Start(ctx, "llm "+stage)
+	span.Set("gen_ai.request.model", req.Model)
+	span.Set("prompt_bytes", len(req.Prompt))
+	out, err := g.sendAudited(ctx, stage, req)
+	span.Set("response_bytes", len(out))
+	span.Finish(err)
+	return out, err
+}
+
+// stopSequences returns the "stop" option of a request.
+func stopSequences(opts map[string]interface{}) []string {
+	switch stop := opts["stop"].(type) {
+	case []string:
+		return stop
+	case []interface{}:
+		var out []string
+		for _, s := range stop {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// sendAudited sends one request, recording it in the audit log first when
+// one is configured. If the request cannot be logged it is not sent.
+func (g *Generator) sendAudited(ctx context.Context, stage string, req llm.Request) (string, error) {
+	if g.audit == nil {
+		return g.generate(ctx, req)
+	}
+	g.seq++
+	id := fmt.Sprintf("%d-%d", time.Now().UnixNano(), g.seq)
+	rid := llm.RequestID(ctx)
+	if err := g.audit.Record(audit.Entry{ID: id, Kind: "request", Stage: stage, Model: req.Model, URL: g.cfg.URL, Prompt: req.Prompt, Options: req.Options, Stop: stopSequences(req.Options), Template: req.Template, RequestID: rid}); err != nil {
+		return "", fmt.Errorf("failed to write audit log, request not sent: %w", err)
+	}
+	out, err := g.generate(ctx, req)
+	entry := audit.Entry{ID: id, Kind: "response", Stage: stage, Model: req.Model, URL: g.cfg.URL, Response: out, RequestID: rid}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if aerr := g.audit.Record(entry); aerr != nil {
+		g.cfg.Warn("failed to write audit log: %v", aerr)
+	}
+	return out, err
+}
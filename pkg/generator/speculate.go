@@ -0,0 +1,200 @@
+package generator
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/changes"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/middleware"
+	"github.com/kylegalloway/commit-writer/pkg/prompt"
+)
+
+// partialKey is the context key of the function a streamed call reports
+// its output so far to.
+type partialKey struct{}
+
+// speculates reports whether the style pass may start before the summary
+// is complete. The plain tone may skip the style pass, an audit log keeps
+// one call at a time, and after_summary middleware could change the lines
+// the style pass started on.
+func (g *Generator) speculates() bool {
+	cfg := g.cfg
+	_, stream := g.client.(llm.StreamClient)
+	return cfg.Speculate && stream && len(cfg.Pipeline) == 0 && g.audit == nil &&
+		len(cfg.Middleware[middleware.AfterSummary]) == 0 &&
+		!strings.EqualFold(strings.TrimSpace(cfg.Tone), plainTone)
+}
+
+// earlyStyle is a style stage started on the first lines of a summary
+// that is still being written.
+type earlyStyle struct {
+	g      *Generator
+	ctx    context.Context
+	cancel context.CancelFunc
+	stage  prompt.Stage
+	i      int
+	vars   map[string]string
+	note   string
+	// files are the changed files a change list names.
+	files []changes.File
+	// input is the summary start the stage runs on, set once it started.
+	input string
+	// list is the change list input renders, or nil when the stage reads
+	// the summary as written.
+	list *changes.Summary
+	done chan earlyResult
+}
+
+// earlyResult is the early style stage's output.
+type earlyResult struct {
+	out string
+	err error
+}
+
+// startEarly prepares the style stage st, stage i, to start as soon as
+// the summary passed to partial has a complete title and first body line.
+func (g *Generator) startEarly(ctx context.Context, st prompt.Stage, i int, vars map[string]string, note string, stats []gitdiff.FileStat) *earlyStyle {
+	ctx, cancel := context.WithCancel(ctx)
+	return &earlyStyle{g: g, ctx: ctx, cancel: cancel, stage: st, i: i, vars: vars, note: note, files: changes.Files(stats)}
+}
+
+// partial starts the style stage once the summary so far has enough
+// complete lines.
+func (e *earlyStyle) partial(raw string) {
+	if e.done != nil {
+		return
+	}
+	input, ok := earlyInput(raw, e.g.cfg.TitleOnly)
+	if !ok {
+		return
+	}
+	input = e.g.cfg.Clean.Apply(format.CleanModelOutput(input))
+	need := 2
+	if e.g.cfg.TitleOnly {
+		need = 1
+	}
+	if countLines(input) < need {
+		return
+	}
+	e.g.cfg.Status("Starting the style pass on the summary's first lines")
+	vars := make(map[string]string, len(e.vars))
+	for k, v := range e.vars {
+		vars[k] = v
+	}
+	vars["summary"], vars["input"] = input, input
+	// The builtin style stage reads the summary as a change list, as in
+	// the full pass.
+	if list, ok := changeList(e.stage, input, e.files, e.g.cfg.TitleOnly); ok {
+		vars["input"], e.list = list.Text(), list
+	}
+	e.input, e.done = vars["input"], make(chan earlyResult, 1)
+	go func() {
+		out, err := e.g.runStage(e.ctx, e.i, e.stage, vars, StageStyle, e.note)
+		e.done <- earlyResult{out, err}
+	}()
+}
+
+// finish returns the message for the complete summary from the early
+// style stage: as is when the summary ended where the stage started, else
+// continued with the rest of the summary. It reports false when the
+// style stage should run on the whole summary instead, including when
+// the summary's change list doesn't start with the one the stage read.
+func (e *earlyStyle) finish(ctx context.Context, res *Result) (string, bool) {
+	defer e.cancel()
+	g := e.g
+	if e.done == nil {
+		return "", false
+	}
+	summary := strings.TrimSpace(res.Summary)
+	sameTitle := g.cfg.TitleOnly && title(summary) == title(e.input)
+	rest, ok := strings.TrimPrefix(summary, e.input), strings.HasPrefix(summary, e.input)
+	if e.list != nil {
+		rest, ok = e.list.Rest(res.Changes)
+	}
+	if !sameTitle && !ok {
+		g.cfg.Status("The summary changed the lines the early style pass started on; styling it in full")
+		e.stop()
+		return "", false
+	}
+	r := <-e.done
+	if r.err != nil {
+		g.debugf("early style pass: %v", r.err)
+		return "", false
+	}
+	rest = strings.TrimSpace(rest)
+	if sameTitle || rest == "" {
+		return r.out, true
+	}
+	g.cfg.Status("Styling the rest of the summary as a continuation")
+	vars := e.vars
+	req := llm.Request{
+		Model:   g.cfg.StyleModel,
+		Prompt:  prompt.StylePatch(r.out, rest, vars["tone"], vars["conventions"], vars["examples"], vars["quirks"]),
+		Options: e.stage.Params().Merge(g.cfg.Params[e.stage.Name]).Options(0.9),
+	}
+	if e.note != "" {
+		req.Prompt += "\n\n" + e.note
+	}
+	_, req.Logprobs = g.client.(llm.LogprobClient)
+	more, err := g.call(ctx, e.stage.Name, req)
+	if err != nil || strings.TrimSpace(more) == "" {
+		g.debugf("style continuation: %v", err)
+		return "", false
+	}
+	return strings.TrimSpace(r.out) + "\n" + strings.TrimSpace(more), true
+}
+
+// stop cancels the style stage and waits for it to return, so it is done
+// before another stage runs.
+func (e *earlyStyle) stop() {
+	e.cancel()
+	if e.done != nil {
+		<-e.done
+	}
+}
+
+// changeList returns the change list stage st reads for summary: the
+// builtin style stage reads one unless only a title is asked for.
+func changeList(st prompt.Stage, summary string, files []changes.File, titleOnly bool) (*changes.Summary, bool) {
+	if st.Builtin != "style" || titleOnly {
+		return nil, false
+	}
+	c := changes.Parse(summary)
+	c.Files = files
+	return &c, true
+}
+
+// earlyInput returns the start of a summary being written up to the end of
+// its first body line, or of its title line for a title-only message.
+func earlyInput(raw string, titleOnly bool) (string, bool) {
+	need := 2
+	if titleOnly {
+		need = 1
+	}
+	end := 0
+	for need > 0 {
+		nl := strings.IndexByte(raw[end:], '\n')
+		if nl < 0 {
+			return "", false
+		}
+		if line := strings.TrimSpace(raw[end : end+nl]); line != "" && !strings.EqualFold(line, "Body:") {
+			need--
+		}
+		end += nl + 1
+	}
+	return raw[:end], true
+}
+
+// countLines counts the non-blank lines of s.
+func countLines(s string) int {
+	n := 0
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,906 @@
+// Package gitdiff collects git diffs and inspects their per-file structure.
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDenyPaths are always treated as sensitive; deny_paths in the config
+// file adds to this list.
+var DefaultDenyPaths = []string{
+	".env*",
+	"*.pem",
+	"*.key",
+	"*.p12",
+	"*.pfx",
+	"id_rsa*",
+	"id_ecdsa*",
+	"id_ed25519*",
+	"**/secrets/**",
+}
+
+// Staged returns the staged diff, falling back to the unstaged diff when
+// nothing is staged. Paths, relative to the repository root, limit the
+// diff to those files.
+func Staged(paths ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"diff", "--staged"}, Pathspecs(paths)...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if wtErr := CheckWorkTree(); wtErr != nil {
+			return "", wtErr
+		}
+		return "", fmt.Errorf("git --staged failed: %w; output=%s", err, string(out))
+	}
+	if strings.TrimSpace(string(out)) == "" {
+		cmd2 := exec.Command("git", append([]string{"diff"}, Pathspecs(paths)...)...)
+		out2, err2 := cmd2.CombinedOutput()
+		if err2 != nil {
+			if wtErr := CheckWorkTree(); wtErr != nil {
+				return "", wtErr
+			}
+			return "", fmt.Errorf("git diff failed: %w; output=%s", err2, string(out2))
+		}
+		return string(out2), nil
+	}
+	return string(out), nil
+}
+
+// ErrBare is returned for a bare repository, which has no working tree
+// whose changes could be described.
+var ErrBare = errors.New("bare git repository: there is no working tree to describe; run commit-writer in a checkout, or set GIT_WORK_TREE along with GIT_DIR")
+
+// CheckWorkTree explains why git has no working tree to diff here: no
+// repository, a bare one, or the inside of a .git directory. It returns
+// nil within a working tree, including one given by GIT_DIR and
+// GIT_WORK_TREE.
+func CheckWorkTree() error {
+	out, err := exec.Command("git", "rev-parse", "--is-bare-repository", "--is-inside-work-tree").Output()
+	fields := strings.Fields(string(out))
+	switch {
+	case err != nil && os.Getenv("GIT_DIR") != "":
+		return fmt.Errorf("GIT_DIR=%s is not a git repository", os.Getenv("GIT_DIR"))
+	case err != nil || len(fields) != 2:
+		return errors.New("not a git repository (or any of the parent directories); run commit-writer inside a checkout or set GIT_DIR")
+	case fields[1] == "true":
+		return nil
+	case fields[0] == "true":
+		return ErrBare
+	}
+	return errors.New("not inside the working tree (e.g. in the .git directory); run commit-writer from the checkout")
+}
+
+// AbsEnv makes relative GIT_DIR and GIT_WORK_TREE settings absolute, so
+// git commands run from another directory, such as the repository root,
+// still find the repository automation pointed at.
+func AbsEnv() error {
+	for _, name := range []string{"GIT_DIR", "GIT_WORK_TREE"} {
+		dir := os.Getenv(name)
+		if dir == "" || filepath.IsAbs(dir) {
+			continue
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if err := os.Setenv(name, abs); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Pathspecs turns repo-relative paths into git arguments that match them
+// literally from any working directory.
+func Pathspecs(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	specs := []string{"--"}
+	for _, p := range paths {
+		specs = append(specs, ":(top,literal)"+p)
+	}
+	return specs
+}
+
+// HasStaged reports whether anything is staged, i.e. whether Staged
+// returns the staged rather than the unstaged diff.
+func HasStaged() bool {
+	err := exec.Command("git", "diff", "--staged", "--quiet").Run()
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 1
+}
+
+// HasChanges reports whether the index or the tracked files differ from
+// HEAD, i.e. whether Staged has anything to describe.
+func HasChanges() bool {
+	out, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// WhitespaceOnly reports whether a change only touches whitespace and
+// blank lines: the change Staged returns when from is empty, else the
+// change from from to HEAD, limited to paths when given. An empty change
+// reports true.
+func WhitespaceOnly(from string, paths ...string) (bool, error) {
+	args := []string{"diff", "--ignore-all-space", "--ignore-blank-lines"}
+	switch {
+	case from != "":
+		args = append(args, from, "HEAD")
+	case HasStaged():
+		args = append(args, "--staged")
+	}
+	out, err := exec.Command("git", append(args, Pathspecs(paths)...)...).CombinedOutput()
+	if err != nil {
+		return false, fmt.Errorf("git diff --ignore-all-space failed: %w; output=%s", err, string(out))
+	}
+	return strings.TrimSpace(string(out)) == "", nil
+}
+
+// Show returns the content of a repo-relative path at rev: a commit such as
+// "HEAD", ":" for the index, or "" for the working tree.
+func Show(rev, name string) ([]byte, error) {
+	if rev == "" {
+		return os.ReadFile(filepath.Join(RepoRoot(), filepath.FromSlash(name)))
+	}
+	spec := rev + ":" + name
+	if rev == ":" {
+		spec = ":" + name
+	}
+	out, err := exec.Command("git", "show", spec).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("git show %s failed: %w; output=%s", spec, err, exitErr.Stderr)
+		}
+		return nil, fmt.Errorf("git show %s failed: %w", spec, err)
+	}
+	return out, nil
+}
+
+// CommitDiff returns the changes a commit made to its first parent.
+func CommitDiff(rev string) (string, error) {
+	out, err := exec.Command("git", "show", "--format=", "--first-parent", rev).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git show %s failed: %w; output=%s", rev, err, string(out))
+	}
+	return string(out), nil
+}
+
+// Message returns a commit's full message.
+func Message(rev string) (string, error) {
+	out, err := exec.Command("git", "log", "-1", "--format=%B", rev, "--").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git log %s failed: %w; output=%s", rev, err, string(out))
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// Files returns the paths commit rev changed against its first parent, or
+// against nothing for a root commit. A merge reports none.
+func Files(rev string) ([]string, error) {
+	out, err := exec.Command("git", "diff-tree", "--no-commit-id", "--name-only", "-r", "--root", "-z", rev).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git diff-tree %s failed: %w; output=%s", rev, err, string(out))
+	}
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Unpushed reports whether commit rev is on no remote-tracking branch. In
+// a repository without remotes every commit is.
+func Unpushed(rev string) bool {
+	out, err := exec.Command("git", "rev-list", "-n1", rev, "--not", "--remotes").Output()
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// RemoteURLs returns the URLs of the repository's remotes, or none outside
+// a repository or without remotes.
+func RemoteURLs() []string {
+	out, err := exec.Command("git", "config", "--get-regexp", `^remote\..*\.url$`).Output()
+	if err != nil {
+		return nil
+	}
+	var urls []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if _, url, ok := strings.Cut(line, " "); ok && url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// MergeBase returns the commit Branch(base) diffs HEAD against.
+func MergeBase(base string) (string, error) {
+	out, err := exec.Command("git", "merge-base", base, "HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git merge-base %s HEAD failed: %w; output=%s", base, err, string(out))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DefaultBase returns the remote's default branch (e.g. "origin/main")
+// from origin/HEAD, or "main" when that is not set.
+func DefaultBase() string {
+	out, err := exec.Command("git", "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD").Output()
+	if err != nil || strings.TrimSpace(string(out)) == "" {
+		return "main"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Branch returns the diff of HEAD against its merge base with base, i.e.
+// what a pull request from the current branch into base would contain.
+func Branch(base string) (string, error) {
+	out, err := exec.Command("git", "diff", base+"...HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git diff %s...HEAD failed: %w; output=%s", base, err, string(out))
+	}
+	return string(out), nil
+}
+
+// Commits returns the subjects of the commits on HEAD that are not on
+// base, oldest first.
+func Commits(base string) ([]string, error) {
+	out, err := exec.Command("git", "log", "--reverse", "--format=%s", base+"..HEAD").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git log %s..HEAD failed: %w; output=%s", base, err, string(out))
+	}
+	var subjects []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+// Commit is a commit's hash, full message and commit time.
+type Commit struct {
+	Hash    string
+	Message string
+	Time    time.Time
+}
+
+// Subject returns the first line of the message.
+func (c Commit) Subject() string {
+	return strings.SplitN(c.Message, "\n", 2)[0]
+}
+
+// Log returns the non-merge commits in revRange (e.g. "origin/main..HEAD"),
+// oldest first.
+func Log(revRange string) ([]Commit, error) {
+	out, err := exec.Command("git", "log", "--reverse", "--no-merges", "--format="+logFormat, revRange).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git log %s failed: %w; output=%s", revRange, err, string(out))
+	}
+	return parseLog(string(out)), nil
+}
+
+// Authored returns the non-merge commits on any local branch whose author
+// email is email, made since since (anything git understands, such as
+// "yesterday" or "2024-05-01"), oldest first.
+func Authored(email, since string) ([]Commit, error) {
+	out, err := exec.Command("git", "log", "--branches", "--reverse", "--no-merges", "--fixed-strings", "--author=<"+email+">", "--since="+since, "--format="+logFormat).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git log --since=%s failed: %w; output=%s", since, err, string(out))
+	}
+	return parseLog(string(out)), nil
+}
+
+// UserEmail returns git's user.email, or "" when it is not set.
+func UserEmail() string {
+	out, err := exec.Command("git", "config", "user.email").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Recent returns up to n non-merge commits reachable from rev, newest
+// first, limited to those touching paths when given.
+func Recent(rev string, n int, paths ...string) ([]Commit, error) {
+	args := append([]string{"log", "--no-merges", fmt.Sprintf("-n%d", n), "--format=" + logFormat, rev}, Pathspecs(paths)...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git log %s failed: %w; output=%s", rev, err, string(out))
+	}
+	return parseLog(string(out)), nil
+}
+
+// logFormat is the git log format parseLog reads: hash, commit time and
+// message, separated by NUL and terminated by RS.
+const logFormat = "%H%x00%ct%x00%B%x1e"
+
+// parseLog splits git log output in logFormat.
+func parseLog(out string) []Commit {
+	var commits []Commit
+	for _, rec := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(rec, "\n"), "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		c := Commit{Hash: fields[0], Message: strings.TrimRight(fields[2], "\n")}
+		if secs, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			c.Time = time.Unix(secs, 0)
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// LatestTag returns the most recent tag reachable from HEAD, or "" when
+// there is none.
+func LatestTag() string {
+	out, err := exec.Command("git", "describe", "--tags", "--abbrev=0").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// TagBefore returns the most recent tag reachable from rev's first parent,
+// i.e. the release before rev when rev is itself tagged, or "" when there
+// is none.
+func TagBefore(rev string) string {
+	out, err := exec.Command("git", "describe", "--tags", "--abbrev=0", rev+"^").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Head returns the hash of HEAD, or "" before the first commit or outside
+// a repository.
+func Head() string {
+	out, err := exec.Command("git", "rev-parse", "--verify", "-q", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// CurrentBranch returns the checked-out branch name, or "" on a detached
+// HEAD or outside a repository.
+func CurrentBranch() string {
+	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	branch := strings.TrimSpace(string(out))
+	if branch == "HEAD" {
+		return ""
+	}
+	return branch
+}
+
+// CheckAttr returns the given gitattributes of each repo-relative path,
+// e.g. {"web/dist/app.js": {"linguist-generated": "set"}}. Values are
+// "set", "unset", a string, or "unspecified".
+func CheckAttr(paths []string, attrs ...string) (map[string]map[string]string, error) {
+	result := map[string]map[string]string{}
+	if len(paths) == 0 || len(attrs) == 0 {
+		return result, nil
+	}
+	args := append(append([]string{"check-attr", "-z"}, attrs...), "--")
+	cmd := exec.Command("git", append(args, paths...)...)
+	cmd.Dir = RepoRoot()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git check-attr failed: %w", err)
+	}
+	// -z output is path NUL attribute NUL value NUL, repeated.
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if result[fields[i]] == nil {
+			result[fields[i]] = map[string]string{}
+		}
+		result[fields[i]][fields[i+1]] = fields[i+2]
+	}
+	return result, nil
+}
+
+// MergeMsg returns the content of MERGE_MSG while a merge is being
+// concluded, i.e. when MERGE_HEAD exists, and false otherwise.
+func MergeMsg() (string, bool) {
+	if exec.Command("git", "rev-parse", "-q", "--verify", "MERGE_HEAD").Run() != nil {
+		return "", false
+	}
+	path := GitPath("MERGE_MSG")
+	if path == "" {
+		return "", false
+	}
+	msg, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(msg), true
+}
+
+// GitPath returns the path of a file in the git directory, e.g.
+// ".git/COMMIT_EDITMSG" for "COMMIT_EDITMSG", or "" outside a repository.
+func GitPath(name string) string {
+	out, err := exec.Command("git", "rev-parse", "--git-path", name).Output()
+	if err != nil {
+		return ""
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(out)))
+}
+
+// RepoRoot returns the top-level directory of the current git repository.
+func RepoRoot() string {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	// Git for Windows prints C:/Users/...; use the OS separator.
+	return filepath.FromSlash(strings.TrimSpace(string(out)))
+}
+
+// FileStat holds the per-file line counts of a unified diff.
+type FileStat struct {
+	Path string
+	// OldPath is the path before a rename or copy; empty when unchanged.
+	OldPath string
+	Status  byte // 'A' added, 'D' deleted, 'M' modified
+	Added   int
+	Removed int
+}
+
+// ParseStat walks a unified git diff and counts added/removed lines per file.
+// Paths git C-quotes, such as names with non-ASCII characters, are unquoted.
+func ParseStat(diff string) []FileStat {
+	var stats []FileStat
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			from, to := headerPaths(line[len("diff --git "):])
+			st := FileStat{Path: to, Status: 'M'}
+			if from != to {
+				st.OldPath = from
+			}
+			stats = append(stats, st)
+			inHunk = false
+		case len(stats) == 0:
+			continue
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk && (strings.HasPrefix(line, "rename from ") || strings.HasPrefix(line, "copy from ")):
+			_, name, _ := strings.Cut(line, " from ")
+			if name = unquotePath(name); name != stats[len(stats)-1].Path {
+				stats[len(stats)-1].OldPath = name
+			}
+		case !inHunk && (strings.HasPrefix(line, "rename to ") || strings.HasPrefix(line, "copy to ")):
+			_, name, _ := strings.Cut(line, " to ")
+			stats[len(stats)-1].Path = unquotePath(name)
+		case !inHunk && strings.HasPrefix(line, "new file mode"):
+			stats[len(stats)-1].Status = 'A'
+		case !inHunk && strings.HasPrefix(line, "deleted file mode"):
+			stats[len(stats)-1].Status = 'D'
+		case inHunk && strings.HasPrefix(line, "+"):
+			stats[len(stats)-1].Added++
+		case inHunk && strings.HasPrefix(line, "-"):
+			stats[len(stats)-1].Removed++
+		}
+	}
+	return stats
+}
+
+// headerPaths returns the old and new paths of a "diff --git" header
+// without their a/ and b/ prefixes.
+func headerPaths(names string) (from, to string) {
+	if strings.HasPrefix(names, `"`) {
+		// A quoted old name ends at the first unescaped quote.
+		for i := 1; i < len(names); i++ {
+			if names[i] == '\\' {
+				i++
+				continue
+			}
+			if names[i] == '"' {
+				from, to = names[:i+1], strings.TrimPrefix(names[i+1:], " ")
+				break
+			}
+		}
+	} else if i := strings.Index(names, " b/"); i >= 0 {
+		from, to = names[:i], names[i+1:]
+	} else if i := strings.Index(names, ` "b/`); i >= 0 {
+		from, to = names[:i], names[i+1:]
+	} else {
+		from, to = names, names
+	}
+	return strings.TrimPrefix(unquotePath(from), "a/"), strings.TrimPrefix(unquotePath(to), "b/")
+}
+
+// unquotePath undoes git's C-style quoting of a path, which it applies to
+// names with special or non-ASCII characters unless core.quotePath is off.
+func unquotePath(name string) string {
+	if len(name) < 2 || name[0] != '"' || name[len(name)-1] != '"' {
+		return name
+	}
+	if u, err := strconv.Unquote(name); err == nil {
+		return u
+	}
+	return name
+}
+
+// SplitFiles splits a unified git diff into one chunk per file, each
+// starting at its "diff --git" line. Text before the first file is dropped.
+func SplitFiles(diff string) []string {
+	var files []string
+	lines := strings.SplitAfter(diff, "\n")
+	start := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "diff --git ") {
+			if start >= 0 {
+				files = append(files, strings.Join(lines[start:i], ""))
+			}
+			start = i
+		}
+	}
+	if start >= 0 {
+		files = append(files, strings.Join(lines[start:], ""))
+	}
+	return files
+}
+
+// ExpandNewFiles replaces the hunks of each added text file of at most max
+// bytes with its content between marker lines, which models read more
+// reliably than a hunk of "+" lines without context. Larger, binary and
+// other files keep their diff.
+func ExpandNewFiles(diff string, max int) string {
+	files := SplitFiles(diff)
+	if len(files) == 0 {
+		return diff
+	}
+	var b strings.Builder
+	b.WriteString(diff[:strings.Index(diff, files[0])])
+	for _, f := range files {
+		stats := ParseStat(f)
+		header, hunks, ok := strings.Cut(f, "\n@@")
+		if len(stats) != 1 || stats[0].Status != 'A' || !ok {
+			b.WriteString(f)
+			continue
+		}
+		var content strings.Builder
+		for _, line := range strings.Split(hunks, "\n")[1:] {
+			if strings.HasPrefix(line, "+") {
+				content.WriteString(line[1:] + "\n")
+			}
+		}
+		if content.Len() > max {
+			b.WriteString(f)
+			continue
+		}
+		name := stats[0].Path
+		var head []string
+		for _, line := range strings.Split(header, "\n") {
+			if !strings.HasPrefix(line, "index ") && !strings.HasPrefix(line, "--- ") && !strings.HasPrefix(line, "+++ ") {
+				head = append(head, line)
+			}
+		}
+		fmt.Fprintf(&b, "%s\n===== new file %s (%d lines, full content) =====\n%s===== end of %s =====\n", strings.Join(head, "\n"), name, stats[0].Added, content.String(), name)
+	}
+	return b.String()
+}
+
+// Normalize strips what does not change a diff's meaning, so equivalent
+// diffs hash the same and prompts spend no tokens on it: "index" lines, the
+// timestamps diff -u writes after the "---" and "+++" names, a file's mode
+// change when its content changes too (usually core.fileMode noise from a
+// filesystem without an executable bit), and the lines of hunks that only
+// change whitespace, whose header is kept and marked "(whitespace only)".
+// New and deleted files, and files whose indentation matters such as Python,
+// YAML and Makefiles, keep their whitespace-only hunks.
+func Normalize(diff string) string {
+	lines := strings.SplitAfter(diff, "\n")
+	out := make([]string, 0, len(lines))
+	var mode []int   // indices in out of the file's mode change lines
+	hunks := false   // whether the file has hunks
+	literal := false // whether the file's whitespace is kept
+	endFile := func() {
+		if hunks && len(mode) == 2 {
+			for _, i := range mode {
+				out[i] = ""
+			}
+		}
+		mode, hunks, literal = nil, false, false
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			endFile()
+		case strings.HasPrefix(line, "index "):
+			continue
+		case strings.HasPrefix(line, "old mode ") || strings.HasPrefix(line, "new mode "):
+			mode = append(mode, len(out))
+		case strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ "):
+			name := strings.TrimRight(line, "\r\n")
+			if t := strings.IndexByte(name, '\t'); t >= 0 {
+				line = name[:t] + line[len(name):]
+				name = name[:t]
+			}
+			name = name[len("--- "):]
+			kept := name == "/dev/null" || significantWhitespace(name)
+			// "---" starts a file in a diff without "diff --git" lines.
+			if strings.HasPrefix(line, "--- ") {
+				literal = kept
+			} else {
+				literal = literal || kept
+			}
+		case strings.HasPrefix(line, "@@ "):
+			n := hunkLen(lines[i:])
+			body := lines[i+1 : i+n]
+			i += n - 1
+			hunks = true
+			if literal || !whitespaceOnly(body) {
+				out = append(append(out, line), body...)
+				continue
+			}
+			if end := strings.Index(line[2:], "@@"); end >= 0 {
+				line = line[:end+4] + " (whitespace only)\n"
+			}
+		}
+		out = append(out, line)
+	}
+	endFile()
+	return strings.Join(out, "")
+}
+
+// Hunk is one hunk of a file's diff, or the whole diff of a file without
+// hunks, such as a binary file or a mode change.
+type Hunk struct {
+	Path string
+	// Header is the file's diff up to its first hunk, shared by all of
+	// its hunks.
+	Header string
+	// Text is the hunk from its "@@" line on; empty for a file without
+	// hunks.
+	Text string
+}
+
+// Hunks splits a unified git diff into its hunks, in order.
+func Hunks(diff string) []Hunk {
+	var hunks []Hunk
+	for _, f := range SplitFiles(diff) {
+		name := ParseStat(f)[0].Path
+		lines := strings.SplitAfter(f, "\n")
+		i := 0
+		for i < len(lines) && !strings.HasPrefix(lines[i], "@@ ") {
+			i++
+		}
+		header := strings.Join(lines[:i], "")
+		if i == len(lines) {
+			hunks = append(hunks, Hunk{Path: name, Header: header})
+			continue
+		}
+		for i < len(lines) {
+			// Anything after a hunk that does not start another belongs
+			// to it.
+			if !strings.HasPrefix(lines[i], "@@ ") {
+				hunks[len(hunks)-1].Text += lines[i]
+				i++
+				continue
+			}
+			n := hunkLen(lines[i:])
+			hunks = append(hunks, Hunk{Path: name, Header: header, Text: strings.Join(lines[i:i+n], "")})
+			i += n
+		}
+	}
+	return hunks
+}
+
+// JoinHunks reassembles hunks, as returned by Hunks or a subset of them in
+// the same order, into a diff.
+func JoinHunks(hunks []Hunk) string {
+	var b strings.Builder
+	for i, h := range hunks {
+		if i == 0 || h.Header != hunks[i-1].Header {
+			b.WriteString(h.Header)
+		}
+		b.WriteString(h.Text)
+	}
+	return b.String()
+}
+
+// hunkLen returns how many of lines, which start at a hunk header, belong
+// to the hunk, going by the line counts in its header.
+func hunkLen(lines []string) int {
+	oldN, newN, ok := hunkCounts(lines[0])
+	if !ok {
+		return 1
+	}
+	n := 1
+	for ; n < len(lines); n++ {
+		line := lines[n]
+		if strings.HasPrefix(line, "\\") {
+			continue
+		}
+		if oldN <= 0 && newN <= 0 {
+			break
+		}
+		switch {
+		case line == "":
+			return n
+		case line[0] == ' ' || line[0] == '\r' || line[0] == '\n':
+			oldN--
+			newN--
+		case line[0] == '-':
+			oldN--
+		case line[0] == '+':
+			newN--
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// hunkCounts reads the old and new line counts from "@@ -a[,b] +c[,d] @@".
+func hunkCounts(header string) (oldN, newN int, ok bool) {
+	f := strings.Fields(header)
+	if len(f) < 4 || f[0] != "@@" || f[3] != "@@" || !strings.HasPrefix(f[1], "-") || !strings.HasPrefix(f[2], "+") {
+		return 0, 0, false
+	}
+	count := func(r string) (int, bool) {
+		_, n, found := strings.Cut(r[1:], ",")
+		if !found {
+			return 1, true
+		}
+		c, err := strconv.Atoi(n)
+		return c, err == nil
+	}
+	oldN, ok1 := count(f[1])
+	newN, ok2 := count(f[2])
+	return oldN, newN, ok1 && ok2
+}
+
+// whitespaceOnly reports whether a hunk's body changes something and
+// nothing but whitespace and blank lines.
+func whitespaceOnly(body []string) bool {
+	var removed, added strings.Builder
+	changed := false
+	for _, line := range body {
+		switch {
+		case strings.HasPrefix(line, "-"):
+			removed.WriteString(strings.Join(strings.Fields(line[1:]), ""))
+			changed = true
+		case strings.HasPrefix(line, "+"):
+			added.WriteString(strings.Join(strings.Fields(line[1:]), ""))
+			changed = true
+		}
+	}
+	return changed && removed.String() == added.String()
+}
+
+// significantWhitespace reports whether indentation changes the meaning of
+// the file at a diff's "a/" or "b/" path.
+func significantWhitespace(name string) bool {
+	base := path.Base(name)
+	switch path.Ext(base) {
+	case ".py", ".yaml", ".yml", ".mk":
+		return true
+	}
+	return base == "Makefile" || base == "GNUmakefile" || base == "makefile"
+}
+
+// Truncate shortens diff to at most max bytes (give or take a note). Files
+// are kept whole while they fit; the first that doesn't is cut at a line
+// boundary and the rest are reduced to their "diff --git" lines, so every
+// changed file is still named. It returns diff unchanged when it fits.
+func Truncate(diff string, max int) string {
+	if len(diff) <= max {
+		return diff
+	}
+	files := SplitFiles(diff)
+	if len(files) == 0 {
+		files = []string{diff}
+	}
+	var b strings.Builder
+	omitted := 0
+	for _, f := range files {
+		switch {
+		case omitted == 0 && b.Len()+len(f) <= max:
+			b.WriteString(f)
+			continue
+		case omitted == 0:
+			for _, line := range strings.SplitAfter(f, "\n") {
+				if b.Len()+len(line) > max {
+					break
+				}
+				b.WriteString(line)
+			}
+			if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+				b.WriteString("\n")
+			}
+		default:
+			b.WriteString(strings.SplitAfterN(f, "\n", 2)[0])
+		}
+		omitted++
+	}
+	fmt.Fprintf(&b, "[diff truncated to fit the model's context; %d file(s) shortened or reduced to their names]\n", omitted)
+	return b.String()
+}
+
+// MatchPath reports whether a slash-separated repo path matches a glob.
+// Patterns without a slash match the base name at any depth; patterns with a
+// slash are anchored at the repo root, and "**" matches any number of
+// directories.
+func MatchPath(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, parts []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pat[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], parts[0]); !ok {
+			return false
+		}
+		pat, parts = pat[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// OmitSensitive replaces the content of every file matching one of the
+// deny patterns with a one-line note carrying only its name and line counts.
+// It returns the filtered diff and the omitted paths.
+func OmitSensitive(diff string, patterns []string) (string, []string) {
+	var b strings.Builder
+	var omitted []string
+	for _, chunk := range SplitFiles(diff) {
+		stats := ParseStat(chunk)
+		if len(stats) == 0 {
+			b.WriteString(chunk)
+			continue
+		}
+		// A file renamed or copied out of a denied path is still denied.
+		st := stats[0]
+		denied := false
+		for _, p := range patterns {
+			if MatchPath(p, st.Path) || st.OldPath != "" && MatchPath(p, st.OldPath) {
+				denied = true
+				break
+			}
+		}
+		if !denied {
+			b.WriteString(chunk)
+			continue
+		}
+		omitted = append(omitted, st.Path)
+		for _, line := range strings.SplitAfter(chunk, "\n") {
+			if strings.HasPrefix(line, "diff --git ") || strings.HasPrefix(line, "new file mode") ||
+				strings.HasPrefix(line, "deleted file mode") || strings.HasPrefix(line, "rename ") || strings.HasPrefix(line, "copy ") {
+				b.WriteString(line)
+			}
+		}
+		fmt.Fprintf(&b, "[content omitted: sensitive path, +%d/-%d lines]\n", st.Added, st.Removed)
+	}
+	return b.String(), omitted
+}
@@ -0,0 +1,353 @@
+// Package gosem describes changes to a Go file as a list of declaration
+// changes (functions added or removed, signature changes, new types)
+// instead of raw diff hunks, which small models summarize far more
+// accurately.
+package gosem
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxDefinition bounds how much of a type, var or const definition is
+// quoted in a change.
+const maxDefinition = 160
+
+// Change is one declaration-level difference.
+type Change struct {
+	// Kind is "added", "removed", "signature" (a function's parameters or
+	// results changed), "modified" (body or definition changed) or
+	// "imports".
+	Kind string
+	// Decl is "func", "method", "type", "var" or "const".
+	Decl string
+	Name string
+	// Old and New are the signatures or definitions involved, starting
+	// with their keyword (e.g. "func (s *Server) Run() error"). Modified
+	// types, vars and consts only carry them when short.
+	Old, New string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case "added":
+		return "added " + clip(c.New)
+	case "removed":
+		return "removed " + clip(c.Old)
+	case "signature":
+		return fmt.Sprintf("changed signature of %s %s: %s -> %s", c.Decl, c.Name, c.Old, c.New)
+	case "imports":
+		return "imports: " + c.New
+	}
+	if c.Decl == "func" || c.Decl == "method" {
+		return fmt.Sprintf("modified body of %s %s", c.Decl, c.Name)
+	}
+	if c.Old != "" && c.New != "" {
+		return fmt.Sprintf("modified %s %s: %s -> %s", c.Decl, c.Name, c.Old, c.New)
+	}
+	return fmt.Sprintf("modified %s %s", c.Decl, c.Name)
+}
+
+// Exported reports whether the declaration is part of the package API: an
+// exported name, or an exported method of an exported type.
+func (c Change) Exported() bool {
+	if c.Kind == "imports" {
+		return false
+	}
+	for _, part := range strings.Split(c.Name, ".") {
+		if !token.IsExported(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// Surface reports whether the change alters the package API: an exported
+// declaration added, removed, given a new signature or, for a type,
+// redefined. Changed function bodies and values are not API changes.
+func (c Change) Surface() bool {
+	if !c.Exported() {
+		return false
+	}
+	switch c.Kind {
+	case "added", "removed", "signature":
+		return true
+	}
+	return c.Kind == "modified" && c.Decl == "type"
+}
+
+// APIChange is a Surface change in a package.
+type APIChange struct {
+	// Package is the package's import path, e.g. "example.com/shop/cart",
+	// or its name when no go.mod declares the module.
+	Package string
+	Change
+}
+
+func (c APIChange) String() string {
+	return c.Package + ": " + c.Change.String()
+}
+
+// APIReport lists API changes under a heading, one per line, or returns ""
+// when there are none.
+func APIReport(heading string, changes []APIChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(heading)
+	for _, c := range changes {
+		b.WriteString("\n- " + c.String())
+	}
+	return b.String()
+}
+
+// ModulePath returns the module path declared in a go.mod file, or "".
+func ModulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			if p, err := strconv.Unquote(fields[1]); err == nil {
+				return p
+			}
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// PackageName returns the name in a file's package clause, or "" when it
+// cannot be parsed.
+func PackageName(src []byte) string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return f.Name.Name
+}
+
+// clip shortens a long definition, such as a large struct type.
+func clip(s string) string {
+	if len(s) <= maxDefinition {
+		return s
+	}
+	return s[:maxDefinition] + " ..."
+}
+
+type decl struct {
+	kind string // func, method, type, var, const
+	sig  string // signature or short definition
+	body string // full printed declaration, for change detection
+	pos  token.Pos
+}
+
+// Compare parses both versions of a file and lists the declaration changes
+// in source order of the new file (removals last). Either side may be
+// empty for an added or deleted file.
+func Compare(before, after []byte) ([]Change, error) {
+	return ComparePackage([][]byte{before}, [][]byte{after})
+}
+
+// ComparePackage is Compare for the files of one package taken together,
+// so a declaration moved from one file to another is not reported as
+// removed and added.
+func ComparePackage(before, after [][]byte) ([]Change, error) {
+	oldDecls, oldImports, err := packageDecls(before)
+	if err != nil {
+		return nil, fmt.Errorf("parsing old version: %w", err)
+	}
+	newDecls, newImports, err := packageDecls(after)
+	if err != nil {
+		return nil, fmt.Errorf("parsing new version: %w", err)
+	}
+
+	var changes []Change
+	if c, ok := importChange(oldImports, newImports); ok {
+		changes = append(changes, c)
+	}
+	for _, name := range order(newDecls) {
+		n := newDecls[name]
+		o, existed := oldDecls[name]
+		switch {
+		case !existed:
+			changes = append(changes, Change{Kind: "added", Decl: n.kind, Name: name, New: n.sig})
+		case o.body == n.body:
+		case (n.kind == "func" || n.kind == "method") && o.sig != n.sig:
+			changes = append(changes, Change{Kind: "signature", Decl: n.kind, Name: name, Old: o.sig, New: n.sig})
+		case n.kind == "func" || n.kind == "method":
+			changes = append(changes, Change{Kind: "modified", Decl: n.kind, Name: name})
+		default:
+			c := Change{Kind: "modified", Decl: n.kind, Name: name}
+			if len(o.sig) <= maxDefinition && len(n.sig) <= maxDefinition {
+				c.Old, c.New = o.sig, n.sig
+			}
+			changes = append(changes, c)
+		}
+	}
+	for _, name := range order(oldDecls) {
+		if _, ok := newDecls[name]; !ok {
+			o := oldDecls[name]
+			changes = append(changes, Change{Kind: "removed", Decl: o.kind, Name: name, Old: o.sig})
+		}
+	}
+	return changes, nil
+}
+
+// Describe renders the changes of one file for a prompt.
+func Describe(path string, changes []Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Go declaration changes in %s:\n", path)
+	if len(changes) == 0 {
+		b.WriteString("- no declaration changes (comments or formatting only)\n")
+	}
+	for _, c := range changes {
+		b.WriteString("- " + c.String() + "\n")
+	}
+	return b.String()
+}
+
+// order returns the declaration names in source order.
+func order(m map[string]decl) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if pi, pj := m[names[i]].pos, m[names[j]].pos; pi != pj {
+			return pi < pj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// packageDecls collects the declarations and imports of several files. A
+// shared file set keeps the positions in file order.
+func packageDecls(files [][]byte) (map[string]decl, []string, error) {
+	m := map[string]decl{}
+	fset := token.NewFileSet()
+	var imports []string
+	seen := map[string]bool{}
+	for _, src := range files {
+		more, err := decls(fset, src, m)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, p := range more {
+			if !seen[p] {
+				seen[p] = true
+				imports = append(imports, p)
+			}
+		}
+	}
+	return m, imports, nil
+}
+
+// decls adds the top-level declarations of a file to m and returns its
+// imports. Methods are keyed by receiver type, e.g. "Server.Run", so they
+// don't collide with functions.
+func decls(fset *token.FileSet, src []byte, m map[string]decl) ([]string, error) {
+	if len(bytes.TrimSpace(src)) == 0 {
+		return nil, nil
+	}
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var imports []string
+	for _, spec := range f.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		imports = append(imports, path)
+	}
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			name, kind := d.Name.Name, "func"
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name, kind = receiver(d.Recv.List[0].Type)+"."+name, "method"
+			}
+			body := d.Body
+			d.Body = nil
+			sig := render(fset, d)
+			d.Body = body
+			m[name] = decl{kind: kind, sig: sig, body: render(fset, d), pos: d.Pos()}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					def := "type " + render(fset, s)
+					m[s.Name.Name] = decl{kind: "type", sig: def, body: def, pos: s.Pos()}
+				case *ast.ValueSpec:
+					def := d.Tok.String() + " " + render(fset, s)
+					for _, n := range s.Names {
+						if n.Name == "_" {
+							continue
+						}
+						m[n.Name] = decl{kind: d.Tok.String(), sig: def, body: def, pos: n.Pos()}
+					}
+				}
+			}
+		}
+	}
+	return imports, nil
+}
+
+// receiver names a method's receiver type without pointer or type
+// parameters.
+func receiver(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiver(e.X)
+	case *ast.IndexExpr:
+		return receiver(e.X)
+	case *ast.IndexListExpr:
+		return receiver(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return "?"
+}
+
+// render prints a node on one line, without comments.
+func render(fset *token.FileSet, node interface{}) string {
+	var b bytes.Buffer
+	if err := printer.Fprint(&b, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// importChange reports added and removed imports.
+func importChange(before, after []string) (Change, bool) {
+	had := map[string]bool{}
+	for _, p := range before {
+		had[p] = true
+	}
+	has := map[string]bool{}
+	var parts []string
+	for _, p := range after {
+		has[p] = true
+		if !had[p] {
+			parts = append(parts, "+"+strconv.Quote(p))
+		}
+	}
+	for _, p := range before {
+		if !has[p] {
+			parts = append(parts, "-"+strconv.Quote(p))
+		}
+	}
+	if len(parts) == 0 {
+		return Change{}, false
+	}
+	return Change{Kind: "imports", New: strings.Join(parts, " ")}, true
+}
@@ -0,0 +1,180 @@
+package gosem
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const before = `package shop
+
+import "fmt"
+
+// Cart holds items.
+type Cart struct {
+	Items []string
+}
+
+const limit = 10
+
+func (c *Cart) Add(item string) {
+	c.Items = append(c.Items, item)
+}
+
+func Total(c Cart) int {
+	return len(c.Items)
+}
+
+func Legacy() {}
+`
+
+const after = `package shop
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Cart holds the items a customer picked.
+type Cart struct {
+	Items []string
+	Owner string
+}
+
+const limit = 10
+
+func (c *Cart) Add(item string) error {
+	if len(c.Items) >= limit {
+		return errors.New("cart full")
+	}
+	c.Items = append(c.Items, item)
+	return nil
+}
+
+// Total counts the items.
+func Total(c Cart) int {
+	return len(c.Items) // all of them
+}
+
+func Checkout(c *Cart) error {
+	return fmt.Errorf("not implemented")
+}
+
+type Receipt struct{ ID string }
+`
+
+func TestCompare(t *testing.T) {
+	changes, err := Compare([]byte(before), []byte(after))
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+	want := []string{
+		`imports: +"errors"`,
+		"modified type Cart: type Cart struct { Items []string } -> type Cart struct { Items []string Owner string }",
+		"changed signature of method Cart.Add: func (c *Cart) Add(item string) -> func (c *Cart) Add(item string) error",
+		"added func Checkout(c *Cart) error",
+		"added type Receipt struct{ ID string }",
+		"removed func Legacy()",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCompareBodyOnly(t *testing.T) {
+	changes, err := Compare([]byte("package p\n\nfunc F() int { return 1 }\n"), []byte("package p\n\nfunc F() int { return 2 }\n"))
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if len(changes) != 1 || changes[0].String() != "modified body of func F" {
+		t.Errorf("changes = %+v", changes)
+	}
+}
+
+func TestComparePackageMove(t *testing.T) {
+	before := [][]byte{
+		[]byte("package p\n\nfunc Move() {}\n\nfunc Keep() {}\n"),
+		[]byte("package p\n"),
+	}
+	after := [][]byte{
+		[]byte("package p\n\nfunc Keep() {}\n"),
+		[]byte("package p\n\nfunc Move() {}\n\nfunc New() {}\n"),
+	}
+	changes, err := ComparePackage(before, after)
+	if err != nil {
+		t.Fatalf("ComparePackage: %v", err)
+	}
+	if len(changes) != 1 || changes[0].String() != "added func New()" {
+		t.Errorf("changes = %+v, want only New added", changes)
+	}
+}
+
+func TestCompareNewFileAndErrors(t *testing.T) {
+	changes, err := Compare(nil, []byte("package p\n\nvar a, b = 1, 2\n"))
+	if err != nil || len(changes) != 2 || changes[0].Name != "a" || changes[1].Kind != "added" {
+		t.Errorf("Compare(new file) = %+v, %v", changes, err)
+	}
+	if _, err := Compare([]byte("package p\n"), []byte("package p\nfunc {")); err == nil {
+		t.Error("Compare accepted an unparsable file")
+	}
+}
+
+func TestAPIReport(t *testing.T) {
+	if APIReport("API changes:", nil) != "" {
+		t.Error("APIReport(nil) not empty")
+	}
+	changes := []APIChange{
+		{"example.com/shop/cart", Change{Kind: "added", Decl: "func", Name: "Checkout", New: "func Checkout(c *Cart) error"}},
+		{"example.com/shop", Change{Kind: "removed", Decl: "func", Name: "Legacy", Old: "func Legacy()"}},
+	}
+	want := "API changes:\n- example.com/shop/cart: added func Checkout(c *Cart) error\n- example.com/shop: removed func Legacy()"
+	if got := APIReport("API changes:", changes); got != want {
+		t.Errorf("APIReport = %q, want %q", got, want)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	got := Describe("a.go", nil)
+	want := "Go declaration changes in a.go:\n- no declaration changes (comments or formatting only)\n"
+	if got != want {
+		t.Errorf("Describe = %q, want %q", got, want)
+	}
+	long := Change{Kind: "added", Decl: "type", New: "type T struct { " + strings.Repeat("F int; ", 40) + "}"}
+	if s := long.String(); len(s) > maxDefinition+20 || !strings.HasSuffix(s, " ...") {
+		t.Errorf("long definition not clipped: %q", s)
+	}
+}
+
+func TestExportedAndPackageName(t *testing.T) {
+	tests := map[string]bool{"Checkout": true, "Cart.Add": true, "cart.Add": false, "Cart.add": false, "legacy": false}
+	for name, want := range tests {
+		if got := (Change{Kind: "removed", Name: name}).Exported(); got != want {
+			t.Errorf("Exported(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if (Change{Kind: "imports"}).Exported() {
+		t.Error("import change reported as exported")
+	}
+	if (Change{Kind: "modified", Decl: "func", Name: "Run"}).Surface() || !(Change{Kind: "modified", Decl: "type", Name: "Cart"}).Surface() {
+		t.Error("Surface: body changes are not API changes, type changes are")
+	}
+	if got := PackageName([]byte(before)); got != "shop" {
+		t.Errorf("PackageName = %q", got)
+	}
+	if got := PackageName([]byte("func {")); got != "" {
+		t.Errorf("PackageName(invalid) = %q", got)
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	if got := ModulePath([]byte("// comment\nmodule example.com/shop\n\ngo 1.20\n")); got != "example.com/shop" {
+		t.Errorf("ModulePath = %q", got)
+	}
+	if got := ModulePath([]byte("go 1.20\n")); got != "" {
+		t.Errorf("ModulePath(no module) = %q", got)
+	}
+}
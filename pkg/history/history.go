@@ -0,0 +1,105 @@
+// Package history keeps every generated commit message in a local log, so
+// a suggestion can be recovered after an aborted commit and compared with
+// what was eventually committed.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is one generated message.
+type Entry struct {
+	Time string `json:"time"`
+	// Repo is the repository's root directory.
+	Repo   string `json:"repo"`
+	Branch string `json:"branch,omitempty"`
+	// DiffHash is the SHA-256 of the diff the message describes.
+	DiffHash        string `json:"diff_sha256,omitempty"`
+	SummarizerModel string `json:"summarizer_model,omitempty"`
+	StyleModel      string `json:"style_model,omitempty"`
+	Tone            string `json:"tone,omitempty"`
+	// Offline is set for the diffstat fallback message.
+	Offline bool   `json:"offline,omitempty"`
+	Message string `json:"message"`
+	// Summary is the factual summary Message was styled from, which
+	// "commit-writer refine" reuses.
+	Summary string `json:"summary,omitempty"`
+	// Accepted is set when the message was committed by commit-writer
+	// itself; Commit is then the new commit's hash.
+	Accepted bool   `json:"accepted"`
+	Commit   string `json:"commit,omitempty"`
+}
+
+// DefaultPath returns the per-user history location, e.g.
+// ~/.config/commit-writer/history.jsonl on Linux.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "commit-writer", "history.jsonl")
+}
+
+// Log appends entries to a local JSONL file.
+type Log struct {
+	Path string
+}
+
+// Record appends e, stamping the current time when e has none.
+func (l *Log) Record(e Entry) error {
+	if e.Time == "" {
+		e.Time = time.Now().UTC().Format(time.RFC3339)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.Path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Entries returns the recorded entries for repo, or for every repository
+// when repo is empty, oldest first. A missing log has no entries.
+func (l *Log) Entries(repo string) ([]Entry, error) {
+	f, err := os.Open(l.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", l.Path, n, err)
+		}
+		if repo == "" || e.Repo == repo {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
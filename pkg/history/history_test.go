@@ -0,0 +1,40 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecordEntries(t *testing.T) {
+	l := &Log{Path: filepath.Join(t.TempDir(), "commit-writer", "history.jsonl")}
+	if entries, err := l.Entries(""); err != nil || entries != nil {
+		t.Fatalf("missing log: %v, %v", entries, err)
+	}
+	a := Entry{Repo: "/src/a", Branch: "main", StyleModel: "mistral:7b", Message: "Add coupons"}
+	b := Entry{Time: "2024-05-01T12:00:00Z", Repo: "/src/b", Message: "Fix totals", Accepted: true, Commit: "3f2a9c1d"}
+	for _, e := range []Entry{a, b} {
+		if err := l.Record(e); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	entries, err := l.Entries("/src/b")
+	if err != nil || !reflect.DeepEqual(entries, []Entry{b}) {
+		t.Errorf("Entries(/src/b) = %+v, %v", entries, err)
+	}
+	entries, err = l.Entries("")
+	if err != nil || len(entries) != 2 || entries[0].Time == "" || entries[0].Message != "Add coupons" {
+		t.Errorf("Entries() = %+v, %v", entries, err)
+	}
+	if info, err := os.Stat(l.Path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("log mode = %v, %v", info.Mode(), err)
+	}
+
+	if err := os.WriteFile(l.Path, []byte("{not json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Entries(""); err == nil {
+		t.Error("corrupt log read without error")
+	}
+}
@@ -0,0 +1,202 @@
+package history
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// matchWindow is how long after a suggestion a commit still counts as
+// the one it was meant for.
+const matchWindow = 24 * time.Hour
+
+// Result is what became of a suggestion.
+type Result string
+
+const (
+	// Unchanged means the suggestion was committed as is, apart from
+	// trailers such as Signed-off-by.
+	Unchanged Result = "unchanged"
+	// Edited means the next commit used a different message.
+	Edited Result = "edited"
+	// Discarded means no commit followed before the next suggestion, or
+	// within a day.
+	Discarded Result = "discarded"
+)
+
+// Outcome pairs an entry with the commit that followed it.
+type Outcome struct {
+	Entry  Entry
+	Result Result
+	// Commit is the hash of the matched commit, unless Discarded.
+	Commit string
+	// Kept is the share of the suggestion's words found in the committed
+	// message.
+	Kept float64
+}
+
+// Match finds what became of each of one repository's entries. A
+// suggestion goes with the first commit after it, unless a later
+// suggestion came before that commit or another suggestion already
+// claimed it. Entries committed by commit-writer are matched by hash.
+// commits must be oldest first.
+func Match(entries []Entry, commits []gitdiff.Commit) []Outcome {
+	byHash := map[string]gitdiff.Commit{}
+	for _, c := range commits {
+		byHash[c.Hash] = c
+	}
+	claimed := map[string]bool{}
+	for _, e := range entries {
+		if _, ok := byHash[e.Commit]; ok && e.Accepted {
+			claimed[e.Commit] = true
+		}
+	}
+	times := make([]time.Time, len(entries))
+	for i, e := range entries {
+		times[i], _ = time.Parse(time.RFC3339, e.Time)
+	}
+
+	outcomes := make([]Outcome, len(entries))
+	for i, e := range entries {
+		outcomes[i] = Outcome{Entry: e, Result: Discarded}
+		c, ok := byHash[e.Commit]
+		if !ok || !e.Accepted {
+			if c, ok = next(commits, times[i]); !ok || claimed[c.Hash] || superseded(times, i, c.Time) {
+				continue
+			}
+			claimed[c.Hash] = true
+		}
+		outcomes[i].Commit = c.Hash
+		outcomes[i].Kept = kept(e.Message, c.Message)
+		outcomes[i].Result = Edited
+		if same(e.Message, c.Message) {
+			outcomes[i].Result = Unchanged
+		}
+	}
+	return outcomes
+}
+
+// next returns the first commit made at or after t, within matchWindow.
+func next(commits []gitdiff.Commit, t time.Time) (gitdiff.Commit, bool) {
+	if t.IsZero() {
+		return gitdiff.Commit{}, false
+	}
+	for _, c := range commits {
+		if !c.Time.Before(t) {
+			return c, c.Time.Sub(t) <= matchWindow
+		}
+	}
+	return gitdiff.Commit{}, false
+}
+
+// superseded reports whether a later entry than i was made by commit time.
+func superseded(times []time.Time, i int, commit time.Time) bool {
+	for _, t := range times[i+1:] {
+		if !t.Before(times[i]) && !t.After(commit) {
+			return true
+		}
+	}
+	return false
+}
+
+// same reports whether committed is suggested, ignoring comment lines,
+// trailing whitespace and trailers added after it.
+func same(suggested, committed string) bool {
+	s, c := clean(suggested), clean(committed)
+	if s == c {
+		return true
+	}
+	rest := strings.TrimPrefix(c, s+"\n\n")
+	if rest == c {
+		return false
+	}
+	for _, line := range strings.Split(rest, "\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok || value == "" || strings.ContainsAny(key, " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+func clean(msg string) string {
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(l, "#") {
+			lines = append(lines, strings.TrimRight(l, " \t"))
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// kept returns the share of suggested's words that committed also has.
+func kept(suggested, committed string) float64 {
+	words := strings.Fields(strings.ToLower(clean(suggested)))
+	if len(words) == 0 {
+		return 0
+	}
+	have := map[string]int{}
+	for _, w := range strings.Fields(strings.ToLower(clean(committed))) {
+		have[w]++
+	}
+	n := 0
+	for _, w := range words {
+		if have[w] > 0 {
+			have[w]--
+			n++
+		}
+	}
+	return float64(n) / float64(len(words))
+}
+
+// Stat counts the outcomes of one model and tone configuration.
+type Stat struct {
+	// Model is "<summarizer> -> <style>", or "offline fallback".
+	Model     string
+	Tone      string
+	Suggested int
+	Unchanged int
+	Edited    int
+	Discarded int
+	// Kept is the mean share of words kept in edited messages.
+	Kept float64
+}
+
+// Summarize groups outcomes by model and tone, most suggestions first.
+func Summarize(outcomes []Outcome) []Stat {
+	index := map[[2]string]int{}
+	var stats []Stat
+	keptSum := map[int]float64{}
+	for _, o := range outcomes {
+		key := [2]string{o.Entry.SummarizerModel + " -> " + o.Entry.StyleModel, o.Entry.Tone}
+		if o.Entry.Offline {
+			key = [2]string{"offline fallback", ""}
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(stats)
+			index[key] = i
+			stats = append(stats, Stat{Model: key[0], Tone: key[1]})
+		}
+		s := &stats[i]
+		s.Suggested++
+		switch o.Result {
+		case Unchanged:
+			s.Unchanged++
+		case Edited:
+			s.Edited++
+			keptSum[i] += o.Kept
+		default:
+			s.Discarded++
+		}
+	}
+	for i := range stats {
+		if stats[i].Edited > 0 {
+			stats[i].Kept = keptSum[i] / float64(stats[i].Edited)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool { return stats[i].Suggested > stats[j].Suggested })
+	return stats
+}
@@ -0,0 +1,69 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestMatch(t *testing.T) {
+	at := func(min int) time.Time { return time.Date(2024, 5, 1, 12, min, 0, 0, time.UTC) }
+	entry := func(min int, msg string) Entry {
+		return Entry{Time: at(min).Format(time.RFC3339), Message: msg}
+	}
+	commits := []gitdiff.Commit{
+		{Hash: "c1", Time: at(2), Message: "Add coupons\n\nSigned-off-by: T <t@example.com>"},
+		{Hash: "c2", Time: at(10), Message: "Round cart totals to cents"},
+		{Hash: "c3", Time: at(20), Message: "Fix login"},
+	}
+	committed := entry(15, "Fix login")
+	committed.Accepted, committed.Commit = true, "c3"
+	entries := []Entry{
+		entry(0, "Add coupons"),                // unchanged apart from the trailer
+		entry(5, "Add rounding"),               // superseded by the next one
+		entry(6, "Round the cart totals"),      // edited
+		entry(12, "Tweak the login"),           // c3 is claimed by the hash match
+		committed,                              // matched by hash
+		entry(30, "Nothing was committed yet"), // no later commit
+	}
+	var got []Result
+	for _, o := range Match(entries, commits) {
+		got = append(got, o.Result)
+	}
+	want := []Result{Unchanged, Discarded, Edited, Discarded, Unchanged, Discarded}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results = %v, want %v", got, want)
+	}
+	if o := Match(entries[2:3], commits)[0]; o.Commit != "c2" || o.Kept != 0.75 {
+		t.Errorf("edited outcome = %+v", o)
+	}
+	// A commit more than a day later is not the suggestion's.
+	late := []gitdiff.Commit{{Hash: "c4", Time: at(0).Add(25 * time.Hour), Message: "Add coupons"}}
+	if o := Match(entries[:1], late)[0]; o.Result != Discarded {
+		t.Errorf("late commit outcome = %+v", o)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	gemma := Entry{SummarizerModel: "gemma3:4B", StyleModel: "mistral:7b", Tone: "concise"}
+	offline := Entry{SummarizerModel: "gemma3:4B", StyleModel: "mistral:7b", Tone: "concise", Offline: true}
+	stats := Summarize([]Outcome{
+		{Entry: offline, Result: Discarded},
+		{Entry: gemma, Result: Unchanged},
+		{Entry: gemma, Result: Edited, Kept: 0.5},
+		{Entry: gemma, Result: Edited, Kept: 0.7},
+	})
+	want := []Stat{
+		{Model: "gemma3:4B -> mistral:7b", Tone: "concise", Suggested: 3, Unchanged: 1, Edited: 2, Kept: 0.6},
+		{Model: "offline fallback", Suggested: 1, Discarded: 1},
+	}
+	if len(stats) != 2 || stats[0].Kept < 0.599 || stats[0].Kept > 0.601 {
+		t.Fatalf("Summarize = %+v", stats)
+	}
+	stats[0].Kept = 0.6
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("Summarize = %+v, want %+v", stats, want)
+	}
+}
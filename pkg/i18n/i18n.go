@@ -0,0 +1,89 @@
+// Package i18n translates commit-writer's own status, warning and error
+// messages. The generated commit message is not affected; its language is
+// the models' business.
+//
+// Catalogs are embedded JSON objects mapping an English format string to
+// its translation, one file per language. Messages without an entry stay
+// in English, so a catalog can grow one message at a time.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed catalogs/*.json
+var catalogs embed.FS
+
+// Catalog maps English format strings to translations. The nil Catalog
+// is English.
+type Catalog map[string]string
+
+// Load returns the catalog for lang, a language tag or locale such as
+// "de", "de-AT" or "de_DE.UTF-8". English, "C", "POSIX" and "" return
+// nil; an unknown language is an error.
+func Load(lang string) (Catalog, error) {
+	base := Base(lang)
+	if base == "" || base == "en" || base == "c" || base == "posix" {
+		return nil, nil
+	}
+	data, err := catalogs.ReadFile(path.Join("catalogs", base+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no messages in %q (have en, %s)", lang, strings.Join(Languages(), ", "))
+	}
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("catalog %s: %w", base, err)
+	}
+	return c, nil
+}
+
+// Languages returns the languages with a catalog, sorted.
+func Languages() []string {
+	entries, _ := catalogs.ReadDir("catalogs")
+	var langs []string
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Base returns the lower-case language of a tag or locale: "pt" for
+// "pt_BR.UTF-8" or "pt-BR".
+func Base(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// EnvLang returns the language the environment asks messages in, as gettext
+// reads it: LC_ALL, then LC_MESSAGES, then LANG.
+func EnvLang() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// T returns the translation of the format string msg, or msg itself.
+func (c Catalog) T(msg string) string {
+	if t, ok := c[msg]; ok && t != "" {
+		return t
+	}
+	return msg
+}
+
+// Sprintf formats args with the translation of format.
+func (c Catalog) Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(c.T(format), args...)
+}
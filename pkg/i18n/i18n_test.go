@@ -0,0 +1,46 @@
+package i18n
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	for _, lang := range []string{"", "C", "POSIX", "en_US.UTF-8", "en"} {
+		if c, err := Load(lang); err != nil || c != nil {
+			t.Errorf("Load(%q) = %v, %v; want English", lang, c, err)
+		}
+	}
+	c, err := Load("de_DE.UTF-8")
+	if err != nil {
+		t.Fatalf("Load(de_DE.UTF-8): %v", err)
+	}
+	if got := c.Sprintf("Diff collected (%d bytes)", 12); got != "Diff gesammelt (12 Bytes)" {
+		t.Errorf("Sprintf = %q", got)
+	}
+	if got := c.T("not in any catalog"); got != "not in any catalog" {
+		t.Errorf("T(untranslated) = %q", got)
+	}
+	if _, err := Load("xx"); err == nil {
+		t.Error("Load(xx) succeeded")
+	}
+}
+
+var verb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+// TestCatalogs checks that every translation takes the same arguments as
+// its English message.
+func TestCatalogs(t *testing.T) {
+	for _, lang := range Languages() {
+		c, err := Load(lang)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", lang, err)
+		}
+		for msg, tr := range c {
+			if got, want := verb.FindAllString(tr, -1), verb.FindAllString(msg, -1); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %q has verbs %q, want %q", lang, tr, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package keychain reads provider credentials from the OS credential store.
+package keychain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Service is the service name credentials are stored under in the OS
+// credential store; the account is the provider name, e.g. "ollama".
+const Service = "commit-writer"
+
+// execCommand runs the credential store's command line tool.
+var execCommand = exec.Command
+
+// Lookup reads the secret stored for account from the OS credential
+// store: the macOS Keychain, the Windows Credential Manager (web credentials
+// vault) or the Secret Service (GNOME Keyring, KWallet) via secret-tool.
+// When the store has no such credential, it returns "" and an error that
+// says so.
+func Lookup(account string) (string, error) {
+	return lookup(runtime.GOOS, account)
+}
+
+func lookup(goos, account string) (string, error) {
+	name, args := command(goos, account)
+	cmd := execCommand(name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && !notFound(goos, err, stderr.String()) {
+		return "", fmt.Errorf("keychain lookup for %q failed: %w: %s", account, err, strings.TrimSpace(stderr.String()))
+	}
+	secret := strings.TrimRight(string(out), "\r\n")
+	if err != nil || secret == "" {
+		return "", fmt.Errorf("no credential for %q found in keychain", account)
+	}
+	return secret, nil
+}
+
+// command returns the command line that prints the secret for account on
+// goos.
+func command(goos, account string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "security", []string{"find-generic-password", "-s", Service, "-a", account, "-w"}
+	case "windows":
+		script := fmt.Sprintf("[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime]; "+
+			"$c = (New-Object Windows.Security.Credentials.PasswordVault).Retrieve('%s', '%s'); $c.RetrievePassword(); $c.Password",
+			Service, account)
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", script}
+	default:
+		return "secret-tool", []string{"lookup", "service", Service, "account", account}
+	}
+}
+
+// notFound reports whether the lookup failed only because the store has no
+// such credential: security exits with 44, secret-tool with 1 and nothing
+// on stderr, and the password vault reports "Element not found".
+func notFound(goos string, err error, stderr string) bool {
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		return false
+	}
+	switch goos {
+	case "darwin":
+		return exit.ExitCode() == 44
+	case "windows":
+		return strings.Contains(stderr, "Element not found")
+	default:
+		return exit.ExitCode() == 1 && strings.TrimSpace(stderr) == ""
+	}
+}
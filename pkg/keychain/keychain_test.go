@@ -0,0 +1,96 @@
+package keychain
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	for _, tt := range []struct {
+		goos, name string
+		args       []string
+	}{
+		{"darwin", "security", []string{"find-generic-password", "-s", "commit-writer", "-a", "jira", "-w"}},
+		{"linux", "secret-tool", []string{"lookup", "service", "commit-writer", "account", "jira"}},
+		{"freebsd", "secret-tool", []string{"lookup", "service", "commit-writer", "account", "jira"}},
+	} {
+		name, args := command(tt.goos, "jira")
+		if name != tt.name || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("command(%s) = %s %q, want %s %q", tt.goos, name, args, tt.name, tt.args)
+		}
+	}
+	name, args := command("windows", "jira")
+	if name != "powershell" || len(args) != 4 || args[2] != "-Command" ||
+		!strings.Contains(args[3], ".Retrieve('commit-writer', 'jira')") {
+		t.Errorf("command(windows) = %s %q", name, args)
+	}
+}
+
+// fakeStore makes lookups run script in place of the store's tool and
+// returns the command line they asked for.
+func fakeStore(t *testing.T, script string) *[]string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	path := filepath.Join(t.TempDir(), "store")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var called []string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		called = append([]string{name}, args...)
+		return exec.Command(path, args...)
+	}
+	t.Cleanup(func() { execCommand = exec.Command })
+	return &called
+}
+
+func TestLookup(t *testing.T) {
+	called := fakeStore(t, `printf 'sekrit\n'`)
+	secret, err := lookup("linux", "ollama")
+	if err != nil || secret != "sekrit" {
+		t.Errorf("lookup = %q, %v", secret, err)
+	}
+	if want := []string{"secret-tool", "lookup", "service", "commit-writer", "account", "ollama"}; !reflect.DeepEqual(*called, want) {
+		t.Errorf("ran %q, want %q", *called, want)
+	}
+}
+
+func TestLookupNotFound(t *testing.T) {
+	for _, tt := range []struct{ goos, script string }{
+		{"linux", "exit 1"},
+		{"linux", "exit 0"},
+		{"darwin", "echo 'security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain.' >&2; exit 44"},
+		{"windows", "echo 'Exception calling \"Retrieve\" with \"2\" argument(s): \"Element not found.\"' >&2; exit 1"},
+	} {
+		fakeStore(t, tt.script)
+		secret, err := lookup(tt.goos, "ollama")
+		if secret != "" || err == nil || !strings.Contains(err.Error(), `no credential for "ollama"`) {
+			t.Errorf("%s: %s: lookup = %q, %v", tt.goos, tt.script, secret, err)
+		}
+	}
+}
+
+func TestLookupFailed(t *testing.T) {
+	for _, tt := range []struct{ goos, script string }{
+		{"linux", "echo 'Cannot autolaunch D-Bus without X11 $DISPLAY' >&2; exit 1"},
+		{"darwin", "echo 'security: SecKeychainCopyDefault: User interaction is not allowed.' >&2; exit 36"},
+	} {
+		fakeStore(t, tt.script)
+		secret, err := lookup(tt.goos, "ollama")
+		if secret != "" || err == nil || !strings.Contains(err.Error(), "lookup for \"ollama\" failed") {
+			t.Errorf("%s: %s: lookup = %q, %v", tt.goos, tt.script, secret, err)
+		}
+	}
+	execCommand = func(string, ...string) *exec.Cmd { return exec.Command(filepath.Join(t.TempDir(), "missing")) }
+	defer func() { execCommand = exec.Command }()
+	if _, err := lookup("linux", "ollama"); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("lookup with no secret-tool = %v", err)
+	}
+}
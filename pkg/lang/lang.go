@@ -0,0 +1,244 @@
+// Package lang detects the languages of changed files, after GitHub
+// linguist's extension and file name rules, so the summarizer knows it is
+// looking at YAML configuration or SQL rather than guessing from the hunks.
+package lang
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxListed bounds how many files are listed one by one in a hint; the
+// rest are counted per language.
+const maxListed = 40
+
+// Language is a linguist language name and type: "programming", "data",
+// "markup" or "prose".
+type Language struct {
+	Name string
+	Type string
+}
+
+func programming(name string) Language { return Language{name, "programming"} }
+func data(name string) Language        { return Language{name, "data"} }
+func markup(name string) Language      { return Language{name, "markup"} }
+func prose(name string) Language       { return Language{name, "prose"} }
+
+var extensions = map[string]Language{
+	".go":       programming("Go"),
+	".py":       programming("Python"),
+	".rb":       programming("Ruby"),
+	".js":       programming("JavaScript"),
+	".mjs":      programming("JavaScript"),
+	".cjs":      programming("JavaScript"),
+	".jsx":      programming("JavaScript"),
+	".ts":       programming("TypeScript"),
+	".tsx":      programming("TSX"),
+	".java":     programming("Java"),
+	".kt":       programming("Kotlin"),
+	".kts":      programming("Kotlin"),
+	".scala":    programming("Scala"),
+	".groovy":   programming("Groovy"),
+	".gradle":   programming("Gradle"),
+	".rs":       programming("Rust"),
+	".c":        programming("C"),
+	".h":        programming("C"),
+	".cc":       programming("C++"),
+	".cpp":      programming("C++"),
+	".cxx":      programming("C++"),
+	".hpp":      programming("C++"),
+	".hh":       programming("C++"),
+	".cs":       programming("C#"),
+	".fs":       programming("F#"),
+	".swift":    programming("Swift"),
+	".m":        programming("Objective-C"),
+	".php":      programming("PHP"),
+	".pl":       programming("Perl"),
+	".lua":      programming("Lua"),
+	".r":        programming("R"),
+	".dart":     programming("Dart"),
+	".ex":       programming("Elixir"),
+	".exs":      programming("Elixir"),
+	".erl":      programming("Erlang"),
+	".hs":       programming("Haskell"),
+	".clj":      programming("Clojure"),
+	".zig":      programming("Zig"),
+	".sh":       programming("Shell"),
+	".bash":     programming("Shell"),
+	".zsh":      programming("Shell"),
+	".fish":     programming("fish"),
+	".ps1":      programming("PowerShell"),
+	".bat":      programming("Batchfile"),
+	".sql":      data("SQL"),
+	".tf":       programming("HCL"),
+	".hcl":      programming("HCL"),
+	".nix":      programming("Nix"),
+	".proto":    data("Protocol Buffer"),
+	".graphql":  data("GraphQL"),
+	".gql":      data("GraphQL"),
+	".vue":      markup("Vue"),
+	".svelte":   markup("Svelte"),
+	".html":     markup("HTML"),
+	".htm":      markup("HTML"),
+	".css":      markup("CSS"),
+	".scss":     markup("SCSS"),
+	".sass":     markup("Sass"),
+	".less":     markup("Less"),
+	".xml":      data("XML"),
+	".svg":      data("SVG"),
+	".json":     data("JSON"),
+	".jsonc":    data("JSON with Comments"),
+	".yaml":     data("YAML"),
+	".yml":      data("YAML"),
+	".toml":     data("TOML"),
+	".ini":      data("INI"),
+	".cfg":      data("INI"),
+	".env":      data("Dotenv"),
+	".csv":      data("CSV"),
+	".tsv":      data("TSV"),
+	".lock":     data("Lock file"),
+	".tmpl":     markup("Go Template"),
+	".gotmpl":   markup("Go Template"),
+	".md":       prose("Markdown"),
+	".markdown": prose("Markdown"),
+	".mdx":      prose("MDX"),
+	".rst":      prose("reStructuredText"),
+	".adoc":     prose("AsciiDoc"),
+	".tex":      markup("TeX"),
+	".txt":      prose("Text"),
+}
+
+var filenames = map[string]Language{
+	"Dockerfile":     programming("Dockerfile"),
+	"Containerfile":  programming("Dockerfile"),
+	"Makefile":       programming("Makefile"),
+	"GNUmakefile":    programming("Makefile"),
+	"CMakeLists.txt": programming("CMake"),
+	"Jenkinsfile":    programming("Groovy"),
+	"Gemfile":        programming("Ruby"),
+	"Rakefile":       programming("Ruby"),
+	"Vagrantfile":    programming("Ruby"),
+	"BUILD":          programming("Starlark"),
+	"BUILD.bazel":    programming("Starlark"),
+	"WORKSPACE":      programming("Starlark"),
+	"go.mod":         data("Go Module"),
+	"go.sum":         data("Go Checksums"),
+	"go.work":        data("Go Workspace"),
+	".gitignore":     data("Ignore List"),
+	".dockerignore":  data("Ignore List"),
+	".gitattributes": data("Git Attributes"),
+	".editorconfig":  data("EditorConfig"),
+	"LICENSE":        prose("Text"),
+	"COPYING":        prose("Text"),
+}
+
+// byName finds a language's type for a linguist-language override.
+var byName = func() map[string]Language {
+	m := map[string]Language{}
+	for _, table := range []map[string]Language{extensions, filenames} {
+		for _, l := range table {
+			m[strings.ToLower(l.Name)] = l
+		}
+	}
+	return m
+}()
+
+// Detect returns the language of a repo path.
+func Detect(name string) (Language, bool) {
+	base := path.Base(name)
+	if l, ok := filenames[base]; ok {
+		return l, true
+	}
+	if strings.HasPrefix(base, "Dockerfile.") || strings.HasSuffix(base, ".dockerfile") {
+		return programming("Dockerfile"), true
+	}
+	if (strings.HasPrefix(name, ".github/workflows/") || strings.HasPrefix(name, ".gitlab-ci")) && (strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")) {
+		return data("YAML (CI pipeline)"), true
+	}
+	l, ok := extensions[strings.ToLower(path.Ext(base))]
+	return l, ok
+}
+
+// File is a changed file and its language.
+type File struct {
+	Path     string
+	Language Language
+	// Generated is set for linguist-generated and linguist-vendored files.
+	Generated bool
+}
+
+// Files detects the language of each path, applying the linguist-language,
+// linguist-generated and linguist-vendored overrides in attrs (path to
+// attribute to value, as returned by gitdiff.CheckAttr). Files of unknown
+// language are left out.
+func Files(paths []string, attrs map[string]map[string]string) []File {
+	var files []File
+	for _, p := range paths {
+		a := attrs[p]
+		l, ok := Detect(p)
+		if name := a["linguist-language"]; name != "" && name != "unspecified" && name != "set" && name != "unset" {
+			l, ok = byName[strings.ToLower(name)]
+			if !ok {
+				l, ok = programming(name), true
+			}
+		}
+		if !ok {
+			continue
+		}
+		files = append(files, File{
+			Path:      p,
+			Language:  l,
+			Generated: isSet(a["linguist-generated"]) || isSet(a["linguist-vendored"]),
+		})
+	}
+	return files
+}
+
+// isSet reports whether a boolean attribute value is on.
+func isSet(v string) bool {
+	return v == "set" || v == "true"
+}
+
+// Hint describes the files for the summarizer, one per line up to a limit
+// and then counted per language. It is empty without files.
+func Hint(files []File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Languages of the changed files (data and markup files are configuration, content or schemas, not program logic):")
+	for i, f := range files {
+		if i == maxListed {
+			break
+		}
+		fmt.Fprintf(&b, "\n- %s: %s (%s", f.Path, f.Language.Name, f.Language.Type)
+		if f.Generated {
+			b.WriteString(", generated or vendored")
+		}
+		b.WriteString(")")
+	}
+	if len(files) > maxListed {
+		counts := map[string]int{}
+		for _, f := range files[maxListed:] {
+			counts[f.Language.Name]++
+		}
+		names := make([]string, 0, len(counts))
+		for n := range counts {
+			names = append(names, n)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if counts[names[i]] != counts[names[j]] {
+				return counts[names[i]] > counts[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		parts := make([]string, len(names))
+		for i, n := range names {
+			parts[i] = fmt.Sprintf("%s (%d)", n, counts[n])
+		}
+		fmt.Fprintf(&b, "\n- %d more files: %s", len(files)-maxListed, strings.Join(parts, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,59 @@
+package lang
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"pkg/a.go":                   "Go",
+		"deploy/app.YML":             "YAML",
+		"db/migrations/001_init.sql": "SQL",
+		"Dockerfile.dev":             "Dockerfile",
+		"build/Makefile":             "Makefile",
+		".github/workflows/ci.yml":   "YAML (CI pipeline)",
+		"go.mod":                     "Go Module",
+		"assets/logo.png":            "",
+	}
+	for name, want := range tests {
+		l, ok := Detect(name)
+		if l.Name != want || ok != (want != "") {
+			t.Errorf("Detect(%q) = %+v, %v; want %q", name, l, ok, want)
+		}
+	}
+}
+
+func TestFilesAndHint(t *testing.T) {
+	attrs := map[string]map[string]string{
+		"web/dist/app.js": {"linguist-generated": "set", "linguist-language": "unspecified"},
+		"schema.def":      {"linguist-language": "SQL"},
+		"rules.star":      {"linguist-language": "Bazel"},
+	}
+	files := Files([]string{"main.go", "web/dist/app.js", "schema.def", "rules.star", "logo.png"}, attrs)
+	got := Hint(files)
+	want := `Languages of the changed files (data and markup files are configuration, content or schemas, not program logic):
+- main.go: Go (programming)
+- web/dist/app.js: JavaScript (programming, generated or vendored)
+- schema.def: SQL (data)
+- rules.star: Bazel (programming)`
+	if got != want {
+		t.Errorf("Hint =\n%s\nwant\n%s", got, want)
+	}
+	if Hint(nil) != "" {
+		t.Error("Hint(nil) not empty")
+	}
+}
+
+func TestHintLimit(t *testing.T) {
+	var paths []string
+	for i := 0; i < maxListed+3; i++ {
+		paths = append(paths, fmt.Sprintf("f%d.go", i))
+	}
+	paths = append(paths, "a.yaml")
+	got := Hint(Files(paths, nil))
+	if !strings.HasSuffix(got, "\n- 4 more files: Go (3), YAML (1)") || strings.Count(got, "\n") != maxListed+1 {
+		t.Errorf("Hint over the limit:\n%s", got)
+	}
+}
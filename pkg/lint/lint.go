@@ -0,0 +1,106 @@
+// Package lint checks commit messages against the rules configured under
+// "rules" in the config file.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxSubjectLength is the subject limit when none is configured.
+const DefaultMaxSubjectLength = 72
+
+// Rules configures the checks. The zero value checks that the subject is
+// present, at most DefaultMaxSubjectLength characters and followed by a
+// blank line.
+type Rules struct {
+	// MaxSubjectLength bounds the first line; negative disables the check.
+	MaxSubjectLength int `json:"max_subject_length,omitempty"`
+	// MaxBodyLineLength bounds body lines; 0 disables the check.
+	MaxBodyLineLength int `json:"max_body_line_length,omitempty"`
+	// SubjectPattern is a regular expression the subject must match, e.g.
+	// "^(feat|fix|docs|chore)(\\(.+\\))?: " for Conventional Commits.
+	SubjectPattern string `json:"subject_pattern,omitempty"`
+	// RequireBody rejects messages with only a subject.
+	RequireBody bool `json:"require_body,omitempty"`
+	// NoTrailingPeriod rejects subjects ending in ".".
+	NoTrailingPeriod bool `json:"no_trailing_period,omitempty"`
+}
+
+// Problem is one rule violation.
+type Problem struct {
+	// Rule names the check, e.g. "subject-length".
+	Rule string `json:"rule"`
+	// Line is the 1-based message line, or 0 for the message as a whole.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s (%s)", p.Line, p.Message, p.Rule)
+	}
+	return fmt.Sprintf("%s (%s)", p.Message, p.Rule)
+}
+
+// Validate reports configuration mistakes, such as an invalid pattern.
+func (r Rules) Validate() error {
+	if r.SubjectPattern != "" {
+		if _, err := regexp.Compile(r.SubjectPattern); err != nil {
+			return fmt.Errorf("rules: invalid subject_pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// Check returns the problems found in msg. Lines starting with "#" are
+// ignored, as git strips them from commit messages.
+func (r Rules) Check(msg string) []Problem {
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(msg, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(l, "#") {
+			lines = append(lines, strings.TrimRight(l, " \t"))
+		}
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return []Problem{{Rule: "subject-empty", Line: 1, Message: "subject line is empty"}}
+	}
+
+	var problems []Problem
+	subject := lines[0]
+	max := r.MaxSubjectLength
+	if max == 0 {
+		max = DefaultMaxSubjectLength
+	}
+	if n := len([]rune(subject)); max > 0 && n > max {
+		problems = append(problems, Problem{Rule: "subject-length", Line: 1, Message: fmt.Sprintf("subject is %d characters, limit is %d", n, max)})
+	}
+	if r.NoTrailingPeriod && strings.HasSuffix(subject, ".") {
+		problems = append(problems, Problem{Rule: "subject-period", Line: 1, Message: "subject ends with a period"})
+	}
+	if r.SubjectPattern != "" {
+		if re, err := regexp.Compile(r.SubjectPattern); err == nil && !re.MatchString(subject) {
+			problems = append(problems, Problem{Rule: "subject-pattern", Line: 1, Message: fmt.Sprintf("subject does not match %s", r.SubjectPattern)})
+		}
+	}
+	if len(lines) > 1 && lines[1] != "" {
+		problems = append(problems, Problem{Rule: "blank-line", Line: 2, Message: "subject is not followed by a blank line"})
+	}
+	hasBody := false
+	for i, l := range lines[1:] {
+		if l != "" {
+			hasBody = true
+		}
+		if n := len([]rune(l)); r.MaxBodyLineLength > 0 && n > r.MaxBodyLineLength {
+			problems = append(problems, Problem{Rule: "body-line-length", Line: i + 2, Message: fmt.Sprintf("line is %d characters, limit is %d", n, r.MaxBodyLineLength)})
+		}
+	}
+	if r.RequireBody && !hasBody {
+		problems = append(problems, Problem{Rule: "body-required", Message: "message has no body"})
+	}
+	return problems
+}
@@ -0,0 +1,54 @@
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func rules(problems []Problem) []string {
+	var names []string
+	for _, p := range problems {
+		names = append(names, p.Rule)
+	}
+	return names
+}
+
+func TestCheck(t *testing.T) {
+	conventional := Rules{SubjectPattern: `^(feat|fix)(\(.+\))?: `, NoTrailingPeriod: true}
+	tests := []struct {
+		name  string
+		rules Rules
+		msg   string
+		want  []string
+	}{
+		{"good", Rules{}, "Add login\n\nHandles tokens.\n", nil},
+		{"subject only", Rules{}, "Add login", nil},
+		{"empty", Rules{}, "\n\n", []string{"subject-empty"}},
+		{"comments ignored", Rules{}, "# Please enter\nAdd login\n", nil},
+		{"long subject", Rules{}, strings.Repeat("x", 73), []string{"subject-length"}},
+		{"custom limit", Rules{MaxSubjectLength: 10}, "Add the login page", []string{"subject-length"}},
+		{"limit disabled", Rules{MaxSubjectLength: -1}, strings.Repeat("x", 200), nil},
+		{"no blank line", Rules{}, "Add login\nbody", []string{"blank-line"}},
+		{"pattern and period", conventional, "Add login.", []string{"subject-period", "subject-pattern"}},
+		{"pattern ok", conventional, "feat(auth): add login", nil},
+		{"body required", Rules{RequireBody: true}, "Add login\n", []string{"body-required"}},
+		{"body line length", Rules{MaxBodyLineLength: 5}, "Add\n\nshort\ntoo long", []string{"body-line-length"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(tt.rules.Check(tt.msg)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check(%q) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Rules{SubjectPattern: "("}).Validate(); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	if err := (Rules{SubjectPattern: "^feat"}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
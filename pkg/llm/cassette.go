@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Interaction is one recorded request/response pair in a cassette file.
+type Interaction struct {
+	Key      string                 `json:"key"`
+	Model    string                 `json:"model"`
+	Prompt   string                 `json:"prompt"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Response string                 `json:"response"`
+}
+
+// Cassette records model responses to a JSON file keyed by a hash of the
+// request, or replays them from it without contacting any model.
+type Cassette struct {
+	// Client is the live backend in record mode; nil means replay.
+	Client Client
+	Path   string
+
+	mu      sync.Mutex
+	entries map[string]Interaction
+}
+
+// OpenCassette loads the cassette at path. With a non-nil client it records:
+// each response from client is added to the file (an existing file is
+// extended). With a nil client it replays and path must exist.
+func OpenCassette(path string, client Client) (*Cassette, error) {
+	c := &Cassette{Client: client, Path: path, entries: make(map[string]Interaction)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && client != nil {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var file struct {
+		Interactions []Interaction `json:"interactions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %w", path, err)
+	}
+	for _, in := range file.Interactions {
+		c.entries[in.Key] = in
+	}
+	return c, nil
+}
+
+// RequestKey is the hex SHA-256 of the model, prompt, options and (when
+// set) template of req.
+func RequestKey(req Request) string {
+	b, _ := json.Marshal(struct {
+		Model    string                 `json:"model"`
+		Prompt   string                 `json:"prompt"`
+		Options  map[string]interface{} `json:"options"`
+		Template string                 `json:"template,omitempty"`
+	}{req.Model, req.Prompt, req.Options, req.Template})
+	return fmt.Sprintf("%x", sha256.Sum256(b))
+}
+
+// Generate replays the recorded response for req, or in record mode calls
+// the live client and saves its response.
+func (c *Cassette) Generate(ctx context.Context, req Request) (string, error) {
+	key := RequestKey(req)
+	if c.Client == nil {
+		c.mu.Lock()
+		in, ok := c.entries[key]
+		c.mu.Unlock()
+		if !ok {
+			return "", fmt.Errorf("cassette %s has no recorded response for this %s request (key %.12s)", c.Path, req.Model, key)
+		}
+		return in.Response, nil
+	}
+
+	out, err := c.Client.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = Interaction{Key: key, Model: req.Model, Prompt: req.Prompt, Options: req.Options, Response: out}
+	if err := c.save(); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// Check always succeeds in replay mode.
+func (c *Cassette) Check(ctx context.Context) error {
+	if c.Client == nil {
+		return nil
+	}
+	return c.Client.Check(ctx)
+}
+
+// CurlCommand delegates to the live client when it supports it.
+func (c *Cassette) CurlCommand(req Request) string {
+	if cc, ok := c.Client.(interface{ CurlCommand(Request) string }); ok {
+		return cc.CurlCommand(req)
+	}
+	return fmt.Sprintf("# replayed from cassette %s (key %s)", c.Path, RequestKey(req))
+}
+
+// save writes all entries sorted by key so re-recording gives stable diffs.
+func (c *Cassette) save() error {
+	file := struct {
+		Interactions []Interaction `json:"interactions"`
+	}{Interactions: make([]Interaction, 0, len(c.entries))}
+	for _, in := range c.entries {
+		file.Interactions = append(file.Interactions, in)
+	}
+	sort.Slice(file.Interactions, func(i, j int) bool {
+		return file.Interactions[i].Key < file.Interactions[j].Key
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %w", err)
+	}
+	if err := os.WriteFile(c.Path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
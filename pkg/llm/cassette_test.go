@@ -0,0 +1,58 @@
+package llm_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/llm/llmtest"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cassette.json")
+	srv := llmtest.NewServer(func(req llm.Request) string { return "reply to " + req.Prompt })
+	reqs := []llm.Request{
+		{Model: "m", Prompt: "one", Options: map[string]interface{}{"temperature": 0.0}},
+		{Model: "m", Prompt: "two", Options: map[string]interface{}{"temperature": 0.9}},
+	}
+
+	rec, err := llm.OpenCassette(path, &llm.Ollama{URL: srv.GenerateURL(), Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("OpenCassette(record): %v", err)
+	}
+	for _, req := range reqs {
+		if _, err := rec.Generate(context.Background(), req); err != nil {
+			t.Fatalf("record Generate: %v", err)
+		}
+	}
+	srv.Close()
+
+	play, err := llm.OpenCassette(path, nil)
+	if err != nil {
+		t.Fatalf("OpenCassette(replay): %v", err)
+	}
+	if err := play.Check(context.Background()); err != nil {
+		t.Errorf("replay Check: %v", err)
+	}
+	for _, req := range reqs {
+		got, err := play.Generate(context.Background(), req)
+		if err != nil || got != "reply to "+req.Prompt {
+			t.Errorf("replay %q = %q, %v", req.Prompt, got, err)
+		}
+	}
+
+	changed := reqs[0]
+	changed.Options = map[string]interface{}{"temperature": 0.5}
+	if _, err := play.Generate(context.Background(), changed); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("replay of unrecorded request: %v", err)
+	}
+}
+
+func TestOpenCassetteReplayMissing(t *testing.T) {
+	if _, err := llm.OpenCassette(filepath.Join(t.TempDir(), "none.json"), nil); err == nil {
+		t.Fatal("replaying a missing cassette succeeded")
+	}
+}
@@ -0,0 +1,116 @@
+// Package llmtest provides an httptest-backed fake Ollama server for tests.
+package llmtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+)
+
+// Server is a fake Ollama server. /api/tags always succeeds and lists
+// Models; /api/show describes the models in Info and fails for others;
+// /api/generate answers with Respond(req), streamed as two NDJSON
+// chunks so clients must concatenate them.
+type Server struct {
+	*httptest.Server
+
+	// Respond builds the reply for a generate request.
+	Respond func(req llm.Request) string
+	// Status, when non-zero, makes /api/generate fail with that status.
+	Status int
+	// Models are listed by /api/tags.
+	Models []string
+	// Info is returned by /api/show.
+	Info map[string]llm.ModelInfo
+
+	mu       sync.Mutex
+	requests []llm.Request
+	headers  []http.Header
+}
+
+// NewServer starts a fake server replying with respond. Call Close when done.
+func NewServer(respond func(req llm.Request) string) *Server {
+	s := &Server{Respond: respond}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		type model struct {
+			Name string `json:"name"`
+		}
+		tags := struct {
+			Models []model `json:"models"`
+		}{Models: []model{}}
+		for _, name := range s.Models {
+			tags.Models = append(tags.Models, model{Name: name})
+		}
+		_ = json.NewEncoder(w).Encode(tags)
+	})
+	mux.HandleFunc("/api/show", s.show)
+	mux.HandleFunc("/api/generate", s.generate)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// GenerateURL is the /api/generate endpoint of the fake server.
+func (s *Server) GenerateURL() string {
+	return s.URL + "/api/generate"
+}
+
+// Requests returns the generate requests received so far.
+func (s *Server) Requests() []llm.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]llm.Request(nil), s.requests...)
+}
+
+// Headers returns the headers of the generate requests received so far.
+func (s *Server) Headers() []http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]http.Header(nil), s.headers...)
+}
+
+func (s *Server) show(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, ok := s.Info[req.Model]
+	if !ok {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"template":   info.Template,
+		"details":    map[string]string{"family": info.Family, "parameter_size": info.ParameterSize},
+		"model_info": map[string]interface{}{info.Family + ".context_length": info.ContextLength, "general.architecture": info.Family},
+	})
+}
+
+func (s *Server) generate(w http.ResponseWriter, r *http.Request) {
+	var req llm.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, r.Header.Clone())
+	status := s.Status
+	s.mu.Unlock()
+
+	if status != 0 {
+		http.Error(w, "fake failure", status)
+		return
+	}
+	out := s.Respond(req)
+	half := len(out) / 2
+	enc := json.NewEncoder(w)
+	_ = enc.Encode(llm.Response{Model: req.Model, Response: out[:half]})
+	_ = enc.Encode(llm.Response{Model: req.Model, Response: out[half:], Done: true})
+}
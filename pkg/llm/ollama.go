@@ -0,0 +1,519 @@
+// Package llm talks to the Ollama generate API.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/container"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+)
+
+// DefaultURL is the Ollama generate endpoint used when none is configured.
+const DefaultURL = "http://localhost:11434/api/generate"
+
+// Request is the body of an Ollama /api/generate call.
+type Request struct {
+	Model   string                 `json:"model"`
+	Prompt  string                 `json:"prompt,omitempty"`
+	Stream  bool                   `json:"stream,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Template replaces the model's prompt template for this request.
+	Template string `json:"template,omitempty"`
+	// Think turns a reasoning model's thinking on or off. Ollama.Generate
+	// sends false when it is nil, so models such as deepseek-r1 answer
+	// without a chain of thought; other models ignore it.
+	Think *bool `json:"think,omitempty"`
+	// Logprobs asks for the log probability of each generated token.
+	// Servers that predate it ignore it and send none.
+	Logprobs bool `json:"logprobs,omitempty"`
+}
+
+// Response is one (possibly streamed) chunk of an Ollama generate response.
+type Response struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Response  string `json:"response"`
+	Done      bool   `json:"done"`
+	// Logprobs are sent for the chunk's tokens when the request asked.
+	Logprobs []Logprob `json:"logprobs,omitempty"`
+}
+
+// Logprob is one generated token and its log probability.
+type Logprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+}
+
+// Client is a text-generation backend. *Ollama implements it; tests
+// substitute a fake.
+type Client interface {
+	// Generate sends req and returns the cleaned model output.
+	Generate(ctx context.Context, req Request) (string, error)
+	// Check verifies the backend is reachable.
+	Check(ctx context.Context) error
+}
+
+// LogprobClient is a Client that can also report how likely the model
+// found its own output.
+type LogprobClient interface {
+	Client
+	// GenerateLogprob is Generate, also returning the mean log probability
+	// of the generated tokens; ok is false when the backend sent none.
+	GenerateLogprob(ctx context.Context, req Request) (out string, mean float64, ok bool, err error)
+}
+
+// StreamClient is a Client that can show the output while it is being
+// generated.
+type StreamClient interface {
+	Client
+	// GenerateStream is Generate, calling partial with the raw, uncleaned
+	// output so far as it grows. partial runs on the caller's goroutine and
+	// should return quickly.
+	GenerateStream(ctx context.Context, req Request, partial func(string)) (string, error)
+}
+
+// Ollama is a client for a single Ollama server.
+type Ollama struct {
+	// URL is the /api/generate endpoint.
+	URL string
+	// APIKey, when set, is sent as a bearer token. Plain local servers
+	// ignore it; hosted or proxied instances require it.
+	APIKey string
+	// Timeout bounds each generate call.
+	Timeout time.Duration
+	// LoopbackOnly makes every connection fail unless the remote address is
+	// a loopback address (see --local-only).
+	LoopbackOnly bool
+}
+
+// httpClient returns an HTTP client honoring LoopbackOnly. Clients differ
+// only in their timeout; the connections are pooled in the shared
+// transport, so the availability check, model lookups and every stage's
+// generate call reuse one keep-alive connection, and its TLS session, to
+// the server instead of dialing again.
+func (o *Ollama) httpClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: transport(o.LoopbackOnly)}
+}
+
+// LoopbackClient returns an HTTP client that only connects to loopback
+// addresses. Proxies are bypassed and each dialed address is checked, so a
+// hostname that later resolves elsewhere still fails closed.
+func LoopbackClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: transport(true)}
+}
+
+var (
+	transportOnce                      sync.Once
+	sharedTransport, loopbackTransport *http.Transport
+)
+
+// transport returns the process-wide transport, or the one that only
+// dials loopback addresses.
+func transport(loopbackOnly bool) *http.Transport {
+	transportOnce.Do(func() {
+		sharedTransport, loopbackTransport = newTransport(false), newTransport(true)
+	})
+	if loopbackOnly {
+		return loopbackTransport
+	}
+	return sharedTransport
+}
+
+// newTransport returns a keep-alive transport sized for a few concurrent
+// model calls to one server.
+func newTransport(loopbackOnly bool) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        16,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	if loopbackOnly {
+		t.Proxy = nil
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+				return fmt.Errorf("local-only: refusing connection to non-loopback address %s", address)
+			}
+			return nil
+		}
+	}
+	return t
+}
+
+// closeBody reads what is left of a response body, so its connection can
+// be reused, and closes it.
+func closeBody(body io.ReadCloser, what string) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 1<<20))
+	if err := body.Close(); err != nil {
+		log.Printf("warning: failed to close %s: %v", what, err)
+	}
+}
+
+// setHeaders adds the Authorization header when an API key is configured
+// and the run's request ID when r's context has one.
+func (o *Ollama) setHeaders(r *http.Request) {
+	if o.APIKey != "" {
+		r.Header.Set("Authorization", "Bearer "+o.APIKey)
+	}
+	if id := RequestID(r.Context()); id != "" {
+		r.Header.Set(RequestIDHeader, id)
+	}
+}
+
+// RequestIDHeader carries a run's request ID to the server, so a proxy or
+// server log can tie the calls of one run together.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID every call made with it
+// sends.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID of 16 hex digits.
+func NewRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ValidRequestID reports whether a caller-supplied ID is safe to adopt:
+// 1 to 128 letters, digits, '-', '_' or '.'.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// noThink is the Think default.
+var noThink = false
+
+// withDefaults fills in the request fields Ollama sends by default.
+func withDefaults(req Request) Request {
+	if req.Think == nil {
+		req.Think = &noThink
+	}
+	return req
+}
+
+// Generate sends req and returns the cleaned model output.
+func (o *Ollama) Generate(ctx context.Context, req Request) (string, error) {
+	out, _, _, err := o.GenerateLogprob(ctx, req)
+	return out, err
+}
+
+// GenerateLogprob sends req and returns the cleaned model output and, when
+// req.Logprobs is set and the server supports it, the mean log
+// probability of its tokens.
+func (o *Ollama) GenerateLogprob(ctx context.Context, req Request) (string, float64, bool, error) {
+	return o.generate(ctx, req, nil)
+}
+
+// GenerateStream sends req as a streaming request, calling partial after
+// each chunk, and returns the cleaned model output.
+func (o *Ollama) GenerateStream(ctx context.Context, req Request, partial func(string)) (string, error) {
+	req.Stream = true
+	out, _, _, err := o.generate(ctx, req, partial)
+	return out, err
+}
+
+// generate sends req, calling partial, when set, with the output so far
+// after each chunk.
+func (o *Ollama) generate(ctx context.Context, req Request, partial func(string)) (string, float64, bool, error) {
+	b, err := json.Marshal(withDefaults(req))
+	if err != nil {
+		return "", 0, false, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	client := o.httpClient(o.Timeout)
+
+	r, err := http.NewRequestWithContext(ctx, "POST", o.URL, bytes.NewReader(b))
+	if err != nil {
+		return "", 0, false, err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	o.setHeaders(r)
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer closeBody(resp.Body, "response body")
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", 0, false, fmt.Errorf("ollama error: status=%d body=%s", resp.StatusCode, string(body))
+	}
+
+	var result string
+	var sum float64
+	var tokens int
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk Response
+		if err := decoder.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", 0, false, fmt.Errorf("failed to decode response: %w", err)
+		}
+		result += chunk.Response
+		for _, lp := range chunk.Logprobs {
+			sum += lp.Logprob
+			tokens++
+		}
+		if partial != nil && chunk.Response != "" {
+			partial(result)
+		}
+	}
+
+	// Clean the response: unquote JSON string if necessary and strip code fences.
+	result = format.CleanModelOutput(result)
+
+	if tokens == 0 {
+		return strings.TrimSpace(result), 0, false, nil
+	}
+	return strings.TrimSpace(result), sum / float64(tokens), true, nil
+}
+
+// CurlCommand creates a curl command that replicates the Ollama API request
+func (o *Ollama) CurlCommand(req Request) string {
+	b, err := json.Marshal(withDefaults(req))
+	if err != nil {
+		return fmt.Sprintf("# Error marshaling request for curl: %v", err)
+	}
+
+	// Escape single quotes in the JSON for shell safety
+	jsonStr := strings.ReplaceAll(string(b), "'", "'\\''")
+
+	// Reference the key by variable so it never ends up in terminal output.
+	auth := ""
+	if o.APIKey != "" {
+		auth = "  -H \"Authorization: Bearer $OLLAMA_API_KEY\" \\\n"
+	}
+
+	return fmt.Sprintf("curl -X POST '%s' \\\n  -H 'Content-Type: application/json' \\\n%s  -d '%s'", o.URL, auth, jsonStr)
+}
+
+// Check verifies the server is reachable by listing its models.
+func (o *Ollama) Check(ctx context.Context) error {
+	u, err := neturl.Parse(o.URL)
+	if err != nil {
+		return fmt.Errorf("invalid ollama URL: %w", err)
+	}
+	u.Path = "/api/tags"
+
+	client := o.httpClient(3 * time.Second)
+	req, _ := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	o.setHeaders(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return &UnreachableError{URL: o.URL, Hint: container.Hint(ctx, o.URL)}
+	}
+	defer closeBody(resp.Body, "tags response body")
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama tags endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// UnreachableError is Check's error when the server does not answer.
+type UnreachableError struct {
+	URL string
+	// Hint explains how Ollama in or outside a container may be reached,
+	// when commit-writer or Ollama seems to run in one.
+	Hint string
+}
+
+func (e *UnreachableError) Error() string {
+	if e.Hint != "" {
+		return "ollama is not reachable at " + e.URL + ": " + e.Hint
+	}
+	return "ollama does not appear to be running; start it with 'ollama serve'"
+}
+
+// ListModels returns the names of the models installed on the server.
+func (o *Ollama) ListModels(ctx context.Context) ([]string, error) {
+	u, err := neturl.Parse(o.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ollama URL: %w", err)
+	}
+	u.Path = "/api/tags"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	o.setHeaders(req)
+	resp, err := o.httpClient(10 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body, "tags response body")
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama tags endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("failed to decode model list: %w", err)
+	}
+	names := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// ModelInfo is what the server reports about a model.
+type ModelInfo struct {
+	// ContextLength is the longest context the model supports, in tokens,
+	// or 0 when unknown.
+	ContextLength int
+	// ParameterSize is the model's size as reported, e.g. "4.3B".
+	ParameterSize string
+	Family        string
+	// Template is the prompt template; base models have none, or one that
+	// passes the prompt through unchanged.
+	Template string
+}
+
+// Parameters returns ParameterSize in billions, or 0 when unknown.
+func (m ModelInfo) Parameters() float64 {
+	s := strings.ToUpper(strings.TrimSpace(m.ParameterSize))
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(s, "B"):
+		s = strings.TrimSuffix(s, "B")
+	case strings.HasSuffix(s, "M"):
+		s, scale = strings.TrimSuffix(s, "M"), 0.001
+	default:
+		return 0
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return n * scale
+}
+
+// Instruct reports whether the model's template wraps prompts in an
+// instruction or chat format, i.e. whether it is tuned to follow them.
+func (m ModelInfo) Instruct() bool {
+	t := strings.Join(strings.Fields(m.Template), "")
+	return t != "" && t != "{{.Prompt}}"
+}
+
+// Show asks the server about model through /api/show.
+func (o *Ollama) Show(ctx context.Context, model string) (ModelInfo, error) {
+	u, err := neturl.Parse(o.URL)
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("invalid ollama URL: %w", err)
+	}
+	u.Path = "/api/show"
+
+	body, _ := json.Marshal(map[string]string{"model": model})
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.setHeaders(req)
+	resp, err := o.httpClient(10 * time.Second).Do(req)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	defer closeBody(resp.Body, "show response body")
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(resp.Body)
+		return ModelInfo{}, fmt.Errorf("ollama show endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var show struct {
+		Template string `json:"template"`
+		Details  struct {
+			Family        string `json:"family"`
+			ParameterSize string `json:"parameter_size"`
+		} `json:"details"`
+		// ModelInfo keys are prefixed with the architecture, e.g.
+		// "gemma3.context_length".
+		ModelInfo map[string]interface{} `json:"model_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return ModelInfo{}, fmt.Errorf("failed to decode model details: %w", err)
+	}
+	info := ModelInfo{ParameterSize: show.Details.ParameterSize, Family: show.Details.Family, Template: show.Template}
+	for k, v := range show.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(k, ".context_length") {
+			info.ContextLength = int(n)
+		}
+	}
+	return info, nil
+}
+
+// CheckLoopback returns an error unless every address the URL's host
+// resolves to is a loopback address.
+func CheckLoopback(rawURL string) error {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid ollama URL: %w", err)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("local-only: URL %q has no host", rawURL)
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		if ips, err = net.LookupIP(host); err != nil {
+			return fmt.Errorf("local-only: cannot resolve %s: %w", host, err)
+		}
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("local-only: %s does not resolve to any address", host)
+	}
+	for _, ip := range ips {
+		if !ip.IsLoopback() {
+			return fmt.Errorf("local-only: %s resolves to non-loopback address %s; refusing to send data", host, ip)
+		}
+	}
+	return nil
+}
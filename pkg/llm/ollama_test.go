@@ -0,0 +1,204 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/llm/llmtest"
+)
+
+func TestOllamaGenerate(t *testing.T) {
+	srv := llmtest.NewServer(func(req llm.Request) string {
+		return "```\nAdd " + req.Model + "\n```"
+	})
+	defer srv.Close()
+
+	client := &llm.Ollama{URL: srv.GenerateURL(), APIKey: "k3y", Timeout: 5 * time.Second}
+	got, err := client.Generate(llm.WithRequestID(context.Background(), "run-1"), llm.Request{Model: "tiny", Prompt: "p"})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got != "Add tiny" {
+		t.Errorf("Generate = %q, want %q", got, "Add tiny")
+	}
+	if h := srv.Headers()[0].Get("Authorization"); h != "Bearer k3y" {
+		t.Errorf("Authorization = %q, want bearer token", h)
+	}
+	if h := srv.Headers()[0].Get(llm.RequestIDHeader); h != "run-1" {
+		t.Errorf("%s = %q, want run-1", llm.RequestIDHeader, h)
+	}
+	if reqs := srv.Requests(); len(reqs) != 1 || reqs[0].Prompt != "p" || reqs[0].Think == nil || *reqs[0].Think {
+		t.Errorf("requests = %+v", reqs)
+	}
+}
+
+func TestOllamaGenerateLogprob(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Logprobs {
+			t.Errorf("request = %+v, %v; want logprobs", req, err)
+		}
+		fmt.Fprintln(w, `{"response":"Add ","logprobs":[{"token":"Add","logprob":-0.5},{"token":" ","logprob":-0.1}]}`)
+		fmt.Fprintln(w, `{"response":"tests","done":true,"logprobs":[{"token":"tests","logprob":-0.3}]}`)
+	}))
+	defer srv.Close()
+
+	client := &llm.Ollama{URL: srv.URL, Timeout: 5 * time.Second}
+	out, mean, ok, err := client.GenerateLogprob(context.Background(), llm.Request{Model: "m", Logprobs: true})
+	if err != nil || out != "Add tests" || !ok || mean < -0.30001 || mean > -0.29999 {
+		t.Errorf("GenerateLogprob = %q, %v, %v, %v; want mean -0.3", out, mean, ok, err)
+	}
+}
+
+func TestOllamaGenerateStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !req.Stream {
+			t.Errorf("request = %+v, %v; want stream", req, err)
+		}
+		fmt.Fprintln(w, `{"response":"Title: Add tests\n"}`)
+		fmt.Fprintln(w, `{"response":"\nBody: Cover the parser."}`)
+		fmt.Fprintln(w, `{"response":"","done":true}`)
+	}))
+	defer srv.Close()
+
+	client := &llm.Ollama{URL: srv.URL, Timeout: 5 * time.Second}
+	var partials []string
+	out, err := client.GenerateStream(context.Background(), llm.Request{Model: "m"}, func(p string) { partials = append(partials, p) })
+	want := []string{"Title: Add tests\n", "Title: Add tests\n\nBody: Cover the parser."}
+	if err != nil || out != want[1] || !reflect.DeepEqual(partials, want) {
+		t.Errorf("GenerateStream = %q, %v; partials %q", out, err, partials)
+	}
+}
+
+func TestOllamaGenerateErrorStatus(t *testing.T) {
+	srv := llmtest.NewServer(func(llm.Request) string { return "" })
+	defer srv.Close()
+	srv.Status = 500
+
+	client := &llm.Ollama{URL: srv.GenerateURL(), Timeout: 5 * time.Second}
+	_, err := client.Generate(context.Background(), llm.Request{Model: "m"})
+	if err == nil || !strings.Contains(err.Error(), "status=500") {
+		t.Fatalf("Generate error = %v, want status=500", err)
+	}
+}
+
+func TestOllamaCheck(t *testing.T) {
+	srv := llmtest.NewServer(func(llm.Request) string { return "" })
+	client := &llm.Ollama{URL: srv.GenerateURL()}
+	if err := client.Check(context.Background()); err != nil {
+		t.Fatalf("Check on running server: %v", err)
+	}
+	srv.Close()
+	if err := client.Check(context.Background()); err == nil {
+		t.Fatal("Check on stopped server succeeded")
+	}
+}
+
+func TestOllamaReusesConnection(t *testing.T) {
+	var mu sync.Mutex
+	conns := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tags" {
+			fmt.Fprint(w, `{"models":[{"name":"tiny"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"response":"Add tiny","done":true}`+"\n")
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := &llm.Ollama{URL: srv.URL + "/api/generate", Timeout: 5 * time.Second, LoopbackOnly: true}
+	if err := client.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Generate(context.Background(), llm.Request{Model: "tiny"}); err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("check and two generate calls opened %d connections, want 1", conns)
+	}
+}
+
+func TestCheckLoopback(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"http://localhost:11434/api/generate", false},
+		{"http://127.0.0.1:11434/api/generate", false},
+		{"http://[::1]:11434/api/generate", false},
+		{"http://10.1.2.3:11434/api/generate", true},
+		{"http:///api/generate", true},
+	}
+	for _, tt := range tests {
+		err := llm.CheckLoopback(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckLoopback(%q) = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+func TestOllamaListModels(t *testing.T) {
+	srv := llmtest.NewServer(func(llm.Request) string { return "" })
+	defer srv.Close()
+	srv.Models = []string{"gemma3:4B", "mistral:7b"}
+
+	got, err := (&llm.Ollama{URL: srv.GenerateURL()}).ListModels(context.Background())
+	if err != nil || strings.Join(got, ",") != "gemma3:4B,mistral:7b" {
+		t.Errorf("ListModels = %v, %v", got, err)
+	}
+}
+
+func TestOllamaShow(t *testing.T) {
+	srv := llmtest.NewServer(func(llm.Request) string { return "" })
+	defer srv.Close()
+	want := llm.ModelInfo{ContextLength: 131072, ParameterSize: "4.3B", Family: "gemma3", Template: "<start_of_turn>user\n{{ .Prompt }}<end_of_turn>"}
+	srv.Info = map[string]llm.ModelInfo{"gemma3:4b": want}
+
+	o := &llm.Ollama{URL: srv.GenerateURL()}
+	got, err := o.Show(context.Background(), "gemma3:4b")
+	if err != nil || got != want {
+		t.Fatalf("Show = %+v, %v; want %+v", got, err, want)
+	}
+	if got.Parameters() != 4.3 || !got.Instruct() {
+		t.Errorf("Parameters = %v, Instruct = %v", got.Parameters(), got.Instruct())
+	}
+	if _, err := o.Show(context.Background(), "missing"); err == nil {
+		t.Error("Show of a missing model succeeded")
+	}
+}
+
+func TestModelInfo(t *testing.T) {
+	for size, want := range map[string]float64{"7B": 7, "270M": 0.27, "": 0, "big": 0} {
+		if got := (llm.ModelInfo{ParameterSize: size}).Parameters(); got != want {
+			t.Errorf("Parameters(%q) = %v, want %v", size, got, want)
+		}
+	}
+	for tmpl, want := range map[string]bool{"": false, "{{ .Prompt }}": false, "{{.Prompt}}\n": false, "[INST] {{ .Prompt }} [/INST]": true} {
+		if got := (llm.ModelInfo{Template: tmpl}).Instruct(); got != want {
+			t.Errorf("Instruct(%q) = %v, want %v", tmpl, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package middleware runs user-defined shell commands that rewrite an
+// artifact (the diff, the summary or the final message) at fixed points in
+// the pipeline. Each command gets the artifact on stdin and its stdout
+// replaces it.
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Hook points.
+const (
+	AfterDiff    = "after_diff"
+	AfterSummary = "after_summary"
+	BeforeWrite  = "before_write"
+)
+
+// Points lists the valid hook points in pipeline order.
+var Points = []string{AfterDiff, AfterSummary, BeforeWrite}
+
+// Hooks maps a hook point to the commands run there, in order.
+type Hooks map[string][]string
+
+// Validate rejects unknown hook points.
+func (h Hooks) Validate() error {
+	for point := range h {
+		known := false
+		for _, p := range Points {
+			known = known || p == point
+		}
+		if !known {
+			return fmt.Errorf("unknown middleware point %q (want one of %s)", point, strings.Join(Points, ", "))
+		}
+	}
+	return nil
+}
+
+// Run pipes artifact through every command registered at point. Commands
+// run through the shell with COMMIT_WRITER_HOOK set to the point name; a
+// non-zero exit aborts the run.
+func (h Hooks) Run(ctx context.Context, point, artifact string) (string, error) {
+	for _, command := range h[point] {
+		var out bytes.Buffer
+		cmd := shell(ctx, command)
+		cmd.Stdin = strings.NewReader(artifact)
+		cmd.Stdout = &out
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), "COMMIT_WRITER_HOOK="+point)
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s hook %q failed: %w", point, command, err)
+		}
+		artifact = out.String()
+	}
+	return artifact, nil
+}
+
+func shell(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
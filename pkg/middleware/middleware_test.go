@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHooksRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test commands use a POSIX shell")
+	}
+	h := Hooks{
+		AfterDiff:   {"sed 's/secret/[internal]/'", `tr a-z A-Z; echo "$COMMIT_WRITER_HOOK"`},
+		BeforeWrite: {"exit 3"},
+	}
+	got, err := h.Run(context.Background(), AfterDiff, "a secret\n")
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got != "A [INTERNAL]\nafter_diff\n" {
+		t.Errorf("Run = %q", got)
+	}
+
+	if got, err := h.Run(context.Background(), AfterSummary, "unchanged"); err != nil || got != "unchanged" {
+		t.Errorf("Run with no hooks = %q, %v", got, err)
+	}
+	if _, err := h.Run(context.Background(), BeforeWrite, "msg"); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("failing hook error = %v", err)
+	}
+}
+
+func TestHooksValidate(t *testing.T) {
+	if err := (Hooks{AfterSummary: {"cat"}}).Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	if err := (Hooks{"after_style": {"cat"}}).Validate(); err == nil {
+		t.Error("Validate accepted unknown point")
+	}
+}
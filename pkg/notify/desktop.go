@@ -0,0 +1,53 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Desktop shows a desktop notification: with osascript on macOS, a
+// PowerShell balloon tip on Windows and notify-send elsewhere.
+func Desktop(title, body string) error {
+	args := desktopCommand(runtime.GOOS, title, body)
+	cmd := exec.Command(args[0], args[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("desktop notification via %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// desktopCommand returns the notification command for goos, with title and
+// body quoted for the script languages that need it.
+func desktopCommand(goos, title, body string) []string {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleString(body), appleString(title))
+		return []string{"osascript", "-e", script}
+	case "windows":
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; " +
+			fmt.Sprintf("$n.ShowBalloonTip(10000, %s, %s, 'Info'); ", psString(title), psString(body)) +
+			"Start-Sleep -Seconds 5; $n.Dispose()"
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command", script}
+	}
+	return []string{"notify-send", "--app-name=commit-writer", title, body}
+}
+
+// appleString quotes s as an AppleScript string literal.
+func appleString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// psString quotes s as a single-quoted PowerShell string literal.
+func psString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
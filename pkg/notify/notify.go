@@ -0,0 +1,72 @@
+// Package notify posts generated commit messages to a chat webhook and
+// shows desktop notifications.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Event describes one generated message.
+type Event struct {
+	Repo    string `json:"repo"`
+	Branch  string `json:"branch"`
+	Message string `json:"message"`
+}
+
+// payload is a Slack incoming-webhook message; the event fields are sent
+// alongside "text" for other receivers.
+type payload struct {
+	Text string `json:"text"`
+	Event
+}
+
+// Webhook posts events to a Slack-compatible incoming webhook.
+type Webhook struct {
+	URL string
+	// Client defaults to an HTTP client with a 10 second timeout.
+	Client *http.Client
+}
+
+// Text renders the Slack text for e: the repository and branch, then the
+// message as a code block.
+func (e Event) Text() string {
+	where := e.Repo
+	if e.Branch != "" {
+		where += " (" + e.Branch + ")"
+	}
+	return fmt.Sprintf("New commit message in *%s*:\n```\n%s\n```", where, strings.ReplaceAll(e.Message, "```", "'''"))
+}
+
+// Post sends e to the webhook.
+func (w *Webhook) Post(ctx context.Context, e Event) error {
+	b, err := json.Marshal(payload{Text: e.Text(), Event: e})
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookPost(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	e := Event{Repo: "commit-writer", Branch: "main", Message: "Add login\n\nDetails"}
+	if err := (&Webhook{URL: srv.URL}).Post(context.Background(), e); err != nil {
+		t.Fatalf("Post: %v", err)
+	}
+	if got["repo"] != "commit-writer" || got["branch"] != "main" || got["message"] != e.Message {
+		t.Errorf("payload = %v", got)
+	}
+	if !strings.Contains(got["text"], "*commit-writer (main)*") || !strings.Contains(got["text"], "```\nAdd login\n\nDetails\n```") {
+		t.Errorf("text = %q", got["text"])
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("invalid_token"))
+	}))
+	defer srv.Close()
+
+	err := (&Webhook{URL: srv.URL}).Post(context.Background(), Event{Repo: "r"})
+	if err == nil || !strings.Contains(err.Error(), "status=403 body=invalid_token") {
+		t.Errorf("Post = %v", err)
+	}
+}
+
+func TestDesktopCommand(t *testing.T) {
+	title, body := `commit-writer: it's "done"`, `Add C:\tmp`
+	if got := desktopCommand("darwin", title, body); got[2] != `display notification "Add C:\\tmp" with title "commit-writer: it's \"done\""` {
+		t.Errorf("darwin script = %s", got[2])
+	}
+	if got := desktopCommand("windows", title, body)[4]; !strings.Contains(got, `ShowBalloonTip(10000, 'commit-writer: it''s "done"', 'Add C:\tmp', 'Info')`) {
+		t.Errorf("windows script = %s", got)
+	}
+	if got := desktopCommand("linux", title, body); got[len(got)-2] != title || got[len(got)-1] != body {
+		t.Errorf("linux command = %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+// Package plugin runs external commit-writer plugins. A plugin is any
+// executable named commit-writer-<name> on PATH (like git subcommands). It is
+// started once per call with its role as the only argument, reads one JSON
+// object from stdin and writes one JSON object to stdout:
+//
+//	provider     {"type":"check"}                                   -> {}
+//	             {"type":"generate","model","prompt","options"}      -> {"response":"..."}
+//	validate     {"type":"validate","message":"..."}                -> {"problems":["..."]}
+//	postprocess  {"type":"postprocess","message":"..."}             -> {"message":"..."}
+//
+// Any response may set "error" to fail the call. Stderr is passed through.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+)
+
+// Prefix is prepended to a plugin name to get its executable name.
+const Prefix = "commit-writer-"
+
+// Request is the JSON object sent to a plugin on stdin.
+type Request struct {
+	Type      string                 `json:"type"`
+	Model     string                 `json:"model,omitempty"`
+	Prompt    string                 `json:"prompt,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+}
+
+// Response is the JSON object a plugin writes to stdout.
+type Response struct {
+	Response string   `json:"response,omitempty"`
+	Message  string   `json:"message,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Find returns the path of the commit-writer-<name> executable on PATH.
+func Find(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid plugin name %q", name)
+	}
+	p, err := exec.LookPath(Prefix + name)
+	if err != nil {
+		return "", fmt.Errorf("plugin %q not found: no %s%s on PATH", name, Prefix, name)
+	}
+	return p, nil
+}
+
+// Call runs the plugin at path in the given role with req on stdin.
+func Call(ctx context.Context, path, role string, req Request) (Response, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, role)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return Response{}, fmt.Errorf("plugin %s %s failed: %w", path, role, err)
+	}
+	var resp Response
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &resp); err != nil {
+		return Response{}, fmt.Errorf("plugin %s %s returned invalid JSON: %w", path, role, err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("plugin %s %s: %s", path, role, resp.Error)
+	}
+	return resp, nil
+}
+
+// Provider is an llm.Client backed by a provider plugin.
+type Provider struct {
+	Path string
+	// Timeout bounds each generate call.
+	Timeout time.Duration
+}
+
+// Generate asks the plugin to complete req.
+func (p *Provider) Generate(ctx context.Context, req llm.Request) (string, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	resp, err := Call(ctx, p.Path, "provider", Request{Type: "generate", Model: req.Model, Prompt: req.Prompt, Options: req.Options, RequestID: llm.RequestID(ctx)})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Response), nil
+}
+
+// Check asks the plugin whether its backend is reachable.
+func (p *Provider) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err := Call(ctx, p.Path, "provider", Request{Type: "check", RequestID: llm.RequestID(ctx)})
+	return err
+}
+
+// Validate runs a validator plugin on msg and returns an error listing the
+// problems it reported, if any.
+func Validate(ctx context.Context, path, msg string) error {
+	resp, err := Call(ctx, path, "validate", Request{Type: "validate", Message: msg})
+	if err != nil {
+		return err
+	}
+	if len(resp.Problems) > 0 {
+		return errors.New(strings.Join(resp.Problems, "; "))
+	}
+	return nil
+}
+
+// PostProcess runs a post-processor plugin on msg and returns its rewrite.
+func PostProcess(ctx context.Context, path, msg string) (string, error) {
+	resp, err := Call(ctx, path, "postprocess", Request{Type: "postprocess", Message: msg})
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(resp.Message) == "" {
+		return "", fmt.Errorf("plugin %s postprocess returned an empty message", path)
+	}
+	return strings.TrimSpace(resp.Message), nil
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+)
+
+// installPlugin writes a shell script plugin to a temp dir and puts that
+// dir first on PATH.
+func installPlugin(t *testing.T, name, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins need a POSIX shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, Prefix+name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestFind(t *testing.T) {
+	installPlugin(t, "echo", "cat\n")
+	if _, err := Find("echo"); err != nil {
+		t.Errorf("Find(echo): %v", err)
+	}
+	for _, name := range []string{"missing-plugin", "", "../echo"} {
+		if _, err := Find(name); err == nil {
+			t.Errorf("Find(%q) succeeded", name)
+		}
+	}
+}
+
+func TestProvider(t *testing.T) {
+	installPlugin(t, "fake", `
+read req
+case "$1:$req" in
+provider:*'"type":"check"'*) echo '{}' ;;
+provider:*'"model":"m"'*) echo '{"response":"  Add thing  "}' ;;
+*) echo '{"error":"unknown model"}' ;;
+esac
+`)
+	path, err := Find("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{Path: path}
+	if err := p.Check(context.Background()); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	got, err := p.Generate(context.Background(), llm.Request{Model: "m", Prompt: "p"})
+	if err != nil || got != "Add thing" {
+		t.Errorf("Generate = %q, %v", got, err)
+	}
+	if _, err := p.Generate(context.Background(), llm.Request{Model: "other"}); err == nil || !strings.Contains(err.Error(), "unknown model") {
+		t.Errorf("Generate with plugin error = %v", err)
+	}
+}
+
+func TestValidateAndPostProcess(t *testing.T) {
+	installPlugin(t, "lint", `
+read req
+case "$1:$req" in
+validate:*WIP*) echo '{"problems":["WIP commits are not allowed"]}' ;;
+validate:*) echo '{}' ;;
+postprocess:*) echo '{"message":"JIRA-1 Add thing"}' ;;
+esac
+`)
+	path, _ := Find("lint")
+	ctx := context.Background()
+
+	if err := Validate(ctx, path, "Add thing"); err != nil {
+		t.Errorf("Validate(ok) = %v", err)
+	}
+	if err := Validate(ctx, path, "WIP thing"); err == nil || err.Error() != "WIP commits are not allowed" {
+		t.Errorf("Validate(WIP) = %v", err)
+	}
+	if got, err := PostProcess(ctx, path, "Add thing"); err != nil || got != "JIRA-1 Add thing" {
+		t.Errorf("PostProcess = %q, %v", got, err)
+	}
+}
+
+func TestCallInvalidOutput(t *testing.T) {
+	installPlugin(t, "broken", "echo not json\n")
+	path, _ := Find("broken")
+	if _, err := Call(context.Background(), path, "validate", Request{Type: "validate"}); err == nil {
+		t.Fatal("Call accepted non-JSON output")
+	}
+}
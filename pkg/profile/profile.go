@@ -0,0 +1,417 @@
+// Package profile learns a repository's commit message style from its
+// history, such as subject length, type prefixes and emoji, so generated
+// messages read like the rest of the log.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kylegalloway/commit-writer/pkg/changelog"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/lint"
+	"github.com/kylegalloway/commit-writer/pkg/tracker"
+)
+
+// File is where "commit-writer learn" writes the profile, relative to the
+// repository root, so it can be committed and shared.
+const File = ".commit-writer/style.json"
+
+// MinCommits is the fewest commits a profile is built from; below that the
+// proportions say little.
+const MinCommits = 10
+
+// maxTypes bounds how many type prefixes a profile lists.
+const maxTypes = 8
+
+// maxScopes bounds how many scopes a profile lists.
+const maxScopes = 30
+
+// maxWrap is the widest body wrap width taken as deliberate; wider bodies
+// are treated as unwrapped.
+const maxWrap = 100
+
+// Profile describes the commit messages of a repository. Shares are
+// fractions of the analyzed commits, from 0 to 1.
+type Profile struct {
+	// Commits is how many commit messages were analyzed.
+	Commits int `json:"commits"`
+	// SubjectLength is the distribution of subject lengths in characters.
+	SubjectLength Lengths `json:"subject_length"`
+	// Conventional is the share of subjects with a type prefix such as
+	// "feat: " or "fix(api)!: ".
+	Conventional float64 `json:"conventional"`
+	// Types are the type prefixes in use, most frequent first.
+	Types []Count `json:"types,omitempty"`
+	// Scopes are the scopes used by more than one commit, most frequent
+	// first.
+	Scopes []Count `json:"scopes,omitempty"`
+	// Ticket is the share of subjects naming an issue key such as
+	// "PROJ-123".
+	Ticket float64 `json:"ticket"`
+	// TicketExample is a subject with an issue key, which shows where the
+	// key goes.
+	TicketExample string `json:"ticket_example,omitempty"`
+	// Emoji is the share of subjects with an emoji or a :shortcode:.
+	Emoji float64 `json:"emoji"`
+	// Shortcodes is set when most emoji are written as :shortcode:, as
+	// gitmoji does.
+	Shortcodes bool `json:"shortcodes,omitempty"`
+	// Body is the share of messages with a body.
+	Body float64 `json:"body"`
+	// Wrap is the column bodies are wrapped at, or 0 when they are not
+	// wrapped consistently.
+	Wrap int `json:"wrap,omitempty"`
+	// Capitalized is the share of subjects whose description, after any
+	// type prefix or emoji, starts with an upper-case letter.
+	Capitalized float64 `json:"capitalized"`
+	// TrailingPeriod is the share of subjects ending in a period.
+	TrailingPeriod float64 `json:"trailing_period"`
+}
+
+// Lengths summarizes a length distribution.
+type Lengths struct {
+	Median int `json:"median"`
+	P90    int `json:"p90"`
+	Max    int `json:"max"`
+}
+
+// Count is how often a value occurs.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// shortcodeRe matches a gitmoji-style :shortcode:.
+var shortcodeRe = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// leadingEmojiRe matches emoji and shortcodes at the start of a subject.
+var leadingEmojiRe = regexp.MustCompile(`^(\s*(:[a-z0-9_+-]+:|[\p{So}\x{FE0F}\x{200D}]+))+\s*`)
+
+// Build returns the profile of commits. Merge commits should already be
+// left out, as their messages are written by git.
+func Build(commits []gitdiff.Commit) Profile {
+	p := Profile{Commits: len(commits)}
+	if len(commits) == 0 {
+		return p
+	}
+	var lengths, widths []int
+	types, scopes := map[string]int{}, map[string]int{}
+	var conventional, emoji, shortcodes, body, capitalized, period, ticket int
+	for _, c := range commits {
+		subject := strings.TrimSpace(c.Subject())
+		lengths = append(lengths, utf8.RuneCountInString(subject))
+		if hasEmoji(subject) {
+			emoji++
+			if shortcodeRe.MatchString(subject) {
+				shortcodes++
+			}
+		}
+		e := changelog.Parse(gitdiff.Commit{Message: leadingEmojiRe.ReplaceAllString(strings.TrimSpace(c.Message), "")})
+		if e.Type != "" {
+			conventional++
+			types[e.Type]++
+			if e.Scope != "" {
+				scopes[e.Scope]++
+			}
+		}
+		if e.Body != "" || e.Footer != "" {
+			body++
+		}
+		if e.Body != "" {
+			widths = append(widths, width(e.Body))
+		}
+		if _, ok := tracker.FindKey(subject, nil); ok {
+			if ticket == 0 {
+				p.TicketExample = subject
+			}
+			ticket++
+		}
+		if r, _ := utf8.DecodeRuneInString(e.Subject); unicode.IsUpper(r) {
+			capitalized++
+		}
+		if strings.HasSuffix(subject, ".") {
+			period++
+		}
+	}
+	n := float64(len(commits))
+	p.SubjectLength = distribution(lengths)
+	p.Conventional = share(conventional, n)
+	p.Emoji = share(emoji, n)
+	p.Shortcodes = shortcodes*2 > emoji
+	p.Body = share(body, n)
+	p.Capitalized = share(capitalized, n)
+	p.TrailingPeriod = share(period, n)
+	p.Ticket = share(ticket, n)
+	// A few long lines, such as URLs, do not make a body unwrapped, but a
+	// handful of bodies is too few to tell.
+	if len(widths) >= 3 {
+		if w := distribution(widths).P90; w <= maxWrap {
+			p.Wrap = w
+		}
+	}
+	p.Types = counts(types, 1, maxTypes)
+	p.Scopes = counts(scopes, 2, maxScopes)
+	return p
+}
+
+// counts lists the values seen at least min times, most frequent first and
+// at most max of them.
+func counts(seen map[string]int, min, max int) []Count {
+	var cs []Count
+	for name, count := range seen {
+		if count >= min {
+			cs = append(cs, Count{Name: name, Count: count})
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if cs[i].Count != cs[j].Count {
+			return cs[i].Count > cs[j].Count
+		}
+		return cs[i].Name < cs[j].Name
+	})
+	if len(cs) > max {
+		cs = cs[:max]
+	}
+	return cs
+}
+
+// width returns the length of the longest prose line of body. Indented
+// lines, such as code, and lines with URLs are not wrapped by hand and are
+// skipped.
+func width(body string) int {
+	w := 0
+	for _, line := range strings.Split(body, "\n") {
+		if unwrappable(line) {
+			continue
+		}
+		if n := utf8.RuneCountInString(line); n > w {
+			w = n
+		}
+	}
+	return w
+}
+
+func unwrappable(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.Contains(line, "://")
+}
+
+// hasEmoji reports whether s holds a pictographic symbol or a :shortcode:.
+func hasEmoji(s string) bool {
+	if shortcodeRe.MatchString(s) {
+		return true
+	}
+	for _, r := range s {
+		if unicode.Is(unicode.So, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// share rounds count/n to two decimals, which is all a profile needs and
+// keeps the file readable.
+func share(count int, n float64) float64 {
+	return float64(int(float64(count)/n*100+0.5)) / 100
+}
+
+func distribution(lengths []int) Lengths {
+	sort.Ints(lengths)
+	return Lengths{
+		Median: lengths[len(lengths)/2],
+		P90:    lengths[(len(lengths)*9)/10],
+		Max:    lengths[len(lengths)-1],
+	}
+}
+
+// UsesTypes reports whether the repository's subjects mostly carry a type
+// prefix. Without a profile commit-writer adds one to single-purpose
+// changes, such as "docs:"; a profile that rarely uses them turns that off.
+func (p Profile) UsesTypes() bool {
+	return p.Conventional >= 0.5
+}
+
+// Instructions tells the models how to write a message in the profile's
+// style, one convention per line. Conventions the history is split on are
+// left out.
+func (p Profile) Instructions() string {
+	if p.Commits == 0 {
+		return ""
+	}
+	var rules []string
+	add := func(format string, args ...interface{}) {
+		rules = append(rules, "- "+fmt.Sprintf(format, args...))
+	}
+	add("Keep the title to %d characters or fewer; most are about %d.", p.SubjectLength.P90, p.SubjectLength.Median)
+	switch {
+	case p.Conventional >= 0.6 && len(p.Types) > 0:
+		names := make([]string, len(p.Types))
+		for i, t := range p.Types {
+			names[i] = t.Name
+		}
+		add("Start the title with a Conventional Commits type and optional scope, e.g. \"%s: \" or \"%s(scope): \"; types in use: %s.", names[0], names[0], strings.Join(names, ", "))
+		if len(p.Scopes) > 0 {
+			add("If you use a scope, pick one of: %s.", strings.Join(p.scopeNames(), ", "))
+		}
+	case p.Conventional <= 0.2:
+		add("Do not start the title with a type prefix such as \"feat:\" or \"fix:\".")
+	}
+	if p.Ticket >= 0.5 {
+		add("Include the issue key (e.g. PROJ-123) in the title, placed as in %q.", p.TicketExample)
+	}
+	switch {
+	case p.Emoji >= 0.5 && p.Shortcodes:
+		add("Start the title with a fitting gitmoji written as a :shortcode:, e.g. :bug: or :sparkles:.")
+	case p.Emoji >= 0.5:
+		add("Start the title with a fitting emoji.")
+	case p.Emoji <= 0.05:
+		add("Do not use emoji.")
+	}
+	switch {
+	case p.Capitalized >= 0.8:
+		add("Capitalize the first word of the title's description.")
+	case p.Capitalized <= 0.2:
+		add("Start the title's description in lower case.")
+	}
+	switch {
+	case p.TrailingPeriod <= 0.2:
+		add("Do not end the title with a period.")
+	case p.TrailingPeriod >= 0.8:
+		add("End the title with a period.")
+	}
+	switch {
+	case p.Body >= 0.7:
+		add("Always write a body.")
+	case p.Body <= 0.3:
+		add("Keep the body short; most commits here have none.")
+	}
+	if p.Wrap > 0 {
+		add("Wrap body lines at %d characters.", p.Wrap)
+	}
+	return strings.Join(rules, "\n")
+}
+
+func (p Profile) scopeNames() []string {
+	names := make([]string, len(p.Scopes))
+	for i, s := range p.Scopes {
+		names[i] = s.Name
+	}
+	return names
+}
+
+// Check returns where msg departs from the conventions Instructions asks
+// for, for --strict. Like lint.Rules.Check it ignores "#" comment lines.
+func (p Profile) Check(msg string) []lint.Problem {
+	if p.Commits == 0 {
+		return nil
+	}
+	var lines []string
+	for _, l := range strings.Split(strings.ReplaceAll(strings.TrimSpace(msg), "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(l, "#") {
+			lines = append(lines, strings.TrimRight(l, " \t"))
+		}
+	}
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) == "" {
+		return []lint.Problem{{Rule: "subject-empty", Line: 1, Message: "subject line is empty"}}
+	}
+	subject := strings.TrimSpace(lines[0])
+	var problems []lint.Problem
+	fail := func(rule string, line int, format string, args ...interface{}) {
+		problems = append(problems, lint.Problem{Rule: rule, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if n := utf8.RuneCountInString(subject); n > p.SubjectLength.P90 {
+		fail("subject-length", 1, "subject is %d characters, the profile's limit is %d", n, p.SubjectLength.P90)
+	}
+	e := changelog.Parse(gitdiff.Commit{Message: leadingEmojiRe.ReplaceAllString(subject, "")})
+	switch {
+	case p.Conventional >= 0.6 && len(p.Types) > 0 && e.Type == "":
+		fail("type-missing", 1, "subject has no type prefix")
+	case p.Conventional <= 0.2 && e.Type != "":
+		fail("type-unexpected", 1, "subject has a type prefix %q", e.Type+":")
+	}
+	// A capped list may leave out scopes that are in use.
+	if e.Scope != "" && p.Conventional >= 0.6 && len(p.Scopes) > 0 && len(p.Scopes) < maxScopes && !p.hasScope(e.Scope) {
+		fail("scope-unknown", 1, "scope %q is not one of %s", e.Scope, strings.Join(p.scopeNames(), ", "))
+	}
+	if _, ok := tracker.FindKey(subject, nil); p.Ticket >= 0.5 && !ok {
+		fail("ticket-missing", 1, "subject has no issue key")
+	}
+	switch {
+	case p.Emoji >= 0.5 && p.Shortcodes && !shortcodeRe.MatchString(subject):
+		fail("emoji-missing", 1, "subject has no :shortcode: emoji")
+	case p.Emoji >= 0.5 && !hasEmoji(subject):
+		fail("emoji-missing", 1, "subject has no emoji")
+	case p.Emoji <= 0.05 && hasEmoji(subject):
+		fail("emoji-unexpected", 1, "subject has an emoji")
+	}
+	r, _ := utf8.DecodeRuneInString(e.Subject)
+	switch {
+	case p.Capitalized >= 0.8 && unicode.IsLower(r):
+		fail("subject-case", 1, "subject's description starts in lower case")
+	case p.Capitalized <= 0.2 && unicode.IsUpper(r):
+		fail("subject-case", 1, "subject's description starts in upper case")
+	}
+	switch {
+	case p.TrailingPeriod <= 0.2 && strings.HasSuffix(subject, "."):
+		fail("subject-period", 1, "subject ends with a period")
+	case p.TrailingPeriod >= 0.8 && !strings.HasSuffix(subject, "."):
+		fail("subject-period", 1, "subject does not end with a period")
+	}
+
+	hasBody := false
+	for i, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		hasBody = true
+		if n := utf8.RuneCountInString(line); p.Wrap > 0 && n > p.Wrap && !unwrappable(line) {
+			fail("body-line-length", i+2, "line is %d characters, the profile wraps at %d", n, p.Wrap)
+		}
+	}
+	if p.Body >= 0.7 && !hasBody {
+		fail("body-missing", 0, "message has no body")
+	}
+	return problems
+}
+
+func (p Profile) hasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if strings.EqualFold(s.Name, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads a profile written by Save.
+func Load(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read style profile: %w", err)
+	}
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse style profile %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Save writes the profile as indented JSON, creating its directory.
+func (p Profile) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,122 @@
+package profile
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func commits(messages ...string) []gitdiff.Commit {
+	cs := make([]gitdiff.Commit, len(messages))
+	for i, m := range messages {
+		cs[i] = gitdiff.Commit{Message: m}
+	}
+	return cs
+}
+
+func TestBuild(t *testing.T) {
+	p := Build(commits(
+		":bug: fix(api): handle empty carts",
+		":sparkles: feat: add coupons\n\nCoupons apply before tax.",
+		"feat(ui): show totals",
+		"Update README.",
+	))
+	if p.Commits != 4 || p.Conventional != 0.75 || p.Emoji != 0.5 || !p.Shortcodes || p.Body != 0.25 || p.Capitalized != 0.25 || p.TrailingPeriod != 0.25 {
+		t.Errorf("Build = %+v", p)
+	}
+	if want := []Count{{"feat", 2}, {"fix", 1}}; !reflect.DeepEqual(p.Types, want) {
+		t.Errorf("Types = %+v, want %+v", p.Types, want)
+	}
+	if want := (Lengths{Median: 28, P90: 34, Max: 34}); p.SubjectLength != want {
+		t.Errorf("SubjectLength = %+v, want %+v", p.SubjectLength, want)
+	}
+	if !p.UsesTypes() {
+		t.Error("UsesTypes = false")
+	}
+}
+
+func TestBuildConventions(t *testing.T) {
+	body := "\n\nThe cart total ignored coupons applied after tax, so\nthe checkout page showed the wrong amount.\n    indented code lines are not wrapped by hand at all, so they are skipped"
+	p := Build(commits(
+		"fix(cart): PROJ-1 Apply coupons"+body,
+		"fix(cart): PROJ-2 Round totals"+body,
+		"feat(ui): PROJ-3 Show totals"+body,
+		"feat(api): Add coupons",
+	))
+	if want := []Count{{"cart", 2}}; !reflect.DeepEqual(p.Scopes, want) {
+		t.Errorf("Scopes = %+v, want %+v", p.Scopes, want)
+	}
+	if p.Ticket != 0.75 || p.TicketExample != "fix(cart): PROJ-1 Apply coupons" {
+		t.Errorf("Ticket = %v, %q", p.Ticket, p.TicketExample)
+	}
+	if p.Wrap != 52 {
+		t.Errorf("Wrap = %d, want 52", p.Wrap)
+	}
+	// Two bodies are too few to tell a wrap width.
+	if p := Build(commits("a"+body, "b"+body, "c")); p.Wrap != 0 {
+		t.Errorf("Wrap from two bodies = %d", p.Wrap)
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	p := Profile{Commits: 50, SubjectLength: Lengths{Median: 41, P90: 58, Max: 70}, Conventional: 0.9, Types: []Count{{"fix", 20}, {"feat", 15}}, Emoji: 0.8, Shortcodes: true, Capitalized: 0.5, TrailingPeriod: 0, Body: 0.5}
+	got := p.Instructions()
+	for _, want := range []string{
+		"- Keep the title to 58 characters or fewer; most are about 41.",
+		`e.g. "fix: " or "fix(scope): "; types in use: fix, feat.`,
+		"as a :shortcode:",
+		"- Do not end the title with a period.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Instructions missing %q:\n%s", want, got)
+		}
+	}
+	// Split conventions are left to the model.
+	for _, unwanted := range []string{"Capitalize", "lower case", "body"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Instructions mention %q:\n%s", unwanted, got)
+		}
+	}
+	if (Profile{}).Instructions() != "" {
+		t.Error("empty profile should have no instructions")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".commit-writer", "style.json")
+	p := Profile{Commits: 12, SubjectLength: Lengths{Median: 30, P90: 50, Max: 60}, Conventional: 0.1, Body: 0.4}
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Load(path)
+	if err != nil || !reflect.DeepEqual(*got, p) {
+		t.Errorf("Load = %+v, %v", got, err)
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := Profile{Commits: 50, SubjectLength: Lengths{Median: 30, P90: 40, Max: 60}, Conventional: 0.9, Types: []Count{{"fix", 30}}, Scopes: []Count{{"cart", 10}}, Ticket: 0.8, Capitalized: 0.9, Wrap: 20}
+	if got := p.Check("fix(cart): PROJ-1 Apply coupons\n\nCoupons apply first.\n# comment that is much longer than twenty"); len(got) != 0 {
+		t.Errorf("Check = %v, want none", got)
+	}
+	var rules []string
+	for _, pr := range p.Check("fix(ui): apply coupons before tax is added.\n\nCoupons now apply before tax.") {
+		rules = append(rules, pr.Rule)
+	}
+	want := []string{"subject-length", "scope-unknown", "ticket-missing", "subject-case", "subject-period", "body-line-length"}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %q, want %q", rules, want)
+	}
+	if got := p.Check(""); len(got) != 1 || got[0].Rule != "subject-empty" {
+		t.Errorf("empty Check = %v", got)
+	}
+	if got := (Profile{}).Check("anything"); got != nil {
+		t.Errorf("empty profile Check = %v", got)
+	}
+}
@@ -0,0 +1,226 @@
+package prompt
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Params are a stage's generation parameters, sent to the model as
+// options. Unset fields leave the model's own defaults, except
+// Temperature, which defaults to 0 for the first stage and 0.9 for the
+// rest.
+type Params struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	// NumCtx is the context window in tokens; large diffs need more than
+	// many models' default of 2048.
+	NumCtx *int `json:"num_ctx,omitempty"`
+	// NumPredict bounds the tokens generated; -1 is unlimited.
+	NumPredict *int `json:"num_predict,omitempty"`
+	Seed       *int `json:"seed,omitempty"`
+	// Stop sequences end the output when generated.
+	Stop []string `json:"stop,omitempty"`
+}
+
+// ParamNames lists the keys Set accepts.
+var ParamNames = []string{"temperature", "top_p", "num_ctx", "num_predict", "seed", "stop"}
+
+// Set parses value into the parameter named key. Each "stop" adds a
+// sequence.
+func (p *Params) Set(key, value string) error {
+	switch key {
+	case "temperature", "top_p":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if key == "temperature" {
+			p.Temperature = &f
+		} else {
+			p.TopP = &f
+		}
+	case "num_ctx", "num_predict", "seed":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		switch key {
+		case "num_ctx":
+			p.NumCtx = &n
+		case "num_predict":
+			p.NumPredict = &n
+		default:
+			p.Seed = &n
+		}
+	case "stop":
+		p.Stop = append(p.Stop, value)
+	default:
+		return fmt.Errorf("unknown parameter %q (want %s)", key, strings.Join(ParamNames, ", "))
+	}
+	return p.Validate()
+}
+
+// Validate checks the parameters' ranges.
+func (p Params) Validate() error {
+	switch {
+	case p.Temperature != nil && *p.Temperature < 0:
+		return fmt.Errorf("temperature must not be negative")
+	case p.TopP != nil && (*p.TopP <= 0 || *p.TopP > 1):
+		return fmt.Errorf("top_p must be above 0 and at most 1")
+	case p.NumCtx != nil && *p.NumCtx <= 0:
+		return fmt.Errorf("num_ctx must be positive")
+	case p.NumPredict != nil && *p.NumPredict < -1:
+		return fmt.Errorf("num_predict must be -1 (unlimited) or more")
+	}
+	for _, s := range p.Stop {
+		if s == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	return nil
+}
+
+// Merge returns p with every field set in over replacing its own.
+func (p Params) Merge(over Params) Params {
+	if over.Temperature != nil {
+		p.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		p.TopP = over.TopP
+	}
+	if over.NumCtx != nil {
+		p.NumCtx = over.NumCtx
+	}
+	if over.NumPredict != nil {
+		p.NumPredict = over.NumPredict
+	}
+	if over.Seed != nil {
+		p.Seed = over.Seed
+	}
+	if over.Stop != nil {
+		p.Stop = over.Stop
+	}
+	return p
+}
+
+// Options returns the parameters as model options, with temperature
+// defaulting to temp.
+func (p Params) Options(temp float64) map[string]interface{} {
+	if p.Temperature != nil {
+		temp = *p.Temperature
+	}
+	opts := map[string]interface{}{"temperature": temp}
+	if p.TopP != nil {
+		opts["top_p"] = *p.TopP
+	}
+	if p.NumCtx != nil {
+		opts["num_ctx"] = *p.NumCtx
+	}
+	if p.NumPredict != nil {
+		opts["num_predict"] = *p.NumPredict
+	}
+	if p.Seed != nil {
+		opts["seed"] = *p.Seed
+	}
+	if len(p.Stop) > 0 {
+		opts["stop"] = p.Stop
+	}
+	return opts
+}
+
+// StageParams are generation parameters by stage name, e.g. "summary".
+type StageParams map[string]Params
+
+// Set parses a "stage.key=value" assignment, e.g. "summary.num_ctx=8192".
+func (sp StageParams) Set(assignment string) error {
+	lhs, value, ok := strings.Cut(assignment, "=")
+	stage, key, dot := strings.Cut(lhs, ".")
+	if !ok || !dot || stage == "" {
+		return fmt.Errorf("invalid parameter %q: want stage.key=value, e.g. summary.temperature=0.2", assignment)
+	}
+	p := sp[stage]
+	if err := p.Set(key, value); err != nil {
+		return fmt.Errorf("stage %q: %w", stage, err)
+	}
+	sp[stage] = p
+	return nil
+}
+
+// Validate checks each stage's parameters and that every stage name is one
+// of the pipeline's stages.
+func (sp StageParams) Validate(stages []Stage) error {
+	names := make([]string, 0, len(sp))
+	for name := range sp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		found := false
+		for _, st := range stages {
+			found = found || st.Name == name
+		}
+		if !found {
+			return fmt.Errorf("parameters for unknown stage %q", name)
+		}
+		if err := sp[name].Validate(); err != nil {
+			return fmt.Errorf("stage %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ModelSettings apply to every call to one model, whatever the stage, for
+// local models that ramble past the message without them.
+type ModelSettings struct {
+	// Stop sequences are added to the stage's.
+	Stop []string `json:"stop,omitempty"`
+	// Template replaces the model's own prompt template, in Ollama's Go
+	// template syntax, e.g. "[INST] {{ .Prompt }} [/INST]".
+	Template string `json:"template,omitempty"`
+}
+
+// ModelParams are settings by model name, e.g. "mistral:7b", or a name
+// without a tag, e.g. "mistral", for every tag of the model.
+type ModelParams map[string]ModelSettings
+
+// For returns the settings for model: those under its full name, else
+// those under its name without the tag.
+func (mp ModelParams) For(model string) (ModelSettings, bool) {
+	if s, ok := mp[model]; ok {
+		return s, true
+	}
+	name, _, _ := strings.Cut(model, ":")
+	s, ok := mp[name]
+	return s, ok
+}
+
+// Validate checks each model's stop sequences and that its template parses
+// and includes the prompt.
+func (mp ModelParams) Validate() error {
+	names := make([]string, 0, len(mp))
+	for name := range mp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := mp[name]
+		for _, stop := range s.Stop {
+			if stop == "" {
+				return fmt.Errorf("model %q: stop sequences must not be empty", name)
+			}
+		}
+		if s.Template == "" {
+			continue
+		}
+		if _, err := template.New(name).Parse(s.Template); err != nil {
+			return fmt.Errorf("model %q: template: %w", name, err)
+		}
+		if !strings.Contains(s.Template, ".Prompt") {
+			return fmt.Errorf("model %q: template must include {{ .Prompt }}", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStageParams(t *testing.T) {
+	sp := StageParams{}
+	for _, a := range []string{"summary.num_ctx=8192", "summary.seed=7", "style.temperature=0.5", "style.stop=###", "style.stop=END"} {
+		if err := sp.Set(a); err != nil {
+			t.Fatalf("Set(%q): %v", a, err)
+		}
+	}
+	if err := sp.Validate(DefaultPipeline); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	want := map[string]interface{}{"temperature": 0.0, "num_ctx": 8192, "seed": 7}
+	if got := sp["summary"].Options(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("summary options = %v, want %v", got, want)
+	}
+	want = map[string]interface{}{"temperature": 0.5, "stop": []string{"###", "END"}}
+	if got := sp["style"].Options(0.9); !reflect.DeepEqual(got, want) {
+		t.Errorf("style options = %v, want %v", got, want)
+	}
+
+	for a, wantErr := range map[string]string{
+		"num_ctx=8192":         "want stage.key=value",
+		"summary.top_k=40":     "unknown parameter",
+		"summary.top_p=1.5":    "top_p must be",
+		"summary.num_ctx=lots": "invalid syntax",
+	} {
+		if err := (StageParams{}).Set(a); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Set(%q) = %v, want error containing %q", a, err, wantErr)
+		}
+	}
+	if err := (StageParams{"critique": {}}).Validate(DefaultPipeline); err == nil || !strings.Contains(err.Error(), `unknown stage "critique"`) {
+		t.Errorf("Validate of an unknown stage = %v", err)
+	}
+}
+
+func TestParamsMerge(t *testing.T) {
+	low, high, ctx := 0.1, 0.8, 4096
+	base := Params{Temperature: &low, NumCtx: &ctx, Stop: []string{"a"}}
+	got := base.Merge(Params{Temperature: &high})
+	if *got.Temperature != 0.8 || *got.NumCtx != 4096 || !reflect.DeepEqual(got.Stop, []string{"a"}) {
+		t.Errorf("Merge = %+v", got)
+	}
+}
+
+func TestModelParams(t *testing.T) {
+	mp := ModelParams{
+		"mistral":    {Stop: []string{"\n\n\n"}},
+		"mistral:7b": {Template: "[INST] {{ .Prompt }} [/INST]"},
+	}
+	if s, ok := mp.For("mistral:7b"); !ok || s.Template == "" || s.Stop != nil {
+		t.Errorf("For(mistral:7b) = %+v, %v", s, ok)
+	}
+	if s, ok := mp.For("mistral:latest"); !ok || len(s.Stop) != 1 {
+		t.Errorf("For(mistral:latest) = %+v, %v", s, ok)
+	}
+	if _, ok := mp.For("gemma3:4b"); ok {
+		t.Error("For(gemma3:4b) found settings")
+	}
+	if err := mp.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	for _, bad := range []ModelSettings{{Stop: []string{""}}, {Template: "{{ .Prompt "}, {Template: "[INST] [/INST]"}} {
+		if err := (ModelParams{"m": bad}).Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package prompt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Stage is one model call in a prompt pipeline. Each stage sees the
+// sanitized diff, the tone, the previous stage's output and the output of
+// every earlier stage by name.
+type Stage struct {
+	Name string `json:"name"`
+	// Model defaults to the summarizer model for the first stage and the
+	// style model for the rest.
+	Model string `json:"model,omitempty"`
+	// Builtin selects the built-in "summary", "style" or "pr" prompt instead
+	// of Template.
+	Builtin string `json:"builtin,omitempty"`
+	// Template is a text/template with the fields .diff, .tone, .input,
+	// .title_only, .ticket (empty without ticket context), .why (the
+	// author's reason from --why, often empty), .hints (notes
+	// such as "only tests changed", often empty), .conventions (the
+	// repository's style profile as instructions, empty without one),
+	// .examples (example messages in the wanted voice from
+	// --style-examples or the persona, often empty), .quirks (the
+	// persona's formatting rules, one per line, often empty) and one per
+	// earlier stage name or Inputs key.
+	Template string `json:"prompt,omitempty"`
+	// Temperature defaults to 0 for the first stage and 0.9 for the rest.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP, NumCtx, NumPredict, Seed and Stop are further generation
+	// parameters, as in Params.
+	TopP       *float64 `json:"top_p,omitempty"`
+	NumCtx     *int     `json:"num_ctx,omitempty"`
+	NumPredict *int     `json:"num_predict,omitempty"`
+	Seed       *int     `json:"seed,omitempty"`
+	Stop       []string `json:"stop,omitempty"`
+	// Inputs binds extra template fields to earlier stage outputs, e.g.
+	// {"draft": "style"} exposes the "style" output as .draft.
+	Inputs map[string]string `json:"inputs,omitempty"`
+}
+
+// DefaultPipeline is the summarize-then-style flow used when no pipeline is
+// configured.
+var DefaultPipeline = []Stage{
+	{Name: "summary", Builtin: "summary"},
+	{Name: "style", Builtin: "style"},
+}
+
+// PullRequestPipeline summarizes a branch diff and writes a pull request
+// title and description from it. The "pr" builtin reads .commits.
+var PullRequestPipeline = []Stage{
+	{Name: "summary", Builtin: "summary"},
+	{Name: "pr", Builtin: "pr"},
+}
+
+// builtinFields are template fields every stage gets.
+var builtinFields = map[string]bool{"diff": true, "tone": true, "input": true, "title_only": true, "ticket": true, "why": true, "hints": true, "conventions": true, "examples": true, "quirks": true, "body_lines": true, "changes": true}
+
+// ValidatePipeline checks stage names, builtins, templates and input
+// bindings, so configuration mistakes surface before any model is called.
+func ValidatePipeline(stages []Stage) error {
+	seen := make(map[string]bool)
+	for i, s := range stages {
+		if s.Name == "" {
+			return fmt.Errorf("pipeline stage %d has no name", i+1)
+		}
+		if seen[s.Name] || builtinFields[s.Name] {
+			return fmt.Errorf("pipeline stage name %q is duplicated or reserved", s.Name)
+		}
+		switch {
+		case s.Builtin != "" && s.Template != "":
+			return fmt.Errorf("pipeline stage %q sets both builtin and prompt", s.Name)
+		case s.Builtin == "" && s.Template == "":
+			return fmt.Errorf("pipeline stage %q needs a builtin or a prompt", s.Name)
+		case s.Builtin != "" && s.Builtin != "summary" && s.Builtin != "style" && s.Builtin != "pr":
+			return fmt.Errorf("pipeline stage %q: unknown builtin %q (want summary, style or pr)", s.Name, s.Builtin)
+		}
+		if err := s.Params().Validate(); err != nil {
+			return fmt.Errorf("pipeline stage %q: %w", s.Name, err)
+		}
+		if s.Template != "" {
+			if _, err := template.New(s.Name).Parse(s.Template); err != nil {
+				return fmt.Errorf("pipeline stage %q: %w", s.Name, err)
+			}
+		}
+		for field, from := range s.Inputs {
+			if !seen[from] {
+				return fmt.Errorf("pipeline stage %q: input %q refers to %q, which is not an earlier stage", s.Name, field, from)
+			}
+		}
+		seen[s.Name] = true
+	}
+	return nil
+}
+
+// Params returns the stage's generation parameters.
+func (s Stage) Params() Params {
+	return Params{Temperature: s.Temperature, TopP: s.TopP, NumCtx: s.NumCtx, NumPredict: s.NumPredict, Seed: s.Seed, Stop: s.Stop}
+}
+
+// NeedsDiff reports whether the stage reads the diff.
+func (s Stage) NeedsDiff() bool {
+	return s.Builtin == "summary" || strings.Contains(s.Template, ".diff")
+}
+
+// Render builds the stage prompt from the pipeline variables (diff, tone,
+// input and earlier stage outputs).
+func (s Stage) Render(vars map[string]string, titleOnly bool) (string, error) {
+	data := map[string]interface{}{"title_only": titleOnly}
+	for k, v := range vars {
+		data[k] = v
+	}
+	for field, from := range s.Inputs {
+		data[field] = vars[from]
+	}
+	switch s.Builtin {
+	case "summary":
+		return Summary(vars["diff"], vars["ticket"], vars["why"], vars["hints"], vars["conventions"], vars["body_lines"], titleOnly), nil
+	case "style":
+		input, _ := data["input"].(string)
+		return Style(input, vars["tone"], vars["conventions"], vars["examples"], vars["quirks"], vars["body_lines"], titleOnly), nil
+	case "pr":
+		input, _ := data["input"].(string)
+		return PullRequest(input, vars["commits"], vars["tone"]), nil
+	}
+	t, err := template.New(s.Name).Option("missingkey=error").Parse(s.Template)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("pipeline stage %q: %w", s.Name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,91 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePipeline(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name    string
+		stages  []Stage
+		wantErr string
+	}{
+		{"default", DefaultPipeline, ""},
+		{"critique", []Stage{
+			{Name: "summary", Builtin: "summary"},
+			{Name: "critique", Template: "Check {{.summary}} against {{.diff}}"},
+			{Name: "style", Builtin: "style", Inputs: map[string]string{"input": "critique"}},
+		}, ""},
+		{"unnamed", []Stage{{Builtin: "summary"}}, "has no name"},
+		{"duplicate", []Stage{{Name: "a", Builtin: "summary"}, {Name: "a", Builtin: "style"}}, "duplicated"},
+		{"reserved", []Stage{{Name: "diff", Builtin: "summary"}}, "reserved"},
+		{"no prompt", []Stage{{Name: "a"}}, "needs a builtin or a prompt"},
+		{"both", []Stage{{Name: "a", Builtin: "style", Template: "x"}}, "both"},
+		{"bad builtin", []Stage{{Name: "a", Builtin: "poem"}}, "unknown builtin"},
+		{"bad params", []Stage{{Name: "a", Builtin: "summary", NumCtx: &zero}}, "num_ctx must be positive"},
+		{"bad template", []Stage{{Name: "a", Template: "{{.diff"}}, "unclosed action"},
+		{"forward input", []Stage{{Name: "a", Template: "x", Inputs: map[string]string{"y": "b"}}, {Name: "b", Template: "y"}}, "not an earlier stage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePipeline(tt.stages)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ValidatePipeline: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ValidatePipeline = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStageRender(t *testing.T) {
+	vars := map[string]string{"diff": "+x", "tone": "dry", "input": "draft", "summary": "facts"}
+	tests := []struct {
+		name  string
+		stage Stage
+		want  string
+	}{
+		{"template", Stage{Name: "c", Template: "{{.summary}}|{{.input}}|{{.tone}}|{{.title_only}}"}, "facts|draft|dry|true"},
+		{"input binding", Stage{Name: "c", Template: "{{.facts}}", Inputs: map[string]string{"facts": "summary"}}, "facts"},
+		{"builtin summary", Stage{Name: "s", Builtin: "summary"}, Summary("+x", "", "", "", "", "", true)},
+		{"builtin style", Stage{Name: "s", Builtin: "style"}, Style("draft", "dry", "", "", "", "", true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.stage.Render(vars, true)
+			if err != nil || got != tt.want {
+				t.Errorf("Render = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (Stage{Name: "c", Template: "{{.missing}}"}).Render(vars, false); err == nil {
+		t.Error("Render with unknown field succeeded")
+	}
+
+	withTicket := map[string]string{"diff": "+x", "ticket": "PROJ-1: Fix login"}
+	got, err := (Stage{Name: "s", Builtin: "summary"}).Render(withTicket, false)
+	if err != nil || !strings.Contains(got, "PROJ-1: Fix login") || strings.Index(got, "PROJ-1") > strings.Index(got, "+x") {
+		t.Errorf("summary prompt does not lead with the ticket:\n%s", got)
+	}
+	withWhy := map[string]string{"diff": "+x", "why": "Works around upstream bug #42."}
+	got, err = (Stage{Name: "s", Builtin: "summary"}).Render(withWhy, false)
+	if err != nil || !strings.Contains(got, "The author's reason for this change (state it in the body):\nWorks around upstream bug #42.") {
+		t.Errorf("summary prompt missing the author's reason:\n%s", got)
+	}
+	withHints := map[string]string{"diff": "+x", "hints": "Only tests changed."}
+	got, err = (Stage{Name: "s", Builtin: "summary"}).Render(withHints, false)
+	if err != nil || !strings.Contains(got, "Notes about this diff (follow them):\nOnly tests changed.") {
+		t.Errorf("summary prompt missing hints:\n%s", got)
+	}
+	withConventions := map[string]string{"diff": "+x", "input": "draft", "conventions": "- Do not use emoji.\n- Always write a body."}
+	for _, st := range []Stage{{Name: "s", Builtin: "summary"}, {Name: "s", Builtin: "style"}} {
+		got, err = st.Render(withConventions, false)
+		if err != nil || !strings.Contains(got, "conventions") || !strings.Contains(got, "- Do not use emoji.") || !strings.Contains(got, "- Always write a body.") {
+			t.Errorf("%s prompt missing conventions:\n%s", st.Builtin, got)
+		}
+	}
+}
@@ -0,0 +1,453 @@
+// Package prompt builds the prompts sent to the summarizer and style models.
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Body lengths the prompts ask for unless configured.
+const (
+	DefaultMinBodyLines = 2
+	DefaultMaxBodyLines = 40
+)
+
+// BodyLines describes a body length for the prompts, e.g. "2-40", from
+// configured bounds, where 0 keeps the default.
+func BodyLines(min, max int) string {
+	lo, hi := DefaultMinBodyLines, DefaultMaxBodyLines
+	if min > 0 {
+		lo = min
+	}
+	if max > 0 {
+		hi = max
+	}
+	if lo > hi {
+		if max == 0 {
+			return fmt.Sprintf("%d or more", lo)
+		}
+		lo = hi
+	}
+	if lo == hi {
+		return strconv.Itoa(lo)
+	}
+	return fmt.Sprintf("%d-%d", lo, hi)
+}
+
+// Summary returns the summarizer prompt for diff. With titleOnly the model is
+// asked for a single descriptive title line instead of title + body. A
+// non-empty ticket (issue tracker context) and why (the author's own reason
+// for the change) are included so the body can say why the change was
+// made, hints are notes about the diff found by inspecting it, such as
+// "only tests changed", and conventions are the repository's message
+// conventions, one per line. bodyLines is the body length asked for, as
+// returned by BodyLines; empty asks for the default.
+func Summary(diff, ticket, why, hints, conventions, bodyLines string, titleOnly bool) string {
+	if bodyLines == "" {
+		bodyLines = BodyLines(0, 0)
+	}
+	diff = "Diff:\n" + diff
+	if conventions != "" {
+		diff = repoConventions(conventions) + "\n" + diff
+	}
+	if hints != "" {
+		diff = fmt.Sprintf("Notes about this diff (follow them):\n%s\n\n", hints) + diff
+	}
+	if why != "" {
+		diff = authorReason(why) + "\n" + diff
+	}
+	if ticket != "" {
+		diff = ticketContext(ticket) + "\n" + diff
+	}
+	if titleOnly {
+		return fmt.Sprintf(`Summarize the following git diff as a single descriptive commit title.
+
+Rules:
+- One line only (max 100 chars for descriptive version).
+- Imperative tense.
+- Be specific about what changed.
+- Do NOT invent or hallucinate.
+- Capture the key changes concisely.
+
+%s
+
+OUTPUT FORMAT:
+A single descriptive title line
+`, diff)
+	}
+	return fmt.Sprintf(`Summarize the following git diff with strict factual accuracy.
+Produce TWO sections:
+1. A short commit title (max 60 chars)
+2. A %s line commit body listing the key changes, one per line starting with "- ".
+
+Rules:
+- Title should be imperative tense.
+- Body should describe files, functions, and intent.
+- Do NOT invent or hallucinate.
+- Do NOT include specific diff content unless necessary to illustrate a change.
+- Keep it concise.
+- Use the ticket and the author's reason, if given, only to explain why; describe only what the diff changes.
+- Do not guess at a reason for the change that neither gives.
+
+%s
+
+OUTPUT FORMAT:
+TITLE (one line)
+BLANK LINE
+BODY (%s lines, each "- " and one key change)
+`, bodyLines, diff, bodyLines)
+}
+
+// repoConventions wraps a repository's message conventions for a prompt.
+func repoConventions(conventions string) string {
+	return fmt.Sprintf("This repository's commit message conventions (follow them):\n%s\n", conventions)
+}
+
+// ticketContext wraps issue tracker text for the summarizer prompt.
+func ticketContext(ticket string) string {
+	return fmt.Sprintf("Ticket (background on why the change was made):\n%s\n", ticket)
+}
+
+// authorReason wraps the author's explanation for the summarizer prompt.
+func authorReason(why string) string {
+	return fmt.Sprintf("The author's reason for this change (state it in the body):\n%s\n", why)
+}
+
+// Style returns the prompt that rewrites summary in the given tone,
+// following the repository's conventions and the persona's quirks, one per
+// line, and imitating examples (as formatted by Examples), when given, with
+// a body of bodyLines lines as for Summary.
+func Style(summary, tone, conventions, examples, quirks, bodyLines string, titleOnly bool) string {
+	if bodyLines == "" {
+		bodyLines = BodyLines(0, 0)
+	}
+	follow, examples := styleRules(conventions, examples, quirks)
+	if titleOnly {
+		return fmt.Sprintf(`Rewrite the following commit title but:
+- KEEP the factual content *exactly*.
+- Apply this tone: %s
+- Make it readable and engaging
+- Keep it as a single line (max 100 chars)
+%s- Do not add commentary, only output the new title
+
+%sOriginal title:
+%s
+`, tone, follow, examples, summary)
+	}
+	return fmt.Sprintf(`Rewrite the following commit (title + body) but:
+- KEEP the factual content *exactly*.
+- Apply this tone: %s
+- Make it readable.
+- Maintain title + body structure of 1 title line, %s body lines.
+- Use the list of changed files, if given, only as context; do not copy it.
+%s- Do not add commentary, only output the content
+
+%sOriginal commit:
+%s
+`, tone, bodyLines, follow, examples, summary)
+}
+
+// StylePatch returns the prompt that continues written, a message the
+// style model wrote from the first lines of a summary, with the rest of
+// the summary in the same tone and voice.
+func StylePatch(written, rest, tone, conventions, examples, quirks string) string {
+	follow, examples := styleRules(conventions, examples, quirks)
+	return fmt.Sprintf(`The commit message below was rewritten from the first lines of a summary.
+Continue it with the rest of the summary but:
+- KEEP the factual content *exactly*.
+- Apply this tone: %s
+- Match the voice of the message so far.
+%s- Do not repeat the message so far or add commentary, only output the new body lines
+
+%sMessage so far:
+%s
+
+Rest of the summary:
+%s
+`, tone, follow, examples, written, rest)
+}
+
+// styleRules returns the rules the style prompts add for conventions,
+// quirks and examples, and the examples section.
+func styleRules(conventions, examples, quirks string) (string, string) {
+	follow := ""
+	if conventions != "" {
+		follow = "- Follow this repository's commit message conventions:\n  " + strings.ReplaceAll(conventions, "\n", "\n  ") + "\n"
+	}
+	if quirks != "" {
+		follow += "- " + strings.ReplaceAll(quirks, "\n", "\n- ") + "\n"
+	}
+	if examples != "" {
+		follow += "- Write in the voice of the example messages below: copy their style, never their content.\n"
+		examples = "Example messages:\n" + examples + "\n"
+	}
+	return follow, examples
+}
+
+// ParseExamples splits a style examples file into commit messages. The
+// messages are separated by lines of "---"; without such a line, each
+// non-empty line is a one-line message.
+func ParseExamples(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var examples []string
+	if !strings.HasPrefix(text, "---\n") && !strings.Contains(text, "\n---\n") && !strings.HasSuffix(text, "\n---") {
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				examples = append(examples, line)
+			}
+		}
+		return examples
+	}
+	var cur []string
+	for _, line := range append(strings.Split(text, "\n"), "---") {
+		if strings.TrimSpace(line) != "---" {
+			cur = append(cur, line)
+			continue
+		}
+		if msg := strings.TrimSpace(strings.Join(cur, "\n")); msg != "" {
+			examples = append(examples, msg)
+		}
+		cur = nil
+	}
+	return examples
+}
+
+// Examples formats example commit messages for the style prompt, each
+// between <example> tags.
+func Examples(examples []string) string {
+	var b strings.Builder
+	for _, e := range examples {
+		fmt.Fprintf(&b, "<example>\n%s\n</example>\n", e)
+	}
+	return b.String()
+}
+
+// PullRequest returns the prompt that turns a change summary and the branch's
+// commit subjects into a pull request title and Markdown description.
+func PullRequest(summary, commits, tone string) string {
+	return fmt.Sprintf(`Write a pull request title and description from the summary and commit list below.
+- KEEP the factual content *exactly*; do not invent motivation or testing.
+- Apply this tone: %s
+- First line: the PR title (max 72 chars), no prefix or label.
+- Then a blank line and a Markdown body with a "## Summary" paragraph and a "## Changes" bullet list.
+- Do not add commentary, only output the title and body.
+
+Summary of the changes:
+%s
+
+Commits on the branch:
+%s
+`, tone, summary, commits)
+}
+
+// NoQuestions is the reply Questions asks for when the diff is clear.
+const NoQuestions = "NONE"
+
+// Questions returns the prompt asking for at most max questions to the
+// author whose answers would make the commit message more accurate, such
+// as whether a change fixes a bug or only refactors. ticket, why and hints
+// are the context the summarizer will already have.
+func Questions(diff, ticket, why, hints string, max int) string {
+	var known strings.Builder
+	if ticket != "" {
+		known.WriteString(ticketContext(ticket) + "\n")
+	}
+	if why != "" {
+		known.WriteString(authorReason(why) + "\n")
+	}
+	if hints != "" {
+		fmt.Fprintf(&known, "Notes about this diff:\n%s\n\n", hints)
+	}
+	return fmt.Sprintf(`You will write a commit message for the following git diff. Before that, you may ask its author up to %d short questions about what the diff cannot show, such as why the change was made or whether it fixes a bug or only refactors.
+
+Rules:
+- Ask only what you need to describe the change accurately; ask nothing the diff or the context below already answers.
+- One question per line, each ending with "?".
+- If you have no questions, reply with %s only.
+- Do not add commentary.
+
+%sDiff:
+%s
+`, max, NoQuestions, known.String(), diff)
+}
+
+// Verified is the reply Verify asks for when every claim holds.
+const Verified = "OK"
+
+// Verify returns the prompt that checks a finished commit message against
+// its diff and asks for a corrected message if it claims anything the diff
+// does not show.
+func Verify(diff, message string, titleOnly bool) string {
+	shape := "title + body structure"
+	if titleOnly {
+		shape = "single title line"
+	}
+	return fmt.Sprintf(`Check the following commit message against the git diff it describes.
+
+Rules:
+- Every file, function, behavior and effect the message mentions must be shown by the diff.
+- A stated reason for the change may stay; the diff cannot show it.
+- If every claim is supported, reply with %s only.
+- Otherwise reply with the corrected message only: remove or fix each unsupported claim and change nothing else, keeping its tone, labels and %s.
+- Do not add commentary.
+
+Commit message:
+%s
+
+Diff:
+%s
+`, Verified, shape, message, diff)
+}
+
+// Judge returns the prompt that scores a commit message for diff against
+// the eval rubric, following the repository's conventions, one per line,
+// when given.
+func Judge(diff, message, conventions string) string {
+	if conventions != "" {
+		conventions = repoConventions(conventions) + "\n"
+	}
+	return fmt.Sprintf(`Review the commit message written for the following git diff. Score it from 1 (poor) to 5 (excellent) on each criterion:
+- ACCURACY: every claim is supported by the diff, and the main changes are covered.
+- LENGTH: the title is short (about 50 characters, at most 72) and the body is as long as the change needs, no longer.
+- CONVENTIONS: imperative title, a blank line after it, no labels or commentary, and the repository's conventions, if given.
+
+Reply in exactly this format:
+ACCURACY: <1-5>
+LENGTH: <1-5>
+CONVENTIONS: <1-5>
+NOTE: <one sentence on the biggest problem, or none>
+
+%sCommit message:
+%s
+
+Diff:
+%s
+`, conventions, message, diff)
+}
+
+// Explain returns the prompt for a plain-English explanation of an
+// existing commit, its message and diff, for someone reading the history.
+func Explain(diff, message string) string {
+	return fmt.Sprintf(`Explain the following commit to a developer who is new to the code and is reading its history.
+
+Rules:
+- Plain English, no headings, at most three short paragraphs.
+- First say what changed and what it does differently now, based on the diff.
+- Then say why it was likely changed. Use the commit message where it says why; where you infer a reason from the diff, say so (e.g. "probably", "seems to").
+- Mention anything a reviewer should notice, such as behavior changes or removed code, only if the diff shows it.
+- Do NOT invent or hallucinate. Do not restate the diff line by line.
+- Do not add commentary, only output the explanation.
+
+Commit message:
+%s
+
+Diff:
+%s
+`, message, diff)
+}
+
+// ReleaseNotes returns the prompt for the user-facing prose of one group
+// of release notes (e.g. "Bug Fixes"), given its commits, one per line.
+func ReleaseNotes(group, commits string) string {
+	return fmt.Sprintf(`Write the %q section of release notes for the people who use this software, from the commits below.
+
+Rules:
+- Describe what users can now do or will notice, not how the code changed.
+- A short paragraph, or a short bulleted list when the changes are unrelated. No heading.
+- Combine related commits; leave out purely internal details.
+- Do NOT invent or hallucinate anything the commits don't say.
+- Do not add commentary, only output the section text.
+
+Commits:
+%s
+`, group, commits)
+}
+
+// Standup returns the prompt for a daily standup update from the
+// author's recent commit messages, one per line, and the diff of their
+// uncommitted work, either of which may be empty.
+func Standup(commits, diff string) string {
+	var work strings.Builder
+	if commits != "" {
+		fmt.Fprintf(&work, "Commits, oldest first:\n%s\n\n", commits)
+	}
+	if diff != "" {
+		fmt.Fprintf(&work, "Uncommitted changes (work in progress):\n%s\n", diff)
+	}
+	return fmt.Sprintf(`Summarize the following work for the author's daily standup.
+
+Rules:
+- 2 to 5 bullets starting with "- ", in the first person and past tense ("Fixed ...", "Added ..."); work in progress as "Working on ...".
+- Group related commits into one bullet; say what was achieved, not how.
+- Do NOT invent or hallucinate anything the work below doesn't show.
+- Do not add commentary or a heading, only output the bullets.
+
+%s`, work.String())
+}
+
+// Review returns the prompt for notes to a reviewer of a diff, given the
+// areas the risk and security rules flagged, one per line, if any.
+func Review(diff, findings string) string {
+	if findings != "" {
+		findings = "Automated checks flagged these areas:\n" + findings + "\n\n"
+	}
+	return fmt.Sprintf(`Write notes for a code reviewer of the following git diff, to help them review it well.
+
+Reply in exactly this format, with 1 to 5 short bullets under each heading:
+What to look at:
+- <the parts of the change that need the most attention, and why>
+Risks:
+- <what could break or who is affected, or "none" if nothing stands out>
+Test focus:
+- <the behavior tests should cover, including edge cases>
+
+Rules:
+- Base every point on the diff; name files and functions the diff shows.
+- Do NOT invent or hallucinate. Do not summarize the change line by line.
+- Do not add commentary, only output the notes.
+
+%sDiff:
+%s
+`, findings, diff)
+}
+
+// Risk returns the prompt for a short risk note on a diff, given the
+// findings of the risk rules, one per line.
+func Risk(diff, findings string) string {
+	return fmt.Sprintf(`Write a short risk note for a reviewer of the following git diff.
+Automated checks flagged these areas:
+%s
+
+Rules:
+- One or two sentences, starting with "Risk: ".
+- Say what could break or who is affected, based only on the diff.
+- Do NOT invent or hallucinate.
+- Do not add commentary, only output the note.
+
+Diff:
+%s
+`, findings, diff)
+}
+
+// Split returns the prompt that groups the changed files of a diff into
+// separate commits.
+func Split(diff string, files []string) string {
+	return fmt.Sprintf(`Group the files changed in the following git diff into separate, cohesive commits.
+Rules:
+- Each commit holds one logical change.
+- Files that only make sense together stay in the same commit: code and its tests, a function and its callers, a flag and its documentation.
+- Do not split a single logical change; one group is fine if everything belongs together.
+- Assign every file to exactly one group, using the paths exactly as listed.
+
+Files:
+- %s
+
+Diff:
+%s
+
+OUTPUT FORMAT (repeat for each commit, nothing else):
+GROUP: <short commit title>
+FILES: <file>, <file>
+`, strings.Join(files, "\n- "), diff)
+}
@@ -0,0 +1,37 @@
+package prompt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExamples(t *testing.T) {
+	for text, want := range map[string][]string{
+		"Fix the thing, finally\n\nTeach the parser manners\n":        {"Fix the thing, finally", "Teach the parser manners"},
+		"Fix the thing\n\nIt was broken.\n---\nAdd a knob\r\n---\n\n": {"Fix the thing\n\nIt was broken.", "Add a knob"},
+		"\n\n": nil,
+	} {
+		if got := ParseExamples(text); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseExamples(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestBodyLines(t *testing.T) {
+	for _, tc := range []struct {
+		min, max int
+		want     string
+	}{
+		{0, 0, "2-40"},
+		{3, 8, "3-8"},
+		{0, 10, "2-10"},
+		{5, 0, "5-40"},
+		{50, 0, "50 or more"},
+		{0, 1, "1"},
+		{4, 4, "4"},
+	} {
+		if got := BodyLines(tc.min, tc.max); got != tc.want {
+			t.Errorf("BodyLines(%d, %d) = %q, want %q", tc.min, tc.max, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package prompt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Tone is one tone of a blend and its share of it.
+type Tone struct {
+	Name   string
+	Weight float64
+}
+
+// ParseTone splits a tone blend such as "dry:0.7,sarcastic:0.3" into its
+// tones, with the weights normalized to sum to 1. A spec without weights,
+// such as "chaotic, wild, funny", is a single tone.
+func ParseTone(spec string) ([]Tone, error) {
+	parts := strings.Split(spec, ",")
+	var tones []Tone
+	total := 0.0
+	for _, part := range parts {
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			continue
+		}
+		w, err := strconv.ParseFloat(strings.TrimSpace(part[i+1:]), 64)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSpace(part[:i])
+		if name == "" || w <= 0 {
+			return nil, fmt.Errorf("tone %q: each tone needs a name and a positive weight", spec)
+		}
+		tones = append(tones, Tone{Name: name, Weight: w})
+		total += w
+	}
+	switch {
+	case len(tones) == 0:
+		return []Tone{{Name: strings.TrimSpace(spec), Weight: 1}}, nil
+	case len(tones) < len(parts):
+		return nil, fmt.Errorf("tone %q mixes weighted and unweighted tones; give each one a weight, e.g. dry:0.7,sarcastic:0.3", spec)
+	}
+	for i := range tones {
+		tones[i].Weight /= total
+	}
+	return tones, nil
+}
+
+// DescribeTone turns a tone spec and an intensity from 0 to 1 into the
+// tone instruction for the style prompt. A single tone at full intensity
+// is used as is.
+func DescribeTone(spec string, intensity float64) (string, error) {
+	if intensity < 0 || intensity > 1 {
+		return "", fmt.Errorf("intensity %v is out of range; want 0 to 1", intensity)
+	}
+	tones, err := ParseTone(spec)
+	if err != nil {
+		return "", err
+	}
+	desc := tones[0].Name
+	if len(tones) > 1 {
+		shares := make([]string, len(tones))
+		for i, t := range tones {
+			shares[i] = fmt.Sprintf("%.0f%% %s", t.Weight*100, t.Name)
+		}
+		desc = "a blend of " + strings.Join(shares, ", ") + ", in those proportions"
+	}
+	switch {
+	case intensity >= 1:
+		return desc, nil
+	case intensity <= 1.0/3:
+		return fmt.Sprintf("%s, at %.0f%% intensity: stay close to the original wording with only a hint of the tone", desc, intensity*100), nil
+	case intensity <= 2.0/3:
+		return fmt.Sprintf("%s, at %.0f%% intensity: noticeable but restrained, keeping most of the original wording", desc, intensity*100), nil
+	}
+	return fmt.Sprintf("%s, at %.0f%% intensity: strong, but short of over the top", desc, intensity*100), nil
+}
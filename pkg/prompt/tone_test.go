@@ -0,0 +1,37 @@
+package prompt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTone(t *testing.T) {
+	for spec, want := range map[string][]Tone{
+		"chaotic, wild, funny":   {{Name: "chaotic, wild, funny", Weight: 1}},
+		"dry:0.7,sarcastic:0.3":  {{Name: "dry", Weight: 0.7}, {Name: "sarcastic", Weight: 0.3}},
+		"dry:3, very formal : 1": {{Name: "dry", Weight: 0.75}, {Name: "very formal", Weight: 0.25}},
+	} {
+		if got, err := ParseTone(spec); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseTone(%q) = %v, %v; want %v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"dry:0.7,sarcastic", "dry:0,sarcastic:1", ":1"} {
+		if _, err := ParseTone(spec); err == nil {
+			t.Errorf("ParseTone(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestDescribeTone(t *testing.T) {
+	if got, err := DescribeTone("chaotic, wild, funny", 1); err != nil || got != "chaotic, wild, funny" {
+		t.Errorf("full intensity = %q, %v", got, err)
+	}
+	got, err := DescribeTone("dry:0.7,sarcastic:0.3", 0.2)
+	if err != nil || !strings.HasPrefix(got, "a blend of 70% dry, 30% sarcastic") || !strings.Contains(got, "at 20% intensity: stay close") {
+		t.Errorf("blend at 0.2 = %q, %v", got, err)
+	}
+	if _, err := DescribeTone("dry", 1.5); err == nil {
+		t.Error("intensity 1.5 accepted")
+	}
+}
@@ -0,0 +1,131 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var emailRe = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+
+// Anonymizer replaces emails, internal hostnames and configured terms with
+// stable pseudonyms, so the same name maps to the same placeholder across a
+// run. Nothing is restored afterwards.
+type Anonymizer struct {
+	hostRe   *regexp.Regexp
+	termRe   *regexp.Regexp
+	terms    map[string]string
+	mapping  map[string]string
+	counters map[string]int
+}
+
+// NewAnonymizer returns an Anonymizer for the given codename terms (mapped to
+// their replacement, or "" for a generated one) and extra internal domains.
+func NewAnonymizer(terms map[string]string, extra []string) *Anonymizer {
+	a := &Anonymizer{
+		terms:    make(map[string]string),
+		mapping:  make(map[string]string),
+		counters: make(map[string]int),
+	}
+	// Suffixes are matched in lowercase, so Go selectors such as time.Local
+	// or cfg.Internal are left alone; Apply skips the identifier contexts.
+	const label = `[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?`
+	suffixes := []string{"internal", "corp", "local", "lan", "intranet"}
+	var domains []string
+	for _, d := range extra {
+		if d = strings.ToLower(strings.Trim(d, ".")); d != "" {
+			domains = append(domains, regexp.QuoteMeta(d))
+		}
+	}
+	hosts := `(?:` + label + `\.)+(?:` + strings.Join(suffixes, "|") + `)`
+	if len(domains) > 0 {
+		hosts += `|(?:` + label + `\.)+(?:` + strings.Join(domains, "|") + `)`
+	}
+	a.hostRe = regexp.MustCompile(`\b(?:` + hosts + `)\b`)
+
+	if len(terms) > 0 {
+		words := make([]string, 0, len(terms))
+		for term, repl := range terms {
+			a.terms[strings.ToLower(term)] = repl
+			words = append(words, term)
+		}
+		// Longest first so "foo-bar" wins over "foo".
+		sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		a.termRe = regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)
+	}
+	return a
+}
+
+func (a *Anonymizer) pseudonym(kind, orig string) string {
+	key := kind + ":" + strings.ToLower(orig)
+	if p, ok := a.mapping[key]; ok {
+		return p
+	}
+	a.counters[kind]++
+	n := a.counters[kind]
+	var p string
+	switch kind {
+	case "email":
+		p = fmt.Sprintf("user%d@example.com", n)
+	case "host":
+		p = fmt.Sprintf("host%d.example.internal", n)
+	default:
+		if repl := a.terms[strings.ToLower(orig)]; repl != "" {
+			p = repl
+		} else {
+			p = fmt.Sprintf("PROJECT%d", n)
+		}
+	}
+	a.mapping[key] = p
+	return p
+}
+
+// Apply pseudonymizes s and returns it with the number of replacements made.
+func (a *Anonymizer) Apply(s string) (string, int) {
+	n := 0
+	replace := func(re *regexp.Regexp, kind string) {
+		if re == nil {
+			return
+		}
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			n++
+			return a.pseudonym(kind, m)
+		})
+	}
+	replace(emailRe, "email")
+	s = a.replaceHosts(s, &n)
+	replace(a.termRe, "term")
+	return s, n
+}
+
+// replaceHosts pseudonymizes the hostnames in s, skipping matches that read
+// as code: an uppercase first label as in Config.local, a match preceded by
+// an identifier character as in a_db.lan or Foo.bar.internal, or one
+// followed by "(" as in conn.local().
+func (a *Anonymizer) replaceHosts(s string, n *int) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range a.hostRe.FindAllStringIndex(s, -1) {
+		start, end := m[0], m[1]
+		if s[start] >= 'A' && s[start] <= 'Z' ||
+			start > 0 && isIdentChar(s[start-1]) ||
+			end < len(s) && s[end] == '(' {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(a.pseudonym("host", s[start:end]))
+		last = end
+		*n++
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// isIdentChar reports whether c can precede a name in a selector chain.
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
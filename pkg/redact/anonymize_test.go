@@ -0,0 +1,28 @@
+package redact
+
+import "testing"
+
+func TestAnonymizerApply(t *testing.T) {
+	a := NewAnonymizer(map[string]string{"Bluebird": "", "acme-core": "LIBRARY"}, []string{"acme.io"})
+	tests := []struct {
+		in, want string
+		n        int
+	}{
+		{"mail jane.doe@acme.io", "mail user1@example.com", 1},
+		{"JANE.DOE@acme.io again", "user1@example.com again", 1},
+		{"db01.prod.internal and ci.acme.io", "host1.example.internal and host2.example.internal", 2},
+		{"bluebird uses acme-core", "PROJECT1 uses LIBRARY", 2},
+		{"plain text", "plain text", 0},
+		{"if t.Location() == time.Local {", "if t.Location() == time.Local {", 0},
+		{"cfg.Internal = opts.LAN", "cfg.Internal = opts.LAN", 0},
+		{"vault.internal, jenkins.corp and db.lan", "host3.example.internal, host4.example.internal and host5.example.internal", 3},
+		{"return conn.local(), x_srv.corp", "return conn.local(), x_srv.corp", 0},
+		{"Config.local = Foo.bar.internal", "Config.local = Foo.bar.internal", 0},
+	}
+	for _, tt := range tests {
+		got, n := a.Apply(tt.in)
+		if got != tt.want || n != tt.n {
+			t.Errorf("Apply(%q) = %q, %d; want %q, %d", tt.in, got, n, tt.want, tt.n)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package redact removes secrets and identifying names from text before it
+// is sent to a model.
+package redact
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redaction records a secret that was replaced before the diff left the machine.
+type Redaction struct {
+	Kind string `json:"kind"`
+	Line int    `json:"line"`
+}
+
+// secretPattern matches one kind of secret. Group selects the submatch to
+// replace (0 for the whole match, -1 for the first submatch that matched);
+// Check, if set, must also accept the candidate text.
+type secretPattern struct {
+	Kind  string
+	Re    *regexp.Regexp
+	Group int
+	Check func(string) bool
+}
+
+// secretPatterns are applied in order. Private keys come last because they
+// span several lines and would otherwise shift the reported line numbers.
+var secretPatterns = []secretPattern{
+	{Kind: "aws-access-key", Re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Kind: "github-token", Re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})\b`)},
+	{Kind: "slack-token", Re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{Kind: "api-key", Re: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{Kind: "jwt", Re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{
+		Kind:  "url-password",
+		Re:    regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^\s:/@]+:([^\s/@]+)@`),
+		Group: 1,
+		Check: func(v string) bool { return !strings.HasPrefix(v, "[REDACTED") && !strings.HasPrefix(v, "$") },
+	},
+	{
+		Kind:  "password",
+		Re:    regexp.MustCompile(`(?im)[A-Za-z0-9_]*(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|auth[_-]?key)[A-Za-z0-9_]*["']?\s*(?::=|[:=])\s*(?:"([^"\s]{6,})"|'([^'\s]{6,})'|([^\s"'()$,]{6,})$)`),
+		Group: -1,
+		Check: func(v string) bool { return !strings.HasPrefix(v, "[REDACTED") },
+	},
+	{Kind: "high-entropy-string", Re: regexp.MustCompile(`[A-Za-z0-9+/=_-]{24,}`), Check: looksRandom},
+	{Kind: "private-key", Re: regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)},
+}
+
+// Secrets replaces API keys, tokens, passwords, JWTs, private keys and
+// other high-entropy strings with [REDACTED:<kind>] placeholders.
+func Secrets(s string) (string, []Redaction) {
+	var found []Redaction
+	for _, p := range secretPatterns {
+		locs := p.Re.FindAllStringSubmatchIndex(s, -1)
+		if locs == nil {
+			continue
+		}
+		var b strings.Builder
+		last := 0
+		for _, loc := range locs {
+			start, end := loc[0], loc[1]
+			if p.Group > 0 {
+				start, end = loc[2*p.Group], loc[2*p.Group+1]
+			} else if p.Group < 0 {
+				start = -1
+				for g := 1; 2*g < len(loc); g++ {
+					if loc[2*g] >= 0 {
+						start, end = loc[2*g], loc[2*g+1]
+						break
+					}
+				}
+			}
+			if start < 0 || (p.Check != nil && !p.Check(s[start:end])) {
+				continue
+			}
+			b.WriteString(s[last:start])
+			b.WriteString("[REDACTED:" + p.Kind + "]")
+			found = append(found, Redaction{Kind: p.Kind, Line: 1 + strings.Count(s[:start], "\n")})
+			last = end
+		}
+		b.WriteString(s[last:])
+		s = b.String()
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Line < found[j].Line })
+	return s, found
+}
+
+// looksRandom reports whether a token is likely a generated secret: mixed
+// case with digits and high Shannon entropy. Hex hashes and identifiers fail
+// the character-class test.
+func looksRandom(tok string) bool {
+	if strings.Contains(tok, "REDACTED") {
+		return false
+	}
+	var upper, lower, digit int
+	freq := make(map[rune]int)
+	for _, r := range tok {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper++
+		case r >= 'a' && r <= 'z':
+			lower++
+		case r >= '0' && r <= '9':
+			digit++
+		}
+		freq[r]++
+	}
+	if upper == 0 || lower == 0 || digit < 2 {
+		return false
+	}
+	var entropy float64
+	n := float64(len(tok))
+	for _, c := range freq {
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= 3.8
+}
+
+// Describe renders redactions as "kind (line N), ..." for status output.
+func Describe(rs []Redaction) string {
+	parts := make([]string, 0, len(rs))
+	for _, r := range rs {
+		parts = append(parts, fmt.Sprintf("%s (line %d)", r.Kind, r.Line))
+	}
+	return strings.Join(parts, ", ")
+}
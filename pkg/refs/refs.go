@@ -0,0 +1,206 @@
+// Package refs finds the file paths and code identifiers a commit message
+// mentions that its diff does not contain, the names a model is most
+// likely to make up, and strips them from the body.
+package refs
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/lang"
+)
+
+var (
+	urlRe = regexp.MustCompile(`\b[a-zA-Z][\w+.-]*://\S+`)
+	// codeRe matches a `code span`.
+	codeRe = regexp.MustCompile("`([^`\n]+)`")
+	// pathRe matches a file name with an extension, with or without
+	// directories, e.g. "pkg/format/format.go" or "README.md".
+	pathRe = regexp.MustCompile(`(?:^|[\s(\["'])((?:[\w.-]+/)*[\w-][\w.-]*\.[A-Za-z]\w*)`)
+	// callRe matches a call without arguments, e.g. "parseLog()" or
+	// "gitdiff.Recent()".
+	callRe = regexp.MustCompile(`\b([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)\(\)`)
+	// identRe matches identifiers that no prose word looks like:
+	// lowerCamelCase and snake_case. "macOS" and "gRPC" don't match.
+	identRe = regexp.MustCompile(`\b([a-z][a-z0-9]*[A-Z][a-z]\w*|[A-Za-z]\w*_\w*[A-Za-z0-9])\b`)
+	// nameRe matches the contents of a code span naming an identifier.
+	nameRe = regexp.MustCompile(`^[A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*(?:\(\))?$`)
+)
+
+// Unknown returns the file paths and identifiers msg mentions that diff
+// does not contain, as written in msg and in order of first mention. Only
+// names that can't be ordinary words are checked: files with a known
+// extension, calls such as "parseLog()", lowerCamelCase and snake_case
+// words, and identifiers in `code spans`.
+func Unknown(msg, diff string) []string {
+	msg = urlRe.ReplaceAllString(msg, " ")
+	seen := map[string]bool{}
+	var unknown []string
+	check := func(ref string, isPath bool) {
+		if seen[ref] {
+			return
+		}
+		seen[ref] = true
+		if isPath && !strings.Contains(diff, ref) || !isPath && !hasWord(diff, name(ref)) {
+			unknown = append(unknown, ref)
+		}
+	}
+	for _, m := range codeRe.FindAllStringSubmatch(msg, -1) {
+		span := strings.TrimSpace(m[1])
+		switch {
+		case isPath(span):
+			check(span, true)
+		case nameRe.MatchString(span):
+			check(span, false)
+		}
+	}
+	msg = codeRe.ReplaceAllString(msg, " ")
+	for _, m := range pathRe.FindAllStringSubmatch(msg, -1) {
+		if p := strings.TrimRight(m[1], "."); isPath(p) {
+			check(p, true)
+		}
+	}
+	// The parts of file names and calls are not checked again.
+	msg = pathRe.ReplaceAllString(msg, " ")
+	for _, m := range callRe.FindAllStringSubmatch(msg, -1) {
+		check(m[0], false)
+	}
+	msg = callRe.ReplaceAllString(msg, " ")
+	for _, m := range identRe.FindAllStringSubmatch(msg, -1) {
+		check(m[1], false)
+	}
+	return unknown
+}
+
+// isPath reports whether s names a file of a known language, e.g.
+// "main.go" but not "e.g" or "example.com".
+func isPath(s string) bool {
+	if strings.ContainsAny(s, " \t") {
+		return false
+	}
+	_, ok := lang.Detect(s)
+	return ok
+}
+
+// name returns the identifier a reference stands for: the last part of a
+// qualified name, without call parentheses.
+func name(ref string) string {
+	ref = strings.TrimSuffix(ref, "()")
+	return ref[strings.LastIndex(ref, ".")+1:]
+}
+
+// hasWord reports whether s contains w as a whole identifier.
+func hasWord(s, w string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], w)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(w)
+		if (start == 0 || !isIdent(s[start-1])) && (end == len(s) || !isIdent(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isIdent(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+var (
+	listRe     = regexp.MustCompile(`^\s*(?:[-*•+]|\d+[.)])\s+`)
+	bodyRe     = regexp.MustCompile(`(?i)^body:\s*`)
+	sentenceRe = regexp.MustCompile(`[.!?]+(?:\s+|$)`)
+)
+
+// Strip removes the list items and sentences of msg's body that mention
+// any of refs, as returned by Unknown. The title is kept as is; a prose
+// paragraph that loses a sentence is joined onto one line.
+func Strip(msg string, refs []string) string {
+	lines := strings.Split(msg, "\n")
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i == len(lines) {
+		return msg
+	}
+	out := []string{lines[i]}
+	var para []string
+	gap := false
+	flush := func() {
+		if kept := stripParagraph(para, refs); len(kept) > 0 {
+			if gap {
+				out = append(out, "")
+			}
+			out = append(out, kept...)
+		}
+		para, gap = nil, false
+	}
+	for _, line := range lines[i+1:] {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			gap = true
+			continue
+		}
+		para = append(para, line)
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+func stripParagraph(para []string, refs []string) []string {
+	if len(para) == 0 {
+		return nil
+	}
+	label := bodyRe.FindString(para[0])
+	para = append([]string{strings.TrimPrefix(para[0], label)}, para[1:]...)
+	var kept []string
+	if listRe.MatchString(para[0]) {
+		// Continuation lines go with their item.
+		var items [][]string
+		for _, line := range para {
+			if listRe.MatchString(line) || len(items) == 0 {
+				items = append(items, nil)
+			}
+			items[len(items)-1] = append(items[len(items)-1], line)
+		}
+		for _, item := range items {
+			if !mentions(strings.Join(strings.Fields(strings.Join(item, " ")), " "), refs) {
+				kept = append(kept, item...)
+			}
+		}
+	} else if !mentions(strings.Join(para, "\n"), refs) {
+		kept = para
+	} else {
+		text := strings.Join(strings.Fields(strings.Join(para, " ")), " ")
+		var sentences []string
+		for text != "" {
+			end := len(text)
+			if loc := sentenceRe.FindStringIndex(text); loc != nil {
+				end = loc[1]
+			}
+			if s := strings.TrimSpace(text[:end]); !mentions(s, refs) {
+				sentences = append(sentences, s)
+			}
+			text = text[end:]
+		}
+		if len(sentences) > 0 {
+			kept = []string{strings.Join(sentences, " ")}
+		}
+	}
+	if len(kept) > 0 && label != "" {
+		kept[0] = label + strings.TrimSpace(kept[0])
+	}
+	return kept
+}
+
+func mentions(text string, refs []string) bool {
+	for _, r := range refs {
+		if strings.Contains(text, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package refs
+
+import (
+	"reflect"
+	"testing"
+)
+
+const diff = `diff --git a/pkg/cart/cart.go b/pkg/cart/cart.go
+--- a/pkg/cart/cart.go
++++ b/pkg/cart/cart.go
+@@ -10,3 +10,6 @@ func Total(items []Item) int {
++func roundCents(v float64) int {
++	return int(math.Round(v * 100))
++}
+`
+
+func TestUnknown(t *testing.T) {
+	msg := "Round cart totals in cart.go\n\n" +
+		"Add roundCents() and call it from `cart.Total` and applyDiscount().\n" +
+		"Update pkg/cart/cart_test.go and the max_items setting.\n" +
+		"Works on macOS and with gRPC, e.g. via https://example.com/api.go, " +
+		"much like Node.js; see `go test ./...` and `RoundCents`."
+	want := []string{"RoundCents", "pkg/cart/cart_test.go", "Node.js", "applyDiscount()", "max_items"}
+	if got := Unknown(msg, diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unknown = %q, want %q", got, want)
+	}
+	if got := Unknown("Round cart totals\n\nAdd roundCents to pkg/cart/cart.go.", diff); len(got) != 0 {
+		t.Errorf("Unknown of a grounded message = %q", got)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	refs := []string{"applyDiscount()", "cart_test.go"}
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"prose",
+			"Round totals in applyDiscount()\n\nAdd roundCents. It is called from\napplyDiscount() now. Totals are exact.\n\nTests are in cart_test.go.",
+			"Round totals in applyDiscount()\n\nAdd roundCents. Totals are exact.",
+		},
+		{
+			"list",
+			"Round totals\n\n- Add roundCents.\n- Call it from\n  applyDiscount().\n- Cover it in\n  cart_test.go.\n- Keep Total exact.",
+			"Round totals\n\n- Add roundCents.\n- Keep Total exact.",
+		},
+		{
+			"labels",
+			"Title: Round totals\nBody: Fix applyDiscount(). Round to cents.",
+			"Title: Round totals\nBody: Round to cents.",
+		},
+		{
+			"untouched",
+			"Round totals\n\nAdd roundCents,\nwrapped.",
+			"Round totals\n\nAdd roundCents,\nwrapped.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Strip(tt.in, refs); got != tt.want {
+				t.Errorf("Strip =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package risk flags changes a reviewer should look at twice, such as
+// authentication code or database migrations, from path and line rules,
+// and security-sensitive changes such as SQL built from strings.
+package risk
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// maxPaths bounds how many matching files a note names per finding.
+const maxPaths = 3
+
+// Rule flags a diff when a changed file matches one of Paths or a changed
+// line matches Pattern.
+type Rule struct {
+	// Note says what the change does, e.g. "modifies database migrations".
+	Note string `json:"note"`
+	// Paths are globs as in deny_paths: without a slash they match the base
+	// name at any depth, with one they are anchored at the repo root.
+	Paths []string `json:"paths,omitempty"`
+	// Pattern is a regular expression matched against each added and
+	// removed line, including its leading "+" or "-", so "^-" only matches
+	// removals.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Rules are checked in order; each rule yields at most one finding.
+type Rules []Rule
+
+// DefaultRules are always checked; configured rules add to them.
+var DefaultRules = Rules{
+	{
+		Note: "touches authentication or authorization code",
+		Paths: []string{"**/auth/**", "**/authn/**", "**/authz/**", "auth.*", "auth_*", "*_auth.*",
+			"*oauth*", "*login*", "*session*", "*password*", "*permission*", "*rbac*"},
+	},
+	{
+		Note:  "modifies database migrations",
+		Paths: []string{"**/migrations/**", "**/migration/**", "**/migrate/**", "*.migration.*", "*_migration.*"},
+	},
+	{
+		// A removed exported Go declaration, or a removed JS/TS export: the
+		// declaration was deleted or its signature changed.
+		Note:    "changes public API",
+		Pattern: `^-\s*(func (\([^)]*\) )?[A-Z]\w*[\[(]|type [A-Z]\w* |export (default |async )?(function|class|const|let|interface|type|enum) )`,
+	},
+	{
+		Note:  "changes CI or deployment configuration",
+		Paths: []string{".github/workflows/**", ".gitlab-ci.yml", "Jenkinsfile", "Dockerfile*", "**/k8s/**", "**/helm/**", "*.tf"},
+	},
+}
+
+// SecurityRules are the built-in rules for the security note; configured
+// security rules add to them. They look at changed lines rather than
+// paths, since security-relevant code lives anywhere.
+var SecurityRules = Rules{
+	{
+		Note:    "uses cryptography",
+		Paths:   []string{"**/crypto/**", "*crypto*", "*cipher*"},
+		Pattern: `(?i)crypto/|\b(md5|sha1|sha256|sha512|aes|rsa|ecdsa|ed25519|hmac|bcrypt|scrypt|argon2|pbkdf2|nonce|cipher)\b|\b(en|de)crypt`,
+	},
+	{
+		Note:    "changes authentication or permission checks",
+		Pattern: `(?i)\b(authenticat|authori[sz]|is_?admin|has_?(role|permission|scope)|check_?permission|require_?(auth|login|role)|login_required|PreAuthorize|csrf|jwt|bearer)`,
+	},
+	{
+		Note:    "changes CORS settings",
+		Pattern: `(?i)\bcors\b|Access-Control-Allow-|allowed_?origins|AllowOrigins`,
+	},
+	{
+		// An added line holding an SQL statement that is concatenated,
+		// formatted or interpolated (a Python f-string, a JS template)
+		// rather than parameterized.
+		Note:    "builds SQL queries from strings",
+		Pattern: `(?i)^\+.*(\bf["'].*\b(select|insert|update|delete)\b.*\b(from|into|set|where)\b.*\{|\b(select|insert|update|delete)\b.*\b(from|into|set|where)\b.*("\s*\+|\+\s*"|'\s*\+|\+\s*'|%[sdv]|\$\{|\.format\())`,
+	},
+	{
+		Note:    "disables TLS certificate verification",
+		Pattern: `^\+.*(InsecureSkipVerify:\s*true|verify\s*=\s*False|rejectUnauthorized:\s*false|CURLOPT_SSL_VERIFYPEER,\s*(0|false)|NODE_TLS_REJECT_UNAUTHORIZED)`,
+	},
+}
+
+// Validate reports rules without a note or with neither paths nor a valid
+// pattern.
+func (r Rules) Validate() error {
+	for i, rule := range r {
+		if strings.TrimSpace(rule.Note) == "" {
+			return fmt.Errorf("risk rule %d: note is required", i+1)
+		}
+		if len(rule.Paths) == 0 && rule.Pattern == "" {
+			return fmt.Errorf("risk rule %q: needs paths or a pattern", rule.Note)
+		}
+		if rule.Pattern != "" {
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("risk rule %q: invalid pattern: %w", rule.Note, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Finding is a rule that matched, with the files it matched in.
+type Finding struct {
+	Note  string   `json:"note"`
+	Paths []string `json:"paths"`
+}
+
+func (f Finding) String() string {
+	paths := f.Paths
+	more := ""
+	if len(paths) > maxPaths {
+		more = fmt.Sprintf(" and %d more", len(paths)-maxPaths)
+		paths = paths[:maxPaths]
+	}
+	return fmt.Sprintf("%s (%s%s)", f.Note, strings.Join(paths, ", "), more)
+}
+
+// Check returns the findings for a unified diff. Rules with an invalid
+// pattern only match by path; see Validate.
+func (r Rules) Check(diff string) []Finding {
+	chunks := gitdiff.SplitFiles(diff)
+	var findings []Finding
+	for _, rule := range r {
+		var re *regexp.Regexp
+		if rule.Pattern != "" {
+			re, _ = regexp.Compile(rule.Pattern)
+		}
+		var paths []string
+		for _, chunk := range chunks {
+			stats := gitdiff.ParseStat(chunk)
+			if len(stats) == 0 {
+				continue
+			}
+			if matchesPath(rule.Paths, stats[0].Path) || (re != nil && matchesLine(re, chunk)) {
+				paths = append(paths, stats[0].Path)
+			}
+		}
+		if len(paths) > 0 {
+			findings = append(findings, Finding{Note: rule.Note, Paths: paths})
+		}
+	}
+	return findings
+}
+
+func matchesPath(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if gitdiff.MatchPath(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLine reports whether an added or removed line of a file's chunk
+// matches re.
+func matchesLine(re *regexp.Regexp, chunk string) bool {
+	inHunk := false
+	for _, line := range strings.Split(chunk, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && (strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")) && re.MatchString(line):
+			return true
+		}
+	}
+	return false
+}
+
+// Note is the risk paragraph for the message body when the model cannot
+// write one: "Risk: " followed by the findings.
+func Note(findings []Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = f.String()
+	}
+	return "Risk: " + strings.Join(parts, "; ") + "."
+}
+
+// SecurityNote is the clearly marked security paragraph for the message
+// body, listing the findings of the security rules one per line.
+func SecurityNote(findings []Finding) string {
+	var b strings.Builder
+	b.WriteString("SECURITY: this change touches security-sensitive code; review it carefully.")
+	for _, f := range findings {
+		b.WriteString("\n- " + f.String())
+	}
+	return b.String()
+}
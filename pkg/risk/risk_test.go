@@ -0,0 +1,116 @@
+package risk
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/pkg/auth/token.go b/pkg/auth/token.go",
+		"@@ -1 +1 @@",
+		"-x",
+		"+y",
+		"diff --git a/db/migrations/002_users.sql b/db/migrations/002_users.sql",
+		"new file mode 100644",
+		"@@ -0,0 +1 @@",
+		"+ALTER TABLE users ADD COLUMN age int;",
+		"diff --git a/pkg/api/client.go b/pkg/api/client.go",
+		"@@ -3,3 +3,3 @@",
+		"-func (c *Client) Get(id string) error {",
+		"+func (c *Client) Get(ctx context.Context, id string) error {",
+		"diff --git a/pkg/api/internal.go b/pkg/api/internal.go",
+		"@@ -3 +3 @@",
+		"-func get(id string) error {",
+		"+func get(id int) error {",
+		"diff --git a/web/author.ts b/web/author.ts",
+		"@@ -1 +1 @@",
+		"+export function authorName() {}",
+		"",
+	}, "\n")
+	custom := Rule{Note: "changes billing", Pattern: `(?i)invoice`}
+	got := append(DefaultRules, Rule{Note: "changes the schema", Paths: []string{"*.sql"}}, custom).Check(diff)
+	want := []Finding{
+		{Note: "touches authentication or authorization code", Paths: []string{"pkg/auth/token.go"}},
+		{Note: "modifies database migrations", Paths: []string{"db/migrations/002_users.sql"}},
+		{Note: "changes public API", Paths: []string{"pkg/api/client.go"}},
+		{Note: "changes the schema", Paths: []string{"db/migrations/002_users.sql"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSecurityRules(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/store/users.go b/store/users.go",
+		"@@ -1,2 +1,2 @@",
+		`-	rows, err := db.Query("SELECT * FROM users WHERE id = $1", id)`,
+		`+	rows, err := db.Query("SELECT * FROM users WHERE id = " + id)`,
+		"diff --git a/store/orders.py b/store/orders.py",
+		"@@ -1 +1 @@",
+		`+    cur.execute(f"DELETE FROM orders WHERE id = {order_id}")`,
+		"diff --git a/store/safe.go b/store/safe.go",
+		"@@ -1 +1 @@",
+		`+	db.Query("SELECT name FROM users WHERE id = ?", id)`,
+		"diff --git a/api/server.go b/api/server.go",
+		"@@ -1,3 +1,3 @@",
+		`+	w.Header().Set("Access-Control-Allow-Origin", "*")`,
+		`+	if !user.HasPermission("admin") {`,
+		`+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}`,
+		"diff --git a/pkg/tokens/hash.go b/pkg/tokens/hash.go",
+		"@@ -1 +1 @@",
+		`+	sum := md5.Sum(token)`,
+		"diff --git a/README.md b/README.md",
+		"@@ -1 +1 @@",
+		"+Update the description of the service",
+		"",
+	}, "\n")
+	want := []Finding{
+		{Note: "uses cryptography", Paths: []string{"pkg/tokens/hash.go"}},
+		{Note: "changes authentication or permission checks", Paths: []string{"api/server.go"}},
+		{Note: "changes CORS settings", Paths: []string{"api/server.go"}},
+		{Note: "builds SQL queries from strings", Paths: []string{"store/users.go", "store/orders.py"}},
+		{Note: "disables TLS certificate verification", Paths: []string{"api/server.go"}},
+	}
+	if got := SecurityRules.Check(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("Check =\n%+v\nwant\n%+v", got, want)
+	}
+	if err := SecurityRules.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+	note := SecurityNote(want[3:4])
+	if note != "SECURITY: this change touches security-sensitive code; review it carefully.\n- builds SQL queries from strings (store/users.go, store/orders.py)" {
+		t.Errorf("SecurityNote = %q", note)
+	}
+}
+
+func TestNote(t *testing.T) {
+	findings := []Finding{
+		{Note: "modifies database migrations", Paths: []string{"a.sql", "b.sql", "c.sql", "d.sql", "e.sql"}},
+		{Note: "changes public API", Paths: []string{"api.go"}},
+	}
+	want := "Risk: modifies database migrations (a.sql, b.sql, c.sql and 2 more); changes public API (api.go)."
+	if got := Note(findings); got != want {
+		t.Errorf("Note = %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		err   string
+	}{
+		{DefaultRules, ""},
+		{Rules{{Paths: []string{"*.sql"}}}, "note is required"},
+		{Rules{{Note: "x"}}, "needs paths or a pattern"},
+		{Rules{{Note: "x", Pattern: "("}}, "invalid pattern"},
+	}
+	for _, tt := range tests {
+		err := tt.rules.Validate()
+		if (err == nil) != (tt.err == "") || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.rules, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package rpc serves the generator as JSON-RPC 2.0 over a reader/writer
+// pair (stdin/stdout in --jsonrpc mode) for editor plugins. Messages are
+// single JSON objects separated by newlines.
+//
+// Methods:
+//
+//	generate   {"diff"?, "tone"?, "title_only"?, "why"?} -> server.GenerateResponse
+//	cancel     {"id": <id of a pending generate>}        -> {"cancelled": bool}
+//	listModels {}                                        -> {"models": [...]}
+//
+// While a generate call runs, the server sends "progress" notifications
+// with params {"id": <request id>, "message": "..."}.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/server"
+)
+
+// Error codes. The -3200x and -32800 codes are specific to this server.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeGenerateFailed = -32000
+	CodeForbidden      = -32001
+	CodeRejected       = -32002
+	CodeCancelled      = -32800
+)
+
+// Error is a JSON-RPC error object.
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type session struct {
+	opts server.Options
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	pending map[string]context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Serve handles requests from in until it reaches EOF or ctx is done, then
+// waits for pending calls to finish.
+func Serve(ctx context.Context, in io.Reader, out io.Writer, opts server.Options) error {
+	s := &session{opts: opts, enc: json.NewEncoder(out), pending: make(map[string]context.CancelFunc)}
+	defer s.wg.Wait()
+
+	dec := json.NewDecoder(in)
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			var syntax *json.SyntaxError
+			if errors.As(err, &syntax) {
+				s.send(message{Error: &Error{Code: CodeParseError, Message: err.Error()}})
+				return err
+			}
+			s.send(message{Error: &Error{Code: CodeInvalidRequest, Message: err.Error()}})
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.handle(ctx, msg)
+	}
+}
+
+func (s *session) send(m message) {
+	m.JSONRPC = "2.0"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_ = s.enc.Encode(m)
+}
+
+func (s *session) reply(id json.RawMessage, result interface{}, err *Error) {
+	if len(id) == 0 {
+		return // notification
+	}
+	if err != nil {
+		s.send(message{ID: id, Error: err})
+		return
+	}
+	s.send(message{ID: id, Result: result})
+}
+
+func (s *session) handle(ctx context.Context, msg message) {
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		s.reply(msg.ID, nil, &Error{Code: CodeInvalidRequest, Message: `expected a "2.0" request with a method`})
+		return
+	}
+	switch msg.Method {
+	case "generate":
+		var req server.GenerateRequest
+		if !s.params(msg, &req) {
+			return
+		}
+		s.generate(ctx, msg.ID, req)
+	case "cancel":
+		var p struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if !s.params(msg, &p) {
+			return
+		}
+		s.mu.Lock()
+		cancel, ok := s.pending[string(p.ID)]
+		s.mu.Unlock()
+		if ok {
+			cancel()
+		}
+		s.reply(msg.ID, map[string]bool{"cancelled": ok}, nil)
+	case "listModels":
+		models, err := generator.New(s.opts.Base).ListModels(ctx)
+		if err != nil {
+			s.reply(msg.ID, nil, &Error{Code: CodeGenerateFailed, Message: err.Error()})
+			return
+		}
+		s.reply(msg.ID, map[string][]string{"models": models}, nil)
+	default:
+		s.reply(msg.ID, nil, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("unknown method %q", msg.Method)})
+	}
+}
+
+// params decodes msg.Params into v, replying with an error on failure.
+func (s *session) params(msg message, v interface{}) bool {
+	if len(msg.Params) == 0 {
+		return true
+	}
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		s.reply(msg.ID, nil, &Error{Code: CodeInvalidParams, Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+// generate runs in the background so cancel and other calls are served
+// meanwhile.
+func (s *session) generate(ctx context.Context, id json.RawMessage, req server.GenerateRequest) {
+	ctx, cancel := context.WithCancel(ctx)
+	key := string(id)
+	if len(id) > 0 {
+		s.mu.Lock()
+		s.pending[key] = cancel
+		s.mu.Unlock()
+	}
+	progress := func(format string, args ...interface{}) {
+		s.send(message{Method: "progress", Params: mustJSON(map[string]interface{}{
+			"id":      id,
+			"message": fmt.Sprintf(format, args...),
+		})})
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		resp, err := s.opts.Generate(ctx, req, progress)
+
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+
+		if err != nil {
+			s.reply(id, nil, toError(ctx, err))
+			return
+		}
+		s.reply(id, resp, nil)
+	}()
+}
+
+func toError(ctx context.Context, err error) *Error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return &Error{Code: CodeCancelled, Message: "request cancelled"}
+	}
+	e := &Error{Code: CodeGenerateFailed, Message: err.Error()}
+	switch {
+	case errors.Is(err, server.ErrForbidden):
+		e.Code = CodeForbidden
+	case errors.Is(err, server.ErrRejected):
+		e.Code = CodeRejected
+	}
+	var gerr *generator.Error
+	if errors.As(err, &gerr) {
+		data := map[string]string{"stage": string(gerr.Stage)}
+		if gerr.RequestID != "" {
+			data["request_id"] = gerr.RequestID
+		}
+		e.Data = data
+	}
+	return e
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
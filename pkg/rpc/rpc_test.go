@@ -0,0 +1,116 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/server"
+)
+
+// fakeClient answers with the model name; the "slow" model blocks until its
+// request is cancelled.
+type fakeClient struct{}
+
+func (fakeClient) Check(context.Context) error { return nil }
+
+func (fakeClient) Generate(ctx context.Context, req llm.Request) (string, error) {
+	if req.Model == "slow" {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return req.Model + " output", nil
+}
+
+func (fakeClient) ListModels(context.Context) ([]string, error) {
+	return []string{"summ", "style"}, nil
+}
+
+// run feeds the request lines to Serve and returns every message it wrote.
+func run(t *testing.T, base generator.Config, lines ...string) []message {
+	t.Helper()
+	var out bytes.Buffer
+	in := strings.NewReader(strings.Join(lines, "\n"))
+	if err := Serve(context.Background(), in, &out, server.Options{Base: base}); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	var msgs []message
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs
+}
+
+// response returns the reply with the given id.
+func response(t *testing.T, msgs []message, id string) message {
+	t.Helper()
+	for _, m := range msgs {
+		if string(m.ID) == id && m.Method == "" {
+			return m
+		}
+	}
+	t.Fatalf("no response with id %s in %+v", id, msgs)
+	return message{}
+}
+
+func TestGenerate(t *testing.T) {
+	base := generator.Config{Client: fakeClient{}, SummarizerModel: "summ", StyleModel: "style"}
+	msgs := run(t, base, `{"jsonrpc":"2.0","id":1,"method":"generate","params":{"diff":"diff --git a/a b/a\n+x\n"}}`)
+
+	resp := response(t, msgs, "1")
+	result, _ := json.Marshal(resp.Result)
+	var got server.GenerateResponse
+	if err := json.Unmarshal(result, &got); err != nil || got.Message != "style output" || got.Summary != "summ output" {
+		t.Errorf("result = %s (%v)", result, resp.Error)
+	}
+	progress := 0
+	for _, m := range msgs {
+		if m.Method == "progress" {
+			progress++
+		}
+	}
+	if progress == 0 {
+		t.Error("no progress notifications sent")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	base := generator.Config{Client: fakeClient{}, SummarizerModel: "slow", StyleModel: "style"}
+	msgs := run(t, base,
+		`{"jsonrpc":"2.0","id":"a","method":"generate","params":{"diff":"diff --git a/a b/a\n+x\n"}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"cancel","params":{"id":"a"}}`,
+	)
+	if e := response(t, msgs, `"a"`).Error; e == nil || e.Code != CodeCancelled {
+		t.Errorf("cancelled generate error = %+v", e)
+	}
+	if r := response(t, msgs, "2").Result; r.(map[string]interface{})["cancelled"] != true {
+		t.Errorf("cancel result = %v", r)
+	}
+}
+
+func TestListModelsAndErrors(t *testing.T) {
+	msgs := run(t, generator.Config{Client: fakeClient{}},
+		`{"jsonrpc":"2.0","id":1,"method":"listModels"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"bogus"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"generate","params":{"tone":7}}`,
+		`{"id":4,"method":"generate"}`,
+	)
+	models, _ := json.Marshal(response(t, msgs, "1").Result)
+	if string(models) != `{"models":["summ","style"]}` {
+		t.Errorf("listModels = %s", models)
+	}
+	for id, code := range map[string]int{"2": CodeMethodNotFound, "3": CodeInvalidParams, "4": CodeInvalidRequest} {
+		if e := response(t, msgs, id).Error; e == nil || e.Code != code {
+			t.Errorf("id %s error = %+v, want code %d", id, e, code)
+		}
+	}
+}
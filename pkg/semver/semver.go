@@ -0,0 +1,106 @@
+// Package semver infers whether a change calls for a patch, minor or major
+// release, for release automation that reads it from the message trailer
+// or the server's JSON response.
+package semver
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/changelog"
+	"github.com/kylegalloway/commit-writer/pkg/format"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+	"github.com/kylegalloway/commit-writer/pkg/gosem"
+)
+
+// Trailer is the message trailer key that carries the bump, e.g.
+// "Semver-Impact: minor".
+const Trailer = "Semver-Impact"
+
+// maxReasons bounds how many API changes are listed as reasons.
+const maxReasons = 5
+
+// Bump is a semantic version increment.
+type Bump string
+
+const (
+	// None is a change that needs no release, such as docs or tests.
+	None  Bump = "none"
+	Patch Bump = "patch"
+	Minor Bump = "minor"
+	Major Bump = "major"
+)
+
+var rank = map[Bump]int{None: 0, Patch: 1, Minor: 2, Major: 3}
+
+// noRelease are the conventional types that leave released behavior alone.
+var noRelease = map[string]bool{"docs": true, "doc": true, "test": true, "style": true, "ci": true, "chore": true}
+
+// Impact is the inferred bump and what it was inferred from.
+type Impact struct {
+	Bump    Bump     `json:"bump"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// raise sets the bump to b if that is higher, recording why.
+func (i *Impact) raise(b Bump, reason string) {
+	if rank[b] < rank[i.Bump] {
+		return
+	}
+	if rank[b] > rank[i.Bump] {
+		i.Reasons = nil
+	}
+	i.Bump = b
+	if len(i.Reasons) < maxReasons {
+		i.Reasons = append(i.Reasons, reason)
+	}
+}
+
+// Infer derives the impact from the message's conventional type and
+// breaking change markers ("feat!:", "BREAKING CHANGE:") and from changes
+// to exported Go declarations: removing one or changing its signature is
+// major, adding one or changing an exported type is minor. Only the
+// reasons for the final bump are kept.
+func Infer(msg string, api []gosem.APIChange) Impact {
+	e := changelog.Parse(gitdiff.Commit{Message: format.StripLabels(msg)})
+	var imp Impact
+	switch {
+	case e.Type == "":
+		imp.raise(Patch, "no conventional type")
+	case e.Type == "feat":
+		imp.raise(Minor, `"feat" type`)
+	case noRelease[e.Type]:
+		imp.raise(None, fmt.Sprintf("%q type", e.Type))
+	default:
+		imp.raise(Patch, fmt.Sprintf("%q type", e.Type))
+	}
+	if e.Breaking {
+		imp.raise(Major, "breaking change marker")
+	}
+	for _, c := range api {
+		switch {
+		case !c.Surface():
+		case c.Kind == "removed" || c.Kind == "signature":
+			imp.raise(Major, "exported API: "+c.String())
+		default:
+			imp.raise(Minor, "exported API: "+c.String())
+		}
+	}
+	return imp
+}
+
+// PublicGoFile reports whether a repo path is a non-test Go file outside
+// internal and testdata directories, i.e. one that can declare public API.
+// Package main is not public API either, but that needs the source.
+func PublicGoFile(name string) bool {
+	if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "internal" || part == "testdata" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package semver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gosem"
+)
+
+func TestInfer(t *testing.T) {
+	removed := gosem.APIChange{Package: "cart", Change: gosem.Change{Kind: "removed", Decl: "func", Name: "Legacy", Old: "func Legacy()"}}
+	added := gosem.APIChange{Package: "cart", Change: gosem.Change{Kind: "added", Decl: "func", Name: "Checkout", New: "func Checkout() error"}}
+	body := gosem.APIChange{Package: "cart", Change: gosem.Change{Kind: "modified", Decl: "func", Name: "Run"}}
+	tests := []struct {
+		msg  string
+		api  []gosem.APIChange
+		want Impact
+	}{
+		{"Fix cart totals", nil, Impact{Patch, []string{"no conventional type"}}},
+		{"fix(cart): round totals", []gosem.APIChange{body}, Impact{Patch, []string{`"fix" type`}}},
+		{"Title: feat: add checkout", nil, Impact{Minor, []string{`"feat" type`}}},
+		{"docs: update README", nil, Impact{None, []string{`"docs" type`}}},
+		{"fix: handle empty cart", []gosem.APIChange{added}, Impact{Minor, []string{"exported API: cart: added func Checkout() error"}}},
+		{"refactor!: drop v1 client", nil, Impact{Major, []string{"breaking change marker"}}},
+		{"feat: new cart\n\nBREAKING CHANGE: Legacy is gone", []gosem.APIChange{removed, added}, Impact{Major, []string{"breaking change marker", "exported API: cart: removed func Legacy()"}}},
+	}
+	for _, tt := range tests {
+		if got := Infer(tt.msg, tt.api); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Infer(%q) = %+v, want %+v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestPublicGoFile(t *testing.T) {
+	tests := map[string]bool{
+		"pkg/cart/cart.go":         true,
+		"cart.go":                  true,
+		"pkg/cart/cart_test.go":    false,
+		"internal/db/db.go":        false,
+		"pkg/x/internal/y.go":      false,
+		"pkg/cart/testdata/bad.go": false,
+		"README.md":                false,
+	}
+	for name, want := range tests {
+		if got := PublicGoFile(name); got != want {
+			t.Errorf("PublicGoFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
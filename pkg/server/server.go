@@ -0,0 +1,198 @@
+// Package server exposes the generator over HTTP for GUI clients and editor
+// plugins:
+//
+//	POST /generate  {"diff": "...", "tone": "...", "title_only": false}
+//	             -> {"message": "...", "summary": "...", "offline": false, ...}
+//	GET  /health   -> {"status": "ok", "model_backend": "ok"}
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/changes"
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/redact"
+	"github.com/kylegalloway/commit-writer/pkg/risk"
+	"github.com/kylegalloway/commit-writer/pkg/semver"
+	"github.com/kylegalloway/commit-writer/pkg/todo"
+	"github.com/kylegalloway/commit-writer/pkg/trace"
+)
+
+// maxBody bounds the size of a /generate request.
+const maxBody = 16 << 20
+
+// Options configures the handler.
+type Options struct {
+	// Base is the generator configuration each request starts from.
+	Base generator.Config
+	// CheckTone vets a per-request tone; nil allows any tone.
+	CheckTone func(tone string) error
+	// Finish post-processes each generated message; nil returns it as is.
+	Finish func(ctx context.Context, msg string) (string, error)
+}
+
+// GenerateRequest is the body of POST /generate.
+type GenerateRequest struct {
+	Diff      string `json:"diff"`
+	Tone      string `json:"tone,omitempty"`
+	TitleOnly *bool  `json:"title_only,omitempty"`
+	// Why is the author's reason for the change, like --why.
+	Why string `json:"why,omitempty"`
+}
+
+// GenerateResponse is the reply to POST /generate.
+type GenerateResponse struct {
+	Message string `json:"message"`
+	Summary string `json:"summary,omitempty"`
+	// Changes is the summary as a structured change list.
+	Changes    *changes.Summary   `json:"changes,omitempty"`
+	Offline    bool               `json:"offline,omitempty"`
+	Omitted    []string           `json:"omitted,omitempty"`
+	Redactions []redact.Redaction `json:"redactions,omitempty"`
+	// Semver is the release bump the change calls for. Exported API
+	// changes only count when the diff is read from the repository, not
+	// posted.
+	Semver semver.Impact `json:"semver"`
+	// Todos are the TODO comments the diff adds, with --todos.
+	Todos []todo.Item `json:"todos,omitempty"`
+	// Security lists the security rules the diff matches, with --security.
+	Security []risk.Finding `json:"security,omitempty"`
+	// Confidence is how far the message can be trusted without a close
+	// look.
+	Confidence generator.Confidence `json:"confidence"`
+	// RequestID identifies the run in the model server's logs and the
+	// audit log.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	Stage     string `json:"stage,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Request errors, matched with errors.Is.
+var (
+	ErrForbidden = errors.New("forbidden by policy")
+	ErrRejected  = errors.New("message rejected")
+)
+
+// Generate runs one request against opts.Base, reporting progress through
+// status. An empty Diff reads the staged diff of the current repository.
+func (opts Options) Generate(ctx context.Context, req GenerateRequest, status func(string, ...interface{})) (*GenerateResponse, error) {
+	cfg := opts.Base
+	cfg.Diff = req.Diff
+	cfg.Summary, cfg.SaveSummary = "", ""
+	if status != nil {
+		cfg.Status = status
+	}
+	if req.Tone != "" {
+		if opts.CheckTone != nil {
+			if err := opts.CheckTone(req.Tone); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrForbidden, err)
+			}
+		}
+		cfg.Tone = req.Tone
+	}
+	if req.TitleOnly != nil {
+		cfg.TitleOnly = *req.TitleOnly
+	}
+	if req.Why != "" {
+		cfg.Why = req.Why
+	}
+
+	res, err := generator.New(cfg).Generate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := strings.TrimSpace(res.Message)
+	if opts.Finish != nil {
+		if msg, err = opts.Finish(ctx, msg); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRejected, err)
+		}
+	}
+	return &GenerateResponse{
+		Message:    msg,
+		Summary:    res.Summary,
+		Changes:    res.Changes,
+		Offline:    res.Offline,
+		Omitted:    res.Omitted,
+		Redactions: res.Redactions,
+		Semver:     res.Semver,
+		Todos:      res.Todos,
+		Security:   res.Security,
+		Confidence: res.Confidence,
+		RequestID:  res.RequestID,
+	}, nil
+}
+
+// New returns the HTTP handler.
+func New(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		backend := "ok"
+		if err := generator.New(opts.Base).Check(r.Context()); err != nil {
+			backend = "unreachable: " + err.Error()
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "model_backend": backend})
+	})
+	mux.HandleFunc("/generate", func(w http.ResponseWriter, r *http.Request) {
+		// A caller's X-Request-ID is adopted so its logs and ours agree.
+		id := r.Header.Get(llm.RequestIDHeader)
+		if !llm.ValidRequestID(id) {
+			id = llm.NewRequestID()
+		}
+		w.Header().Set(llm.RequestIDHeader, id)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "use POST", RequestID: id})
+			return
+		}
+		var req GenerateRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request: %v", err), RequestID: id})
+			return
+		}
+		// The server's working directory is not the caller's repository.
+		if strings.TrimSpace(req.Diff) == "" {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: "diff is required", RequestID: id})
+			return
+		}
+
+		// A caller's traceparent header makes the request part of its trace.
+		ctx := llm.WithRequestID(trace.Extract(r.Context(), r.Header.Get("traceparent")), id)
+		ctx, span := opts.Base.Tracer.Start(ctx, "POST /generate")
+		resp, err := opts.Generate(ctx, req, nil)
+		span.Finish(err)
+		if err != nil {
+			status := http.StatusBadGateway
+			switch {
+			case errors.Is(err, ErrForbidden):
+				status = http.StatusForbidden
+			case errors.Is(err, ErrRejected):
+				status = http.StatusUnprocessableEntity
+			}
+			e := errorResponse{Error: err.Error(), RequestID: id}
+			var gerr *generator.Error
+			if errors.As(err, &gerr) {
+				e.Stage = string(gerr.Stage)
+			}
+			writeJSON(w, status, e)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/generator"
+	"github.com/kylegalloway/commit-writer/pkg/llm"
+	"github.com/kylegalloway/commit-writer/pkg/semver"
+)
+
+// echoClient answers every request with the model name, so tests can see
+// which stage produced what.
+type echoClient struct{ checkErr error }
+
+func (c echoClient) Check(context.Context) error { return c.checkErr }
+
+func (c echoClient) Generate(_ context.Context, req llm.Request) (string, error) {
+	return req.Model + " says hi", nil
+}
+
+const diff = "diff --git a/a.txt b/a.txt\nnew file mode 100644\n--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+token = \"hunter22hunter\"\n"
+
+func post(t *testing.T, h http.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(body)))
+	return rec
+}
+
+func TestGenerate(t *testing.T) {
+	h := New(Options{
+		Base: generator.Config{Client: echoClient{}, SummarizerModel: "summ", StyleModel: "style"},
+		Finish: func(_ context.Context, msg string) (string, error) {
+			return strings.ToUpper(msg), nil
+		},
+	})
+	body, _ := json.Marshal(GenerateRequest{Diff: diff, Tone: "dry"})
+	rec := post(t, h, string(body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var resp GenerateResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Message != "STYLE SAYS HI" || resp.Summary != "summ says hi" {
+		t.Errorf("response = %+v", resp)
+	}
+	if len(resp.Redactions) != 1 || resp.Redactions[0].Kind != "password" {
+		t.Errorf("redactions = %+v", resp.Redactions)
+	}
+	if resp.Semver.Bump != semver.Patch {
+		t.Errorf("semver = %+v", resp.Semver)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	h := New(Options{
+		Base:      generator.Config{Client: echoClient{checkErr: errors.New("down")}, NoFallback: true},
+		CheckTone: func(tone string) error { return errors.New("tone " + tone + " forbidden") },
+	})
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"bad json", "{", http.StatusBadRequest},
+		{"no diff", `{"diff":" "}`, http.StatusBadRequest},
+		{"forbidden tone", `{"diff":"x","tone":"rude"}`, http.StatusForbidden},
+		{"backend down", `{"diff":"x"}`, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		if rec := post(t, h, tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/generate", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /generate status = %d", rec.Code)
+	}
+}
+
+// idClient answers with the request ID the model was sent.
+type idClient struct{}
+
+func (idClient) Check(context.Context) error { return nil }
+
+func (idClient) Generate(ctx context.Context, _ llm.Request) (string, error) {
+	return llm.RequestID(ctx), nil
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	h := New(Options{Base: generator.Config{Client: idClient{}, SummarizerModel: "summ", StyleModel: "style"}})
+	body, _ := json.Marshal(GenerateRequest{Diff: diff})
+	for _, sent := range []string{"caller-1", "", "bad id\n"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/generate", strings.NewReader(string(body)))
+		if sent != "" {
+			req.Header.Set(llm.RequestIDHeader, sent)
+		}
+		h.ServeHTTP(rec, req)
+		var resp GenerateResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		id := rec.Header().Get(llm.RequestIDHeader)
+		if sent == "caller-1" && id != sent || !llm.ValidRequestID(id) {
+			t.Errorf("sent %q: %s = %q", sent, llm.RequestIDHeader, id)
+		}
+		if resp.RequestID != id || resp.Summary != id {
+			t.Errorf("sent %q: request_id = %q, model saw %q, want %q", sent, resp.RequestID, resp.Summary, id)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	for _, checkErr := range []error{nil, errors.New("down")} {
+		h := New(Options{Base: generator.Config{Client: echoClient{checkErr: checkErr}}})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var resp map[string]string
+		_ = json.NewDecoder(rec.Body).Decode(&resp)
+		wantOK := checkErr == nil
+		if rec.Code != http.StatusOK || resp["status"] != "ok" || (resp["model_backend"] == "ok") != wantOK {
+			t.Errorf("health with check error %v = %d %v", checkErr, rec.Code, resp)
+		}
+	}
+}
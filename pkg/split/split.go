@@ -0,0 +1,117 @@
+// Package split groups the files of a diff into suggested commits, from the
+// model's answer or, without a usable one, by directory.
+package split
+
+import (
+	"path"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/classify"
+	"github.com/kylegalloway/commit-writer/pkg/deps"
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// Group is one suggested commit.
+type Group struct {
+	// Title is a short description of the group, not the commit message.
+	Title string
+	Files []string
+}
+
+// Parse reads the model's grouping, blocks of "GROUP: <title>" followed by
+// "FILES: <file>, <file>" lines. Files not in files are dropped, a file
+// listed twice stays in its first group, and files left out are gathered
+// in a final group. ok is false when no group names a known file.
+func Parse(out string, files []string) (groups []Group, ok bool) {
+	known := map[string]bool{}
+	for _, f := range files {
+		known[f] = true
+	}
+	assigned := map[string]bool{}
+	var cur *Group
+	for _, line := range strings.Split(out, "\n") {
+		// Models like to add Markdown: "**GROUP:** ...", "- FILES: ...".
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		value = strings.Trim(value, "* ")
+		switch strings.ToUpper(strings.Trim(key, "*-#> \t")) {
+		case "GROUP":
+			groups = append(groups, Group{Title: strings.TrimSpace(value)})
+			cur = &groups[len(groups)-1]
+		case "FILES":
+			if cur == nil {
+				continue
+			}
+			for _, f := range strings.Split(value, ",") {
+				f = strings.Trim(strings.TrimSpace(f), "`\"'")
+				if known[f] && !assigned[f] {
+					cur.Files = append(cur.Files, f)
+					assigned[f] = true
+				}
+			}
+		}
+	}
+	var kept []Group
+	for _, g := range groups {
+		if len(g.Files) > 0 {
+			kept = append(kept, g)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, false
+	}
+	rest := Group{Title: "Remaining changes"}
+	for _, f := range files {
+		if !assigned[f] {
+			rest.Files = append(rest.Files, f)
+		}
+	}
+	if len(rest.Files) > 0 {
+		kept = append(kept, rest)
+	}
+	return kept, true
+}
+
+// ByDirectory groups files by directory, keeping tests and their fixtures
+// with the directory they test and putting documentation and dependency
+// manifests in groups of their own. Groups are in order of first file.
+func ByDirectory(stats []gitdiff.FileStat) []Group {
+	var groups []Group
+	index := map[string]int{}
+	for _, st := range stats {
+		key, title := directory(st.Path)
+		switch {
+		case deps.IsManifest(st.Path):
+			key, title = "\x00deps", "Dependency updates"
+		case classify.IsDocs(st.Path):
+			key, title = "\x00docs", "Documentation"
+		}
+		i, seen := index[key]
+		if !seen {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, Group{Title: title})
+		}
+		groups[i].Files = append(groups[i].Files, st.Path)
+	}
+	return groups
+}
+
+// testDirs are directory names that hold tests or fixtures for their
+// parent directory.
+var testDirs = map[string]bool{"test": true, "tests": true, "__tests__": true, "testdata": true, "spec": true}
+
+// directory returns the grouping key and title for a file: its directory,
+// above any test directories.
+func directory(name string) (string, string) {
+	dir := path.Dir(name)
+	for dir != "." && testDirs[path.Base(dir)] {
+		dir = path.Dir(dir)
+	}
+	if dir == "." {
+		return dir, "Changes at the repository root"
+	}
+	return dir, "Changes in " + dir
+}
@@ -0,0 +1,52 @@
+package split
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+func TestParse(t *testing.T) {
+	files := []string{"auth/session.go", "auth/session_test.go", "README.md", "ui/app.ts", "go.mod"}
+	out := `Here is the grouping:
+
+GROUP: Expire idle sessions
+FILES: auth/session.go, ` + "`auth/session_test.go`" + `
+
+**GROUP:** Update the app shell
+**FILES:** ui/app.ts, auth/session.go, unknown.go
+
+GROUP: Nothing
+FILES: missing.go
+`
+	got, ok := Parse(out, files)
+	want := []Group{
+		{Title: "Expire idle sessions", Files: []string{"auth/session.go", "auth/session_test.go"}},
+		{Title: "Update the app shell", Files: []string{"ui/app.ts"}},
+		{Title: "Remaining changes", Files: []string{"README.md", "go.mod"}},
+	}
+	if !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %+v, %v\nwant %+v", got, ok, want)
+	}
+	if _, ok := Parse("I can't group these.", files); ok {
+		t.Error("Parse accepted an answer without groups")
+	}
+}
+
+func TestByDirectory(t *testing.T) {
+	var stats []gitdiff.FileStat
+	for _, p := range []string{"pkg/a/a.go", "docs/guide.md", "pkg/b/b.go", "go.mod", "pkg/a/testdata/in.txt", "go.sum", "main.go", "tests/test_main.py"} {
+		stats = append(stats, gitdiff.FileStat{Path: p})
+	}
+	want := []Group{
+		{Title: "Changes in pkg/a", Files: []string{"pkg/a/a.go", "pkg/a/testdata/in.txt"}},
+		{Title: "Documentation", Files: []string{"docs/guide.md"}},
+		{Title: "Changes in pkg/b", Files: []string{"pkg/b/b.go"}},
+		{Title: "Dependency updates", Files: []string{"go.mod", "go.sum"}},
+		{Title: "Changes at the repository root", Files: []string{"main.go", "tests/test_main.py"}},
+	}
+	if got := ByDirectory(stats); !reflect.DeepEqual(got, want) {
+		t.Errorf("ByDirectory =\n%+v\nwant\n%+v", got, want)
+	}
+}
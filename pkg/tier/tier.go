@@ -0,0 +1,53 @@
+// Package tier picks models by the size of the diff, so a one-line fix can
+// use a small, fast model while a large refactor gets a bigger one.
+package tier
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tier names the models for diffs of up to MaxLines changed lines.
+type Tier struct {
+	// MaxLines is the largest diff the tier covers, in added plus removed
+	// lines; 0 covers any size and only suits the last tier.
+	MaxLines int `json:"max_lines,omitempty"`
+	// SummarizerModel and StyleModel replace the configured models; empty
+	// keeps them.
+	SummarizerModel string `json:"summarizer_model,omitempty"`
+	StyleModel      string `json:"style_model,omitempty"`
+}
+
+// Tiers are checked in order; the first that covers the diff is used.
+type Tiers []Tier
+
+// Validate checks that the tiers grow, that only the last is unbounded and
+// that each names a model.
+func (ts Tiers) Validate() error {
+	prev := 0
+	for i, t := range ts {
+		switch {
+		case t.SummarizerModel == "" && t.StyleModel == "":
+			return fmt.Errorf("model tier %d names no model", i+1)
+		case t.MaxLines < 0:
+			return fmt.Errorf("model tier %d: max_lines must not be negative", i+1)
+		case t.MaxLines == 0 && i < len(ts)-1:
+			return errors.New("only the last model tier may leave out max_lines")
+		case t.MaxLines != 0 && t.MaxLines <= prev:
+			return fmt.Errorf("model tier %d: max_lines must be larger than the tier before's", i+1)
+		}
+		prev = t.MaxLines
+	}
+	return nil
+}
+
+// Pick returns the first tier covering a diff of lines changed lines, and
+// false when none does.
+func (ts Tiers) Pick(lines int) (Tier, bool) {
+	for _, t := range ts {
+		if t.MaxLines == 0 || lines <= t.MaxLines {
+			return t, true
+		}
+	}
+	return Tier{}, false
+}
@@ -0,0 +1,38 @@
+package tier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPick(t *testing.T) {
+	ts := Tiers{
+		{MaxLines: 10, SummarizerModel: "tiny", StyleModel: "tiny"},
+		{MaxLines: 300, SummarizerModel: "medium"},
+		{StyleModel: "large"},
+	}
+	if err := ts.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for lines, want := range map[int]string{1: "tiny", 10: "tiny", 11: "medium", 300: "medium", 5000: ""} {
+		if got, ok := ts.Pick(lines); !ok || got.SummarizerModel != want {
+			t.Errorf("Pick(%d) = %+v, %v; want summarizer %q", lines, got, ok, want)
+		}
+	}
+	if _, ok := ts[:2].Pick(301); ok {
+		t.Error("Pick beyond the last bounded tier succeeded")
+	}
+
+	for _, tt := range []struct {
+		ts   Tiers
+		want string
+	}{
+		{Tiers{{MaxLines: 10}}, "names no model"},
+		{Tiers{{SummarizerModel: "a"}, {MaxLines: 10, SummarizerModel: "b"}}, "only the last"},
+		{Tiers{{MaxLines: 10, SummarizerModel: "a"}, {MaxLines: 10, SummarizerModel: "b"}}, "must be larger"},
+	} {
+		if err := tt.ts.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.ts, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package todo finds TODO, FIXME and XXX comments added by a diff, so the
+// message can call them out for reviewers.
+package todo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+const (
+	// maxListed bounds how many items a section lists.
+	maxListed = 10
+	// maxText bounds the quoted comment text.
+	maxText = 100
+)
+
+// commentRe matches a TODO, FIXME or XXX word that starts a comment in
+// most languages: //, #, /*, *, --, ; or <!--.
+var commentRe = regexp.MustCompile(`(?:^|[\s;{}()])(?://+|#+|/\*+|\*|--|;+|<!--)\s*((?:TODO|FIXME|XXX)\b.*?)\s*(?:\*/|-->)?$`)
+
+var hunkRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)`)
+
+// Item is one added comment.
+type Item struct {
+	Path string `json:"path"`
+	// Line is the line number in the new version of the file.
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+func (i Item) String() string {
+	return fmt.Sprintf("%s:%d: %s", i.Path, i.Line, i.Text)
+}
+
+// Find returns the TODO, FIXME and XXX comments on the added lines of a
+// unified diff, in diff order.
+func Find(diff string) []Item {
+	var items []Item
+	for _, chunk := range gitdiff.SplitFiles(diff) {
+		stats := gitdiff.ParseStat(chunk)
+		if len(stats) == 0 {
+			continue
+		}
+		line, inHunk := 0, false
+		for _, l := range strings.Split(chunk, "\n") {
+			if m := hunkRe.FindStringSubmatch(l); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				inHunk = true
+				continue
+			}
+			if !inHunk || l == "" {
+				continue
+			}
+			switch l[0] {
+			case '+':
+				if m := commentRe.FindStringSubmatch(l[1:]); m != nil {
+					text := m[1]
+					if len(text) > maxText {
+						text = text[:maxText] + " ..."
+					}
+					items = append(items, Item{Path: stats[0].Path, Line: line, Text: text})
+				}
+				line++
+			case ' ':
+				line++
+			}
+		}
+	}
+	return items
+}
+
+// Section lists the items as a message body paragraph, or returns "" when
+// there are none.
+func Section(items []Item) string {
+	if len(items) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Added TODOs:")
+	for i, it := range items {
+		if i == maxListed {
+			fmt.Fprintf(&b, "\n- and %d more", len(items)-maxListed)
+			break
+		}
+		b.WriteString("\n- " + it.String())
+	}
+	return b.String()
+}
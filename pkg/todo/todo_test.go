@@ -0,0 +1,60 @@
+package todo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	diff := strings.Join([]string{
+		"diff --git a/a.go b/a.go",
+		"--- a/a.go",
+		"+++ b/a.go",
+		"@@ -10,3 +10,5 @@ func A() {",
+		" \tx := 1",
+		"-\t// TODO: old note",
+		"+\t// TODO(kim): handle retries",
+		"+\ty := x // FIXME overflow on 32-bit",
+		" \treturn",
+		"+\ts := \"TODO: not a comment\"",
+		"diff --git a/b.py b/b.py",
+		"@@ -0,0 +1,3 @@",
+		"+import os",
+		"+# XXX: hack until the API is fixed",
+		"+todo = 1",
+		"diff --git a/c.sql b/c.sql",
+		"@@ -1 +1,2 @@",
+		" SELECT 1;",
+		"+-- TODO drop the legacy table",
+		"diff --git a/d.html b/d.html",
+		"@@ -1 +1 @@",
+		"+<!-- TODO: translate -->",
+		"",
+	}, "\n")
+	want := []Item{
+		{"a.go", 11, "TODO(kim): handle retries"},
+		{"a.go", 12, "FIXME overflow on 32-bit"},
+		{"b.py", 2, "XXX: hack until the API is fixed"},
+		{"c.sql", 2, "TODO drop the legacy table"},
+		{"d.html", 1, "TODO: translate"},
+	}
+	if got := Find(diff); !reflect.DeepEqual(got, want) {
+		t.Errorf("Find =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSection(t *testing.T) {
+	if Section(nil) != "" {
+		t.Error("Section(nil) not empty")
+	}
+	var items []Item
+	for i := 1; i <= maxListed+2; i++ {
+		items = append(items, Item{"a.go", i, fmt.Sprintf("TODO %d", i)})
+	}
+	got := Section(items)
+	if !strings.HasPrefix(got, "Added TODOs:\n- a.go:1: TODO 1\n") || !strings.HasSuffix(got, "\n- a.go:10: TODO 10\n- and 2 more") {
+		t.Errorf("Section =\n%s", got)
+	}
+}
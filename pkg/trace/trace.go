@@ -0,0 +1,355 @@
+// Package trace records OpenTelemetry spans for where a run spends its
+// time, prints them as a tree and exports them to a collector with OTLP
+// over HTTP in its JSON encoding.
+//
+// A nil *Tracer and the nil *Span it starts do nothing, so code can be
+// instrumented unconditionally.
+package trace
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer collects finished spans.
+type Tracer struct {
+	// Endpoint is the OTLP/HTTP traces URL, e.g.
+	// http://localhost:4318/v1/traces. Without one, spans are only
+	// reported.
+	Endpoint string
+	// Service is the service.name resource attribute.
+	Service string
+	// Client sends the exports; nil uses a client with a 10 second
+	// timeout.
+	Client *http.Client
+	// Report, when set, gets each trace as a tree of span durations once
+	// its root span ends.
+	Report io.Writer
+
+	mu      sync.Mutex
+	pending []*Span
+}
+
+// Span is one timed operation.
+type Span struct {
+	tracer *Tracer
+	// local is set when the parent is a span of this process, not one
+	// from a traceparent header.
+	local bool
+
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte
+	Name     string
+	Start    time.Time
+	End      time.Time
+	// Attrs are string, int or bool values.
+	Attrs map[string]interface{}
+	// Err is the error the operation failed with, if any.
+	Err string
+}
+
+type spanKey struct{}
+
+// Start begins a span named name, the child of the span in ctx if any, and
+// returns a context carrying it.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Span{tracer: t, Name: name, Start: time.Now(), SpanID: spanID()}
+	if parent, ok := ctx.Value(spanKey{}).(*Span); ok {
+		s.TraceID, s.ParentID, s.local = parent.TraceID, parent.SpanID, parent.tracer != nil
+	} else {
+		_, _ = rand.Read(s.TraceID[:])
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Set adds an attribute.
+func (s *Span) Set(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if s.Attrs == nil {
+		s.Attrs = map[string]interface{}{}
+	}
+	s.Attrs[key] = value
+}
+
+// Finish ends the span, failed with err when it is not nil.
+func (s *Span) Finish(err error) {
+	if s == nil || !s.End.IsZero() {
+		return
+	}
+	s.End = time.Now()
+	if err != nil {
+		s.Err = err.Error()
+	}
+	s.tracer.finished(s)
+}
+
+// finished queues s for export and reports its trace when s is the root.
+func (t *Tracer) finished(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, s)
+	if s.local {
+		return
+	}
+	var spans, rest []*Span
+	for _, p := range t.pending {
+		if p.TraceID == s.TraceID {
+			spans = append(spans, p)
+		} else {
+			rest = append(rest, p)
+		}
+	}
+	if t.Report != nil {
+		report(t.Report, s, spans)
+	}
+	if t.Endpoint == "" {
+		t.pending = rest
+	}
+}
+
+// report writes the tree of spans under root with their durations.
+func report(w io.Writer, root *Span, spans []*Span) {
+	children := map[[8]byte][]*Span{}
+	for _, s := range spans {
+		if s != root {
+			children[s.ParentID] = append(children[s.ParentID], s)
+		}
+	}
+	fmt.Fprintf(w, "trace %s\n", hex.EncodeToString(root.TraceID[:]))
+	var walk func(s *Span, depth int)
+	walk = func(s *Span, depth int) {
+		line := strings.Repeat("  ", depth+1) + s.Name
+		fmt.Fprintf(w, "%-40s %8s", line, s.End.Sub(s.Start).Round(time.Millisecond))
+		if s.Err != "" {
+			fmt.Fprintf(w, "  error: %s", s.Err)
+		}
+		fmt.Fprintln(w)
+		kids := children[s.SpanID]
+		sort.SliceStable(kids, func(i, j int) bool { return kids[i].Start.Before(kids[j].Start) })
+		for _, k := range kids {
+			walk(k, depth+1)
+		}
+	}
+	walk(root, 0)
+}
+
+// Flush exports the finished spans to Endpoint. Spans that could not be
+// sent are dropped rather than retried.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil || t.Endpoint == "" {
+		return nil
+	}
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(t.export(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("exporting %d span(s): %w", len(spans), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("exporting %d span(s): %s: %s", len(spans), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// FlushEvery flushes every d until ctx is done, then once more, calling
+// warn with export errors.
+func (t *Tracer) FlushEvery(ctx context.Context, d time.Duration, warn func(error)) {
+	if t == nil || t.Endpoint == "" {
+		return
+	}
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if err := t.Flush(context.Background()); err != nil {
+				warn(err)
+			}
+			return
+		}
+		if err := t.Flush(ctx); err != nil {
+			warn(err)
+		}
+	}
+}
+
+// The OTLP/HTTP JSON encoding of a trace export request.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []spanJSON `json:"spans"`
+	}
+	scope struct {
+		Name string `json:"name"`
+	}
+	spanJSON struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            status     `json:"status"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+	status struct {
+		// Code is 0 unset, 1 ok or 2 error.
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// spanKindInternal is OTLP's SPAN_KIND_INTERNAL.
+const spanKindInternal = 1
+
+// export encodes spans for the OTLP/HTTP endpoint.
+func (t *Tracer) export(spans []*Span) exportRequest {
+	service := t.Service
+	if service == "" {
+		service = "commit-writer"
+	}
+	out := make([]spanJSON, len(spans))
+	for i, s := range spans {
+		j := spanJSON{
+			TraceID:           hex.EncodeToString(s.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.SpanID[:]),
+			Name:              s.Name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attrs),
+		}
+		if s.ParentID != ([8]byte{}) {
+			j.ParentSpanID = hex.EncodeToString(s.ParentID[:])
+		}
+		if s.Err != "" {
+			j.Status = status{Code: 2, Message: s.Err}
+		}
+		out[i] = j
+	}
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: attributes(map[string]interface{}{"service.name": service})},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: "commit-writer"}, Spans: out}},
+	}}}
+}
+
+// attributes encodes attrs sorted by key.
+func attributes(attrs map[string]interface{}) []keyValue {
+	var kvs []keyValue
+	for k, v := range attrs {
+		var av anyValue
+		switch v := v.(type) {
+		case int:
+			s := strconv.Itoa(v)
+			av.IntValue = &s
+		case bool:
+			av.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			av.StringValue = &s
+		}
+		kvs = append(kvs, keyValue{Key: k, Value: av})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+// Extract returns ctx with the remote parent of a W3C traceparent header,
+// e.g. "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", so the
+// spans started from it join the caller's trace. An invalid header is
+// ignored.
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return ctx
+	}
+	var s Span
+	if _, err := hex.Decode(s.TraceID[:], []byte(parts[1])); err != nil || s.TraceID == ([16]byte{}) {
+		return ctx
+	}
+	if _, err := hex.Decode(s.SpanID[:], []byte(parts[2])); err != nil || s.SpanID == ([8]byte{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, &s)
+}
+
+// EnvEndpoint returns the OTLP/HTTP traces URL from the standard
+// OpenTelemetry environment variables: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// as is, or OTEL_EXPORTER_OTLP_ENDPOINT with /v1/traces appended.
+func EnvEndpoint() string {
+	if u := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); u != "" {
+		return u
+	}
+	if u := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); u != "" {
+		return strings.TrimRight(u, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// spanID returns a random, non-zero span ID.
+func spanID() [8]byte {
+	var id [8]byte
+	for id == ([8]byte{}) {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
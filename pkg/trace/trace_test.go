@@ -0,0 +1,70 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	var got exportRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding export: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	var report bytes.Buffer
+	tr := &Tracer{Endpoint: srv.URL, Report: &report}
+	ctx := Extract(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tr.Start(ctx, "generate")
+	_, child := tr.Start(ctx, "llm summary")
+	child.Set("gen_ai.request.model", "gemma3:4B")
+	child.Set("prompt_bytes", 1200)
+	child.Finish(errors.New("timeout"))
+	root.Finish(nil)
+
+	if out := report.String(); !strings.HasPrefix(out, "trace 4bf92f3577b34da6a3ce929d0e0e4736\n  generate") || !strings.Contains(out, "\n    llm summary") || !strings.Contains(out, "error: timeout") {
+		t.Errorf("report =\n%s", out)
+	}
+	if err := tr.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 || spans[1].ParentSpanID != "00f067aa0ba902b7" || spans[0].ParentSpanID != spans[1].SpanID {
+		t.Fatalf("exported spans = %+v", spans)
+	}
+	if spans[0].Status.Code != 2 || len(spans[0].Attributes) != 2 || *spans[0].Attributes[1].Value.IntValue != "1200" {
+		t.Errorf("child span = %+v", spans[0])
+	}
+	if err := tr.Flush(context.Background()); err != nil || len(tr.pending) != 0 {
+		t.Errorf("second Flush: %v, %d pending", err, len(tr.pending))
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tr *Tracer
+	ctx, span := tr.Start(context.Background(), "generate")
+	span.Set("k", "v")
+	span.Finish(nil)
+	if ctx != context.Background() || tr.Flush(ctx) != nil {
+		t.Error("nil Tracer did something")
+	}
+}
+
+func TestReportOnlyDropsSpans(t *testing.T) {
+	tr := &Tracer{}
+	ctx, root := tr.Start(context.Background(), "generate")
+	_, child := tr.Start(ctx, "diff")
+	child.Finish(nil)
+	root.Finish(nil)
+	if len(tr.pending) != 0 {
+		t.Errorf("%d spans kept without an endpoint", len(tr.pending))
+	}
+}
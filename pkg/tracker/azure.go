@@ -0,0 +1,100 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// AzureURL is the Azure DevOps Services root.
+const AzureURL = "https://dev.azure.com"
+
+var workItemPattern = regexp.MustCompile(`(?i)(?:^|[^a-z0-9])ab[#-]?([0-9]+)`)
+
+// FindWorkItem returns the Azure Boards mention for the first work item
+// referenced in branch as AB#1234, AB-1234 or ab1234, e.g. "AB#1234".
+func FindWorkItem(branch string) (string, bool) {
+	m := workItemPattern.FindStringSubmatch(branch)
+	if m == nil {
+		return "", false
+	}
+	return "AB#" + m[1], true
+}
+
+// Azure fetches work items from Azure Boards.
+type Azure struct {
+	// URL defaults to AzureURL; set it for Azure DevOps Server.
+	URL          string
+	Organization string
+	Project      string
+	// Token is a personal access token with Work Items (Read) scope.
+	Token string
+	// Client defaults to an HTTP client with a 30 second timeout.
+	Client *http.Client
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Fetch returns the title and description of the work item key, given as
+// "AB#1234" or "1234".
+func (a *Azure) Fetch(ctx context.Context, key string) (*Ticket, error) {
+	id := strings.TrimPrefix(strings.ToUpper(key), "AB#")
+	base := a.URL
+	if base == "" {
+		base = AzureURL
+	}
+	project := strings.TrimRight(base, "/") + "/" + neturl.PathEscape(a.Organization) + "/" + neturl.PathEscape(a.Project)
+	endpoint := project + "/_apis/wit/workitems/" + neturl.PathEscape(id) + "?fields=System.Title,System.Description&api-version=7.0"
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errorf("azure boards", key, err)
+	}
+	r.Header.Set("Accept", "application/json")
+	if a.Token != "" {
+		r.SetBasicAuth("", a.Token)
+	}
+
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, errorf("azure boards", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errorf("azure boards", key, fmt.Errorf("status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(b))))
+	}
+	// Azure DevOps answers unauthenticated API calls with a sign-in page.
+	if ct := resp.Header.Get("Content-Type"); !strings.Contains(ct, "json") {
+		return nil, errorf("azure boards", key, errors.New("unexpected non-JSON response; check the token"))
+	}
+
+	var item struct {
+		Fields struct {
+			Title       string `json:"System.Title"`
+			Description string `json:"System.Description"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&item); err != nil {
+		return nil, errorf("azure boards", key, fmt.Errorf("failed to decode response: %w", err))
+	}
+	// Descriptions are HTML.
+	desc := htmlTag.ReplaceAllString(strings.ReplaceAll(item.Fields.Description, "<br>", "\n"), "")
+	return &Ticket{
+		Key:         "AB#" + id,
+		Title:       item.Fields.Title,
+		Description: strings.TrimSpace(html.UnescapeString(desc)),
+		URL:         project + "/_workitems/edit/" + id,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package tracker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFindWorkItem(t *testing.T) {
+	tests := map[string]string{
+		"feature/AB#1234-login": "AB#1234",
+		"ab-77-fix":             "AB#77",
+		"users/me/ab1234":       "AB#1234",
+		"tab-12":                "",
+		"main":                  "",
+	}
+	for branch, want := range tests {
+		got, ok := FindWorkItem(branch)
+		if got != want || ok != (want != "") {
+			t.Errorf("FindWorkItem(%q) = %q, %v; want %q", branch, got, ok, want)
+		}
+	}
+}
+
+func TestAzureFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "pat" {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>Sign in</html>"))
+			return
+		}
+		if r.URL.Path != "/acme/Web/_apis/wit/workitems/1234" || r.URL.Query().Get("api-version") == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"id":1234,"fields":{"System.Title":"Fix login","System.Description":"<div>Tokens &amp; sessions<br>expire early.</div>"}}`))
+	}))
+	defer srv.Close()
+
+	a := &Azure{URL: srv.URL, Organization: "acme", Project: "Web", Token: "pat"}
+	got, err := a.Fetch(context.Background(), "AB#1234")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := Ticket{Key: "AB#1234", Title: "Fix login", Description: "Tokens & sessions\nexpire early.", URL: srv.URL + "/acme/Web/_workitems/edit/1234"}
+	if *got != want {
+		t.Errorf("Fetch = %+v, want %+v", *got, want)
+	}
+
+	a.Token = "expired"
+	if _, err := a.Fetch(context.Background(), "AB#1234"); err == nil {
+		t.Error("Fetch with a sign-in page response succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// Jira fetches issues from the Jira REST API (v2, which returns plain-text
+// descriptions).
+type Jira struct {
+	// URL is the site root, e.g. https://example.atlassian.net.
+	URL string
+	// Email selects basic auth with Token as the API token (Jira Cloud).
+	// Without it Token is sent as a bearer personal access token (Jira
+	// Server and Data Center).
+	Email string
+	Token string
+	// Client defaults to an HTTP client with a 30 second timeout.
+	Client *http.Client
+}
+
+// Fetch returns the summary and description of the issue key.
+func (j *Jira) Fetch(ctx context.Context, key string) (*Ticket, error) {
+	base := strings.TrimRight(j.URL, "/")
+	endpoint := base + "/rest/api/2/issue/" + neturl.PathEscape(key) + "?fields=summary,description"
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errorf("jira", key, err)
+	}
+	r.Header.Set("Accept", "application/json")
+	switch {
+	case j.Email != "":
+		r.SetBasicAuth(j.Email, j.Token)
+	case j.Token != "":
+		r.Header.Set("Authorization", "Bearer "+j.Token)
+	}
+
+	client := j.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, errorf("jira", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errorf("jira", key, fmt.Errorf("status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+
+	var issue struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary     string `json:"summary"`
+			Description string `json:"description"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return nil, errorf("jira", key, fmt.Errorf("failed to decode response: %w", err))
+	}
+	if issue.Key == "" {
+		issue.Key = key
+	}
+	return &Ticket{
+		Key:         issue.Key,
+		Title:       issue.Fields.Summary,
+		Description: issue.Fields.Description,
+		URL:         base + "/browse/" + issue.Key,
+	}, nil
+}
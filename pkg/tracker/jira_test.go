@@ -0,0 +1,56 @@
+package tracker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJiraFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/issue/PROJ-1" || r.URL.Query().Get("fields") != "summary,description" {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@example.com" || pass != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"key":"PROJ-1","fields":{"summary":"Fix login","description":"Users are logged out on refresh."}}`))
+	}))
+	defer srv.Close()
+
+	j := &Jira{URL: srv.URL + "/", Email: "me@example.com", Token: "tok"}
+	got, err := j.Fetch(context.Background(), "PROJ-1")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := Ticket{Key: "PROJ-1", Title: "Fix login", Description: "Users are logged out on refresh.", URL: srv.URL + "/browse/PROJ-1"}
+	if *got != want {
+		t.Errorf("Fetch = %+v, want %+v", *got, want)
+	}
+
+	j.Token = "wrong"
+	if _, err := j.Fetch(context.Background(), "PROJ-1"); err == nil || !strings.Contains(err.Error(), "status=401") {
+		t.Errorf("Fetch with bad credentials = %v, want status=401", err)
+	}
+}
+
+func TestJiraBearerToken(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"fields":{"summary":"Title"}}`))
+	}))
+	defer srv.Close()
+
+	got, err := (&Jira{URL: srv.URL, Token: "pat"}).Fetch(context.Background(), "OPS-2")
+	if err != nil || got.Key != "OPS-2" || got.Title != "Title" {
+		t.Fatalf("Fetch = %+v, %v", got, err)
+	}
+	if auth != "Bearer pat" {
+		t.Errorf("Authorization = %q, want bearer token", auth)
+	}
+}
@@ -0,0 +1,90 @@
+package tracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// LinearURL is the Linear GraphQL endpoint.
+const LinearURL = "https://api.linear.app/graphql"
+
+const linearIssueQuery = `query Issue($id: String!) { issue(id: $id) { identifier title description url } }`
+
+// Linear fetches issues from the Linear GraphQL API.
+type Linear struct {
+	// URL defaults to LinearURL.
+	URL string
+	// APIKey is a personal API key, sent as is in the Authorization header.
+	APIKey string
+	// Client defaults to an HTTP client with a 30 second timeout.
+	Client *http.Client
+}
+
+// Fetch returns the title and description of the issue key, e.g. "ENG-123".
+func (l *Linear) Fetch(ctx context.Context, key string) (*Ticket, error) {
+	endpoint := l.URL
+	if endpoint == "" {
+		endpoint = LinearURL
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     linearIssueQuery,
+		"variables": map[string]string{"id": key},
+	})
+	if err != nil {
+		return nil, errorf("linear", key, err)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, errorf("linear", key, err)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if l.APIKey != "" {
+		r.Header.Set("Authorization", l.APIKey)
+	}
+
+	client := l.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, errorf("linear", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errorf("linear", key, fmt.Errorf("status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(b))))
+	}
+
+	var result struct {
+		Data struct {
+			Issue *struct {
+				Identifier  string `json:"identifier"`
+				Title       string `json:"title"`
+				Description string `json:"description"`
+				URL         string `json:"url"`
+			} `json:"issue"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errorf("linear", key, fmt.Errorf("failed to decode response: %w", err))
+	}
+	if len(result.Errors) > 0 {
+		return nil, errorf("linear", key, errors.New(result.Errors[0].Message))
+	}
+	issue := result.Data.Issue
+	if issue == nil {
+		return nil, errorf("linear", key, errors.New("issue not found"))
+	}
+	return &Ticket{Key: issue.Identifier, Title: issue.Title, Description: issue.Description, URL: issue.URL}, nil
+}
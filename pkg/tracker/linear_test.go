@@ -0,0 +1,51 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLinearFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_api_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Query     string            `json:"query"`
+			Variables map[string]string `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !strings.Contains(req.Query, "issue(id: $id)") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Variables["id"] != "ENG-123" {
+			_, _ = w.Write([]byte(`{"data":{"issue":null},"errors":[{"message":"Entity not found: Issue"}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"issue":{"identifier":"ENG-123","title":"Fix login","description":"Tokens expire early.","url":"https://linear.app/acme/issue/ENG-123"}}}`))
+	}))
+	defer srv.Close()
+
+	l := &Linear{URL: srv.URL, APIKey: "lin_api_key"}
+	got, err := l.Fetch(context.Background(), "ENG-123")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	want := Ticket{Key: "ENG-123", Title: "Fix login", Description: "Tokens expire early.", URL: "https://linear.app/acme/issue/ENG-123"}
+	if *got != want {
+		t.Errorf("Fetch = %+v, want %+v", *got, want)
+	}
+
+	if _, err := l.Fetch(context.Background(), "ENG-999"); err == nil || !strings.Contains(err.Error(), "Entity not found") {
+		t.Errorf("Fetch of unknown issue = %v, want GraphQL error", err)
+	}
+	l.APIKey = ""
+	if _, err := l.Fetch(context.Background(), "ENG-123"); err == nil || !strings.Contains(err.Error(), "status=401") {
+		t.Errorf("Fetch without key = %v, want status=401", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package tracker resolves the issue tracker ticket named in a branch and
+// fetches its title and description, so the summarizer can explain why a
+// change was made and not only what it does.
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxDescription bounds how much of a ticket description goes into a prompt.
+const maxDescription = 4000
+
+// Ticket is an issue fetched from a tracker.
+type Ticket struct {
+	Key         string
+	Title       string
+	Description string
+	// URL is the ticket's web page.
+	URL string
+}
+
+// Context formats the ticket for a prompt: the key and title, then the
+// description, truncated to a few thousand characters.
+func (t Ticket) Context() string {
+	s := t.Key + ": " + t.Title
+	if desc := strings.TrimSpace(t.Description); desc != "" {
+		if len(desc) > maxDescription {
+			desc = desc[:maxDescription] + "\n[description truncated]"
+		}
+		s += "\n\n" + desc
+	}
+	return s
+}
+
+// Tracker fetches tickets by key.
+type Tracker interface {
+	Fetch(ctx context.Context, key string) (*Ticket, error)
+}
+
+var keyPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9])([A-Za-z][A-Za-z0-9]+)-([0-9]+)`)
+
+// FindKey returns the first ticket key such as "PROJ-123" in branch. When
+// projects is empty only upper-case keys are recognized, so a branch like
+// "fix-2-bugs" is not mistaken for a ticket; otherwise keys of the listed
+// projects match in any case and are returned upper-cased.
+func FindKey(branch string, projects []string) (string, bool) {
+	for _, m := range keyPattern.FindAllStringSubmatch(branch, -1) {
+		project, num := m[1], m[2]
+		if len(projects) == 0 {
+			if project == strings.ToUpper(project) {
+				return project + "-" + num, true
+			}
+			continue
+		}
+		for _, p := range projects {
+			if strings.EqualFold(p, project) {
+				return strings.ToUpper(project) + "-" + num, true
+			}
+		}
+	}
+	return "", false
+}
+
+// errorf wraps a fetch failure with the tracker name and ticket key.
+func errorf(tracker, key string, err error) error {
+	return fmt.Errorf("%s: failed to fetch %s: %w", tracker, key, err)
+}
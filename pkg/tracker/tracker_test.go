@@ -0,0 +1,40 @@
+package tracker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindKey(t *testing.T) {
+	tests := []struct {
+		branch   string
+		projects []string
+		want     string
+	}{
+		{"feature/PROJ-123-add-login", nil, "PROJ-123"},
+		{"PROJ-7", nil, "PROJ-7"},
+		{"fix-2-bugs", nil, ""},
+		{"feature/proj-123-add-login", nil, ""},
+		{"feature/proj-123-add-login", []string{"PROJ"}, "PROJ-123"},
+		{"fix-2-bugs/ENG-9", []string{"eng"}, "ENG-9"},
+		{"XPROJ-1", []string{"PROJ"}, ""},
+		{"main", nil, ""},
+	}
+	for _, tt := range tests {
+		got, ok := FindKey(tt.branch, tt.projects)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("FindKey(%q, %v) = %q, %v; want %q", tt.branch, tt.projects, got, ok, tt.want)
+		}
+	}
+}
+
+func TestTicketContext(t *testing.T) {
+	tk := Ticket{Key: "PROJ-1", Title: "Fix login", Description: strings.Repeat("x", maxDescription+10)}
+	got := tk.Context()
+	if !strings.HasPrefix(got, "PROJ-1: Fix login\n\n") || !strings.HasSuffix(got, "[description truncated]") {
+		t.Errorf("Context() = %q...", got[:40])
+	}
+	if got := (Ticket{Key: "PROJ-1", Title: "Fix login"}).Context(); got != "PROJ-1: Fix login" {
+		t.Errorf("Context() without description = %q", got)
+	}
+}
@@ -0,0 +1,159 @@
+// Package tunnel reaches Ollama on another machine through an SSH port
+// forward, for an --ollama URL such as ssh://me@gpu-box. It runs the
+// system's ssh, so keys, agents, ~/.ssh/config and jump hosts work as they
+// do on the command line.
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sshCommand is the ssh client to run.
+var sshCommand = "ssh"
+
+// Tunnel is a running ssh port forward.
+type Tunnel struct {
+	// URL is the local /api/generate endpoint that reaches the remote
+	// Ollama.
+	URL string
+	// Host is the machine forwarded to.
+	Host string
+
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// IsSSH reports whether rawURL asks for a tunnel.
+func IsSSH(rawURL string) bool {
+	return strings.HasPrefix(strings.ToLower(rawURL), "ssh://")
+}
+
+// target is what an ssh:// URL names.
+type target struct {
+	dest    string // [user@]host for ssh
+	sshPort string // empty for ssh's default
+	port    string // Ollama's port on the remote machine
+}
+
+// parse reads ssh://[user@]host[:ssh port][?port=ollama port].
+func parse(rawURL string) (target, error) {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return target{}, fmt.Errorf("invalid ollama URL: %w", err)
+	}
+	if u.Hostname() == "" {
+		return target{}, fmt.Errorf("ollama URL %q has no host to tunnel to", rawURL)
+	}
+	t := target{dest: u.Hostname(), sshPort: u.Port(), port: "11434"}
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			return target{}, errors.New("ssh:// ollama URLs take no password; use a key or ssh-agent")
+		}
+		t.dest = u.User.Username() + "@" + t.dest
+	}
+	if p := u.Query().Get("port"); p != "" {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return target{}, fmt.Errorf("ollama URL %q: port=%s is not a port", rawURL, p)
+		}
+		t.port = p
+	}
+	return t, nil
+}
+
+// args are ssh's arguments forwarding localPort to Ollama on t.
+func (t target) args(localPort int) []string {
+	args := []string{
+		"-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-o", "ConnectTimeout=10",
+		"-o", "ServerAliveInterval=30",
+		"-L", fmt.Sprintf("127.0.0.1:%d:localhost:%s", localPort, t.port),
+	}
+	if t.sshPort != "" {
+		args = append(args, "-p", t.sshPort)
+	}
+	return append(args, "--", t.dest)
+}
+
+// Open starts ssh forwarding a free local port to the remote Ollama and
+// waits until the forward accepts connections, ssh gives up or ctx ends.
+func Open(ctx context.Context, rawURL string) (*Tunnel, error) {
+	t, err := parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("ssh tunnel: %w", err)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(sshCommand, t.args(port)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("ssh tunnel to %s: %w", t.dest, err)
+	}
+	tun := &Tunnel{
+		URL:  fmt.Sprintf("http://127.0.0.1:%d/api/generate", port),
+		Host: t.dest,
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+	go func() {
+		_ = cmd.Wait()
+		close(tun.done)
+	}()
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+			conn.Close()
+			return tun, nil
+		}
+		select {
+		case <-tun.done:
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = cmd.ProcessState.String()
+			}
+			return nil, fmt.Errorf("ssh tunnel to %s: %s", t.dest, msg)
+		case <-ctx.Done():
+			tun.Close()
+			return nil, fmt.Errorf("ssh tunnel to %s: %w", t.dest, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops ssh. A nil Tunnel does nothing.
+func (t *Tunnel) Close() {
+	if t == nil {
+		return
+	}
+	select {
+	case <-t.done:
+	default:
+		_ = t.cmd.Process.Kill()
+		<-t.done
+	}
+}
+
+// freePort returns a loopback port nothing listens on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,279 @@
+// Package update replaces the running commit-writer binary with the latest
+// GitHub release, for the single static binary most installs are.
+//
+// A release carries one raw binary per platform, named by AssetName, and
+// a checksums.txt in sha256sum format listing them. A download whose
+// SHA-256 does not match its line there is refused.
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylegalloway/commit-writer/pkg/forge"
+)
+
+// Repo is the repository releases come from.
+const Repo = "kylegalloway/commit-writer"
+
+// ChecksumsFile is the release asset listing every binary's SHA-256.
+const ChecksumsFile = "checksums.txt"
+
+// maxBinary bounds a downloaded asset.
+const maxBinary = 256 << 20
+
+// Release is a published GitHub release.
+type Release struct {
+	Tag string
+	URL string
+	// Assets maps file names to download URLs.
+	Assets map[string]string
+}
+
+// Updater finds and downloads releases through the GitHub REST API.
+type Updater struct {
+	// URL is the API root; forge.GitHubAPI when empty.
+	URL string
+	// Repo is "owner/name"; Repo when empty.
+	Repo string
+	// Token, when set, is sent as a bearer token for a higher rate limit.
+	Token string
+	// Client defaults to an HTTP client with a 5 minute timeout.
+	Client *http.Client
+}
+
+// AssetName is the name of the release binary for a platform, e.g.
+// "commit-writer_linux_amd64" or "commit-writer_windows_arm64.exe".
+func AssetName(goos, goarch string) string {
+	name := "commit-writer_" + goos + "_" + goarch
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Latest returns the newest release that is not a draft or pre-release.
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	repo := u.Repo
+	if repo == "" {
+		repo = Repo
+	}
+	body, err := u.get(ctx, fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(u.apiRoot(), "/"), repo), 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("latest release: %w", err)
+	}
+	var out struct {
+		Tag    string `json:"tag_name"`
+		URL    string `json:"html_url"`
+		Assets []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("latest release: %w", err)
+	}
+	rel := &Release{Tag: out.Tag, URL: out.URL, Assets: map[string]string{}}
+	for _, a := range out.Assets {
+		rel.Assets[a.Name] = a.URL
+	}
+	return rel, nil
+}
+
+// Download fetches the named asset of rel and checks it against the
+// release's checksums.
+func (u *Updater) Download(ctx context.Context, rel *Release, name string) ([]byte, error) {
+	assetURL, ok := rel.Assets[name]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s binary", rel.Tag, name)
+	}
+	sumsURL, ok := rel.Assets[ChecksumsFile]
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s to verify %s against", rel.Tag, ChecksumsFile, name)
+	}
+	sums, err := u.get(ctx, sumsURL, 1<<20)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", ChecksumsFile, err)
+	}
+	want, err := checksum(sums, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.get(ctx, assetURL, maxBinary)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", name, err)
+	}
+	got := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(got[:]), want) {
+		return nil, fmt.Errorf("%s: SHA-256 %x does not match %s (%s); not installing it", name, got, ChecksumsFile, want)
+	}
+	return data, nil
+}
+
+// checksum finds name's SHA-256 in a sha256sum listing.
+func checksum(sums []byte, name string) (string, error) {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// "*" marks a file hashed in binary mode.
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name && len(fields[0]) == sha256.Size*2 {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list %s", ChecksumsFile, name)
+}
+
+// get fetches url, reading at most limit bytes.
+func (u *Updater) get(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	// Downloads are redirected to other hosts; the token is only for the API.
+	if u.Token != "" && strings.HasPrefix(url, strings.TrimSuffix(u.apiRoot(), "/")) {
+		req.Header.Set("Authorization", "Bearer "+u.Token)
+	}
+	client := u.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("larger than %d bytes", limit)
+	}
+	return data, nil
+}
+
+// apiRoot is URL or forge.GitHubAPI.
+func (u *Updater) apiRoot() string {
+	if u.URL != "" {
+		return u.URL
+	}
+	return forge.GitHubAPI
+}
+
+// Newer reports whether the release tag is a later version than current.
+// A current version that is not a release, such as "dev" or "(devel)",
+// is never older: updating it is a choice, not an upgrade.
+func Newer(tag, current string) bool {
+	t, ok := parse(tag)
+	c, cok := parse(current)
+	if !ok || !cok {
+		return false
+	}
+	for i := range t {
+		if t[i] != c[i] {
+			return t[i] > c[i]
+		}
+	}
+	return false
+}
+
+// IsRelease reports whether version is a released "vX.Y.Z".
+func IsRelease(version string) bool {
+	_, ok := parse(version)
+	return ok
+}
+
+// parse reads "v1.2.3" or "1.2.3". Pre-release and build suffixes are not
+// releases.
+func parse(version string) ([3]int, bool) {
+	var v [3]int
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// Managed returns the package manager that owns the binary at path, such
+// as "Homebrew", or "" when it was installed by hand. Replacing a managed
+// binary would leave the package manager's records wrong.
+func Managed(path string) string {
+	path = filepath.ToSlash(path)
+	switch {
+	case strings.Contains(path, "/Cellar/"):
+		return "Homebrew"
+	case strings.HasPrefix(path, "/nix/store/"):
+		return "Nix"
+	case strings.Contains(path, "/scoop/apps/"):
+		return "Scoop"
+	case strings.HasPrefix(path, "/usr/bin/"):
+		return "the system package manager"
+	}
+	return ""
+}
+
+// Replace atomically swaps the binary at exe for data, keeping its
+// permissions. Windows cannot overwrite a running executable, so there the
+// old one is first renamed to exe+".old", which the next Replace removes.
+func Replace(exe string, data []byte) error {
+	info, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".commit-writer-update-*")
+	if err != nil {
+		return fmt.Errorf("cannot write next to %s: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		return os.Rename(tmp.Name(), exe)
+	}
+	old := exe + ".old"
+	if err := os.Remove(old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(exe, old); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		_ = os.Rename(old, exe)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLatestAndDownload(t *testing.T) {
+	binary := []byte("new binary")
+	sum := sha256.Sum256(binary)
+	name := AssetName("linux", "amd64")
+	sums := fmt.Sprintf("%x  %s\n%x  commit-writer_darwin_arm64\n", sum, name, sha256.Sum256(nil))
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/kylegalloway/commit-writer/releases/latest":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				t.Errorf("API request without the token")
+			}
+			fmt.Fprintf(w, `{"tag_name":"v1.4.0","html_url":"https://example.com/v1.4.0","assets":[
+				{"name":%q,"browser_download_url":"%s/dl/bin"},
+				{"name":"commit-writer_darwin_arm64","browser_download_url":"%s/dl/bad"},
+				{"name":"checksums.txt","browser_download_url":"%s/dl/sums"}]}`, name, srv.URL, srv.URL, srv.URL)
+		case "/dl/bin":
+			_, _ = w.Write(binary)
+		case "/dl/bad":
+			_, _ = w.Write([]byte("tampered"))
+		case "/dl/sums":
+			fmt.Fprint(w, sums)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	u := &Updater{URL: srv.URL, Token: "tok"}
+	rel, err := u.Latest(context.Background())
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if rel.Tag != "v1.4.0" || len(rel.Assets) != 3 {
+		t.Fatalf("release = %+v", rel)
+	}
+	got, err := u.Download(context.Background(), rel, name)
+	if err != nil || string(got) != string(binary) {
+		t.Errorf("Download = %q, %v", got, err)
+	}
+	if _, err := u.Download(context.Background(), rel, "commit-writer_darwin_arm64"); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("tampered Download error = %v", err)
+	}
+	if _, err := u.Download(context.Background(), rel, AssetName("windows", "amd64")); err == nil {
+		t.Error("Download of a missing asset succeeded")
+	}
+}
+
+func TestNewer(t *testing.T) {
+	for _, tc := range []struct {
+		tag, current string
+		want         bool
+	}{
+		{"v1.4.0", "v1.3.9", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v2.0.0", "1.9.9", true},
+		{"v1.4.0", "v1.4.0", false},
+		{"v1.3.0", "v1.4.0", false},
+		{"v1.4.0", "dev", false},
+		{"v1.4.0", "(devel)", false},
+		{"v1.5.0-rc.1", "v1.4.0", false},
+	} {
+		if got := Newer(tc.tag, tc.current); got != tc.want {
+			t.Errorf("Newer(%q, %q) = %v", tc.tag, tc.current, got)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	exe := filepath.Join(t.TempDir(), "commit-writer")
+	if err := os.WriteFile(exe, []byte("old"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(exe, []byte("new")); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	got, err := os.ReadFile(exe)
+	if err != nil || string(got) != "new" {
+		t.Fatalf("binary = %q, %v", got, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(exe))
+	if len(entries) != 1 {
+		t.Errorf("left %d files behind", len(entries)-1)
+	}
+}
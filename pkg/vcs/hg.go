@@ -0,0 +1,129 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Mercurial is hg or, with Command "sl", Sapling, which descends from it
+// and keeps its commands. Neither has a staging area, so Diff returns every
+// change in the working directory, which is what "hg commit" records.
+type Mercurial struct {
+	// Command is the executable: "hg" when empty, or "sl".
+	Command string
+}
+
+// command returns the executable to run.
+func (m Mercurial) command() string {
+	if m.Command == "" {
+		return "hg"
+	}
+	return m.Command
+}
+
+// hg runs a command with HGPLAIN set, which Sapling honors too, so user
+// aliases, colors and the pager don't change its output.
+func (m Mercurial) hg(args ...string) ([]byte, error) {
+	cmd := exec.Command(m.command(), args...)
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	return cmd.Output()
+}
+
+// hgPatterns turns repo-relative paths into hg file patterns that match
+// them from any working directory.
+func hgPatterns(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	pats := []string{"--"}
+	for _, p := range paths {
+		pats = append(pats, "path:"+p)
+	}
+	return pats
+}
+
+// Name implements VCS.
+func (m Mercurial) Name() string { return m.command() }
+
+// Root implements VCS.
+func (m Mercurial) Root() string {
+	out, err := m.hg("root")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Diff implements VCS. --git makes hg write git's extended headers, which
+// name added, deleted and renamed files.
+func (m Mercurial) Diff(paths ...string) (string, error) {
+	out, err := m.hg(append([]string{"diff", "--git"}, hgPatterns(paths)...)...)
+	if err != nil {
+		return "", fmt.Errorf("%s diff failed: %w; output=%s", m.command(), err, stderr(err))
+	}
+	return string(out), nil
+}
+
+// HasChanges implements VCS. Missing files don't count: hg commit only
+// records them after "hg remove".
+func (m Mercurial) HasChanges() bool {
+	out, err := m.hg("status", "--modified", "--added", "--removed")
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// Branch implements VCS: the active bookmark, else the named branch,
+// which Sapling doesn't have.
+func (m Mercurial) Branch() string {
+	if out, err := m.hg("log", "-r", ".", "-T", "{activebookmark}"); err == nil && len(out) > 0 {
+		return strings.TrimSpace(string(out))
+	}
+	if m.command() == "sl" {
+		return ""
+	}
+	out, err := m.hg("branch")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Head implements VCS.
+func (m Mercurial) Head() string {
+	out, err := m.hg("log", "-r", ".", "-T", "{node}")
+	if err != nil {
+		return ""
+	}
+	return nullNode(strings.TrimSpace(string(out)))
+}
+
+// CommitArgs implements VCS.
+func (m Mercurial) CommitArgs(msg, msgPath string) []string {
+	return []string{m.command(), "commit", "-l", msgPath}
+}
+
+// Comment implements VCS: hg drops the "HG:" lines of its editor
+// template, Sapling the "SL:" ones.
+func (m Mercurial) Comment() string {
+	return strings.ToUpper(m.command()) + ":"
+}
+
+// nullNode returns "" for the all-zero ID hg, Sapling and jj report
+// before the first commit, and id otherwise.
+func nullNode(id string) string {
+	if strings.Trim(id, "0") == "" {
+		return ""
+	}
+	return id
+}
+
+// stderr returns what a failed command printed to stderr, if known.
+func stderr(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return ""
+}
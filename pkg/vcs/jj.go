@@ -0,0 +1,103 @@
+package vcs
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Jujutsu is jj. The working copy is itself a change, so Diff returns
+// that change's diff, the one "jj commit" and "jj describe" describe.
+type Jujutsu struct{}
+
+// jj runs a jj command without color, so its output is plain text.
+func jj(args ...string) ([]byte, error) {
+	return exec.Command("jj", append([]string{"--color=never"}, args...)...).Output()
+}
+
+// jjFilesets turns repo-relative paths into jj filesets that match exactly
+// those files from any working directory.
+func jjFilesets(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	sets := []string{"--"}
+	for _, p := range paths {
+		sets = append(sets, "root-file:"+strconv.Quote(p))
+	}
+	return sets
+}
+
+// Name implements VCS.
+func (Jujutsu) Name() string { return "jj" }
+
+// Root implements VCS.
+func (Jujutsu) Root() string {
+	out, err := jj("root")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Diff implements VCS.
+func (Jujutsu) Diff(paths ...string) (string, error) {
+	out, err := jj(append([]string{"diff", "--git"}, jjFilesets(paths)...)...)
+	if err != nil {
+		return "", fmt.Errorf("jj diff failed: %w; output=%s", err, stderr(err))
+	}
+	return string(out), nil
+}
+
+// HasChanges implements VCS.
+func (Jujutsu) HasChanges() bool {
+	out, err := jj("diff", "--summary")
+	return err == nil && strings.TrimSpace(string(out)) != ""
+}
+
+// Branch implements VCS: the first bookmark on the working-copy change or,
+// as after "jj new main", on its parent.
+func (Jujutsu) Branch() string {
+	for _, rev := range []string{"@", "@-"} {
+		out, err := jj("log", "--no-graph", "-r", rev, "-T", `local_bookmarks.map(|b| b.name()).join(" ") ++ "\n"`)
+		if err != nil {
+			return ""
+		}
+		if fields := strings.Fields(string(out)); len(fields) > 0 {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// Head implements VCS: the commit the working-copy change is based on,
+// which after "jj commit" is the change just committed.
+func (Jujutsu) Head() string {
+	out, err := jj("log", "--no-graph", "-r", "@-", "-T", `commit_id ++ "\n"`)
+	if err != nil {
+		return ""
+	}
+	first, _, _ := strings.Cut(string(out), "\n")
+	return nullNode(strings.TrimSpace(first))
+}
+
+// CommitArgs implements VCS. jj commit describes the working-copy change
+// and starts a new one on top of it.
+func (Jujutsu) CommitArgs(msg, msgPath string) []string {
+	return []string{"jj", "commit", "-m", msg}
+}
+
+// Comment implements VCS: jj drops the "JJ:" lines of its editor
+// template.
+func (Jujutsu) Comment() string { return "JJ:" }
+
+// Describe sets the description of the working-copy change to msg
+// without starting a new change, like "jj describe".
+func (Jujutsu) Describe(msg string) error {
+	out, err := exec.Command("jj", "describe", "-m", msg).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("jj describe failed: %w; output=%s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package vcs reads the changes to describe from, and commits them to, the
+// version control system of the working directory.
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/kylegalloway/commit-writer/pkg/gitdiff"
+)
+
+// VCS is a version control system. Diffs are git-style unified diffs
+// whatever the system, so the rest of commit-writer reads them alike.
+type VCS interface {
+	// Name is the system's command, e.g. "git" or "hg".
+	Name() string
+	// Root returns the top-level directory of the working copy, or ""
+	// outside one.
+	Root() string
+	// Diff returns the changes the next commit would record, limited to
+	// the given repo-relative paths.
+	Diff(paths ...string) (string, error)
+	// HasChanges reports whether Diff has anything to describe.
+	HasChanges() bool
+	// Branch returns the current branch or bookmark, or "" when there is
+	// none.
+	Branch() string
+	// Head returns the ID of the commit the changes apply to, or ""
+	// before the first commit.
+	Head() string
+	// CommitArgs returns the command line that commits the changes with
+	// msg, which is also in the file msgPath.
+	CommitArgs(msg, msgPath string) []string
+	// Comment is the prefix of the lines the system drops from commit
+	// messages, e.g. "#".
+	Comment() string
+}
+
+// New returns the system called name: "git", "hg", "jj" or "sl".
+func New(name string) (VCS, error) {
+	switch name {
+	case "git":
+		return Git{}, nil
+	case "hg", "mercurial":
+		return Mercurial{}, nil
+	case "jj", "jujutsu":
+		return Jujutsu{}, nil
+	case "sl", "sapling":
+		return Mercurial{Command: "sl"}, nil
+	}
+	return nil, fmt.Errorf("unknown version control system %q (want git, hg, jj or sl)", name)
+}
+
+// markers name the directory that marks each system's working copy. A jj
+// repository colocated with git has both .jj and .git; jj comes first so
+// it wins.
+var markers = []struct{ dir, name string }{
+	{".jj", "jj"},
+	{".git", "git"},
+	{".hg", "hg"},
+	{".sl", "sl"},
+}
+
+// Detect returns the name of the system whose working copy contains the
+// current directory, the innermost one when they nest, or "git" when none
+// does or GIT_DIR points git elsewhere.
+func Detect() string {
+	if os.Getenv("GIT_DIR") != "" {
+		return "git"
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "git"
+	}
+	return detectFrom(dir)
+}
+
+// detectFrom looks for the markers in dir and its parents.
+func detectFrom(dir string) string {
+	for {
+		for _, m := range markers {
+			if _, err := os.Stat(filepath.Join(dir, m.dir)); err == nil {
+				return m.name
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "git"
+		}
+		dir = parent
+	}
+}
+
+// Git is git. Diff returns the staged changes, or the unstaged ones when
+// nothing is staged.
+type Git struct{}
+
+// Name implements VCS.
+func (Git) Name() string { return "git" }
+
+// Root implements VCS.
+func (Git) Root() string { return gitdiff.RepoRoot() }
+
+// Diff implements VCS.
+func (Git) Diff(paths ...string) (string, error) { return gitdiff.Staged(paths...) }
+
+// HasChanges implements VCS.
+func (Git) HasChanges() bool { return gitdiff.HasChanges() }
+
+// Branch implements VCS.
+func (Git) Branch() string { return gitdiff.CurrentBranch() }
+
+// Head implements VCS.
+func (Git) Head() string { return gitdiff.Head() }
+
+// CommitArgs implements VCS.
+func (Git) CommitArgs(msg, msgPath string) []string { return []string{"git", "commit", "-F", msgPath} }
+
+// Comment implements VCS.
+func (Git) Comment() string { return "#" }
@@ -0,0 +1,71 @@
+package vcs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{".git", "lib/.hg", "lib/src", "other", "jj/.jj", "jj/.git", "sl/.sl"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct{ dir, want string }{
+		{"", "git"},
+		{"other", "git"},
+		{"lib", "hg"},
+		{"lib/src", "hg"},
+		{"jj", "jj"},
+		{"sl", "sl"},
+	}
+	for _, tt := range tests {
+		if got := detectFrom(filepath.Join(root, tt.dir)); got != tt.want {
+			t.Errorf("detectFrom(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for name, want := range map[string]string{"git": "git", "hg": "hg", "mercurial": "hg", "jj": "jj", "jujutsu": "jj", "sl": "sl", "sapling": "sl"} {
+		v, err := New(name)
+		if err != nil || v.Name() != want {
+			t.Errorf("New(%q) = %v, %v; want %s", name, v, err, want)
+		}
+	}
+	if _, err := New("svn"); err == nil {
+		t.Error("New(svn) succeeded")
+	}
+}
+
+func TestMercurialArgs(t *testing.T) {
+	if got, want := hgPatterns([]string{"a b.go", "docs/x.md"}), []string{"--", "path:a b.go", "path:docs/x.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hgPatterns = %q, want %q", got, want)
+	}
+	if got := hgPatterns(nil); got != nil {
+		t.Errorf("hgPatterns(nil) = %q, want nil", got)
+	}
+	if got := (Mercurial{Command: "sl"}).CommitArgs("msg", "m.txt"); !reflect.DeepEqual(got, []string{"sl", "commit", "-l", "m.txt"}) {
+		t.Errorf("Sapling CommitArgs = %q", got)
+	}
+	if got := (Mercurial{}).Comment() + (Mercurial{Command: "sl"}).Comment(); got != "HG:SL:" {
+		t.Errorf("comments = %q, want HG: and SL:", got)
+	}
+	if got := nullNode("0000000000000000000000000000000000000000"); got != "" {
+		t.Errorf("nullNode(null) = %q, want empty", got)
+	}
+	if got := nullNode("1f3c"); got != "1f3c" {
+		t.Errorf("nullNode(1f3c) = %q", got)
+	}
+}
+
+func TestJujutsuFilesets(t *testing.T) {
+	got := jjFilesets([]string{"main.go", `odd "name".txt`})
+	want := []string{"--", `root-file:"main.go"`, `root-file:"odd \"name\".txt"`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("jjFilesets = %q, want %q", got, want)
+	}
+}
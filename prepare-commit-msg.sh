@@ -1,8 +1,9 @@
-#!/bin/bash
+#!/bin/sh
 # Git hook: prepare-commit-msg
 # $1 = path to commit message file
+# "commit-writer install-hook" writes a hook like this one for you.
 
 TOOL=".git/hooks/commit-writer"
 TONE="increasingly insane Victorian author"
 
-$TOOL --hook "$1" --tone "$TONE" --force
\ No newline at end of file
+exec "$TOOL" --hook "$1" --tone "$TONE" --force